@@ -0,0 +1,163 @@
+// Command backtest replays file-based market data through the trading
+// signal logic and prints the performance report.
+//
+//	backtest -symbols DEMO-GRN,DEMO-TEC,DEMO-OIL -benchmark DEMO-IDX \
+//	    -start 2023-01-02 -end 2024-12-31 -cost-bps 5 -slippage-bps 2
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/edgeesg/edge-esg-backend/internal/backtest"
+	"github.com/edgeesg/edge-esg-backend/internal/marketdata"
+)
+
+func main() {
+	dataDir := flag.String("data", envOr("MARKET_DATA_DIR", "data/market"), "market data directory")
+	symbols := flag.String("symbols", "", "comma-separated symbols to trade (default: every symbol with inputs)")
+	benchmark := flag.String("benchmark", "", "benchmark symbol")
+	start := flag.String("start", "", "first day, YYYY-MM-DD")
+	end := flag.String("end", "", "last day, YYYY-MM-DD")
+	capital := flag.Float64("capital", 1_000_000, "initial capital")
+	costBps := flag.Float64("cost-bps", 5, "commission and fees per trade, in bps of notional")
+	slippageBps := flag.Float64("slippage-bps", 2, "slippage per fill, in bps")
+	riskFree := flag.Float64("risk-free", 0, "annual risk-free rate for Sharpe, e.g. 0.04")
+	asJSON := flag.Bool("json", false, "print the full result as JSON")
+	showTrades := flag.Bool("trades", false, "list every trade")
+	flag.Parse()
+
+	store := marketdata.NewFileStore(*dataDir)
+	cfg := backtest.Config{
+		Benchmark:      *benchmark,
+		InitialCapital: *capital,
+		CostBps:        *costBps,
+		SlippageBps:    *slippageBps,
+		RiskFreeRate:   *riskFree,
+	}
+
+	if *symbols != "" {
+		cfg.Symbols = strings.Split(*symbols, ",")
+	} else {
+		all, err := store.Symbols()
+		if err != nil {
+			fail(err)
+		}
+		for _, symbol := range all {
+			if _, err := store.Inputs(symbol); err == nil {
+				cfg.Symbols = append(cfg.Symbols, symbol)
+			}
+		}
+	}
+
+	var err error
+	if cfg.Start, cfg.End, err = dateRange(store, cfg, *start, *end); err != nil {
+		fail(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	result, err := backtest.NewEngine(store).Run(ctx, cfg)
+	if err != nil {
+		fail(err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			fail(err)
+		}
+		return
+	}
+	printReport(result, *showTrades)
+}
+
+// dateRange parses the requested dates, defaulting to the span of the first
+// symbol's prices.
+func dateRange(store *marketdata.FileStore, cfg backtest.Config, start, end string) (time.Time, time.Time, error) {
+	var from, to time.Time
+	if len(cfg.Symbols) > 0 && (start == "" || end == "") {
+		bars, err := store.Bars(cfg.Symbols[0])
+		if err != nil {
+			return from, to, err
+		}
+		from, to = bars[0].Date, bars[len(bars)-1].Date
+	}
+	var err error
+	if start != "" {
+		if from, err = time.Parse(marketdata.DateLayout, start); err != nil {
+			return from, to, fmt.Errorf("invalid -start: %w", err)
+		}
+	}
+	if end != "" {
+		if to, err = time.Parse(marketdata.DateLayout, end); err != nil {
+			return from, to, fmt.Errorf("invalid -end: %w", err)
+		}
+	}
+	return from, to, nil
+}
+
+func printReport(r *backtest.Result, showTrades bool) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Symbols\t%s\n", strings.Join(r.Config.Symbols, ", "))
+	fmt.Fprintf(w, "Period\t%s to %s (%d trading days)\n",
+		r.Config.Start.Format(marketdata.DateLayout), r.Config.End.Format(marketdata.DateLayout), r.TradingDays)
+	fmt.Fprintf(w, "Costs\t%.1f bps + %.1f bps slippage\n\n", r.Config.CostBps, r.Config.SlippageBps)
+
+	fmt.Fprintln(w, "\tStrategy\tBenchmark")
+	row := func(name string, value func(backtest.Performance) float64, format string) {
+		bench := "-"
+		if r.Benchmark != nil {
+			bench = fmt.Sprintf(format, value(*r.Benchmark))
+		}
+		fmt.Fprintf(w, "%s\t"+format+"\t%s\n", name, value(r.Strategy), bench)
+	}
+	pct := func(v float64) float64 { return v * 100 }
+	row("Total return %", func(p backtest.Performance) float64 { return pct(p.TotalReturn) }, "%.2f")
+	row("Annualised return %", func(p backtest.Performance) float64 { return pct(p.AnnualizedReturn) }, "%.2f")
+	row("Volatility %", func(p backtest.Performance) float64 { return pct(p.Volatility) }, "%.2f")
+	row("Sharpe", func(p backtest.Performance) float64 { return p.Sharpe }, "%.2f")
+	row("Max drawdown %", func(p backtest.Performance) float64 { return pct(p.MaxDrawdown) }, "%.2f")
+
+	fmt.Fprintf(w, "\nFinal equity\t%.2f\n", r.FinalEquity)
+	if r.Benchmark != nil {
+		fmt.Fprintf(w, "Active return %%\t%.2f\n", r.ActiveReturn*100)
+	}
+	fmt.Fprintf(w, "Hit rate %%\t%.1f (%d closed, %d open)\n", r.HitRate*100, r.ClosedTrades, r.OpenPositions)
+	fmt.Fprintf(w, "Turnover\t%.2fx a year\n", r.Turnover)
+	fmt.Fprintf(w, "Total costs\t%.2f\n", r.TotalCosts)
+	fmt.Fprintf(w, "Signals\tBUY %d, SELL %d, HOLD %d\n", r.Signals["BUY"], r.Signals["SELL"], r.Signals["HOLD"])
+
+	if showTrades {
+		fmt.Fprintln(w, "\nDate\tSymbol\tSide\tQuantity\tPrice\tCost\tP&L")
+		for _, t := range r.Trades {
+			pnl := "-"
+			if t.Side == "SELL" {
+				pnl = fmt.Sprintf("%.2f", t.PnL)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%.4f\t%.2f\t%.2f\t%s\n",
+				t.Date.Format(marketdata.DateLayout), t.Symbol, t.Side, t.Quantity, t.Price, t.Cost, pnl)
+		}
+	}
+	_ = w.Flush()
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "backtest:", err)
+	os.Exit(1)
+}
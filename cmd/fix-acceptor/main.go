@@ -0,0 +1,185 @@
+// Command fix-acceptor is a local stand-in for an OMS, for testing FIX
+// export. It accepts one FIX 4.4 session, acknowledges every
+// NewOrderSingle and then fills it at the latest close in the market data,
+// rejects it, or leaves it working, depending on -mode.
+//
+//	fix-acceptor -listen :9878 -sender OMS -target EDGE -mode fill
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/edgeesg/edge-esg-backend/internal/fix"
+	"github.com/edgeesg/edge-esg-backend/internal/marketdata"
+)
+
+func main() {
+	listen := flag.String("listen", ":9878", "address to accept the session on")
+	sender := flag.String("sender", "OMS", "our SenderCompID (the gateway's FIX_TARGET_COMP_ID)")
+	target := flag.String("target", "EDGE", "the gateway's SenderCompID")
+	mode := flag.String("mode", "fill", "what to do with orders: fill, reject or ack")
+	dataDir := flag.String("data", envOr("MARKET_DATA_DIR", "data/market"), "market data directory for fill prices")
+	storeDir := flag.String("store", "", "directory to keep sequence numbers in (default: memory)")
+	heartbeat := flag.Duration("heartbeat", 30*time.Second, "heartbeat interval")
+	flag.Parse()
+
+	if *mode != "fill" && *mode != "reject" && *mode != "ack" {
+		fail(fmt.Errorf("-mode must be fill, reject or ack"))
+	}
+
+	var store fix.Store = fix.NewMemoryStore()
+	if *storeDir != "" {
+		fileStore, err := fix.NewFileStore(*storeDir, *sender, *target)
+		if err != nil {
+			fail(err)
+		}
+		defer fileStore.Close()
+		store = fileStore
+	}
+
+	oms := &oms{mode: *mode, prices: marketdata.NewFileStore(*dataDir)}
+	oms.session = fix.NewSession(fix.SessionConfig{
+		SenderCompID: *sender,
+		TargetCompID: *target,
+		HeartBtInt:   *heartbeat,
+	}, store, oms)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fail(err)
+	}
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+	fmt.Printf("accepting %s -> %s on %s, mode %s\n", *target, *sender, listener.Addr(), *mode)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			fail(err)
+		}
+		fmt.Printf("connection from %s\n", conn.RemoteAddr())
+		go func() {
+			err := oms.session.Serve(ctx, conn)
+			if errors.Is(err, fix.ErrAlreadyConnected) {
+				fmt.Printf("refused %s: session already connected\n", conn.RemoteAddr())
+				return
+			}
+			fmt.Printf("session ended: %v\n", err)
+		}()
+	}
+}
+
+// oms answers orders with execution reports.
+type oms struct {
+	mode    string
+	prices  marketdata.Source
+	session *fix.Session
+	ids     atomic.Int64
+}
+
+func (o *oms) OnLogon()  { fmt.Println("logged on") }
+func (o *oms) OnLogout() { fmt.Println("logged out") }
+
+func (o *oms) FromApp(msg *fix.Message) {
+	if msg.MsgType() != fix.MsgNewOrderSingle {
+		fmt.Printf("ignoring %s\n", msg)
+		return
+	}
+	fmt.Printf("order %s\n", msg)
+
+	quantity, err := msg.Float(fix.TagOrderQty)
+	if err != nil || quantity <= 0 {
+		o.report(msg, "8", "8", 0, 0, "invalid OrderQty")
+		return
+	}
+	o.report(msg, "0", "0", 0, 0, "")
+
+	switch o.mode {
+	case "reject":
+		o.report(msg, "8", "8", 0, 0, "rejected by fix-acceptor")
+	case "fill":
+		price, err := o.price(msg.Value(fix.TagSymbol))
+		if err != nil {
+			o.report(msg, "8", "8", 0, 0, "no price: "+err.Error())
+			return
+		}
+		o.report(msg, "F", "2", quantity, price, "")
+	}
+}
+
+func (o *oms) price(symbol string) (float64, error) {
+	bars, err := o.prices.Bars(symbol)
+	if err != nil {
+		return 0, err
+	}
+	bar, ok := marketdata.BarAsOf(bars, time.Now())
+	if !ok {
+		return 0, marketdata.ErrNoData
+	}
+	return bar.Close, nil
+}
+
+// report sends an ExecutionReport for order. filled and price describe a
+// single fill of the whole order, or nothing when filled is 0.
+func (o *oms) report(order *fix.Message, execType, ordStatus string, filled, price float64, text string) {
+	quantity, _ := order.Float(fix.TagOrderQty)
+	leaves := quantity - filled
+	if ordStatus == "8" {
+		leaves = 0
+	}
+	report := fix.NewMessage(fix.MsgExecutionReport).
+		Set(fix.TagOrderID, "OMS-"+order.Value(fix.TagClOrdID)).
+		Set(fix.TagExecID, "EX-"+strconv.FormatInt(o.ids.Add(1), 10)).
+		Set(fix.TagClOrdID, order.Value(fix.TagClOrdID)).
+		Set(fix.TagAccount, order.Value(fix.TagAccount)).
+		Set(fix.TagExecType, execType).
+		Set(fix.TagOrdStatus, ordStatus).
+		Set(fix.TagSymbol, order.Value(fix.TagSymbol)).
+		Set(fix.TagSide, order.Value(fix.TagSide)).
+		SetFloat(fix.TagOrderQty, quantity).
+		SetFloat(fix.TagLeavesQty, leaves).
+		SetFloat(fix.TagCumQty, filled).
+		SetFloat(fix.TagAvgPx, price).
+		SetTime(fix.TagTransactTime, time.Now())
+	if filled > 0 {
+		report.SetFloat(fix.TagLastQty, filled).SetFloat(fix.TagLastPx, price)
+	}
+	if text != "" {
+		report.Set(fix.TagText, text)
+	}
+	if err := o.session.Send(report); err != nil {
+		fmt.Printf("execution report not sent: %v\n", err)
+		return
+	}
+	fmt.Printf("report  %s\n", report)
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "fix-acceptor:", err)
+	os.Exit(1)
+}
@@ -1,13 +1,26 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/edgeesg/edge-esg-backend/internal/backtest"
 	"github.com/edgeesg/edge-esg-backend/internal/config"
 	"github.com/edgeesg/edge-esg-backend/internal/handlers"
 	"github.com/edgeesg/edge-esg-backend/internal/loggers"
+	"github.com/edgeesg/edge-esg-backend/internal/marketdata"
+	"github.com/edgeesg/edge-esg-backend/internal/masking"
+	"github.com/edgeesg/edge-esg-backend/internal/metrics"
 	"github.com/edgeesg/edge-esg-backend/internal/middleware"
+	"github.com/edgeesg/edge-esg-backend/internal/repository"
 	"github.com/edgeesg/edge-esg-backend/internal/services"
+	"github.com/edgeesg/edge-esg-backend/internal/types"
 	"github.com/edgeesg/edge-esg-backend/pkg/database"
 	"github.com/gin-gonic/gin"
 )
@@ -30,33 +43,254 @@ func main() {
 		panic(fmt.Sprintf("Failed to connect to Redis: %v", err))
 	}
 
+	maskingPolicy, err := masking.LoadPolicy(cfg.MaskingPolicyFile)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to load masking policy: %v", err))
+	}
+
+	// Upstream API quotas are shared by every bank
+	quotaManager := services.NewQuotaManager(redisClient, []*services.ProviderQuota{
+		{
+			Name:            services.ProviderNewsAPI,
+			Keys:            cfg.NewsAPIKeys,
+			DailyLimit:      cfg.NewsAPIDailyLimit,
+			PerMinute:       cfg.NewsAPIPerMinute,
+			PriorityReserve: cfg.UpstreamPriorityReserve,
+			CacheThreshold:  cfg.UpstreamCacheThreshold,
+		},
+		{
+			Name:            services.ProviderAlphaVantage,
+			Keys:            cfg.AlphaVantageKeys,
+			DailyLimit:      cfg.AlphaVantageDailyLimit,
+			PerMinute:       cfg.AlphaVantagePerMinute,
+			PriorityReserve: cfg.UpstreamPriorityReserve,
+			CacheThreshold:  cfg.UpstreamCacheThreshold,
+		},
+	}, cfg.PriorityBankIDs, cfg.UpstreamCacheTTL)
+
 	// Initialize services
-	orchestrator := services.NewOrchestrator()
-	analyzeHandler := handlers.NewAnalyzeHandler(orchestrator)
-	portfolioHandler := handlers.NewPortfolioHandler(orchestrator)
-	wsHub := handlers.NewWSHub()
+	orchestrator := services.NewOrchestrator(quotaManager)
+	wsHub := handlers.NewWSHub(maskingPolicy, handlers.SlowClientPolicy(cfg.WSSlowClientPolicy), cfg.WSSendBuffer, cfg.SSEReplayBuffer)
+	wsHub.EnableRedisFanout(redisClient, cfg.WSFanoutChannel)
+	analyzeHandler := handlers.NewAnalyzeHandler(orchestrator, wsHub)
+	portfolioHandler := handlers.NewPortfolioHandler(orchestrator, wsHub)
+
+	// Asynchronous jobs queue in Redis and run on a bounded worker pool
+	jobService := services.NewJobService(redisClient, services.JobConfig{
+		Workers:   cfg.JobWorkers,
+		Timeout:   cfg.JobTimeout,
+		Retention: cfg.JobRetention,
+	})
+	jobService.Register(types.JobAnalyze, orchestrator.RunAnalyzeJob, 0)
+	batchAnalyzer := services.NewBatchAnalyzer(orchestrator, jobService, cfg.BatchConcurrency)
+	jobService.Register(types.JobBatchAnalyze, batchAnalyzer.Run, cfg.BatchTimeout)
+	jobService.OnPipelineEvent(wsHub.PublishJobEvent)
+	jobsHandler := handlers.NewJobsHandler(jobService, wsHub)
+
+	apiKeyService, err := services.NewAPIKeyService(repository.NewAPIKeyRepository(db), cfg.EncryptionKey)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize API key service: %v", err))
+	}
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	quotaHandler := handlers.NewQuotaHandler(quotaManager)
+	marketData := marketdata.NewFileStore(cfg.MarketDataDir)
+	orchestrator.UseMarketData(marketData)
+	orchestrator.UseBenchmarks(marketData)
+	benchmarksHandler := handlers.NewBenchmarksHandler(marketData)
+	climateStress := services.NewClimateStressService(marketData, marketData)
+	climateHandler := handlers.NewClimateHandler(climateStress)
+	backtestHandler := handlers.NewBacktestHandler(backtest.NewEngine(marketData))
+
+	// Trading signals from analyses are stored for risk/trader approval
+	signalService := services.NewSignalService(repository.NewSignalRepository(db), cfg.SignalHorizon)
+	orchestrator.RecordSignals(signalService)
+	signalsHandler := handlers.NewSignalsHandler(signalService)
+
+	// Paper portfolios trade approved signals against market data quotes
+	paperTrading := services.NewPaperTradingService(repository.NewPaperRepository(db), signalService, marketData)
+	signalService.OnApproved(paperTrading.FollowSignal)
+	paperHandler := handlers.NewPaperTradingHandler(paperTrading)
+
+	// Stored portfolios alert on drift over the WebSocket and SSE streams
+	portfolioService := services.NewPortfolioService(repository.NewPortfolioRepository(db), marketData, marketData)
+	portfolioService.OnDrift(wsHub.PublishPortfolioDrift)
+	portfoliosHandler := handlers.NewPortfoliosHandler(portfolioService)
+	financedEmissions, err := services.NewFinancedEmissionsService(repository.NewPortfolioRepository(db), portfolioService, marketData, cfg.EncryptionKey)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize financed emissions service: %v", err))
+	}
+	financedEmissionsHandler := handlers.NewFinancedEmissionsHandler(financedEmissions)
+
+	// Asset locations score physical climate risk, in analyses too
+	physicalRisk := services.NewPhysicalRiskService(repository.NewLocationRepository(db), financedEmissions, marketData)
+	orchestrator.UsePhysicalRisk(physicalRisk)
+	physicalRiskHandler := handlers.NewPhysicalRiskHandler(physicalRisk)
+
+	// Credit overlays adjust ratings for ESG and climate risk, recording each
+	creditOverlays := services.NewCreditOverlayService(repository.NewCreditRepository(db), physicalRisk)
+	if cfg.CreditStressScenario != "" {
+		if err := creditOverlays.UseClimateStress(climateStress, cfg.CreditStressScenario, cfg.CreditStressYear); err != nil {
+			panic(fmt.Sprintf("Failed to configure the credit overlay climate stress: %v", err))
+		}
+	}
+	orchestrator.UseCreditOverlays(creditOverlays)
+	creditHandler := handlers.NewCreditHandler(creditOverlays)
 
-	// Start WebSocket hub
+	// Keycloak guards the admin endpoints; without a client ID they fail closed.
+	var keycloak *middleware.KeycloakMiddleware
+	adminAuth := []gin.HandlerFunc{middleware.AuthUnavailable()}
+	if cfg.KeycloakClientID != "" {
+		keycloak, err = middleware.NewKeycloakMiddleware(cfg.KeycloakURL+"/realms/"+cfg.KeycloakRealm, cfg.KeycloakClientID)
+		if err != nil {
+			keycloak = nil
+			loggers.Warn("Keycloak unavailable, admin endpoints disabled", map[string]interface{}{
+				"error": err.Error(),
+			})
+		} else {
+			adminAuth = []gin.HandlerFunc{keycloak.Authenticate(), keycloak.RequireRole(string(types.RoleAdmin))}
+		}
+	}
+
+	// Start WebSocket hub and job workers
 	go wsHub.Run()
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	jobService.Start(jobsCtx)
+	signalService.StartExpiry(jobsCtx, cfg.SignalExpiryInterval)
+	paperTrading.StartSnapshots(jobsCtx, cfg.PaperSnapshotInterval)
+	portfolioService.StartDriftChecks(jobsCtx, cfg.PortfolioDriftInterval)
+	fixExporter, err := services.StartFIXExport(jobsCtx, signalService, repository.NewFIXRepository(db), services.FIXExportConfig{
+		Mode:          cfg.FIXMode,
+		Address:       cfg.FIXAddress,
+		SenderCompID:  cfg.FIXSenderCompID,
+		TargetCompID:  cfg.FIXTargetCompID,
+		HeartBtInt:    cfg.FIXHeartBtInt,
+		ResetOnLogon:  cfg.FIXResetOnLogon,
+		DryRunFile:    cfg.FIXDryRunFile,
+		OrderNotional: cfg.FIXOrderNotional,
+		PollInterval:  cfg.FIXPollInterval,
+	})
+	if err != nil {
+		panic(fmt.Sprintf("Failed to start FIX export: %v", err))
+	}
 
 	// Setup Gin router
 	r := gin.Default()
+	// Anonymous callers are limited per client IP, which must not come from
+	// a header anyone can set
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		panic(fmt.Sprintf("Invalid TRUSTED_PROXIES: %v", err))
+	}
 	r.Use(middleware.CORS())
-	r.Use(middleware.DataMasking())
+	r.Use(middleware.APIKeyAuth(apiKeyService, handlers.MaxBatchUploadBytes))
+	r.Use(middleware.DataMasking(maskingPolicy))
 
 	// Rate limiter
-	rateLimiter := middleware.NewRateLimiter(redisClient, 10000, 60)
-	r.Use(rateLimiter.Limit())
+	rateLimitConfig, err := middleware.LoadRateLimitConfig(cfg.RateLimitConfigFile)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to load rate limit config: %v", err))
+	}
+	rateLimiter := middleware.NewRateLimiter(redisClient, rateLimitConfig)
+	companyCount := middleware.CompanyCountCost(handlers.MaxBatchUploadBytes)
+	rateLimiter.SetCost("POST", "/api/v1/portfolio/compare", companyCount)
+	rateLimiter.SetCost("POST", "/api/v1/portfolio/frontier", companyCount)
+	rateLimiter.SetCost("POST", "/api/v1/analyze/batch", companyCount)
+	// Routes behind BankAuth are limited after it, so users signed in with a
+	// bearer token count against their bank rather than the anonymous plan
+	limit := rateLimiter.Limit()
+	bankAuth := func(chain ...gin.HandlerFunc) []gin.HandlerFunc {
+		return append([]gin.HandlerFunc{middleware.BankAuth(apiKeyService, keycloak), limit}, chain...)
+	}
 
 	// Public routes
-	r.GET("/health", handlers.HealthCheck)
-	r.GET("/ws", wsHub.HandleWebSocket)
+	r.GET("/health", limit, handlers.HealthCheck)
+	r.GET("/ws", bankAuth(wsHub.HandleWebSocket)...)
+	r.GET("/events", bankAuth(wsHub.HandleSSE)...)
+	r.GET("/metrics", limit, metrics.Handler)
 
-	// Protected routes (Keycloak auth disabled for demo)
-	api := r.Group("/api/v1")
+	// Bank routes (Keycloak auth disabled for demo; banks identify via API key)
+	api := r.Group("/api/v1", limit)
+	if cfg.APIKeyRequired {
+		api.Use(middleware.RequireAPIKey())
+	}
+	{
+		api.POST("/analyze", middleware.RequireScope(string(types.ScopeAnalyze)), analyzeHandler.Analyze)
+		api.POST("/portfolio/compare", middleware.RequireScope(string(types.ScopePortfolio)), portfolioHandler.ComparePortfolio)
+		api.POST("/portfolio/frontier", middleware.RequireScope(string(types.ScopePortfolio)), portfolioHandler.Frontier)
+		api.POST("/analyze/jobs", middleware.RequireScope(string(types.ScopeAnalyze)), jobsHandler.SubmitAnalyze)
+		api.POST("/analyze/batch", middleware.RequestSizeLimit(handlers.MaxBatchUploadBytes), middleware.RequireScope(string(types.ScopeAnalyze)), jobsHandler.SubmitBatch)
+		api.GET("/jobs/:job_id", jobsHandler.Get)
+		api.GET("/jobs/:job_id/items", jobsHandler.Items)
+		api.GET("/jobs/:job_id/download", jobsHandler.Download)
+		api.DELETE("/jobs/:job_id", jobsHandler.Cancel)
+		api.POST("/backtests", middleware.RequireScope(string(types.ScopeAnalyze)), backtestHandler.Run)
+		api.GET("/benchmarks", middleware.RequireScope(string(types.ScopePortfolio)), benchmarksHandler.List)
+		api.GET("/benchmarks/:name", middleware.RequireScope(string(types.ScopePortfolio)), benchmarksHandler.Get)
+		api.GET("/climate/scenarios", middleware.RequireScope(string(types.ScopePortfolio)), climateHandler.Scenarios)
+		api.GET("/climate/scenarios/:name", middleware.RequireScope(string(types.ScopePortfolio)), climateHandler.Scenario)
+		api.POST("/climate/stress-test", middleware.RequireScope(string(types.ScopePortfolio)), climateHandler.StressTest)
+	}
+
+	// Signal decisions need a signed-in user; API keys may only read
+	signals := r.Group("/api/v1/signals", bankAuth()...)
+	{
+		signals.GET("", signalsHandler.List)
+		signals.GET("/:signal_id", signalsHandler.Get)
+		signals.POST("/:signal_id/approve", signalsHandler.Approve)
+		signals.POST("/:signal_id/reject", signalsHandler.Reject)
+		signals.POST("/:signal_id/cancel", signalsHandler.Cancel)
+	}
+
+	paper := r.Group("/api/v1/paper/portfolios", bankAuth()...)
+	{
+		paper.POST("", paperHandler.Create)
+		paper.GET("", paperHandler.List)
+		paper.GET("/:portfolio_id", paperHandler.Get)
+		paper.PATCH("/:portfolio_id", paperHandler.Update)
+		paper.DELETE("/:portfolio_id", paperHandler.Delete)
+		paper.GET("/:portfolio_id/orders", paperHandler.Orders)
+		paper.POST("/:portfolio_id/orders", paperHandler.PlaceOrder)
+		paper.GET("/:portfolio_id/performance", paperHandler.Performance)
+	}
+
+	portfolios := r.Group("/api/v1/portfolios", bankAuth(middleware.RequireScope(string(types.ScopePortfolio)))...)
+	{
+		portfolios.POST("", portfoliosHandler.Create)
+		portfolios.GET("", portfoliosHandler.List)
+		portfolios.GET("/:portfolio_id", portfoliosHandler.Get)
+		portfolios.PUT("/:portfolio_id", portfoliosHandler.Replace)
+		portfolios.DELETE("/:portfolio_id", portfoliosHandler.Delete)
+		portfolios.GET("/:portfolio_id/drift", portfoliosHandler.Drift)
+		portfolios.GET("/:portfolio_id/analytics", portfoliosHandler.Analytics)
+		portfolios.GET("/:portfolio_id/exposures", financedEmissionsHandler.Exposures)
+		portfolios.PUT("/:portfolio_id/exposures", financedEmissionsHandler.ReplaceExposures)
+		portfolios.GET("/:portfolio_id/financed-emissions", financedEmissionsHandler.Report)
+		portfolios.GET("/:portfolio_id/physical-risk", physicalRiskHandler.PortfolioRisk)
+		portfolios.POST("/:portfolio_id/rebalance", portfoliosHandler.Rebalance)
+	}
+
+	companies := r.Group("/api/v1/companies", bankAuth(middleware.RequireScope(string(types.ScopePortfolio)))...)
 	{
-		api.POST("/analyze", analyzeHandler.Analyze)
-		api.POST("/portfolio/compare", portfolioHandler.ComparePortfolio)
+		companies.GET("/:company/locations", physicalRiskHandler.Locations)
+		companies.PUT("/:company/locations", physicalRiskHandler.ReplaceLocations)
+		companies.GET("/:company/physical-risk", physicalRiskHandler.CompanyRisk)
+	}
+
+	creditRoutes := r.Group("/api/v1/credit", bankAuth(middleware.RequireScope(string(types.ScopeAnalyze)))...)
+	{
+		creditRoutes.GET("/policy", creditHandler.Policy)
+		creditRoutes.PUT("/policy", creditHandler.ReplacePolicy)
+		creditRoutes.POST("/adjustments", creditHandler.Adjust)
+		creditRoutes.GET("/adjustments", creditHandler.History)
+	}
+
+	// Admin routes
+	admin := r.Group("/api/v1/admin", append([]gin.HandlerFunc{limit}, adminAuth...)...)
+	{
+		admin.POST("/banks/:bank_id/api-keys", apiKeyHandler.Issue)
+		admin.GET("/banks/:bank_id/api-keys", apiKeyHandler.List)
+		admin.POST("/api-keys/:key_id/rotate", apiKeyHandler.Rotate)
+		admin.DELETE("/api-keys/:key_id", apiKeyHandler.Revoke)
+		admin.GET("/quotas", quotaHandler.Usage)
 	}
 
 	loggers.Info("Gateway server starting", map[string]interface{}{
@@ -64,7 +298,37 @@ func main() {
 		"db":   db != nil,
 	})
 
-	if err := r.Run(":" + cfg.ServerPort); err != nil {
-		panic(fmt.Sprintf("Failed to start server: %v", err))
+	srv := &http.Server{
+		Addr:              ":" + cfg.ServerPort,
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			panic(fmt.Sprintf("Failed to start server: %v", err))
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	loggers.Info("Gateway shutting down", map[string]interface{}{
+		"timeout": cfg.ShutdownTimeout.String(),
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	// Close event streams first (WebSockets get a going-away frame), since
+	// open SSE responses would hold up the HTTP shutdown. Then stop taking
+	// requests and hand running jobs back to the queue for another replica.
+	if err := wsHub.Shutdown(ctx); err != nil {
+		loggers.Error("WebSocket shutdown incomplete", err, nil)
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		loggers.Error("HTTP shutdown incomplete", err, nil)
 	}
+	stopJobs()
+	jobService.Wait()
+	fixExporter.Wait()
 }
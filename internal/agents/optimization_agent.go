@@ -2,96 +2,699 @@ package agents
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/edgeesg/edge-esg-backend/internal/marketdata"
+	"github.com/edgeesg/edge-esg-backend/internal/quant"
 )
 
+const (
+	// Daily returns used to estimate covariance, and the fewest accepted
+	historyDays    = 252
+	minHistoryDays = 60
+
+	// Used for every asset when there is no usable price history
+	assumedVolatility  = 0.25
+	assumedCorrelation = 0.3
+
+	CovarianceHistorical = "historical"
+	CovarianceAssumed    = "assumed"
+
+	// Stands for cash among current weights; it is not a valid symbol
+	cashKey = "(cash)"
+)
+
+// ErrInvalidConstraints means the constraints are malformed or need
+// attributes the request does not have; infeasible ones are reported as
+// quant.ErrInfeasible.
+var ErrInvalidConstraints = errors.New("invalid portfolio constraints")
+
 type OptimizationAgent struct {
+	prices marketdata.Source
 }
 
 func NewOptimizationAgent() *OptimizationAgent {
 	return &OptimizationAgent{}
 }
 
+// UsePriceHistory makes the agent estimate covariance from prices. Without
+// it every portfolio uses the assumed volatility and correlation.
+func (o *OptimizationAgent) UsePriceHistory(prices marketdata.Source) {
+	o.prices = prices
+}
+
+// PortfolioRequest describes the assets to allocate between. Expected
+// returns are annual, in percent. Symbols, when given, are looked up in
+// the price history. RiskAversion and ESGPreference default from
+// RiskTolerance when zero; see quant.Problem for their meaning.
+// Attributes and Constraints are keyed by company name, case-insensitively;
+// names that are not in Companies are ignored.
 type PortfolioRequest struct {
 	Companies       []string
+	Symbols         []string
 	ESGScores       []float64
 	ExpectedReturns []float64
 	RiskTolerance   float64 // 0-1
+	RiskAversion    float64
+	ESGPreference   float64
+	RiskFreeRate    float64 // annual, fraction
+	Attributes      map[string]AssetAttributes
+	Constraints     *PortfolioConstraints
 }
 
+type AssetAttributes struct {
+	Sector          string
+	Country         string
+	CarbonIntensity *float64
+}
+
+// PortfolioConstraints are mandate limits; see dtos.PortfolioConstraints.
+// A zero MaxWeight means no cap.
+type PortfolioConstraints struct {
+	MinWeight          float64
+	MaxWeight          float64
+	WeightBounds       map[string]WeightBounds
+	SectorCaps         map[string]float64
+	CountryCaps        map[string]float64
+	MinESGScore        float64
+	MaxCarbonIntensity *float64
+	Exclude            []string
+	CurrentWeights     map[string]float64
+	MaxTurnover        *float64
+}
+
+type WeightBounds struct {
+	Min *float64
+	Max *float64
+}
+
+// PortfolioResponse reports returns and risk as annual percentages.
+// MarginalRisk and RiskContributions follow the order of Companies.
 type PortfolioResponse struct {
-	OptimalWeights []float64
+	OptimalWeights    []float64
+	ExpectedReturn    float64
+	PortfolioRisk     float64
+	Sharpe            float64
+	ESGScore          float64
+	MarginalRisk      []float64
+	RiskContributions []float64
+	CovarianceSource  string
+	Shrinkage         float64
+	Observations      int
+	// BindingConstraints names the constraints that limited the result
+	BindingConstraints []string
+}
+
+// OptimizePortfolio solves a long-only mean-variance problem with an ESG
+// tilt over the request's assets, using a shrunk covariance matrix from
+// their price history where there is enough of it.
+func (o *OptimizationAgent) OptimizePortfolio(ctx context.Context, req *PortfolioRequest) (*PortfolioResponse, error) {
+	if len(req.Companies) == 0 {
+		return &PortfolioResponse{}, nil
+	}
+	problem, estimate, err := o.problem(req)
+	if err != nil {
+		return nil, err
+	}
+	result, err := quant.MeanVariance(problem)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PortfolioResponse{
+		OptimalWeights:     result.Weights,
+		ExpectedReturn:     result.ExpectedReturn * 100,
+		PortfolioRisk:      result.Volatility * 100,
+		Sharpe:             result.Sharpe,
+		ESGScore:           result.ESGScore,
+		MarginalRisk:       result.MarginalRisk,
+		RiskContributions:  result.RiskContributions,
+		CovarianceSource:   estimate.source,
+		Shrinkage:          estimate.shrinkage,
+		Observations:       estimate.observations,
+		BindingConstraints: result.Binding,
+	}, nil
+}
+
+// FrontierPoint is a portfolio on the efficient frontier, with returns and
+// risk as annual percentages like PortfolioResponse.
+type FrontierPoint struct {
+	Weights        []float64
 	ExpectedReturn float64
 	PortfolioRisk  float64
+	Sharpe         float64
 	ESGScore       float64
 }
 
-// OptimizePortfolio performs portfolio optimization with ESG constraints
-func (o *OptimizationAgent) OptimizePortfolio(ctx context.Context, req *PortfolioRequest) (*PortfolioResponse, error) {
+// FrontierCurve is the frontier among portfolios scoring at least
+// MinESGScore.
+type FrontierCurve struct {
+	MinESGScore float64
+	Points      []FrontierPoint
+}
+
+type FrontierResponse struct {
+	Points []FrontierPoint
+	// MaxSharpeIndex is the point with the best risk-adjusted return
+	MaxSharpeIndex   int
+	Surface          []FrontierCurve
+	CovarianceSource string
+	Shrinkage        float64
+	Observations     int
+}
+
+// EfficientFrontier traces points portfolios along the request's efficient
+// frontier under its constraints, and with esgLevels above 1 the frontiers
+// under rising ESG floors as well. RiskTolerance, RiskAversion and
+// ESGPreference do not apply.
+func (o *OptimizationAgent) EfficientFrontier(ctx context.Context, req *PortfolioRequest, points, esgLevels int) (*FrontierResponse, error) {
+	if len(req.Companies) == 0 {
+		return &FrontierResponse{}, nil
+	}
+	problem, estimate, err := o.problem(req)
+	if err != nil {
+		return nil, err
+	}
+	response := &FrontierResponse{
+		CovarianceSource: estimate.source,
+		Shrinkage:        estimate.shrinkage,
+		Observations:     estimate.observations,
+	}
+
+	var curves []quant.FrontierCurve
+	if esgLevels > 1 {
+		curves, err = quant.ESGSurface(problem, points, esgLevels)
+	} else {
+		var frontier []quant.FrontierPoint
+		frontier, err = quant.Frontier(problem, points)
+		curves = []quant.FrontierCurve{{Points: frontier}}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	response.Points = frontierPoints(curves[0].Points)
+	response.MaxSharpeIndex = quant.MaxSharpe(curves[0].Points)
+	if esgLevels > 1 {
+		for _, curve := range curves {
+			response.Surface = append(response.Surface, FrontierCurve{
+				MinESGScore: curve.MinESGScore,
+				Points:      frontierPoints(curve.Points),
+			})
+		}
+	}
+	return response, nil
+}
+
+// RebalanceRequest asks for the portfolio nearest TargetWeights that meets
+// the mandate, starting from CurrentWeights, which may add up to less than
+// 1 when part of the portfolio is cash. A turnover limit in Constraints is
+// measured from CurrentWeights. Expected returns are optional and only
+// reported on.
+type RebalanceRequest struct {
+	PortfolioRequest
+	CurrentWeights []float64
+	TargetWeights  []float64
+}
+
+// RebalanceResponse reports risk and tracking errors, against the
+// targets, as annual percentages.
+type RebalanceResponse struct {
+	Weights              []float64
+	ExpectedReturn       float64
+	PortfolioRisk        float64
+	ESGScore             float64
+	TrackingError        float64
+	CurrentTrackingError float64
+	CovarianceSource     string
+	BindingConstraints   []string
+}
+
+// Rebalance finds the weights with the least tracking error to the
+// request's targets that the mandate allows. Targets that already meet
+// every constraint come back unchanged; a turnover limit stops short of
+// them when reaching them would trade too much.
+func (o *OptimizationAgent) Rebalance(ctx context.Context, req *RebalanceRequest) (*RebalanceResponse, error) {
 	n := len(req.Companies)
+	if len(req.TargetWeights) != n || len(req.CurrentWeights) != n {
+		return nil, fmt.Errorf("rebalance request has %d companies, %d current and %d target weights", n, len(req.CurrentWeights), len(req.TargetWeights))
+	}
 	if n == 0 {
-		return &PortfolioResponse{}, nil
+		return &RebalanceResponse{}, nil
 	}
-
-	response := &PortfolioResponse{
-		OptimalWeights: make([]float64, n),
+	portfolio := req.PortfolioRequest
+	if portfolio.ExpectedReturns == nil {
+		portfolio.ExpectedReturns = make([]float64, n)
+	}
+	if portfolio.Constraints != nil {
+		constraints := *portfolio.Constraints
+		constraints.CurrentWeights = make(map[string]float64, n)
+		invested := 0.0
+		for i, company := range req.Companies {
+			constraints.CurrentWeights[company] += req.CurrentWeights[i]
+			invested += req.CurrentWeights[i]
+		}
+		// Cash is held outside the assets and is all invested, so it
+		// counts towards turnover like a sold holding
+		if invested < 1 {
+			constraints.CurrentWeights[cashKey] = 1 - invested
+		}
+		portfolio.Constraints = &constraints
+	}
+	problem, estimate, err := o.problem(&portfolio)
+	if err != nil {
+		return nil, err
 	}
+	result, err := quant.Tracking(problem, req.TargetWeights)
+	if err != nil {
+		return nil, err
+	}
+	return &RebalanceResponse{
+		Weights:              result.Weights,
+		ExpectedReturn:       result.ExpectedReturn * 100,
+		PortfolioRisk:        result.Volatility * 100,
+		ESGScore:             result.ESGScore,
+		TrackingError:        quant.TrackingError(problem.Covariance, result.Weights, req.TargetWeights) * 100,
+		CurrentTrackingError: quant.TrackingError(problem.Covariance, req.CurrentWeights, req.TargetWeights) * 100,
+		CovarianceSource:     estimate.source,
+		BindingConstraints:   result.Binding,
+	}, nil
+}
 
-	// Simple optimization: weight by ESG score and expected return
-	totalScore := 0.0
-	scores := make([]float64, n)
+// BenchmarkPosition is an asset held by a portfolio, its benchmark or
+// both, with weights as fractions of each. ESGScore and CarbonIntensity
+// are nil when unknown.
+type BenchmarkPosition struct {
+	Symbol          string
+	Weight          float64
+	BenchmarkWeight float64
+	Sector          string
+	ESGScore        *float64
+	CarbonIntensity *float64
+}
 
-	for i := 0; i < n; i++ {
-		// Composite score: ESG (60%) + Expected Return (40%)
-		esgWeight := 0.6
-		returnWeight := 0.4
+// BenchmarkResponse compares a portfolio with its benchmark. TrackingError
+// is ex ante, as an annual percentage. ActiveWeights follow the request's
+// positions. The ESG score and carbon intensity of each side are weighted
+// averages over the positions that have one, and are nil when none do;
+// the coverages are those positions' share of each side's weight.
+type BenchmarkResponse struct {
+	ActiveWeights            []float64
+	TrackingError            float64
+	ESGScore                 *float64
+	BenchmarkESGScore        *float64
+	ActiveESGScore           *float64
+	ESGCoverage              float64
+	BenchmarkESGCoverage     float64
+	CarbonIntensity          *float64
+	BenchmarkCarbonIntensity *float64
+	ActiveCarbonIntensity    *float64
+	CarbonCoverage           float64
+	BenchmarkCarbonCoverage  float64
+	// SectorAttribution splits ActiveESGScore by sector, over the
+	// positions with an ESG score
+	SectorAttribution []SectorAttribution
+	CovarianceSource  string
+}
 
-		// Normalize ESG score (0-10 to 0-1)
-		normalizedESG := req.ESGScores[i] / 10.0
+// SectorAttribution is a sector's part in the active ESG score; see
+// quant.Attribute. Allocation and Selection add up to the sector's share.
+type SectorAttribution struct {
+	Sector            string
+	Weight            float64
+	BenchmarkWeight   float64
+	ESGScore          float64
+	BenchmarkESGScore float64
+	Allocation        float64
+	Selection         float64
+}
+
+// BenchmarkAnalytics measures a portfolio against a benchmark: its active
+// weights, ex-ante tracking error from the shrunk covariance of all the
+// positions, and its ESG score and carbon intensity relative to the
+// benchmark's, with the ESG difference attributed by sector. Positions
+// without a sector are grouped as "Unclassified".
+func (o *OptimizationAgent) BenchmarkAnalytics(ctx context.Context, positions []BenchmarkPosition) (*BenchmarkResponse, error) {
+	n := len(positions)
+	if n == 0 {
+		return &BenchmarkResponse{}, nil
+	}
+	symbols := make([]string, n)
+	weights := make([]float64, n)
+	benchmark := make([]float64, n)
+	response := &BenchmarkResponse{ActiveWeights: make([]float64, n)}
+	for i, position := range positions {
+		symbols[i] = position.Symbol
+		weights[i] = position.Weight
+		benchmark[i] = position.BenchmarkWeight
+		response.ActiveWeights[i] = position.Weight - position.BenchmarkWeight
+	}
+	cov, estimate := o.covariance(symbols, n)
+	response.TrackingError = quant.TrackingError(cov, weights, benchmark) * 100
+	response.CovarianceSource = estimate.source
+
+	esg := func(p BenchmarkPosition) *float64 { return p.ESGScore }
+	carbon := func(p BenchmarkPosition) *float64 { return p.CarbonIntensity }
+	response.ESGScore, response.ESGCoverage = weightedAverage(positions, weights, esg)
+	response.BenchmarkESGScore, response.BenchmarkESGCoverage = weightedAverage(positions, benchmark, esg)
+	response.ActiveESGScore = difference(response.ESGScore, response.BenchmarkESGScore)
+	response.CarbonIntensity, response.CarbonCoverage = weightedAverage(positions, weights, carbon)
+	response.BenchmarkCarbonIntensity, response.BenchmarkCarbonCoverage = weightedAverage(positions, benchmark, carbon)
+	response.ActiveCarbonIntensity = difference(response.CarbonIntensity, response.BenchmarkCarbonIntensity)
+
+	if response.ActiveESGScore != nil {
+		var sectors []string
+		var scored, scoredBenchmark, scores []float64
+		for i, position := range positions {
+			if position.ESGScore == nil {
+				continue
+			}
+			sector := strings.TrimSpace(position.Sector)
+			if sector == "" {
+				sector = "Unclassified"
+			}
+			sectors = append(sectors, sector)
+			scored = append(scored, weights[i])
+			scoredBenchmark = append(scoredBenchmark, benchmark[i])
+			scores = append(scores, *position.ESGScore)
+		}
+		for _, group := range quant.Attribute(sectors, scored, scoredBenchmark, scores) {
+			response.SectorAttribution = append(response.SectorAttribution, SectorAttribution{
+				Sector:            group.Group,
+				Weight:            group.Weight,
+				BenchmarkWeight:   group.BenchmarkWeight,
+				ESGScore:          group.Score,
+				BenchmarkESGScore: group.BenchmarkScore,
+				Allocation:        group.Allocation,
+				Selection:         group.Selection,
+			})
+		}
+	}
+	return response, nil
+}
 
-		// Normalize expected return (assume -20% to +50% range)
-		normalizedReturn := (req.ExpectedReturns[i] + 20.0) / 70.0
-		if normalizedReturn < 0 {
-			normalizedReturn = 0
+// weightedAverage averages value over the positions that have one, and
+// returns their share of the total weight.
+func weightedAverage(positions []BenchmarkPosition, weights []float64, value func(BenchmarkPosition) *float64) (*float64, float64) {
+	var sum, covered, total float64
+	for i, position := range positions {
+		total += weights[i]
+		if v := value(position); v != nil && weights[i] > 0 {
+			sum += weights[i] * *v
+			covered += weights[i]
 		}
-		if normalizedReturn > 1 {
-			normalizedReturn = 1
+	}
+	if covered == 0 {
+		return nil, 0
+	}
+	average := sum / covered
+	return &average, covered / total
+}
+
+func difference(a, b *float64) *float64 {
+	if a == nil || b == nil {
+		return nil
+	}
+	d := *a - *b
+	return &d
+}
+
+func frontierPoints(points []quant.FrontierPoint) []FrontierPoint {
+	out := make([]FrontierPoint, len(points))
+	for i, point := range points {
+		out[i] = FrontierPoint{
+			Weights:        point.Weights,
+			ExpectedReturn: point.ExpectedReturn * 100,
+			PortfolioRisk:  point.Volatility * 100,
+			Sharpe:         point.Sharpe,
+			ESGScore:       point.ESGScore,
 		}
+	}
+	return out
+}
 
-		// Adjust weights based on risk tolerance
-		// High risk tolerance = more weight on returns
-		// Low risk tolerance = more weight on ESG
-		esgWeight = 0.8 - (req.RiskTolerance * 0.4)
-		returnWeight = 0.2 + (req.RiskTolerance * 0.4)
+type covarianceEstimate struct {
+	source       string
+	shrinkage    float64
+	observations int
+}
 
-		scores[i] = (normalizedESG * esgWeight) + (normalizedReturn * returnWeight)
-		totalScore += scores[i]
+// problem builds the optimisation problem for a request: annual expected
+// returns as fractions, the covariance estimate, the objective's weights
+// and the mandate constraints.
+func (o *OptimizationAgent) problem(req *PortfolioRequest) (quant.Problem, covarianceEstimate, error) {
+	n := len(req.Companies)
+	if len(req.ESGScores) != n || len(req.ExpectedReturns) != n {
+		return quant.Problem{}, covarianceEstimate{}, fmt.Errorf("portfolio request has %d companies, %d ESG scores and %d expected returns", n, len(req.ESGScores), len(req.ExpectedReturns))
 	}
 
-	// Calculate optimal weights
-	for i := 0; i < n; i++ {
-		response.OptimalWeights[i] = scores[i] / totalScore
+	cov, estimate := o.covariance(req.Symbols, n)
+	expected := make([]float64, n)
+	for i, r := range req.ExpectedReturns {
+		expected[i] = r / 100
+	}
+	riskTolerance := math.Max(0, math.Min(1, req.RiskTolerance))
+	riskAversion := req.RiskAversion
+	if riskAversion <= 0 {
+		// 10 for the most cautious investor down to 1 for the boldest
+		riskAversion = 1 + 9*(1-riskTolerance)
+	}
+	esgPreference := req.ESGPreference
+	if esgPreference <= 0 {
+		// Up to 6% of annual return traded for a perfect ESG score
+		esgPreference = 0.02 + 0.04*(1-riskTolerance)
 	}
 
-	// Calculate portfolio metrics
-	response.ExpectedReturn = 0.0
-	response.ESGScore = 0.0
-	for i := 0; i < n; i++ {
-		response.ExpectedReturn += response.OptimalWeights[i] * req.ExpectedReturns[i]
-		response.ESGScore += response.OptimalWeights[i] * req.ESGScores[i]
+	problem := quant.Problem{
+		ExpectedReturns: expected,
+		Covariance:      cov,
+		ESGScores:       req.ESGScores,
+		RiskAversion:    riskAversion,
+		ESGPreference:   esgPreference,
+		RiskFreeRate:    req.RiskFreeRate,
+	}
+	if err := applyConstraints(&problem, req); err != nil {
+		return quant.Problem{}, covarianceEstimate{}, err
 	}
+	return problem, estimate, nil
+}
 
-	// Calculate portfolio risk (simplified)
-	variance := 0.0
+// applyConstraints turns the request's mandate into linear constraints on
+// the weights, named after the rule they come from.
+func applyConstraints(problem *quant.Problem, req *PortfolioRequest) error {
+	limits := req.Constraints
+	if limits == nil {
+		return nil
+	}
+	n := len(req.Companies)
+	index := make(map[string]int, n)
+	for i, company := range req.Companies {
+		index[companyKey(company)] = i
+	}
+	attributes := make([]AssetAttributes, n)
+	for name, attr := range req.Attributes {
+		if i, ok := index[companyKey(name)]; ok {
+			attributes[i] = attr
+		}
+	}
+	unit := func(i int) []float64 {
+		row := make([]float64, n)
+		row[i] = 1
+		return row
+	}
+	add := func(name string, row []float64, lower, upper float64) {
+		problem.Constraints = append(problem.Constraints, quant.Constraint{Name: name, Coefficients: row, Lower: lower, Upper: upper})
+	}
+
+	excluded := make(map[int]bool)
+	for _, name := range limits.Exclude {
+		if i, ok := index[companyKey(name)]; ok && !excluded[i] {
+			excluded[i] = true
+			add("exclusions", unit(i), 0, 0)
+		}
+	}
+
+	own := make(map[int]WeightBounds)
+	for name, bounds := range limits.WeightBounds {
+		if i, ok := index[companyKey(name)]; ok {
+			own[i] = bounds
+			lower, upper := 0.0, 1.0
+			if bounds.Min != nil {
+				lower = *bounds.Min
+			}
+			if bounds.Max != nil {
+				upper = *bounds.Max
+			}
+			if lower > upper {
+				return fmt.Errorf("%w: weight_bounds for %s have min above max", ErrInvalidConstraints, req.Companies[i])
+			}
+			add("weight_bounds:"+req.Companies[i], unit(i), lower, upper)
+		}
+	}
+	if limits.MaxWeight > 0 && limits.MinWeight > limits.MaxWeight {
+		return fmt.Errorf("%w: min_weight is above max_weight", ErrInvalidConstraints)
+	}
 	for i := 0; i < n; i++ {
-		// Risk increases with weight concentration
-		variance += math.Pow(response.OptimalWeights[i], 2) * math.Abs(req.ExpectedReturns[i])
+		if _, ok := own[i]; ok || excluded[i] {
+			continue
+		}
+		if limits.MinWeight > 0 {
+			add("min_weight", unit(i), limits.MinWeight, math.Inf(1))
+		}
+		if limits.MaxWeight > 0 && limits.MaxWeight < 1 {
+			add("max_weight", unit(i), math.Inf(-1), limits.MaxWeight)
+		}
+	}
+
+	groupCaps := func(kind string, caps map[string]float64, group func(AssetAttributes) string) error {
+		if len(caps) == 0 {
+			return nil
+		}
+		rows := make(map[string][]float64, len(caps))
+		for i, attr := range attributes {
+			value := strings.TrimSpace(group(attr))
+			if value == "" {
+				return fmt.Errorf("%w: %s caps need a %s for %s", ErrInvalidConstraints, kind, kind, req.Companies[i])
+			}
+			for name := range caps {
+				if strings.EqualFold(name, value) {
+					if rows[name] == nil {
+						rows[name] = make([]float64, n)
+					}
+					rows[name][i] = 1
+				}
+			}
+		}
+		names := make([]string, 0, len(rows))
+		for name := range rows {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			add(kind+"_cap:"+name, rows[name], math.Inf(-1), caps[name])
+		}
+		return nil
+	}
+	if err := groupCaps("sector", limits.SectorCaps, func(a AssetAttributes) string { return a.Sector }); err != nil {
+		return err
+	}
+	if err := groupCaps("country", limits.CountryCaps, func(a AssetAttributes) string { return a.Country }); err != nil {
+		return err
+	}
+
+	if limits.MinESGScore > 0 {
+		add("min_esg_score", append([]float64(nil), req.ESGScores...), limits.MinESGScore, math.Inf(1))
+	}
+	if limits.MaxCarbonIntensity != nil {
+		row := make([]float64, n)
+		for i, attr := range attributes {
+			if attr.CarbonIntensity == nil {
+				return fmt.Errorf("%w: max_carbon_intensity needs a carbon_intensity for %s", ErrInvalidConstraints, req.Companies[i])
+			}
+			row[i] = *attr.CarbonIntensity
+		}
+		add("max_carbon_intensity", row, math.Inf(-1), *limits.MaxCarbonIntensity)
 	}
-	response.PortfolioRisk = math.Sqrt(variance)
 
-	// Adjust risk based on ESG (higher ESG = lower risk)
-	esgRiskReduction := (response.ESGScore / 10.0) * 0.2
-	response.PortfolioRisk *= (1.0 - esgRiskReduction)
+	if limits.MaxTurnover != nil {
+		turnover := &quant.TurnoverLimit{Name: "max_turnover", Current: make([]float64, n), Max: *limits.MaxTurnover}
+		total := 0.0
+		for name, weight := range limits.CurrentWeights {
+			total += weight
+			if i, ok := index[companyKey(name)]; ok {
+				turnover.Current[i] += weight
+			} else {
+				turnover.Outside += weight
+			}
+		}
+		if total > 1+1e-6 {
+			return fmt.Errorf("%w: current_weights add up to more than 1", ErrInvalidConstraints)
+		}
+		problem.Turnover = turnover
+	}
+	return nil
+}
 
-	return response, nil
+// covariance estimates the annual covariance of n assets from the price
+// history of symbols, falling back to the assumed volatility and
+// correlation when there is not enough of it.
+func (o *OptimizationAgent) covariance(symbols []string, n int) (quant.Matrix, covarianceEstimate) {
+	if returns, err := dailyReturns(o.prices, symbols); err == nil {
+		shrunk, err := quant.ShrunkCovariance(returns)
+		if err == nil {
+			return shrunk.Covariance.Scale(quant.PeriodsPerYear), covarianceEstimate{
+				source:       CovarianceHistorical,
+				shrinkage:    shrunk.Shrinkage,
+				observations: shrunk.Observations,
+			}
+		}
+	}
+	vols := make([]float64, n)
+	for i := range vols {
+		vols[i] = assumedVolatility
+	}
+	return quant.ConstantCorrelation(vols, assumedCorrelation), covarianceEstimate{source: CovarianceAssumed}
+}
+
+func companyKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// dailyReturns returns aligned daily returns for symbols over the dates
+// they all have prices for, most recent historyDays of them.
+func dailyReturns(prices marketdata.Source, symbols []string) ([][]float64, error) {
+	if prices == nil || len(symbols) == 0 {
+		return nil, marketdata.ErrNoData
+	}
+
+	closes := make([]map[time.Time]float64, len(symbols))
+	var common []time.Time
+	for i, symbol := range symbols {
+		bars, err := prices.Bars(symbol)
+		if err != nil {
+			return nil, err
+		}
+		closes[i] = make(map[time.Time]float64, len(bars))
+		for _, bar := range bars {
+			if bar.Close > 0 {
+				closes[i][bar.Date] = bar.Close
+			}
+		}
+		if i == 0 {
+			for _, bar := range bars {
+				if bar.Close > 0 {
+					common = append(common, bar.Date)
+				}
+			}
+			continue
+		}
+		kept := common[:0]
+		for _, date := range common {
+			if _, ok := closes[i][date]; ok {
+				kept = append(kept, date)
+			}
+		}
+		common = kept
+	}
+	sort.Slice(common, func(a, b int) bool { return common[a].Before(common[b]) })
+	if len(common) > historyDays+1 {
+		common = common[len(common)-historyDays-1:]
+	}
+	if len(common)-1 < minHistoryDays {
+		return nil, fmt.Errorf("%w: %d common days of prices", quant.ErrInsufficientData, len(common))
+	}
+
+	returns := make([][]float64, len(symbols))
+	for i := range symbols {
+		returns[i] = make([]float64, len(common)-1)
+		for k := 1; k < len(common); k++ {
+			returns[i][k-1] = closes[i][common[k]]/closes[i][common[k-1]] - 1
+		}
+	}
+	return returns, nil
 }
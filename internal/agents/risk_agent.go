@@ -3,27 +3,81 @@ package agents
 import (
 	"context"
 	"fmt"
+
+	"github.com/edgeesg/edge-esg-backend/internal/credit"
+	"github.com/edgeesg/edge-esg-backend/internal/marketdata"
+	"github.com/edgeesg/edge-esg-backend/internal/quant"
 )
 
+// marketRiskSeed seeds Monte Carlo VaR, so the same prices and parameters
+// always give the same figures
+const marketRiskSeed = 1
+
 type RiskAgent struct {
+	prices marketdata.Source
 }
 
 func NewRiskAgent() *RiskAgent {
 	return &RiskAgent{}
 }
 
+// UsePriceHistory lets the agent measure value at risk from prices.
+// Without it assessments carry no market risk.
+func (r *RiskAgent) UsePriceHistory(prices marketdata.Source) {
+	r.prices = prices
+}
+
+// RiskAssessmentRequest describes a company to assess. When Symbol has a
+// price history, the assessment includes its market risk measured with
+// MarketRisk. PhysicalRisk is the 0-100 physical climate risk score of the
+// company's assets, when their locations are known. Credit is the
+// company's ESG and climate-adjusted rating, when a base rating or PD was
+// given for it.
 type RiskAssessmentRequest struct {
 	CompanyName     string
+	Symbol          string
 	ESGScore        float64
 	NewsSentiment   float64
 	StockVolatility float64
+	MarketRisk      MarketRiskParams
+	PhysicalRisk    *float64
+	Credit          *credit.Adjustment
 }
 
 type RiskAssessmentResponse struct {
-	Action    string // APPROVE/REJECT/REVIEW
-	Reasons   []string
-	RiskScore float64 // 0-100
-	RiskLevel string  // LOW/MEDIUM/HIGH/CRITICAL
+	Action     string // APPROVE/REJECT/REVIEW
+	Reasons    []string
+	RiskScore  float64 // 0-100
+	RiskLevel  string  // LOW/MEDIUM/HIGH/CRITICAL
+	MarketRisk *quant.MarketRisk
+}
+
+// MarketRiskParams set the confidence, horizon in trading days and Monte
+// Carlo scenarios of value at risk; zero values take quant's defaults.
+type MarketRiskParams struct {
+	Confidence  float64
+	HorizonDays int
+	Simulations int
+}
+
+// MarketRisk measures the value at risk and expected shortfall of holding
+// weights in symbols, from their daily returns over the last year. A
+// single symbol with a weight of 1 measures a single name.
+func (r *RiskAgent) MarketRisk(ctx context.Context, symbols []string, weights []float64, params MarketRiskParams) (*quant.MarketRisk, error) {
+	returns, err := dailyReturns(r.prices, symbols)
+	if err != nil {
+		return nil, err
+	}
+	risk, err := quant.ValueAtRisk(returns, weights, quant.VaRParams{
+		Confidence:  params.Confidence,
+		Horizon:     params.HorizonDays,
+		Simulations: params.Simulations,
+		Seed:        marketRiskSeed,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &risk, nil
 }
 
 // AssessRisk performs comprehensive risk analysis
@@ -53,6 +107,12 @@ func (r *RiskAgent) AssessRisk(ctx context.Context, req *RiskAssessmentRequest)
 		riskScore += volatilityRisk
 	}
 
+	// Physical Climate Risk Impact (if known)
+	// Exposed assets add risk: score / 100 × 20 = 0-20 points
+	if req.PhysicalRisk != nil {
+		riskScore += *req.PhysicalRisk / 100.0 * 20.0
+	}
+
 	// Clamp to 0-100
 	if riskScore < 0 {
 		riskScore = 0
@@ -105,10 +165,42 @@ func (r *RiskAgent) AssessRisk(ctx context.Context, req *RiskAssessmentRequest)
 		response.Reasons = append(response.Reasons, "High stock volatility indicates market uncertainty")
 	}
 
+	if req.PhysicalRisk != nil {
+		if *req.PhysicalRisk >= 60 {
+			response.Reasons = append(response.Reasons,
+				fmt.Sprintf("High physical climate risk (%.0f/100) at asset locations", *req.PhysicalRisk))
+		} else if *req.PhysicalRisk >= 35 {
+			response.Reasons = append(response.Reasons,
+				fmt.Sprintf("Moderate physical climate risk (%.0f/100) at asset locations", *req.PhysicalRisk))
+		}
+	}
+
+	// Credit overlay, reported alongside the score like market risk
+	if req.Credit != nil {
+		adjustment := req.Credit
+		response.Reasons = append(response.Reasons,
+			fmt.Sprintf("ESG and climate-adjusted rating %s (base %s, %+d notches) with PD of %.2f%%",
+				adjustment.AdjustedRating, adjustment.BaseRating, adjustment.Notches, adjustment.AdjustedPD*100))
+		if credit.InvestmentGrade(adjustment.BaseRating) && !credit.InvestmentGrade(adjustment.AdjustedRating) {
+			response.Reasons = append(response.Reasons, "ESG and climate overlay takes the obligor below investment grade")
+		}
+	}
+
 	// Regulatory compliance check
 	if req.ESGScore < 3.0 {
 		response.Reasons = append(response.Reasons, "High regulatory compliance risk")
 	}
 
+	// Market risk, when the symbol has enough price history. It is
+	// reported alongside the score rather than added to it.
+	if req.Symbol != "" {
+		if marketRisk, err := r.MarketRisk(ctx, []string{req.Symbol}, []float64{1}, req.MarketRisk); err == nil {
+			response.MarketRisk = marketRisk
+			response.Reasons = append(response.Reasons,
+				fmt.Sprintf("%d-day %.1f%% VaR of %.1f%% with expected shortfall of %.1f%% (Monte Carlo)",
+					marketRisk.Horizon, marketRisk.Confidence*100, marketRisk.MonteCarlo.VaR*100, marketRisk.MonteCarlo.ExpectedShortfall*100))
+		}
+	}
+
 	return response, nil
 }
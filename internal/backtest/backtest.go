@@ -0,0 +1,437 @@
+// Package backtest replays historical prices and point-in-time ESG and
+// sentiment inputs through TradingAgent's signal logic, one trading day at a
+// time, to measure whether its signals would have made money.
+package backtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/edgeesg/edge-esg-backend/internal/agents"
+	"github.com/edgeesg/edge-esg-backend/internal/marketdata"
+)
+
+const (
+	TradingDaysPerYear = 252
+
+	MaxSymbols = 50
+	MaxBps     = 1000
+)
+
+var ErrInvalidConfig = errors.New("invalid backtest config")
+
+// Config describes one backtest. Costs are in basis points of traded
+// notional: CostBps covers commissions and fees, SlippageBps moves every
+// fill price against the trade.
+type Config struct {
+	Symbols        []string  `json:"symbols"`
+	Benchmark      string    `json:"benchmark,omitempty"`
+	Start          time.Time `json:"start"`
+	End            time.Time `json:"end"`
+	InitialCapital float64   `json:"initial_capital"`
+	CostBps        float64   `json:"cost_bps"`
+	SlippageBps    float64   `json:"slippage_bps"`
+	// RiskFreeRate is annual, used for the Sharpe ratio
+	RiskFreeRate float64 `json:"risk_free_rate"`
+}
+
+// Performance summarises an equity curve. Returns are fractions, annualised
+// over 252 trading days; MaxDrawdown is the worst peak-to-trough fall.
+type Performance struct {
+	TotalReturn      float64 `json:"total_return"`
+	AnnualizedReturn float64 `json:"annualized_return"`
+	Volatility       float64 `json:"volatility"`
+	Sharpe           float64 `json:"sharpe"`
+	MaxDrawdown      float64 `json:"max_drawdown"`
+}
+
+type Trade struct {
+	Date     time.Time `json:"date"`
+	Symbol   string    `json:"symbol"`
+	Side     string    `json:"side"`
+	Quantity float64   `json:"quantity"`
+	Price    float64   `json:"price"`
+	Notional float64   `json:"notional"`
+	Cost     float64   `json:"cost"`
+	// PnL is set on exits: proceeds less the entry's cost, fees included
+	PnL float64 `json:"pnl,omitempty"`
+}
+
+type EquityPoint struct {
+	Date      time.Time `json:"date"`
+	Equity    float64   `json:"equity"`
+	Benchmark float64   `json:"benchmark,omitempty"`
+}
+
+// Result is a backtest's report. HitRate is the share of closed trades
+// that made money after costs. Turnover is annualised one-way turnover as a
+// multiple of average equity.
+type Result struct {
+	Config        Config         `json:"config"`
+	TradingDays   int            `json:"trading_days"`
+	FinalEquity   float64        `json:"final_equity"`
+	Strategy      Performance    `json:"strategy"`
+	Benchmark     *Performance   `json:"benchmark,omitempty"`
+	ActiveReturn  float64        `json:"active_return,omitempty"`
+	HitRate       float64        `json:"hit_rate"`
+	ClosedTrades  int            `json:"closed_trades"`
+	OpenPositions int            `json:"open_positions"`
+	Turnover      float64        `json:"turnover"`
+	TotalCosts    float64        `json:"total_costs"`
+	Signals       map[string]int `json:"signals"`
+	Trades        []Trade        `json:"trades,omitempty"`
+	Equity        []EquityPoint  `json:"equity,omitempty"`
+}
+
+// Engine runs backtests against a market data source.
+//
+// Each day, orders signalled the previous evening fill at the open (or the
+// close when the data has no open), positions are marked at the close, and
+// then every symbol is put through GenerateSignal with that close and the
+// ESG score and sentiment known on that day. The strategy is long-only and
+// equal-weight: BUY opens a position of 1/N of equity when flat, SELL
+// closes it, HOLD does nothing. Quantities are fractional.
+type Engine struct {
+	source marketdata.Source
+	agent  *agents.TradingAgent
+}
+
+func NewEngine(source marketdata.Source) *Engine {
+	return &Engine{
+		source: source,
+		agent:  agents.NewTradingAgent(),
+	}
+}
+
+// position tracks one symbol through the simulation.
+type position struct {
+	symbol  string
+	bars    []marketdata.Bar
+	inputs  []marketdata.Inputs
+	next    int
+	last    float64
+	qty     float64
+	basis   float64
+	pending string
+	target  float64
+}
+
+func (e *Engine) Run(ctx context.Context, cfg Config) (*Result, error) {
+	if err := cfg.normalize(); err != nil {
+		return nil, err
+	}
+
+	positions := make([]*position, len(cfg.Symbols))
+	for i, symbol := range cfg.Symbols {
+		bars, err := e.source.Bars(symbol)
+		if err != nil {
+			return nil, err
+		}
+		inputs, err := e.source.Inputs(symbol)
+		if err != nil {
+			return nil, err
+		}
+		positions[i] = &position{symbol: symbol, bars: bars, inputs: inputs}
+	}
+
+	var benchmark *position
+	if cfg.Benchmark != "" {
+		bars, err := e.source.Bars(cfg.Benchmark)
+		if err != nil {
+			return nil, err
+		}
+		benchmark = &position{symbol: cfg.Benchmark, bars: bars}
+	}
+
+	calendar := tradingCalendar(cfg, positions, benchmark)
+	if len(calendar) < 2 {
+		return nil, fmt.Errorf("%w: fewer than 2 trading days between %s and %s", ErrInvalidConfig,
+			cfg.Start.Format(marketdata.DateLayout), cfg.End.Format(marketdata.DateLayout))
+	}
+
+	result := &Result{
+		Config:  cfg,
+		Signals: map[string]int{"BUY": 0, "SELL": 0, "HOLD": 0},
+		Equity:  make([]EquityPoint, 0, len(calendar)),
+	}
+	cash := cfg.InitialCapital
+	costRate := cfg.CostBps / 10000
+	slippage := cfg.SlippageBps / 10000
+	var traded, benchStart float64
+	wins := 0
+
+	equity := func() float64 {
+		total := cash
+		for _, p := range positions {
+			total += p.qty * p.last
+		}
+		return total
+	}
+
+	for day, date := range calendar {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		// Fill last evening's orders at today's open
+		for _, p := range positions {
+			bar, ok := p.advance(date)
+			if !ok || p.pending == "" {
+				continue
+			}
+			price := bar.Open
+			if price <= 0 {
+				price = bar.Close
+			}
+
+			switch p.pending {
+			case "BUY":
+				price *= 1 + slippage
+				notional := math.Min(p.target, cash/(1+costRate))
+				if notional > 0.01 {
+					fee := notional * costRate
+					qty := notional / price
+					cash -= notional + fee
+					p.qty += qty
+					p.basis += notional + fee
+					traded += notional
+					result.TotalCosts += fee
+					result.Trades = append(result.Trades, Trade{
+						Date: date, Symbol: p.symbol, Side: "BUY", Quantity: qty,
+						Price: price, Notional: notional, Cost: fee,
+					})
+				}
+
+			case "SELL":
+				price *= 1 - slippage
+				notional := p.qty * price
+				fee := notional * costRate
+				pnl := notional - fee - p.basis
+				cash += notional - fee
+				traded += notional
+				result.TotalCosts += fee
+				result.ClosedTrades++
+				if pnl > 0 {
+					wins++
+				}
+				result.Trades = append(result.Trades, Trade{
+					Date: date, Symbol: p.symbol, Side: "SELL", Quantity: p.qty,
+					Price: price, Notional: notional, Cost: fee, PnL: pnl,
+				})
+				p.qty, p.basis = 0, 0
+			}
+			p.pending = ""
+		}
+
+		// Mark to market at the close
+		point := EquityPoint{Date: date, Equity: equity()}
+		if benchmark != nil {
+			benchmark.advance(date)
+			if benchStart == 0 {
+				benchStart = benchmark.last
+			}
+			if benchStart > 0 {
+				point.Benchmark = cfg.InitialCapital * benchmark.last / benchStart
+			}
+		}
+		result.Equity = append(result.Equity, point)
+
+		// Signal on today's close; there is no next open on the last day
+		if day == len(calendar)-1 {
+			break
+		}
+		for _, p := range positions {
+			if p.next == 0 || !p.bars[p.next-1].Date.Equal(date) {
+				continue
+			}
+			inputs, ok := marketdata.AsOf(p.inputs, date)
+			if !ok {
+				continue
+			}
+			signal, err := e.agent.GenerateSignal(ctx, &agents.TradingSignalRequest{
+				Symbol:       p.symbol,
+				CurrentPrice: p.last,
+				ESGScore:     inputs.ESGScore,
+				Sentiment:    inputs.Sentiment,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("signal for %s on %s: %w", p.symbol, date.Format(marketdata.DateLayout), err)
+			}
+			result.Signals[signal.Action]++
+
+			switch {
+			case signal.Action == "BUY" && p.qty == 0:
+				p.pending = "BUY"
+				p.target = point.Equity / float64(len(positions))
+			case signal.Action == "SELL" && p.qty > 0:
+				p.pending = "SELL"
+			}
+		}
+	}
+
+	values := make([]float64, len(result.Equity))
+	for i, point := range result.Equity {
+		values[i] = point.Equity
+	}
+	result.TradingDays = len(calendar)
+	result.FinalEquity = values[len(values)-1]
+	result.Strategy = Measure(values, cfg.RiskFreeRate)
+	if result.ClosedTrades > 0 {
+		result.HitRate = float64(wins) / float64(result.ClosedTrades)
+	}
+	for _, p := range positions {
+		if p.qty > 0 {
+			result.OpenPositions++
+		}
+	}
+
+	years := float64(len(values)-1) / TradingDaysPerYear
+	if average := mean(values); average > 0 && years > 0 {
+		result.Turnover = traded / 2 / average / years
+	}
+
+	if benchmark != nil && benchStart > 0 {
+		bench := make([]float64, len(result.Equity))
+		for i, point := range result.Equity {
+			bench[i] = point.Benchmark
+		}
+		perf := Measure(bench, cfg.RiskFreeRate)
+		result.Benchmark = &perf
+		result.ActiveReturn = result.Strategy.TotalReturn - perf.TotalReturn
+	}
+	return result, nil
+}
+
+// advance moves a position's cursor to date, keeping the latest close for
+// valuation, and returns the bar for date if the symbol traded that day.
+func (p *position) advance(date time.Time) (marketdata.Bar, bool) {
+	for p.next < len(p.bars) && !p.bars[p.next].Date.After(date) {
+		p.last = p.bars[p.next].Close
+		p.next++
+	}
+	if p.next > 0 && p.bars[p.next-1].Date.Equal(date) {
+		return p.bars[p.next-1], true
+	}
+	return marketdata.Bar{}, false
+}
+
+// tradingCalendar uses the benchmark's trading days when there is one, and
+// otherwise every day any of the symbols traded.
+func tradingCalendar(cfg Config, positions []*position, benchmark *position) []time.Time {
+	sources := positions
+	if benchmark != nil {
+		sources = []*position{benchmark}
+	}
+	seen := make(map[time.Time]bool)
+	var calendar []time.Time
+	for _, p := range sources {
+		for _, bar := range p.bars {
+			if bar.Date.Before(cfg.Start) || bar.Date.After(cfg.End) || seen[bar.Date] {
+				continue
+			}
+			seen[bar.Date] = true
+			calendar = append(calendar, bar.Date)
+		}
+	}
+	sort.Slice(calendar, func(i, j int) bool { return calendar[i].Before(calendar[j]) })
+	return calendar
+}
+
+func (cfg *Config) normalize() error {
+	if len(cfg.Symbols) == 0 || len(cfg.Symbols) > MaxSymbols {
+		return fmt.Errorf("%w: between 1 and %d symbols required", ErrInvalidConfig, MaxSymbols)
+	}
+	seen := make(map[string]bool)
+	symbols := make([]string, 0, len(cfg.Symbols))
+	for _, raw := range cfg.Symbols {
+		symbol, err := marketdata.NormalizeSymbol(raw)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+		}
+		if !seen[symbol] {
+			seen[symbol] = true
+			symbols = append(symbols, symbol)
+		}
+	}
+	cfg.Symbols = symbols
+
+	if cfg.Benchmark != "" {
+		benchmark, err := marketdata.NormalizeSymbol(cfg.Benchmark)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+		}
+		cfg.Benchmark = benchmark
+	}
+	if cfg.Start.IsZero() || cfg.End.IsZero() || !cfg.End.After(cfg.Start) {
+		return fmt.Errorf("%w: end must be after start", ErrInvalidConfig)
+	}
+	if cfg.InitialCapital == 0 {
+		cfg.InitialCapital = 1_000_000
+	}
+	if cfg.InitialCapital < 0 {
+		return fmt.Errorf("%w: initial capital must be positive", ErrInvalidConfig)
+	}
+	if cfg.CostBps < 0 || cfg.CostBps > MaxBps || cfg.SlippageBps < 0 || cfg.SlippageBps > MaxBps {
+		return fmt.Errorf("%w: costs and slippage must be 0 to %d bps", ErrInvalidConfig, MaxBps)
+	}
+	return nil
+}
+
+// Measure summarises a series of daily equity values, oldest first.
+func Measure(values []float64, riskFreeRate float64) Performance {
+	var perf Performance
+	if len(values) < 2 || values[0] <= 0 {
+		return perf
+	}
+
+	returns := make([]float64, 0, len(values)-1)
+	peak := values[0]
+	for i := 1; i < len(values); i++ {
+		returns = append(returns, values[i]/values[i-1]-1)
+		peak = math.Max(peak, values[i])
+		perf.MaxDrawdown = math.Max(perf.MaxDrawdown, (peak-values[i])/peak)
+	}
+
+	perf.TotalReturn = values[len(values)-1]/values[0] - 1
+	years := float64(len(returns)) / TradingDaysPerYear
+	if perf.TotalReturn > -1 {
+		perf.AnnualizedReturn = math.Pow(1+perf.TotalReturn, 1/years) - 1
+	} else {
+		perf.AnnualizedReturn = -1
+	}
+
+	sd := stddev(returns)
+	perf.Volatility = sd * math.Sqrt(TradingDaysPerYear)
+	if sd > 0 {
+		perf.Sharpe = (mean(returns) - riskFreeRate/TradingDaysPerYear) / sd * math.Sqrt(TradingDaysPerYear)
+	}
+	return perf
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// stddev is the sample standard deviation.
+func stddev(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+	m := mean(values)
+	sum := 0.0
+	for _, v := range values {
+		sum += (v - m) * (v - m)
+	}
+	return math.Sqrt(sum / float64(len(values)-1))
+}
@@ -0,0 +1,309 @@
+// Package climate stress tests companies and portfolios against climate
+// transition scenarios. Each year of a scenario shocks a company's revenue
+// and emissions and prices its carbon; from the resulting EBITDA path it
+// projects the change in the company's value and, through a Merton model,
+// in its probability of default.
+package climate
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/edgeesg/edge-esg-backend/internal/marketdata"
+)
+
+// Asset classes of an exposure
+const (
+	Equity = "equity"
+	Loan   = "loan"
+)
+
+const (
+	DefaultDiscountRate    = 0.08
+	DefaultAssetVolatility = 0.25
+	// DefaultLGD is the Basel foundation IRB loss given default of senior
+	// unsecured claims
+	DefaultLGD = 0.45
+)
+
+var ErrInvalidCompany = errors.New("invalid company")
+
+// Company is what the stress test needs of a company: today's annual
+// revenue and EBITDA, its scope 1 and 2 emissions in tCO2e, enterprise
+// value and total debt, all in the reporting currency. Emissions are
+// estimated from the sector's emission factor when nil. PD, a one-year
+// probability of default, anchors the projected PDs when given; otherwise
+// they come from the Merton model alone. Exposure is what a portfolio
+// holds, as equity (losing value with the company's equity) or a loan
+// (losing LGD of the exposure on default).
+type Company struct {
+	Name            string
+	Sector          string
+	Revenue         float64
+	EBITDA          float64
+	Emissions       *float64
+	EnterpriseValue float64
+	Debt            float64
+	PD              *float64
+	AssetVolatility *float64
+	Exposure        float64
+	AssetClass      string
+	LGD             *float64
+}
+
+// Validate checks a company has what the stress test needs.
+func (c Company) Validate() error {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s: %s", ErrInvalidCompany, c.Name, fmt.Sprintf(format, args...))
+	}
+	switch {
+	case strings.TrimSpace(c.Name) == "":
+		return fmt.Errorf("%w: a name is required", ErrInvalidCompany)
+	case c.Revenue <= 0 || c.EBITDA <= 0 || c.EnterpriseValue <= 0:
+		return invalid("revenue, EBITDA and enterprise value must be positive")
+	case c.Debt < 0 || c.Exposure < 0:
+		return invalid("debt and exposure cannot be negative")
+	case c.Emissions != nil && *c.Emissions < 0:
+		return invalid("emissions cannot be negative")
+	case c.PD != nil && (*c.PD <= 0 || *c.PD >= 1):
+		return invalid("PD must be between 0 and 1")
+	case c.AssetVolatility != nil && *c.AssetVolatility <= 0:
+		return invalid("asset volatility must be positive")
+	case c.LGD != nil && (*c.LGD < 0 || *c.LGD > 1):
+		return invalid("LGD must be from 0 to 1")
+	case c.AssetClass != "" && c.AssetClass != Equity && c.AssetClass != Loan:
+		return invalid("asset class must be %s or %s", Equity, Loan)
+	case c.Exposure > 0 && c.assetClass() == Equity && c.EnterpriseValue <= c.Debt:
+		return invalid("an equity exposure needs enterprise value above debt")
+	}
+	return nil
+}
+
+func (c Company) assetClass() string {
+	if c.AssetClass == "" {
+		return Equity
+	}
+	return c.AssetClass
+}
+
+// Year is a company's position in a year of a scenario. Changes are
+// fractions of today's values; PDChange is in probability, not relative.
+// ValuationChange is of enterprise value, EquityChange of enterprise value
+// less debt, at most a total loss.
+type Year struct {
+	Year            int
+	CarbonPrice     float64
+	CarbonCost      float64
+	Revenue         float64
+	EBITDA          float64
+	EBITDAChange    float64
+	ValuationChange float64
+	EquityChange    float64
+	PD              float64
+	PDChange        float64
+}
+
+// CompanyResult is a company's path through a scenario, in the years the
+// scenario gives.
+type CompanyResult struct {
+	Company
+	Emissions          float64
+	EmissionsEstimated bool
+	BasePD             float64
+	Years              []Year
+}
+
+// PortfolioYear totals the exposures in a year. Losses are positive:
+// EquityLoss is the fall in value of equity exposures and CreditLoss the
+// rise in expected loss on loans. EBITDAChange and PD are averages
+// weighted by exposure.
+type PortfolioYear struct {
+	Year         int
+	Exposure     float64
+	EquityLoss   float64
+	CreditLoss   float64
+	Loss         float64
+	LossShare    float64
+	EBITDAChange float64
+	PD           float64
+}
+
+// Result is a scenario's effect on each company, and on the portfolio when
+// any company has an exposure.
+type Result struct {
+	Scenario  *marketdata.Scenario
+	Companies []CompanyResult
+	Portfolio []PortfolioYear
+}
+
+// Stress runs companies through a scenario. EBITDA in each year is
+//
+//	EBITDA·(1 + revenue shock) − carbon price·emissions·(1 + emissions change)·(1 − pass-through)
+//
+// keeping today's margin on the shocked revenue. A company's value in a
+// year is its EBITDA path from then on, discounted at discountRate, with
+// the last year's EBITDA held in perpetuity, relative to today's EBITDA in
+// perpetuity. Its PD moves with its distance to default on that value,
+// against constant debt. Emission factors are keyed by lower-case sector.
+func Stress(scenario *marketdata.Scenario, companies []Company, factors map[string]marketdata.EmissionFactor, discountRate float64) (*Result, error) {
+	if len(scenario.Years) == 0 {
+		return nil, fmt.Errorf("%w: scenario %s has no years", marketdata.ErrNoData, scenario.Name)
+	}
+	if discountRate == 0 {
+		discountRate = DefaultDiscountRate
+	}
+	if discountRate <= 0 {
+		return nil, fmt.Errorf("discount rate must be positive")
+	}
+	result := &Result{Scenario: scenario, Companies: make([]CompanyResult, len(companies))}
+	for i, company := range companies {
+		if err := company.Validate(); err != nil {
+			return nil, err
+		}
+		stressed, err := stressCompany(scenario, company, factors, discountRate)
+		if err != nil {
+			return nil, err
+		}
+		result.Companies[i] = stressed
+	}
+	result.Portfolio = portfolio(scenario.Years, result.Companies)
+	return result, nil
+}
+
+func stressCompany(scenario *marketdata.Scenario, company Company, factors map[string]marketdata.EmissionFactor, r float64) (CompanyResult, error) {
+	result := CompanyResult{Company: company}
+	if company.Emissions != nil {
+		result.Emissions = *company.Emissions
+	} else {
+		factor, ok := factors[strings.ToLower(strings.TrimSpace(company.Sector))]
+		if !ok || factor.Revenue == nil {
+			return result, fmt.Errorf("%w: %s: emissions are needed, as sector %q has no emission factor", ErrInvalidCompany, company.Name, company.Sector)
+		}
+		result.Emissions = *factor.Revenue * company.Revenue / 1e6
+		result.EmissionsEstimated = true
+	}
+
+	// Yearly EBITDA over the scenario
+	shocks := scenario.For(company.Sector)
+	first, last := scenario.Years[0], scenario.Years[len(scenario.Years)-1]
+	years := make([]Year, last-first+1)
+	for k := range years {
+		year := &years[k]
+		year.Year = first + k
+		price, _ := shocks.CarbonPrice.At(year.Year)
+		revenueShock, _ := shocks.RevenueShock.At(year.Year)
+		emissionsChange, _ := shocks.EmissionsChange.At(year.Year)
+		passThrough, _ := shocks.PassThrough.At(year.Year)
+		year.CarbonPrice = price
+		year.CarbonCost = price * result.Emissions * (1 + emissionsChange)
+		year.Revenue = company.Revenue * (1 + revenueShock)
+		year.EBITDA = company.EBITDA*(1+revenueShock) - year.CarbonCost*(1-passThrough)
+		year.EBITDAChange = year.EBITDA/company.EBITDA - 1
+	}
+
+	// Value at the start of each year, working back from the perpetuity
+	// after the last
+	sigma := DefaultAssetVolatility
+	if company.AssetVolatility != nil {
+		sigma = *company.AssetVolatility
+	}
+	baseDD := distanceToDefault(company.EnterpriseValue, company.Debt, sigma)
+	result.BasePD = normalCDF(-baseDD)
+	if company.PD != nil {
+		result.BasePD = *company.PD
+	}
+	value := years[len(years)-1].EBITDA / r
+	for k := len(years) - 1; k >= 0; k-- {
+		year := &years[k]
+		value = (year.EBITDA + value) / (1 + r)
+		year.ValuationChange = math.Max(-1, value*r/company.EBITDA-1)
+		change := company.EnterpriseValue * year.ValuationChange
+		year.EquityChange = -1
+		if equity := company.EnterpriseValue - company.Debt; equity > 0 {
+			year.EquityChange = math.Max(-1, change/equity)
+		}
+		year.PD = stressedPD(company.PD, baseDD, company.EnterpriseValue+change, company.Debt, sigma)
+		year.PDChange = year.PD - result.BasePD
+	}
+
+	for _, year := range scenario.Years {
+		result.Years = append(result.Years, years[year-first])
+	}
+	return result, nil
+}
+
+// distanceToDefault is the Merton distance to default over one year of
+// assets worth value against debt, with no drift.
+func distanceToDefault(value, debt, sigma float64) float64 {
+	if debt == 0 {
+		return math.Inf(1)
+	}
+	if value <= 0 {
+		return math.Inf(-1)
+	}
+	return (math.Log(value/debt) - sigma*sigma/2) / sigma
+}
+
+// stressedPD is the PD at the stressed value. A given base PD is moved by
+// the change in distance to default from baseDD; without one, the PD is
+// the Merton model's.
+func stressedPD(basePD *float64, baseDD, value, debt, sigma float64) float64 {
+	dd := distanceToDefault(value, debt, sigma)
+	switch {
+	case math.IsInf(dd, -1):
+		return 1
+	case basePD == nil:
+		return normalCDF(-dd)
+	case math.IsInf(dd, 1):
+		return *basePD
+	}
+	return normalCDF(normalQuantile(*basePD) - (dd - baseDD))
+}
+
+func normalCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+func normalQuantile(p float64) float64 {
+	return math.Sqrt2 * math.Erfinv(2*p-1)
+}
+
+func portfolio(years []int, companies []CompanyResult) []PortfolioYear {
+	held := false
+	for _, company := range companies {
+		held = held || company.Exposure > 0
+	}
+	if !held {
+		return nil
+	}
+	out := make([]PortfolioYear, len(years))
+	for k, year := range years {
+		total := &out[k]
+		total.Year = year
+		for _, company := range companies {
+			if company.Exposure == 0 {
+				continue
+			}
+			position := company.Years[k]
+			total.Exposure += company.Exposure
+			if company.assetClass() == Equity {
+				total.EquityLoss -= company.Exposure * position.EquityChange
+			} else {
+				lgd := DefaultLGD
+				if company.LGD != nil {
+					lgd = *company.LGD
+				}
+				total.CreditLoss += company.Exposure * lgd * position.PDChange
+			}
+			total.EBITDAChange += company.Exposure * position.EBITDAChange
+			total.PD += company.Exposure * position.PD
+		}
+		total.Loss = total.EquityLoss + total.CreditLoss
+		total.LossShare = total.Loss / total.Exposure
+		total.EBITDAChange /= total.Exposure
+		total.PD /= total.Exposure
+	}
+	return out
+}
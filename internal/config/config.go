@@ -3,6 +3,9 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -18,6 +21,104 @@ type Config struct {
 	TLSEnabled    bool
 	TLSCertFile   string
 	TLSKeyFile    string
+
+	// MaskingPolicyFile points at a JSON masking policy; empty uses the
+	// built-in defaults.
+	MaskingPolicyFile string
+
+	// KeycloakClientID enables Keycloak-protected routes (admin endpoints);
+	// when empty those routes refuse all requests.
+	KeycloakClientID string
+
+	// RateLimitConfigFile points at a JSON file of rate limit plans, bank
+	// assignments and route costs; empty uses the built-in defaults.
+	RateLimitConfigFile string
+
+	// TrustedProxies are the proxy addresses or CIDRs whose X-Forwarded-For
+	// is believed when resolving the client IP; empty trusts none.
+	TrustedProxies []string
+
+	// APIKeyRequired rejects /api/v1 requests that do not carry a bank API key.
+	APIKeyRequired bool
+
+	// Upstream data providers. Each provider may have several keys, rotated
+	// by usage; every key has the same daily limit.
+	NewsAPIKeys            []string
+	NewsAPIDailyLimit      int
+	NewsAPIPerMinute       int
+	AlphaVantageKeys       []string
+	AlphaVantageDailyLimit int
+	AlphaVantagePerMinute  int
+	// PriorityBankIDs may spend the UpstreamPriorityReserve share of each
+	// key's budget; other banks stop short of it.
+	PriorityBankIDs         []string
+	UpstreamPriorityReserve float64
+	// UpstreamCacheThreshold is the fraction of the daily budget after which
+	// cached upstream responses are preferred over new calls.
+	UpstreamCacheThreshold float64
+	UpstreamCacheTTL       time.Duration
+
+	// Asynchronous jobs: worker pool size per gateway, the longest a single
+	// job may run, and how long finished jobs and their results are kept.
+	JobWorkers   int
+	JobTimeout   time.Duration
+	JobRetention time.Duration
+
+	// BatchConcurrency is how many companies one batch job analyses at once;
+	// BatchTimeout replaces JobTimeout for batch jobs.
+	BatchConcurrency int
+	BatchTimeout     time.Duration
+
+	// WebSocket fan-out: messages queued per connection, and whether a
+	// client whose queue is full loses messages ("drop") or its connection
+	// ("disconnect").
+	WSSendBuffer       int
+	WSSlowClientPolicy string
+	// SSEReplayBuffer is how many recent topic events each replica keeps
+	// for SSE clients resuming with Last-Event-ID.
+	SSEReplayBuffer int
+	// WSFanoutChannel is the Redis pub/sub channel gateway replicas share
+	// WebSocket events on; replicas on the same channel see each other's.
+	WSFanoutChannel string
+
+	// MarketDataDir holds the CSV prices and point-in-time ESG/sentiment
+	// inputs used by backtests.
+	MarketDataDir string
+
+	// SignalHorizon is how long a trade signal stays open before it
+	// expires; SignalExpiryInterval is how often expiry runs.
+	SignalHorizon        time.Duration
+	SignalExpiryInterval time.Duration
+
+	// PaperSnapshotInterval is how often paper portfolios are marked to
+	// market; the last snapshot of a day is that day's report.
+	PaperSnapshotInterval time.Duration
+
+	// PortfolioDriftInterval is how often stored portfolios are checked
+	// for drift from their targets.
+	PortfolioDriftInterval time.Duration
+
+	// FIX export of approved signals: FIXMode is "" (off), "session" or
+	// "dry-run". Replicas elect one exporter between them, since a
+	// counterparty accepts one session per CompID pair.
+	FIXMode          string
+	FIXAddress       string
+	FIXSenderCompID  string
+	FIXTargetCompID  string
+	FIXHeartBtInt    time.Duration
+	FIXResetOnLogon  bool
+	FIXDryRunFile    string
+	FIXOrderNotional float64
+	FIXPollInterval  time.Duration
+
+	// CreditStressScenario and CreditStressYear are the climate stress
+	// whose PD rise feeds credit overlays, for obligors given with their
+	// financials; an empty scenario turns it off.
+	CreditStressScenario string
+	CreditStressYear     int
+
+	// ShutdownTimeout bounds graceful shutdown on SIGINT/SIGTERM.
+	ShutdownTimeout time.Duration
 }
 
 func Load() (*Config, error) {
@@ -34,6 +135,63 @@ func Load() (*Config, error) {
 		TLSEnabled:    getEnv("TLS_ENABLED", "false") == "true",
 		TLSCertFile:   getEnv("TLS_CERT_FILE", ""),
 		TLSKeyFile:    getEnv("TLS_KEY_FILE", ""),
+
+		MaskingPolicyFile: getEnv("MASKING_POLICY_FILE", ""),
+		KeycloakClientID:  getEnv("KEYCLOAK_CLIENT_ID", ""),
+		APIKeyRequired:    getEnv("API_KEY_REQUIRED", "false") == "true",
+
+		RateLimitConfigFile: getEnv("RATE_LIMIT_CONFIG_FILE", ""),
+		TrustedProxies:      getEnvList("TRUSTED_PROXIES", ""),
+
+		NewsAPIKeys:             getEnvList("NEWS_API_KEYS", getEnv("NEWS_API_KEY", "")),
+		NewsAPIDailyLimit:       getEnvInt("NEWS_API_DAILY_LIMIT", 100),
+		AlphaVantageKeys:        getEnvList("ALPHA_VANTAGE_KEYS", getEnv("ALPHA_VANTAGE_KEY", "")),
+		AlphaVantageDailyLimit:  getEnvInt("ALPHA_VANTAGE_DAILY_LIMIT", 25),
+		NewsAPIPerMinute:        getEnvInt("NEWS_API_PER_MINUTE", 0),
+		AlphaVantagePerMinute:   getEnvInt("ALPHA_VANTAGE_PER_MINUTE", 5),
+		PriorityBankIDs:         getEnvList("PRIORITY_BANK_IDS", ""),
+		UpstreamPriorityReserve: getEnvFloat("UPSTREAM_PRIORITY_RESERVE", 0.2),
+		UpstreamCacheThreshold:  getEnvFloat("UPSTREAM_CACHE_THRESHOLD", 0.8),
+		UpstreamCacheTTL:        time.Duration(getEnvInt("UPSTREAM_CACHE_TTL_HOURS", 24)) * time.Hour,
+
+		JobWorkers:   getEnvInt("JOB_WORKERS", 4),
+		JobTimeout:   time.Duration(getEnvInt("JOB_TIMEOUT_SECONDS", 120)) * time.Second,
+		JobRetention: time.Duration(getEnvInt("JOB_RETENTION_HOURS", 24)) * time.Hour,
+
+		BatchConcurrency: getEnvInt("BATCH_CONCURRENCY", 4),
+		BatchTimeout:     time.Duration(getEnvInt("BATCH_TIMEOUT_MINUTES", 360)) * time.Minute,
+
+		WSSendBuffer:         getEnvInt("WS_SEND_BUFFER", 64),
+		WSSlowClientPolicy:   getEnv("WS_SLOW_CLIENT_POLICY", "drop"),
+		WSFanoutChannel:      getEnv("WS_FANOUT_CHANNEL", "ws:deliveries"),
+		SSEReplayBuffer:      getEnvInt("SSE_REPLAY_BUFFER", 1000),
+		MarketDataDir:        getEnv("MARKET_DATA_DIR", "data/market"),
+		SignalHorizon:        time.Duration(getEnvInt("SIGNAL_HORIZON_HOURS", 24)) * time.Hour,
+		SignalExpiryInterval: time.Duration(getEnvInt("SIGNAL_EXPIRY_INTERVAL_SECONDS", 60)) * time.Second,
+		ShutdownTimeout:      time.Duration(getEnvInt("SHUTDOWN_TIMEOUT_SECONDS", 20)) * time.Second,
+
+		PaperSnapshotInterval:  time.Duration(getEnvInt("PAPER_SNAPSHOT_INTERVAL_MINUTES", 60)) * time.Minute,
+		PortfolioDriftInterval: time.Duration(getEnvInt("PORTFOLIO_DRIFT_INTERVAL_MINUTES", 60)) * time.Minute,
+
+		FIXMode:          getEnv("FIX_MODE", ""),
+		FIXAddress:       getEnv("FIX_ADDRESS", ""),
+		FIXSenderCompID:  getEnv("FIX_SENDER_COMP_ID", "EDGE"),
+		FIXTargetCompID:  getEnv("FIX_TARGET_COMP_ID", "OMS"),
+		FIXHeartBtInt:    time.Duration(getEnvInt("FIX_HEARTBEAT_SECONDS", 30)) * time.Second,
+		FIXResetOnLogon:  getEnv("FIX_RESET_ON_LOGON", "false") == "true",
+		FIXDryRunFile:    getEnv("FIX_DRY_RUN_FILE", "data/fix/orders.log"),
+		FIXOrderNotional: getEnvFloat("FIX_ORDER_NOTIONAL", 100000),
+		FIXPollInterval:  time.Duration(getEnvInt("FIX_POLL_INTERVAL_SECONDS", 5)) * time.Second,
+
+		CreditStressScenario: getEnv("CREDIT_STRESS_SCENARIO", "NET-ZERO-2050"),
+		CreditStressYear:     getEnvInt("CREDIT_STRESS_YEAR", 2030),
+	}
+
+	if config.PaperSnapshotInterval <= 0 || config.PortfolioDriftInterval <= 0 {
+		return nil, fmt.Errorf("PAPER_SNAPSHOT_INTERVAL_MINUTES and PORTFOLIO_DRIFT_INTERVAL_MINUTES must be positive")
+	}
+	if config.FIXPollInterval <= 0 || config.FIXHeartBtInt <= 0 {
+		return nil, fmt.Errorf("FIX_POLL_INTERVAL_SECONDS and FIX_HEARTBEAT_SECONDS must be positive")
 	}
 
 	// Validate encryption key length
@@ -41,6 +199,24 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("ENCRYPTION_KEY must be 64 hex characters (32 bytes)")
 	}
 
+	if config.UpstreamPriorityReserve < 0 || config.UpstreamPriorityReserve >= 1 {
+		return nil, fmt.Errorf("UPSTREAM_PRIORITY_RESERVE must be in [0, 1)")
+	}
+
+	if config.WSSlowClientPolicy != "drop" && config.WSSlowClientPolicy != "disconnect" {
+		return nil, fmt.Errorf("WS_SLOW_CLIENT_POLICY must be drop or disconnect")
+	}
+
+	switch config.FIXMode {
+	case "", "dry-run":
+	case "session":
+		if config.FIXAddress == "" {
+			return nil, fmt.Errorf("FIX_ADDRESS is required when FIX_MODE=session")
+		}
+	default:
+		return nil, fmt.Errorf("FIX_MODE must be session, dry-run or empty")
+	}
+
 	// TLS is optional - Render provides TLS at the edge
 	// No need to enforce TLS at application level
 
@@ -61,3 +237,30 @@ func getEnvRequired(key string) string {
 	}
 	return value
 }
+
+func getEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getEnvList splits a comma-separated variable, dropping empty entries.
+func getEnvList(key, fallback string) []string {
+	var list []string
+	for _, item := range strings.Split(getEnv(key, fallback), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,307 @@
+// Package credit adjusts an obligor's internal rating and probability of
+// default for ESG and climate risk. A bank's overlay policy is a list of
+// rules, each moving the rating by a number of notches when a factor
+// crosses a threshold; the PD moves with the rating along a master scale.
+package credit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Factors the overlay rules test, with their ranges
+const (
+	// FactorESGScore is the overall ESG score, 0-10.
+	FactorESGScore = "esg_score"
+	// FactorEnvironmental, FactorSocial and FactorGovernance are the pillar
+	// scores, 0-10.
+	FactorEnvironmental = "environmental"
+	FactorSocial        = "social"
+	FactorGovernance    = "governance"
+	// FactorPhysicalRisk is the physical climate risk score of the
+	// obligor's assets, 0-100.
+	FactorPhysicalRisk = "physical_risk"
+	// FactorClimatePDChange is the rise in PD under a climate stress
+	// scenario, in basis points.
+	FactorClimatePDChange = "climate_pd_change_bps"
+	// FactorCarbonIntensity is scope 1 and 2 emissions per million of
+	// revenue, in tCO2e.
+	FactorCarbonIntensity = "carbon_intensity"
+)
+
+// factorRanges bounds each factor's values.
+var factorRanges = map[string][2]float64{
+	FactorESGScore:        {0, 10},
+	FactorEnvironmental:   {0, 10},
+	FactorSocial:          {0, 10},
+	FactorGovernance:      {0, 10},
+	FactorPhysicalRisk:    {0, 100},
+	FactorClimatePDChange: {-10000, 10000},
+	FactorCarbonIntensity: {0, 1e6},
+}
+
+// Comparisons of a factor with a rule's threshold
+const (
+	Below   = "lt"
+	AtMost  = "lte"
+	Above   = "gt"
+	AtLeast = "gte"
+)
+
+const (
+	MaxRules     = 50
+	MaxRuleNotch = 5
+
+	DefaultMaxDowngrade = 3
+	DefaultMaxUpgrade   = 1
+	// DefaultPDFloor is the Basel IRB floor on corporate PDs, 3 basis
+	// points
+	DefaultPDFloor = 0.0003
+)
+
+var (
+	ErrInvalidPolicy  = errors.New("invalid overlay policy")
+	ErrInvalidObligor = errors.New("invalid obligor")
+)
+
+// Grade is a rating on the master scale and its one-year PD.
+type Grade struct {
+	Rating string
+	PD     float64
+}
+
+// MasterScale is the internal rating scale, best first. Its PDs are
+// illustrative, near long-run corporate default rates; a grade covers the
+// PDs between the geometric means with its neighbours.
+var MasterScale = []Grade{
+	{"AAA", 0.0001}, {"AA+", 0.0002}, {"AA", 0.0003}, {"AA-", 0.0004},
+	{"A+", 0.0005}, {"A", 0.0007}, {"A-", 0.0009},
+	{"BBB+", 0.0013}, {"BBB", 0.0019}, {"BBB-", 0.0029},
+	{"BB+", 0.0045}, {"BB", 0.0070}, {"BB-", 0.0110},
+	{"B+", 0.0180}, {"B", 0.0290}, {"B-", 0.0470},
+	{"CCC+", 0.0800}, {"CCC", 0.1300}, {"CCC-", 0.2000},
+	{"CC", 0.3000}, {"C", 0.4500},
+}
+
+// lowestInvestmentGrade is the index of BBB- on the master scale.
+const lowestInvestmentGrade = 9
+
+// InvestmentGrade reports whether a rating is BBB- or better.
+func InvestmentGrade(rating string) bool {
+	i, ok := gradeIndex(rating)
+	return ok && i <= lowestInvestmentGrade
+}
+
+// RatingForPD returns the master scale grade a PD falls in.
+func RatingForPD(pd float64) string {
+	return MasterScale[pdIndex(pd)].Rating
+}
+
+func pdIndex(pd float64) int {
+	for i := 0; i < len(MasterScale)-1; i++ {
+		if pd <= math.Sqrt(MasterScale[i].PD*MasterScale[i+1].PD) {
+			return i
+		}
+	}
+	return len(MasterScale) - 1
+}
+
+func gradeIndex(rating string) (int, bool) {
+	rating = strings.ToUpper(strings.TrimSpace(rating))
+	for i, grade := range MasterScale {
+		if grade.Rating == rating {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Rule moves the rating by Notches, positive to downgrade, when Factor
+// compares with Threshold as Operator says. An obligor without the factor
+// is not moved.
+type Rule struct {
+	Name      string
+	Factor    string
+	Operator  string
+	Threshold float64
+	Notches   int
+}
+
+func (r Rule) matches(value float64) bool {
+	switch r.Operator {
+	case Below:
+		return value < r.Threshold
+	case AtMost:
+		return value <= r.Threshold
+	case Above:
+		return value > r.Threshold
+	default:
+		return value >= r.Threshold
+	}
+}
+
+// Policy is a bank's overlay rules. Every matching rule applies; their
+// notches add up and are capped at MaxDowngrade down and MaxUpgrade up.
+// Adjusted PDs are no lower than PDFloor.
+type Policy struct {
+	Rules        []Rule
+	MaxDowngrade int
+	MaxUpgrade   int
+	PDFloor      float64
+}
+
+// DefaultPolicy is the overlay applied for banks that have not set their
+// own. Weak ESG, governance and physical risk and a large rise in PD under
+// climate stress downgrade; a strong ESG score upgrades by a notch.
+func DefaultPolicy() Policy {
+	return Policy{
+		Rules: []Rule{
+			{Name: "Weak ESG score", Factor: FactorESGScore, Operator: Below, Threshold: 5, Notches: 1},
+			{Name: "Poor ESG score", Factor: FactorESGScore, Operator: Below, Threshold: 3, Notches: 1},
+			{Name: "Strong ESG score", Factor: FactorESGScore, Operator: AtLeast, Threshold: 8, Notches: -1},
+			{Name: "Weak governance", Factor: FactorGovernance, Operator: Below, Threshold: 3, Notches: 1},
+			{Name: "High physical climate risk", Factor: FactorPhysicalRisk, Operator: AtLeast, Threshold: 60, Notches: 1},
+			{Name: "Climate stress PD rise over 50bp", Factor: FactorClimatePDChange, Operator: AtLeast, Threshold: 50, Notches: 1},
+			{Name: "Climate stress PD rise over 200bp", Factor: FactorClimatePDChange, Operator: AtLeast, Threshold: 200, Notches: 1},
+		},
+		MaxDowngrade: DefaultMaxDowngrade,
+		MaxUpgrade:   DefaultMaxUpgrade,
+		PDFloor:      DefaultPDFloor,
+	}
+}
+
+// Validate checks a policy's rules and caps.
+func (p Policy) Validate() error {
+	switch {
+	case len(p.Rules) > MaxRules:
+		return fmt.Errorf("%w: at most %d rules", ErrInvalidPolicy, MaxRules)
+	case p.MaxDowngrade < 0 || p.MaxDowngrade >= len(MasterScale) || p.MaxUpgrade < 0 || p.MaxUpgrade >= len(MasterScale):
+		return fmt.Errorf("%w: notch caps must be from 0 to %d", ErrInvalidPolicy, len(MasterScale)-1)
+	case p.PDFloor < 0 || p.PDFloor > 0.01:
+		return fmt.Errorf("%w: PD floor must be from 0 to 0.01", ErrInvalidPolicy)
+	}
+	for _, rule := range p.Rules {
+		invalid := func(format string, args ...interface{}) error {
+			return fmt.Errorf("%w: rule %q: %s", ErrInvalidPolicy, rule.Name, fmt.Sprintf(format, args...))
+		}
+		if strings.TrimSpace(rule.Name) == "" {
+			return fmt.Errorf("%w: every rule needs a name", ErrInvalidPolicy)
+		}
+		bounds, ok := factorRanges[rule.Factor]
+		switch {
+		case !ok:
+			return invalid("unknown factor %q", rule.Factor)
+		case rule.Operator != Below && rule.Operator != AtMost && rule.Operator != Above && rule.Operator != AtLeast:
+			return invalid("operator must be %s, %s, %s or %s", Below, AtMost, Above, AtLeast)
+		case rule.Threshold < bounds[0] || rule.Threshold > bounds[1]:
+			return invalid("%s thresholds are from %g to %g", rule.Factor, bounds[0], bounds[1])
+		case rule.Notches == 0 || rule.Notches < -MaxRuleNotch || rule.Notches > MaxRuleNotch:
+			return invalid("notches must be from -%d to %d, and not 0", MaxRuleNotch, MaxRuleNotch)
+		}
+	}
+	return nil
+}
+
+// Obligor is a borrower's base internal rating or PD, or both, and the
+// ESG and climate factors known about it, keyed by factor name.
+type Obligor struct {
+	Name    string
+	Rating  string
+	PD      *float64
+	Factors map[string]float64
+}
+
+// Validate checks an obligor has a base rating or PD and known factors.
+func (o Obligor) Validate() error {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s: %s", ErrInvalidObligor, o.Name, fmt.Sprintf(format, args...))
+	}
+	if strings.TrimSpace(o.Name) == "" {
+		return fmt.Errorf("%w: a name is required", ErrInvalidObligor)
+	}
+	if o.Rating == "" && o.PD == nil {
+		return invalid("a base rating or PD is required")
+	}
+	if _, ok := gradeIndex(o.Rating); o.Rating != "" && !ok {
+		return invalid("rating %q is not on the master scale", o.Rating)
+	}
+	if o.PD != nil && (*o.PD <= 0 || *o.PD >= 1) {
+		return invalid("PD must be between 0 and 1")
+	}
+	for factor, value := range o.Factors {
+		bounds, ok := factorRanges[factor]
+		if !ok {
+			return invalid("unknown factor %q", factor)
+		}
+		if value < bounds[0] || value > bounds[1] {
+			return invalid("%s must be from %g to %g", factor, bounds[0], bounds[1])
+		}
+	}
+	return nil
+}
+
+// BasePD is the obligor's base PD, or its rating's without one. It is
+// false when the obligor has neither.
+func (o Obligor) BasePD() (float64, bool) {
+	if o.PD != nil {
+		return *o.PD, true
+	}
+	if i, ok := gradeIndex(o.Rating); ok {
+		return MasterScale[i].PD, true
+	}
+	return 0, false
+}
+
+// Adjustment is an obligor's rating and PD before and after the overlay.
+// RuleNotches adds up the notches of the rules applied; Notches is the
+// move made, after the policy's caps and the ends of the scale.
+type Adjustment struct {
+	Obligor        Obligor
+	BaseRating     string
+	BasePD         float64
+	AdjustedRating string
+	AdjustedPD     float64
+	RuleNotches    int
+	Notches        int
+	Applied        []Rule
+}
+
+// Adjust applies a policy to an obligor. Without a base rating, it is the
+// grade of the base PD; without a base PD, it is the rating's. The
+// adjusted PD is the base PD scaled by the ratio of the adjusted and base
+// grades' PDs, so an obligor keeps its place within its grade.
+func Adjust(obligor Obligor, policy Policy) (*Adjustment, error) {
+	if err := obligor.Validate(); err != nil {
+		return nil, err
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	base := 0
+	if obligor.Rating != "" {
+		base, _ = gradeIndex(obligor.Rating)
+	} else {
+		base = pdIndex(*obligor.PD)
+	}
+	adjustment := &Adjustment{Obligor: obligor, BaseRating: MasterScale[base].Rating, BasePD: MasterScale[base].PD}
+	if obligor.PD != nil {
+		adjustment.BasePD = *obligor.PD
+	}
+
+	for _, rule := range policy.Rules {
+		value, ok := obligor.Factors[rule.Factor]
+		if ok && rule.matches(value) {
+			adjustment.Applied = append(adjustment.Applied, rule)
+			adjustment.RuleNotches += rule.Notches
+		}
+	}
+	notches := max(-policy.MaxUpgrade, min(policy.MaxDowngrade, adjustment.RuleNotches))
+	adjusted := max(0, min(len(MasterScale)-1, base+notches))
+	adjustment.Notches = adjusted - base
+	adjustment.AdjustedRating = MasterScale[adjusted].Rating
+	pd := adjustment.BasePD * MasterScale[adjusted].PD / MasterScale[base].PD
+	adjustment.AdjustedPD = math.Min(1, math.Max(policy.PDFloor, pd))
+	return adjustment, nil
+}
@@ -5,6 +5,20 @@ type AnalyzeRequest struct {
 	BankID      string `json:"bank_id" validate:"omitempty,uuid"`
 	Mode        string `json:"mode" validate:"omitempty,oneof=auto manual"`
 	UserRole    string `json:"user_role,omitempty"`
+	// MarketRisk sets how the company's value at risk is measured
+	MarketRisk *MarketRiskRequest `json:"market_risk"`
+	// Credit is the company's base rating or PD, to adjust for ESG and
+	// climate risk under the bank's credit overlay
+	Credit *CreditBaseRequest `json:"credit"`
+}
+
+// MarketRiskRequest sets value at risk and expected shortfall. Confidence
+// defaults to 0.99, the horizon to 1 trading day and Monte Carlo to 10,000
+// scenarios.
+type MarketRiskRequest struct {
+	Confidence  float64 `json:"confidence" validate:"omitempty,min=0.9,max=0.999"`
+	HorizonDays int     `json:"horizon_days" validate:"omitempty,min=1,max=20"`
+	Simulations int     `json:"simulations" validate:"omitempty,min=1000,max=100000"`
 }
 
 type ComplianceAuditRequest struct {
@@ -17,4 +31,273 @@ type ComplianceAuditRequest struct {
 type PortfolioCompareRequest struct {
 	Companies     []string `json:"companies" validate:"required,min=2,max=10,dive,required,min=2,max=100"`
 	RiskTolerance float64  `json:"risk_tolerance" validate:"omitempty,min=0,max=1"`
+	// RiskFreeRate is annual, as a fraction, for the Sharpe ratio
+	RiskFreeRate float64                      `json:"risk_free_rate" validate:"omitempty,min=0,max=0.2"`
+	Attributes   map[string]CompanyAttributes `json:"attributes" validate:"omitempty,max=10,dive,keys,required,endkeys"`
+	Constraints  *PortfolioConstraints        `json:"constraints"`
+	// Benchmark names a benchmark definition in the market data to
+	// compare the optimal allocation with
+	Benchmark string `json:"benchmark" validate:"omitempty,max=20"`
+	// MarketRisk sets how the value at risk of each company and of the
+	// optimal allocation is measured
+	MarketRisk *MarketRiskRequest `json:"market_risk"`
+}
+
+// PortfolioFrontierRequest traces the efficient frontier for companies.
+// Points defaults to 20. ESGLevels of 2 or more also traces a frontier for
+// each of that many rising ESG-score floors.
+type PortfolioFrontierRequest struct {
+	Companies    []string                     `json:"companies" validate:"required,min=2,max=10,dive,required,min=2,max=100"`
+	Points       int                          `json:"points" validate:"omitempty,min=2,max=50"`
+	ESGLevels    int                          `json:"esg_levels" validate:"omitempty,min=2,max=10"`
+	RiskFreeRate float64                      `json:"risk_free_rate" validate:"omitempty,min=0,max=0.2"`
+	Attributes   map[string]CompanyAttributes `json:"attributes" validate:"omitempty,max=10,dive,keys,required,endkeys"`
+	Constraints  *PortfolioConstraints        `json:"constraints"`
+}
+
+// CompanyAttributes classify a company for mandate constraints. Carbon
+// intensity is in tCO2e per $m revenue.
+type CompanyAttributes struct {
+	Sector          string   `json:"sector" validate:"max=100"`
+	Country         string   `json:"country" validate:"max=100"`
+	CarbonIntensity *float64 `json:"carbon_intensity" validate:"omitempty,min=0"`
+}
+
+// PortfolioConstraints are mandate limits on the optimised allocation.
+// Weights are fractions of the portfolio. MinWeight and MaxWeight apply to
+// every company that is not excluded and has no WeightBounds of its own.
+// Sector and country caps need those attributes for every company, and
+// MaxCarbonIntensity (a weighted average) needs carbon_intensity.
+// MaxTurnover is one-way, ½·Σ|w − current|, against CurrentWeights.
+type PortfolioConstraints struct {
+	MinWeight          float64                 `json:"min_weight" validate:"omitempty,min=0,max=1"`
+	MaxWeight          float64                 `json:"max_weight" validate:"omitempty,gt=0,max=1"`
+	WeightBounds       map[string]WeightBounds `json:"weight_bounds" validate:"omitempty,max=50,dive,keys,required,endkeys"`
+	SectorCaps         map[string]float64      `json:"sector_caps" validate:"omitempty,max=50,dive,keys,required,endkeys,min=0,max=1"`
+	CountryCaps        map[string]float64      `json:"country_caps" validate:"omitempty,max=50,dive,keys,required,endkeys,min=0,max=1"`
+	MinESGScore        float64                 `json:"min_esg_score" validate:"omitempty,min=0,max=10"`
+	MaxCarbonIntensity *float64                `json:"max_carbon_intensity" validate:"omitempty,min=0"`
+	Exclude            []string                `json:"exclude" validate:"omitempty,max=50,dive,required"`
+	CurrentWeights     map[string]float64      `json:"current_weights" validate:"omitempty,max=100,dive,keys,required,endkeys,min=0,max=1"`
+	MaxTurnover        *float64                `json:"max_turnover" validate:"omitempty,min=0,max=1"`
+}
+
+type WeightBounds struct {
+	Min *float64 `json:"min" validate:"omitempty,min=0,max=1"`
+	Max *float64 `json:"max" validate:"omitempty,min=0,max=1"`
+}
+
+// BatchAnalyzeRequest screens a watchlist or loan book; CSV uploads are
+// converted to this form.
+type BatchAnalyzeRequest struct {
+	Companies []string `json:"companies" validate:"required,min=1,max=1000,dive,required,min=2,max=100"`
+}
+
+// BacktestRequest replays the trading signal over file-based market data.
+// Costs are in basis points; trades and the daily equity curve are only
+// returned on request.
+type BacktestRequest struct {
+	Symbols        []string `json:"symbols" validate:"required,min=1,max=50,dive,required,min=1,max=20"`
+	Benchmark      string   `json:"benchmark" validate:"omitempty,max=20"`
+	StartDate      string   `json:"start_date" validate:"required,datetime=2006-01-02"`
+	EndDate        string   `json:"end_date" validate:"required,datetime=2006-01-02"`
+	InitialCapital float64  `json:"initial_capital" validate:"omitempty,gt=0"`
+	CostBps        float64  `json:"cost_bps" validate:"omitempty,min=0,max=1000"`
+	SlippageBps    float64  `json:"slippage_bps" validate:"omitempty,min=0,max=1000"`
+	RiskFreeRate   float64  `json:"risk_free_rate" validate:"omitempty,min=0,max=1"`
+	IncludeTrades  bool     `json:"include_trades"`
+	IncludeEquity  bool     `json:"include_equity"`
+}
+
+// SignalDecisionRequest approves, rejects or cancels a trade signal.
+type SignalDecisionRequest struct {
+	Reason string `json:"reason" validate:"max=500"`
+}
+
+// CreatePaperPortfolioRequest opens a simulated portfolio. PositionSize is
+// the fraction of equity each BUY invests; it defaults to 0.1, InitialCash
+// to 1,000,000 and FollowSignals to true.
+type CreatePaperPortfolioRequest struct {
+	Name          string  `json:"name" validate:"required,min=1,max=100"`
+	InitialCash   float64 `json:"initial_cash" validate:"omitempty,gt=0,max=1000000000000"`
+	PositionSize  float64 `json:"position_size" validate:"omitempty,gt=0,max=1"`
+	CostBps       float64 `json:"cost_bps" validate:"omitempty,min=0,max=1000"`
+	SlippageBps   float64 `json:"slippage_bps" validate:"omitempty,min=0,max=1000"`
+	FollowSignals *bool   `json:"follow_signals"`
+	Shared        bool    `json:"shared"`
+}
+
+type UpdatePaperPortfolioRequest struct {
+	FollowSignals *bool `json:"follow_signals" validate:"required"`
+}
+
+// PaperOrderRequest trades an approved signal in a paper portfolio.
+type PaperOrderRequest struct {
+	SignalID string `json:"signal_id" validate:"required,uuid"`
+}
+
+// PortfolioRequest creates or replaces a stored portfolio. Target weights
+// must add up to 1. The mandate takes the limits a comparison does, naming
+// holdings by symbol, apart from current_weights, which the holdings give.
+// DriftThreshold defaults to 0.05; MinESGScore, when set, alerts when the
+// portfolio's ESG score falls below it.
+type PortfolioRequest struct {
+	Name           string                `json:"name" validate:"required,min=1,max=100"`
+	Benchmark      string                `json:"benchmark" validate:"omitempty,max=20"`
+	Holdings       []HoldingRequest      `json:"holdings" validate:"required,min=1,max=50,dive"`
+	Mandate        *PortfolioConstraints `json:"mandate"`
+	DriftThreshold float64               `json:"drift_threshold" validate:"omitempty,gt=0,max=1"`
+	MinESGScore    *float64              `json:"min_esg_score" validate:"omitempty,min=0,max=10"`
+}
+
+// ExposuresRequest replaces a portfolio's loans and investments for
+// financed emissions reporting. Amounts are in the reporting currency and
+// emissions in tCO2e.
+type ExposuresRequest struct {
+	Exposures []ExposureRequest `json:"exposures" validate:"max=500,dive"`
+}
+
+// ExposureRequest is one loan or investment. Listed equity needs EVIC;
+// bonds and loans need EVIC or, for companies without one, total equity
+// plus debt. Emissions are the company's scope 1 and 2, with
+// emissions_source verified, reported or physical; without them they are
+// estimated from revenue or the attribution denominator.
+type ExposureRequest struct {
+	Counterparty      string   `json:"counterparty" validate:"required,max=200" mask:"counterparty"`
+	Symbol            string   `json:"symbol" validate:"omitempty,max=20"`
+	AssetClass        string   `json:"asset_class" validate:"required,oneof=listed_equity corporate_bond business_loan"`
+	Sector            string   `json:"sector" validate:"max=100"`
+	OutstandingAmount float64  `json:"outstanding_amount" validate:"gt=0"`
+	EVIC              *float64 `json:"evic" validate:"omitempty,gt=0"`
+	TotalEquityDebt   *float64 `json:"total_equity_debt" validate:"omitempty,gt=0"`
+	Revenue           *float64 `json:"revenue" validate:"omitempty,gt=0" mask:"revenue"`
+	Emissions         *float64 `json:"emissions" validate:"omitempty,min=0" mask:"emissions"`
+	Scope3Emissions   *float64 `json:"scope3_emissions" validate:"omitempty,min=0" mask:"emissions"`
+	EmissionsSource   string   `json:"emissions_source" validate:"omitempty,oneof=verified reported physical"`
+}
+
+// ClimateStressRequest runs companies through climate scenarios, or
+// through every scenario when none are named. Amounts are in one reporting
+// currency and emissions, scope 1 and 2, in tCO2e.
+type ClimateStressRequest struct {
+	Scenarios    []string                `json:"scenarios" validate:"omitempty,max=10,dive,required,max=20"`
+	DiscountRate float64                 `json:"discount_rate" validate:"omitempty,gt=0,max=0.3"`
+	Companies    []ClimateCompanyRequest `json:"companies" validate:"required,min=1,max=100,dive"`
+}
+
+// ClimateCompanyRequest is a company's financials today. Emissions are
+// estimated from the sector when omitted. Exposure is what the portfolio
+// holds in the company, as equity or a loan.
+type ClimateCompanyRequest struct {
+	Name            string   `json:"name" validate:"required,max=200"`
+	Sector          string   `json:"sector" validate:"max=100"`
+	Revenue         float64  `json:"revenue" validate:"gt=0"`
+	EBITDA          float64  `json:"ebitda" validate:"gt=0"`
+	Emissions       *float64 `json:"emissions" validate:"omitempty,min=0"`
+	EnterpriseValue float64  `json:"enterprise_value" validate:"gt=0"`
+	TotalDebt       float64  `json:"total_debt" validate:"min=0"`
+	PD              *float64 `json:"pd" validate:"omitempty,gt=0,lt=1"`
+	AssetVolatility *float64 `json:"asset_volatility" validate:"omitempty,gt=0,max=2"`
+	Exposure        float64  `json:"exposure" validate:"min=0"`
+	AssetClass      string   `json:"asset_class" validate:"omitempty,oneof=equity loan"`
+	LGD             *float64 `json:"lgd" validate:"omitempty,min=0,max=1"`
+}
+
+// LocationsRequest replaces a company's asset and collateral locations for
+// physical climate risk scoring.
+type LocationsRequest struct {
+	Locations []LocationRequest `json:"locations" validate:"max=1000,dive"`
+}
+
+// LocationRequest is a site, by latitude and longitude or by ISO 3166
+// region such as IN or IN-MH. Coordinates are scored from the hazard grid,
+// falling back to the region. Asset value, in the reporting currency,
+// weights the site in the company's score.
+type LocationRequest struct {
+	Name       string   `json:"name" validate:"required,max=200"`
+	Latitude   *float64 `json:"latitude,omitempty" validate:"omitempty,min=-90,max=90"`
+	Longitude  *float64 `json:"longitude,omitempty" validate:"omitempty,min=-180,max=180"`
+	Region     string   `json:"region,omitempty" validate:"omitempty,max=10"`
+	AssetValue float64  `json:"asset_value" validate:"gt=0"`
+	Kind       string   `json:"kind,omitempty" validate:"omitempty,oneof=asset collateral"`
+}
+
+// CreditPolicyRequest replaces a bank's ESG and climate credit overlay.
+// Every rule whose factor compares with its threshold applies; notches,
+// positive to downgrade, add up and are capped by max_downgrade and
+// max_upgrade. Adjusted PDs are floored at pd_floor.
+type CreditPolicyRequest struct {
+	Rules        []CreditRuleRequest `json:"rules" validate:"max=50,dive"`
+	MaxDowngrade int                 `json:"max_downgrade" validate:"min=0,max=20"`
+	MaxUpgrade   int                 `json:"max_upgrade" validate:"min=0,max=20"`
+	PDFloor      float64             `json:"pd_floor" validate:"min=0,max=0.01"`
+}
+
+type CreditRuleRequest struct {
+	Name      string  `json:"name" validate:"required,max=100"`
+	Factor    string  `json:"factor" validate:"required,oneof=esg_score environmental social governance physical_risk climate_pd_change_bps carbon_intensity"`
+	Operator  string  `json:"operator" validate:"required,oneof=lt lte gt gte"`
+	Threshold float64 `json:"threshold"`
+	Notches   int     `json:"notches" validate:"ne=0,min=-5,max=5"`
+}
+
+// CreditAdjustRequest adjusts obligors' ratings and PDs under the bank's
+// overlay policy.
+type CreditAdjustRequest struct {
+	Obligors []CreditObligorRequest `json:"obligors" validate:"required,min=1,max=100,dive"`
+}
+
+// CreditBaseRequest is an obligor's base internal rating on the master
+// scale, or one-year PD, or both. Financials, when given, climate stress
+// test the obligor.
+type CreditBaseRequest struct {
+	Rating     string                   `json:"rating" validate:"omitempty,max=4"`
+	PD         *float64                 `json:"pd" validate:"omitempty,gt=0,lt=1"`
+	Financials *CreditFinancialsRequest `json:"financials"`
+}
+
+// CreditFinancialsRequest is an obligor's financials today, as the climate
+// stress test takes them. Emissions are estimated from the sector when
+// omitted.
+type CreditFinancialsRequest struct {
+	Sector          string   `json:"sector" validate:"max=100"`
+	Revenue         float64  `json:"revenue" validate:"gt=0"`
+	EBITDA          float64  `json:"ebitda" validate:"gt=0"`
+	Emissions       *float64 `json:"emissions" validate:"omitempty,min=0"`
+	EnterpriseValue float64  `json:"enterprise_value" validate:"gt=0"`
+	TotalDebt       float64  `json:"total_debt" validate:"min=0"`
+	AssetVolatility *float64 `json:"asset_volatility" validate:"omitempty,gt=0,max=2"`
+}
+
+// CreditObligorRequest is an obligor and the ESG and climate factors known
+// about it, by factor name. A missing physical_risk is taken from the
+// obligor's stored asset locations, and a missing climate_pd_change_bps
+// from the climate stress test of its financials.
+type CreditObligorRequest struct {
+	Name string `json:"name" validate:"required,max=200"`
+	CreditBaseRequest
+	Factors map[string]float64 `json:"factors" validate:"max=7"`
+}
+
+type HoldingRequest struct {
+	Symbol       string  `json:"symbol" validate:"required,max=20"`
+	Quantity     float64 `json:"quantity" validate:"min=0"`
+	TargetWeight float64 `json:"target_weight" validate:"min=0,max=1"`
+	CompanyAttributes
+}
+
+// RebalanceRequest may add cash to invest while rebalancing.
+type RebalanceRequest struct {
+	Cash float64 `json:"cash" validate:"omitempty,min=0,max=1000000000000"`
+}
+
+type IssueAPIKeyRequest struct {
+	Name             string   `json:"name" validate:"required,min=2,max=100"`
+	Scopes           []string `json:"scopes" validate:"required,min=1,dive,required"`
+	ExpiresInDays    int      `json:"expires_in_days" validate:"omitempty,min=1,max=730"`
+	RequireSignature bool     `json:"require_signature"`
+}
+
+type RotateAPIKeyRequest struct {
+	GracePeriodHours int `json:"grace_period_hours" validate:"omitempty,min=0,max=720"`
 }
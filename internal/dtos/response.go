@@ -3,25 +3,33 @@ package dtos
 import "time"
 
 type AnalyzeResponse struct {
-	ESGScore              string                   `json:"esg_score"`
-	RiskAction            string                   `json:"risk_action"`
-	RiskReasons           []string                 `json:"risk_reasons,omitempty"`
-	TradingSignal         TradingSignal            `json:"trading_signal"`
-	HistoricalReturns     []map[string]interface{} `json:"historical_returns,omitempty"`
-	InvestmentProjections []map[string]interface{} `json:"investment_projections,omitempty"`
-	AuditHash             string                   `json:"audit_hash"`
-	ProcessingTimeMs      int64                    `json:"processing_time_ms"`
-	MaskedData            bool                     `json:"masked_data"`
-	Timestamp             time.Time                `json:"timestamp"`
+	AnalysisID            string                    `json:"analysis_id,omitempty"`
+	ESGScore              string                    `json:"esg_score"`
+	RiskAction            string                    `json:"risk_action"`
+	RiskReasons           []string                  `json:"risk_reasons,omitempty"`
+	TradingSignal         TradingSignal             `json:"trading_signal"`
+	HistoricalReturns     []map[string]interface{}  `json:"historical_returns,omitempty"`
+	InvestmentProjections []map[string]interface{}  `json:"investment_projections,omitempty"`
+	MarketRisk            *MarketRiskResponse       `json:"market_risk,omitempty"`
+	PhysicalRisk          *PhysicalRiskSummary      `json:"physical_risk,omitempty"`
+	CreditRisk            *CreditAdjustmentResponse `json:"credit_risk,omitempty"`
+	AuditHash             string                    `json:"audit_hash"`
+	ProcessingTimeMs      int64                     `json:"processing_time_ms"`
+	MaskedData            bool                      `json:"masked_data"`
+	Timestamp             time.Time                 `json:"timestamp"`
 }
 
 type TradingSignal struct {
 	Action       string  `json:"action"`
 	Symbol       string  `json:"symbol"`
 	CurrentPrice string  `json:"current_price,omitempty"`
-	TargetPrice  string  `json:"target_price"`
+	TargetPrice  string  `json:"target_price" mask:"target_price"`
 	PriceChange  string  `json:"price_change,omitempty"`
 	Confidence   float64 `json:"confidence"`
+	// SignalID and SignalStatus are set when the signal was stored for
+	// approval (BUY and SELL signals of bank analyses)
+	SignalID     string `json:"signal_id,omitempty"`
+	SignalStatus string `json:"signal_status,omitempty"`
 }
 
 type HealthResponse struct {
@@ -37,26 +45,674 @@ type ErrorResponse struct {
 }
 
 type CompanyComparison struct {
-	CompanyName     string        `json:"company_name"`
-	ESGScore        float64       `json:"esg_score"`
-	Environmental   float64       `json:"environmental"`
-	Social          float64       `json:"social"`
-	Governance      float64       `json:"governance"`
-	RiskLevel       string        `json:"risk_level"`
-	RiskScore       float64       `json:"risk_score"`
-	TradingSignal   TradingSignal `json:"trading_signal"`
-	ComplianceScore float64       `json:"compliance_score"`
-	RegulatoryRisk  float64       `json:"regulatory_risk"`
+	CompanyName     string               `json:"company_name" mask:"counterparty"`
+	ESGScore        float64              `json:"esg_score"`
+	Environmental   float64              `json:"environmental"`
+	Social          float64              `json:"social"`
+	Governance      float64              `json:"governance"`
+	RiskLevel       string               `json:"risk_level"`
+	RiskScore       float64              `json:"risk_score"`
+	TradingSignal   TradingSignal        `json:"trading_signal"`
+	ComplianceScore float64              `json:"compliance_score"`
+	RegulatoryRisk  float64              `json:"regulatory_risk"`
+	MarketRisk      *MarketRiskResponse  `json:"market_risk,omitempty"`
+	PhysicalRisk    *PhysicalRiskSummary `json:"physical_risk,omitempty"`
+}
+
+// MarketRiskResponse is value at risk and expected shortfall over
+// horizon_days at confidence, from observations days of returns. Losses
+// and the annual volatility are percentages of the value held. Historical
+// figures are left out when the history is too short for the confidence.
+type MarketRiskResponse struct {
+	Confidence   float64           `json:"confidence"`
+	HorizonDays  int               `json:"horizon_days"`
+	Observations int               `json:"observations"`
+	Volatility   float64           `json:"volatility"`
+	Historical   *TailRiskResponse `json:"historical,omitempty"`
+	Parametric   TailRiskResponse  `json:"parametric"`
+	MonteCarlo   TailRiskResponse  `json:"monte_carlo"`
+}
+
+type TailRiskResponse struct {
+	VaR               float64 `json:"var"`
+	ExpectedShortfall float64 `json:"expected_shortfall"`
 }
 
 type PortfolioCompareResponse struct {
-	Companies         []CompanyComparison `json:"companies"`
-	OptimalAllocation []float64           `json:"optimal_allocation"`
-	PortfolioESGScore float64             `json:"portfolio_esg_score"`
-	PortfolioRisk     float64             `json:"portfolio_risk"`
-	ExpectedReturn    float64             `json:"expected_return"`
-	BestESGCompany    string              `json:"best_esg_company"`
-	LowestRiskCompany string              `json:"lowest_risk_company"`
+	AnalysisID         string              `json:"analysis_id,omitempty"`
+	Companies          []CompanyComparison `json:"companies"`
+	OptimalAllocation  []float64           `json:"optimal_allocation"`
+	PortfolioESGScore  float64             `json:"portfolio_esg_score"`
+	PortfolioRisk      float64             `json:"portfolio_risk"`
+	ExpectedReturn     float64             `json:"expected_return"`
+	SharpeRatio        float64             `json:"sharpe_ratio"`
+	RiskContributions  []RiskContribution  `json:"risk_contributions,omitempty"`
+	CovarianceSource   string              `json:"covariance_source,omitempty"`
+	BindingConstraints []string            `json:"binding_constraints,omitempty"`
+	// BenchmarkAnalytics compares the optimal allocation with the
+	// requested benchmark
+	BenchmarkAnalytics *BenchmarkAnalyticsResponse `json:"benchmark_analytics,omitempty"`
+	// MarketRisk is the optimal allocation's value at risk
+	MarketRisk        *MarketRiskResponse `json:"market_risk,omitempty"`
+	BestESGCompany    string              `json:"best_esg_company" mask:"counterparty"`
+	LowestRiskCompany string              `json:"lowest_risk_company" mask:"counterparty"`
 	ProcessingTimeMs  int64               `json:"processing_time_ms"`
+	MaskedData        bool                `json:"masked_data"`
 	Timestamp         time.Time           `json:"timestamp"`
 }
+
+// PortfolioFrontierResponse is the efficient frontier, ordered from the
+// minimum-variance portfolio to the highest-return one. Weights in each
+// point follow Companies. Returns and risk are annual percentages.
+type PortfolioFrontierResponse struct {
+	AnalysisID       string            `json:"analysis_id,omitempty"`
+	Companies        []FrontierCompany `json:"companies"`
+	Points           []FrontierPoint   `json:"points"`
+	MaxSharpeIndex   int               `json:"max_sharpe_index"`
+	Surface          []FrontierCurve   `json:"surface,omitempty"`
+	CovarianceSource string            `json:"covariance_source"`
+	SkippedCompanies []string          `json:"skipped_companies,omitempty" mask:"counterparty"`
+	ProcessingTimeMs int64             `json:"processing_time_ms"`
+	MaskedData       bool              `json:"masked_data"`
+	Timestamp        time.Time         `json:"timestamp"`
+}
+
+// FrontierCompany is an asset on the frontier and the inputs used for it.
+type FrontierCompany struct {
+	CompanyName    string  `json:"company_name" mask:"counterparty"`
+	Symbol         string  `json:"symbol"`
+	ESGScore       float64 `json:"esg_score"`
+	ExpectedReturn float64 `json:"expected_return"`
+}
+
+type FrontierPoint struct {
+	Weights        []float64 `json:"weights"`
+	ExpectedReturn float64   `json:"expected_return"`
+	PortfolioRisk  float64   `json:"portfolio_risk"`
+	SharpeRatio    float64   `json:"sharpe_ratio"`
+	ESGScore       float64   `json:"esg_score"`
+}
+
+// FrontierCurve is the frontier among portfolios with an ESG score of at
+// least MinESGScore; the curves together form the ESG-return-risk surface.
+type FrontierCurve struct {
+	MinESGScore float64         `json:"min_esg_score"`
+	Points      []FrontierPoint `json:"points"`
+}
+
+// RiskContribution breaks the optimised portfolio's volatility down by
+// holding. MarginalRisk is the change in volatility per unit of weight;
+// Contribution is the holding's share of volatility, summing to 1.
+type RiskContribution struct {
+	CompanyName  string  `json:"company_name" mask:"counterparty"`
+	Weight       float64 `json:"weight"`
+	MarginalRisk float64 `json:"marginal_risk"`
+	Contribution float64 `json:"contribution"`
+}
+
+type APIKeyResponse struct {
+	ID               string     `json:"id"`
+	BankID           string     `json:"bank_id"`
+	Name             string     `json:"name"`
+	KeyPrefix        string     `json:"key_prefix"`
+	Scopes           []string   `json:"scopes"`
+	RequireSignature bool       `json:"require_signature"`
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt       *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+	RotatedFrom      string     `json:"rotated_from,omitempty"`
+	CreatedBy        string     `json:"created_by,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// IssuedAPIKeyResponse is the only response that ever contains the plaintext
+// key and signing secret.
+type IssuedAPIKeyResponse struct {
+	APIKeyResponse
+	APIKey        string `json:"api_key"`
+	SigningSecret string `json:"signing_secret"`
+}
+
+// SignalResponse is a stored trade signal; History is only included when a
+// single signal is fetched.
+type SignalResponse struct {
+	ID           string                `json:"id"`
+	Company      string                `json:"company,omitempty" mask:"counterparty"`
+	AnalysisID   string                `json:"analysis_id,omitempty"`
+	Symbol       string                `json:"symbol"`
+	Action       string                `json:"action"`
+	CurrentPrice float64               `json:"current_price"`
+	TargetPrice  float64               `json:"target_price" mask:"target_price"`
+	Confidence   float64               `json:"confidence"`
+	ESGScore     float64               `json:"esg_score"`
+	RiskAction   string                `json:"risk_action,omitempty"`
+	Reasoning    string                `json:"reasoning,omitempty"`
+	Status       string                `json:"status"`
+	StatusReason string                `json:"status_reason,omitempty"`
+	DecidedBy    string                `json:"decided_by,omitempty"`
+	DecidedRole  string                `json:"decided_role,omitempty"`
+	DecidedAt    *time.Time            `json:"decided_at,omitempty"`
+	ExpiresAt    *time.Time            `json:"expires_at,omitempty"`
+	ExecutedAt   *time.Time            `json:"executed_at,omitempty"`
+	CreatedAt    time.Time             `json:"created_at"`
+	UpdatedAt    time.Time             `json:"updated_at"`
+	History      []SignalEventResponse `json:"history,omitempty"`
+	MaskedData   bool                  `json:"masked_data"`
+}
+
+type SignalEventResponse struct {
+	FromStatus string    `json:"from_status,omitempty"`
+	ToStatus   string    `json:"to_status"`
+	Actor      string    `json:"actor"`
+	ActorRole  string    `json:"actor_role,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type PaperPortfolioResponse struct {
+	ID            string                  `json:"id"`
+	Name          string                  `json:"name"`
+	Owner         string                  `json:"owner,omitempty"`
+	InitialCash   float64                 `json:"initial_cash"`
+	Cash          float64                 `json:"cash"`
+	PositionSize  float64                 `json:"position_size"`
+	CostBps       float64                 `json:"cost_bps"`
+	SlippageBps   float64                 `json:"slippage_bps"`
+	FollowSignals bool                    `json:"follow_signals"`
+	CreatedAt     time.Time               `json:"created_at"`
+	Valuation     *PaperValuationResponse `json:"valuation,omitempty"`
+}
+
+// PaperValuationResponse marks a paper portfolio to the latest quotes.
+// ESGScore is the value-weighted ESG score of the holdings; the leader and
+// laggard weights are the shares of equity in names scoring at least 7 and
+// at most 3.5.
+type PaperValuationResponse struct {
+	MarketValue      float64                 `json:"market_value"`
+	Equity           float64                 `json:"equity"`
+	TotalReturn      float64                 `json:"total_return"`
+	ESGScore         *float64                `json:"esg_score,omitempty"`
+	ESGLeaderWeight  float64                 `json:"esg_leader_weight"`
+	ESGLaggardWeight float64                 `json:"esg_laggard_weight"`
+	Positions        []PaperPositionResponse `json:"positions"`
+}
+
+type PaperPositionResponse struct {
+	Symbol        string    `json:"symbol"`
+	Quantity      float64   `json:"quantity"`
+	CostBasis     float64   `json:"cost_basis"`
+	Quote         float64   `json:"quote,omitempty"`
+	QuoteDate     string    `json:"quote_date,omitempty"`
+	MarketValue   float64   `json:"market_value"`
+	UnrealizedPnL float64   `json:"unrealized_pnl"`
+	Weight        float64   `json:"weight"`
+	ESGScore      float64   `json:"esg_score"`
+	OpenedAt      time.Time `json:"opened_at"`
+}
+
+type PaperOrderResponse struct {
+	ID          string    `json:"id"`
+	SignalID    string    `json:"signal_id,omitempty"`
+	Symbol      string    `json:"symbol"`
+	Side        string    `json:"side"`
+	Status      string    `json:"status"`
+	Reason      string    `json:"reason,omitempty"`
+	Quantity    float64   `json:"quantity"`
+	Quote       float64   `json:"quote,omitempty"`
+	QuoteDate   string    `json:"quote_date,omitempty"`
+	Price       float64   `json:"price,omitempty"`
+	Fees        float64   `json:"fees"`
+	RealizedPnL *float64  `json:"realized_pnl,omitempty"`
+	CreatedBy   string    `json:"created_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// PaperPerformanceResponse is a paper portfolio's daily report.
+type PaperPerformanceResponse struct {
+	PortfolioID string                  `json:"portfolio_id"`
+	Days        int                     `json:"days"`
+	Performance interface{}             `json:"performance"`
+	Daily       []PaperSnapshotResponse `json:"daily"`
+}
+
+type PaperSnapshotResponse struct {
+	Date             string   `json:"date"`
+	Cash             float64  `json:"cash"`
+	MarketValue      float64  `json:"market_value"`
+	Equity           float64  `json:"equity"`
+	DailyReturn      float64  `json:"daily_return"`
+	ESGScore         *float64 `json:"esg_score,omitempty"`
+	ESGLeaderWeight  float64  `json:"esg_leader_weight"`
+	ESGLaggardWeight float64  `json:"esg_laggard_weight"`
+}
+
+type PortfolioResponse struct {
+	ID             string                `json:"id"`
+	Name           string                `json:"name"`
+	Benchmark      string                `json:"benchmark,omitempty"`
+	Holdings       []HoldingResponse     `json:"holdings"`
+	Mandate        *PortfolioConstraints `json:"mandate,omitempty"`
+	DriftThreshold float64               `json:"drift_threshold"`
+	MinESGScore    *float64              `json:"min_esg_score,omitempty"`
+	// DriftBreaches are the breaches found by the last scheduled check
+	DriftBreaches  []string   `json:"drift_breaches"`
+	DriftCheckedAt *time.Time `json:"drift_checked_at,omitempty"`
+	CreatedBy      string     `json:"created_by,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+type HoldingResponse struct {
+	Symbol          string   `json:"symbol"`
+	Quantity        float64  `json:"quantity"`
+	TargetWeight    float64  `json:"target_weight"`
+	Sector          string   `json:"sector,omitempty"`
+	Country         string   `json:"country,omitempty"`
+	CarbonIntensity *float64 `json:"carbon_intensity,omitempty"`
+}
+
+// PortfolioDriftResponse compares a portfolio, marked to the latest
+// quotes, with its targets. ESGScore is value-weighted over the holdings
+// with a score, which make up ESGCoverage of the market value.
+type PortfolioDriftResponse struct {
+	PortfolioID    string                 `json:"portfolio_id"`
+	MarketValue    float64                `json:"market_value"`
+	ESGScore       *float64               `json:"esg_score,omitempty"`
+	ESGCoverage    float64                `json:"esg_coverage"`
+	MaxDrift       float64                `json:"max_drift"`
+	DriftThreshold float64                `json:"drift_threshold"`
+	MinESGScore    *float64               `json:"min_esg_score,omitempty"`
+	Holdings       []HoldingValueResponse `json:"holdings"`
+	Breaches       []DriftBreachResponse  `json:"breaches"`
+	CheckedAt      time.Time              `json:"checked_at"`
+}
+
+type HoldingValueResponse struct {
+	Symbol       string   `json:"symbol"`
+	Quantity     float64  `json:"quantity"`
+	Quote        float64  `json:"quote"`
+	QuoteDate    string   `json:"quote_date"`
+	MarketValue  float64  `json:"market_value"`
+	Weight       float64  `json:"weight"`
+	TargetWeight float64  `json:"target_weight"`
+	Drift        float64  `json:"drift"`
+	ESGScore     *float64 `json:"esg_score,omitempty"`
+}
+
+// DriftBreachResponse is a weight that drifted by more than the limit, or
+// an ESG score below it.
+type DriftBreachResponse struct {
+	Kind   string  `json:"kind"`
+	Symbol string  `json:"symbol,omitempty"`
+	Value  float64 `json:"value"`
+	Limit  float64 `json:"limit"`
+}
+
+// RebalanceProposalResponse lists the trades that bring a portfolio as
+// close to its targets as the mandate allows. Tracking errors are annual
+// percentages against the targets; turnover is one-way.
+type RebalanceProposalResponse struct {
+	PortfolioID          string                  `json:"portfolio_id"`
+	MarketValue          float64                 `json:"market_value"`
+	Cash                 float64                 `json:"cash"`
+	Holdings             []RebalanceLineResponse `json:"holdings"`
+	Trades               []ProposedTradeResponse `json:"trades"`
+	Turnover             float64                 `json:"turnover"`
+	CurrentTrackingError float64                 `json:"current_tracking_error"`
+	TrackingError        float64                 `json:"tracking_error"`
+	CurrentESGScore      float64                 `json:"current_esg_score"`
+	ESGScore             float64                 `json:"esg_score"`
+	CovarianceSource     string                  `json:"covariance_source"`
+	BindingConstraints   []string                `json:"binding_constraints,omitempty"`
+	Timestamp            time.Time               `json:"timestamp"`
+}
+
+type RebalanceLineResponse struct {
+	Symbol           string  `json:"symbol"`
+	Quote            float64 `json:"quote"`
+	Quantity         float64 `json:"quantity"`
+	ProposedQuantity float64 `json:"proposed_quantity"`
+	CurrentWeight    float64 `json:"current_weight"`
+	TargetWeight     float64 `json:"target_weight"`
+	ProposedWeight   float64 `json:"proposed_weight"`
+}
+
+type ProposedTradeResponse struct {
+	Symbol       string  `json:"symbol"`
+	Side         string  `json:"side"`
+	Quantity     float64 `json:"quantity"`
+	Notional     float64 `json:"notional"`
+	WeightChange float64 `json:"weight_change"`
+}
+
+// BenchmarkAnalyticsResponse compares a portfolio with a benchmark.
+// TrackingError is ex ante, as an annual percentage. Each side's ESG score
+// and carbon intensity (tCO2e/$m revenue) are weighted averages over the
+// positions that have one, which make up its coverage; active values are
+// the portfolio's less the benchmark's. SectorAttribution splits the
+// active ESG score by sector.
+type BenchmarkAnalyticsResponse struct {
+	Benchmark                string                      `json:"benchmark"`
+	AsOf                     time.Time                   `json:"as_of"`
+	TrackingError            float64                     `json:"tracking_error"`
+	ESGScore                 *float64                    `json:"esg_score"`
+	BenchmarkESGScore        *float64                    `json:"benchmark_esg_score"`
+	ActiveESGScore           *float64                    `json:"active_esg_score"`
+	ESGCoverage              float64                     `json:"esg_coverage"`
+	BenchmarkESGCoverage     float64                     `json:"benchmark_esg_coverage"`
+	CarbonIntensity          *float64                    `json:"carbon_intensity"`
+	BenchmarkCarbonIntensity *float64                    `json:"benchmark_carbon_intensity"`
+	ActiveCarbonIntensity    *float64                    `json:"active_carbon_intensity"`
+	CarbonCoverage           float64                     `json:"carbon_coverage"`
+	BenchmarkCarbonCoverage  float64                     `json:"benchmark_carbon_coverage"`
+	ActiveWeights            []ActiveWeightResponse      `json:"active_weights"`
+	SectorAttribution        []SectorAttributionResponse `json:"sector_attribution"`
+	CovarianceSource         string                      `json:"covariance_source"`
+}
+
+type ActiveWeightResponse struct {
+	Symbol          string  `json:"symbol"`
+	Weight          float64 `json:"weight"`
+	BenchmarkWeight float64 `json:"benchmark_weight"`
+	ActiveWeight    float64 `json:"active_weight"`
+}
+
+// SectorAttributionResponse is a sector's part in the active ESG score:
+// Allocation from over- or underweighting the sector, Selection from the
+// scores of the holdings within it. Weights and scores cover positions
+// with an ESG score; a side with no weight in the sector has no score.
+type SectorAttributionResponse struct {
+	Sector            string   `json:"sector"`
+	Weight            float64  `json:"weight"`
+	BenchmarkWeight   float64  `json:"benchmark_weight"`
+	ESGScore          *float64 `json:"esg_score"`
+	BenchmarkESGScore *float64 `json:"benchmark_esg_score"`
+	Allocation        float64  `json:"allocation"`
+	Selection         float64  `json:"selection"`
+	Total             float64  `json:"total"`
+}
+
+// PortfolioAnalyticsResponse measures a stored portfolio, at its latest
+// market value, against a benchmark.
+type PortfolioAnalyticsResponse struct {
+	PortfolioID string  `json:"portfolio_id"`
+	MarketValue float64 `json:"market_value"`
+	BenchmarkAnalyticsResponse
+}
+
+// BenchmarkResponse is a benchmark's definition. Weights add up to 1.
+type BenchmarkResponse struct {
+	Name         string                `json:"name"`
+	Constituents []ConstituentResponse `json:"constituents"`
+}
+
+type ConstituentResponse struct {
+	Symbol          string   `json:"symbol"`
+	Weight          float64  `json:"weight"`
+	Sector          string   `json:"sector,omitempty"`
+	Country         string   `json:"country,omitempty"`
+	CarbonIntensity *float64 `json:"carbon_intensity,omitempty"`
+}
+
+type ExposuresResponse struct {
+	PortfolioID string            `json:"portfolio_id"`
+	Exposures   []ExposureRequest `json:"exposures"`
+	MaskedData  bool              `json:"masked_data"`
+}
+
+// FinancedEmissionsResponse is a portfolio's PCAF financed emissions, in
+// tCO2e. Totals cover the exposures with an estimate, which make up
+// coverage of the outstanding amount. Emission intensity is financed
+// emissions per million invested; WACI is the weighted average carbon
+// intensity per million of revenue. Data quality is the outstanding-
+// weighted PCAF score, 1 (best) to 5.
+type FinancedEmissionsResponse struct {
+	PortfolioID string `json:"portfolio_id"`
+	FinancedEmissionsSummary
+	BySector     []FinancedEmissionsSummary `json:"by_sector"`
+	ByAssetClass []FinancedEmissionsSummary `json:"by_asset_class"`
+	Exposures    []FinancedExposureResponse `json:"exposures"`
+	MaskedData   bool                       `json:"masked_data"`
+	Timestamp    time.Time                  `json:"timestamp"`
+}
+
+type FinancedEmissionsSummary struct {
+	Group             string   `json:"group,omitempty"`
+	Exposures         int      `json:"exposure_count"`
+	OutstandingAmount float64  `json:"outstanding_amount"`
+	FinancedEmissions float64  `json:"financed_emissions"`
+	FinancedScope3    float64  `json:"financed_scope3_emissions"`
+	Share             float64  `json:"share"`
+	Coverage          float64  `json:"coverage"`
+	EmissionIntensity *float64 `json:"emission_intensity"`
+	WACI              *float64 `json:"waci"`
+	WACICoverage      float64  `json:"waci_coverage"`
+	DataQualityScore  *float64 `json:"data_quality_score"`
+}
+
+// FinancedExposureResponse is an exposure's attributed emissions. Method
+// is reported, physical_activity, revenue_based or asset_based, and empty
+// when there was nothing to estimate from.
+type FinancedExposureResponse struct {
+	Counterparty      string   `json:"counterparty" mask:"counterparty"`
+	Symbol            string   `json:"symbol,omitempty"`
+	AssetClass        string   `json:"asset_class"`
+	Sector            string   `json:"sector,omitempty"`
+	OutstandingAmount float64  `json:"outstanding_amount"`
+	AttributionFactor float64  `json:"attribution_factor"`
+	Method            string   `json:"method,omitempty"`
+	DataQualityScore  int      `json:"data_quality_score,omitempty"`
+	CompanyEmissions  *float64 `json:"company_emissions" mask:"emissions"`
+	FinancedEmissions *float64 `json:"financed_emissions"`
+	FinancedScope3    *float64 `json:"financed_scope3_emissions,omitempty"`
+	CarbonIntensity   *float64 `json:"carbon_intensity,omitempty"`
+}
+
+// ScenarioResponse is a climate scenario. Sectors without shocks of their
+// own follow the economy; a sector's empty path also follows the economy's.
+type ScenarioResponse struct {
+	Name        string                    `json:"name"`
+	Category    string                    `json:"category"`
+	Description string                    `json:"description,omitempty"`
+	Years       []int                     `json:"years"`
+	Economy     *ScenarioShocks           `json:"economy,omitempty"`
+	Sectors     map[string]ScenarioShocks `json:"sectors,omitempty"`
+}
+
+// ScenarioShocks are yearly paths: the carbon price per tCO2e on top of
+// today's, fractional changes of revenue and emissions, and the share of
+// carbon costs passed on.
+type ScenarioShocks struct {
+	CarbonPrice     []ScenarioPoint `json:"carbon_price,omitempty"`
+	RevenueShock    []ScenarioPoint `json:"revenue_shock,omitempty"`
+	EmissionsChange []ScenarioPoint `json:"emissions_change,omitempty"`
+	PassThrough     []ScenarioPoint `json:"pass_through,omitempty"`
+}
+
+type ScenarioPoint struct {
+	Year  int     `json:"year"`
+	Value float64 `json:"value"`
+}
+
+// ClimateStressResponse has one result per scenario. Changes are fractions
+// of today's values; PD changes are in basis points.
+type ClimateStressResponse struct {
+	Scenarios  []ClimateScenarioResult `json:"scenarios"`
+	MaskedData bool                    `json:"masked_data"`
+	Timestamp  time.Time               `json:"timestamp"`
+}
+
+type ClimateScenarioResult struct {
+	Scenario    string                 `json:"scenario"`
+	Category    string                 `json:"category"`
+	Description string                 `json:"description,omitempty"`
+	Companies   []ClimateCompanyResult `json:"companies"`
+	// Portfolio totals the exposures, when any company has one
+	Portfolio []ClimatePortfolioYear `json:"portfolio,omitempty"`
+}
+
+type ClimateCompanyResult struct {
+	Name               string               `json:"name" mask:"counterparty"`
+	Sector             string               `json:"sector,omitempty"`
+	Emissions          float64              `json:"emissions" mask:"emissions"`
+	EmissionsEstimated bool                 `json:"emissions_estimated"`
+	BasePD             float64              `json:"base_pd"`
+	Years              []ClimateCompanyYear `json:"years"`
+}
+
+type ClimateCompanyYear struct {
+	Year            int     `json:"year"`
+	CarbonPrice     float64 `json:"carbon_price"`
+	CarbonCost      float64 `json:"carbon_cost"`
+	Revenue         float64 `json:"revenue" mask:"revenue"`
+	EBITDA          float64 `json:"ebitda"`
+	EBITDAChange    float64 `json:"ebitda_change"`
+	ValuationChange float64 `json:"valuation_change"`
+	EquityChange    float64 `json:"equity_change"`
+	PD              float64 `json:"pd"`
+	PDChangeBps     float64 `json:"pd_change_bps"`
+}
+
+// ClimatePortfolioYear totals a year's losses, positive for a loss: the
+// fall in value of equity exposures and the rise in expected loss on
+// loans. EBITDA change and PD are weighted by exposure.
+type ClimatePortfolioYear struct {
+	Year         int     `json:"year"`
+	Exposure     float64 `json:"exposure"`
+	EquityLoss   float64 `json:"equity_loss"`
+	CreditLoss   float64 `json:"credit_loss"`
+	Loss         float64 `json:"loss"`
+	LossShare    float64 `json:"loss_share"`
+	EBITDAChange float64 `json:"ebitda_change"`
+	PD           float64 `json:"pd"`
+}
+
+type LocationsResponse struct {
+	Company   string            `json:"company"`
+	Locations []LocationRequest `json:"locations"`
+}
+
+// PhysicalRiskSummary is physical climate risk: a score from 0 to 100 and
+// each hazard from 0 to 1, averaged by value over what the hazard data
+// covers. Coverage is the share of value covered; score is null when
+// nothing is.
+type PhysicalRiskSummary struct {
+	Score    *float64           `json:"score"`
+	Hazards  map[string]float64 `json:"hazards"`
+	Coverage float64            `json:"coverage"`
+}
+
+type CompanyPhysicalRiskResponse struct {
+	Company string `json:"company"`
+	PhysicalRiskSummary
+	Locations []LocationRiskResponse `json:"locations"`
+	Timestamp time.Time              `json:"timestamp"`
+}
+
+// LocationRiskResponse is a site's hazard scores. Source is grid or region,
+// empty when the site is not covered.
+type LocationRiskResponse struct {
+	LocationRequest
+	Source  string             `json:"source,omitempty"`
+	Score   *float64           `json:"score"`
+	Hazards map[string]float64 `json:"hazards,omitempty"`
+}
+
+// PortfolioPhysicalRiskResponse is a portfolio's physical risk over its
+// financed exposures, weighted by outstanding amount.
+type PortfolioPhysicalRiskResponse struct {
+	PortfolioID string `json:"portfolio_id"`
+	PhysicalRiskSummary
+	Exposures  []ExposurePhysicalRisk `json:"exposures"`
+	MaskedData bool                   `json:"masked_data"`
+	Timestamp  time.Time              `json:"timestamp"`
+}
+
+// ExposurePhysicalRisk is the physical risk behind an exposure: the
+// counterparty's collateral for loans and bonds that have some, its assets
+// otherwise. Physical risk is null when it has no such locations.
+type ExposurePhysicalRisk struct {
+	Counterparty      string               `json:"counterparty" mask:"counterparty"`
+	OutstandingAmount float64              `json:"outstanding_amount"`
+	PhysicalRisk      *PhysicalRiskSummary `json:"physical_risk"`
+}
+
+// CreditPolicyResponse is the overlay policy in force. Version 0 is the
+// default policy, for banks that have not set their own.
+type CreditPolicyResponse struct {
+	Version int `json:"version"`
+	CreditPolicyRequest
+	MasterScale []CreditGrade `json:"master_scale"`
+}
+
+type CreditGrade struct {
+	Rating string  `json:"rating"`
+	PD     float64 `json:"pd"`
+}
+
+// CreditAdjustmentResponse is an obligor's rating and PD before and after
+// the overlay. rule_notches adds up the rules applied; notches is the move
+// made after the policy's caps.
+type CreditAdjustmentResponse struct {
+	ID              string              `json:"id,omitempty"`
+	Obligor         string              `json:"obligor" mask:"counterparty"`
+	PolicyVersion   int                 `json:"policy_version"`
+	Source          string              `json:"source"`
+	BaseRating      string              `json:"base_rating"`
+	BasePD          float64             `json:"base_pd"`
+	AdjustedRating  string              `json:"adjusted_rating"`
+	AdjustedPD      float64             `json:"adjusted_pd"`
+	RuleNotches     int                 `json:"rule_notches"`
+	Notches         int                 `json:"notches"`
+	InvestmentGrade bool                `json:"investment_grade"`
+	Factors         map[string]float64  `json:"factors"`
+	AppliedRules    []CreditRuleRequest `json:"applied_rules"`
+	CreatedBy       string              `json:"created_by,omitempty"`
+	CreatedAt       time.Time           `json:"created_at"`
+}
+
+type CreditAdjustmentsResponse struct {
+	Adjustments []CreditAdjustmentResponse `json:"adjustments"`
+	MaskedData  bool                       `json:"masked_data"`
+}
+
+// JobResponse reports an asynchronous job. Result holds the same payload the
+// synchronous endpoint would have returned, masked for the caller.
+type JobResponse struct {
+	JobID      string      `json:"job_id"`
+	Type       string      `json:"type"`
+	Status     string      `json:"status"`
+	Progress   int         `json:"progress"`
+	Result     interface{} `json:"result,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	MaskedData bool        `json:"masked_data"`
+	CreatedAt  time.Time   `json:"created_at"`
+	StartedAt  *time.Time  `json:"started_at,omitempty"`
+	FinishedAt *time.Time  `json:"finished_at,omitempty"`
+}
+
+// BatchDownloadResponse is the JSON summary of a batch analysis. Failures are
+// listed apart from the successful results.
+type BatchDownloadResponse struct {
+	JobID      string            `json:"job_id"`
+	Status     string            `json:"status"`
+	Total      int               `json:"total"`
+	Succeeded  int               `json:"succeeded"`
+	Failed     int               `json:"failed"`
+	Pending    int               `json:"pending"`
+	Results    []BatchResultRow  `json:"results"`
+	Failures   []BatchFailureRow `json:"failures"`
+	MaskedData bool              `json:"masked_data"`
+}
+
+type BatchResultRow struct {
+	Index    int              `json:"index"`
+	Company  string           `json:"company" mask:"counterparty"`
+	Analysis *AnalyzeResponse `json:"analysis"`
+}
+
+type BatchFailureRow struct {
+	Index   int    `json:"index"`
+	Company string `json:"company" mask:"counterparty"`
+	Error   string `json:"error"`
+}
@@ -9,10 +9,42 @@ const (
 	ESGNotFound         ErrorCode = "ESG_NOT_FOUND"
 
 	// Auth Errors
-	AuthUnauthorized ErrorCode = "AUTH_UNAUTHORIZED"
-	AuthForbidden    ErrorCode = "AUTH_FORBIDDEN"
-	AuthInvalidToken ErrorCode = "AUTH_INVALID_TOKEN" // #nosec G101 -- This is an error code constant, not a credential
-	AuthExpiredToken ErrorCode = "AUTH_EXPIRED_TOKEN" // #nosec G101 -- This is an error code constant, not a credential
+	AuthUnauthorized     ErrorCode = "AUTH_UNAUTHORIZED"
+	AuthForbidden        ErrorCode = "AUTH_FORBIDDEN"
+	AuthInvalidToken     ErrorCode = "AUTH_INVALID_TOKEN"     // #nosec G101 -- This is an error code constant, not a credential
+	AuthExpiredToken     ErrorCode = "AUTH_EXPIRED_TOKEN"     // #nosec G101 -- This is an error code constant, not a credential
+	AuthInvalidAPIKey    ErrorCode = "AUTH_INVALID_API_KEY"   // #nosec G101 -- This is an error code constant, not a credential
+	AuthInvalidSignature ErrorCode = "AUTH_INVALID_SIGNATURE" // #nosec G101 -- This is an error code constant, not a credential
+	AuthUnavailable      ErrorCode = "AUTH_UNAVAILABLE"
+
+	// API Key Errors
+	APIKeyNotFound ErrorCode = "API_KEY_NOT_FOUND"
+
+	// Job Errors
+	JobNotFound         ErrorCode = "JOB_NOT_FOUND"
+	JobAlreadyFinished  ErrorCode = "JOB_ALREADY_FINISHED"
+	JobQueueUnavailable ErrorCode = "JOB_QUEUE_UNAVAILABLE"
+
+	// Trade Signal Errors
+	SignalNotFound          ErrorCode = "SIGNAL_NOT_FOUND"
+	SignalInvalidTransition ErrorCode = "SIGNAL_INVALID_TRANSITION"
+	SignalNotApproved       ErrorCode = "SIGNAL_NOT_APPROVED"
+
+	// Paper Trading Errors
+	PaperPortfolioNotFound ErrorCode = "PAPER_PORTFOLIO_NOT_FOUND"
+
+	// Portfolio Errors
+	PortfolioNotFound   ErrorCode = "PORTFOLIO_NOT_FOUND"
+	PortfolioInfeasible ErrorCode = "PORTFOLIO_INFEASIBLE"
+
+	// Physical Risk Errors
+	LocationsNotFound ErrorCode = "LOCATIONS_NOT_FOUND"
+
+	// Event Stream Errors
+	StreamUnavailable ErrorCode = "STREAM_UNAVAILABLE"
+
+	// Market Data Errors
+	MarketDataNotFound ErrorCode = "MARKET_DATA_NOT_FOUND"
 
 	// Validation Errors
 	ValidationFailed ErrorCode = "VALIDATION_FAILED"
@@ -23,7 +55,9 @@ const (
 	DBConstraintViolation ErrorCode = "DB_CONSTRAINT_VIOLATION"
 
 	// Rate Limit Errors
-	RateLimitExceeded ErrorCode = "RATE_LIMIT_EXCEEDED"
+	RateLimitExceeded      ErrorCode = "RATE_LIMIT_EXCEEDED"
+	RateLimitUnavailable   ErrorCode = "RATE_LIMIT_UNAVAILABLE"
+	RateLimitBurstExceeded ErrorCode = "RATE_LIMIT_BURST_EXCEEDED"
 
 	// Compliance Errors
 	ComplianceViolation   ErrorCode = "COMPLIANCE_VIOLATION"
@@ -0,0 +1,41 @@
+package fix
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// DryRun stands in for a session: messages are numbered from its Store as
+// a session would number them, then written to w one per line instead of
+// being sent anywhere.
+type DryRun struct {
+	cfg   SessionConfig
+	store Store
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewDryRun(cfg SessionConfig, store Store, w io.Writer) *DryRun {
+	return &DryRun{cfg: cfg, store: store, w: w}
+}
+
+// LoggedOn is always true: a dry run never has to wait for a counterparty.
+func (d *DryRun) LoggedOn() bool {
+	return true
+}
+
+func (d *DryRun) Send(msg *Message) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	seq := d.store.NextSenderSeq()
+	msg.Set(TagSenderCompID, d.cfg.SenderCompID).
+		Set(TagTargetCompID, d.cfg.TargetCompID).
+		SetInt(TagMsgSeqNum, seq).
+		SetTime(TagSendingTime, time.Now())
+	if _, err := d.w.Write(append(msg.Bytes(), '\n')); err != nil {
+		return err
+	}
+	return d.store.SetNextSenderSeq(seq + 1)
+}
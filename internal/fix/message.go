@@ -0,0 +1,331 @@
+// Package fix is a small FIX 4.4 engine: tag=value messages, a session
+// layer with logon, heartbeats and sequence recovery, and a store that
+// keeps sequence numbers and sent messages across restarts. It implements
+// the subset needed to send orders and receive execution reports; it has
+// no repeating groups or data dictionary.
+package fix
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	BeginString = "FIX.4.4"
+
+	soh = '\x01'
+
+	// TimestampLayout is the UTCTimestamp format, with milliseconds.
+	TimestampLayout = "20060102-15:04:05.000"
+
+	// Longest body accepted from a counterparty
+	maxBodyLength = 1 << 20
+)
+
+// Tags used by this package and its callers
+const (
+	TagAccount             = 1
+	TagAvgPx               = 6
+	TagBeginSeqNo          = 7
+	TagBeginString         = 8
+	TagBodyLength          = 9
+	TagCheckSum            = 10
+	TagClOrdID             = 11
+	TagCumQty              = 14
+	TagEndSeqNo            = 16
+	TagExecID              = 17
+	TagLastPx              = 31
+	TagLastQty             = 32
+	TagMsgSeqNum           = 34
+	TagMsgType             = 35
+	TagNewSeqNo            = 36
+	TagOrderID             = 37
+	TagOrderQty            = 38
+	TagOrdStatus           = 39
+	TagOrdType             = 40
+	TagPossDupFlag         = 43
+	TagPrice               = 44
+	TagRefSeqNum           = 45
+	TagSenderCompID        = 49
+	TagSendingTime         = 52
+	TagSide                = 54
+	TagSymbol              = 55
+	TagTargetCompID        = 56
+	TagText                = 58
+	TagTimeInForce         = 59
+	TagTransactTime        = 60
+	TagEncryptMethod       = 98
+	TagHeartBtInt          = 108
+	TagTestReqID           = 112
+	TagOrigSendingTime     = 122
+	TagGapFillFlag         = 123
+	TagResetSeqNumFlag     = 141
+	TagExecType            = 150
+	TagLeavesQty           = 151
+	TagSessionRejectReason = 373
+)
+
+// Message types
+const (
+	MsgHeartbeat         = "0"
+	MsgTestRequest       = "1"
+	MsgResendRequest     = "2"
+	MsgReject            = "3"
+	MsgSequenceReset     = "4"
+	MsgLogout            = "5"
+	MsgExecutionReport   = "8"
+	MsgLogon             = "A"
+	MsgNewOrderSingle    = "D"
+	MsgBusinessReject    = "j"
+	MsgOrderCancelReject = "9"
+)
+
+var (
+	ErrGarbled  = errors.New("garbled FIX message")
+	ErrChecksum = errors.New("FIX checksum mismatch")
+)
+
+type Field struct {
+	Tag   int
+	Value string
+}
+
+// Message is a FIX message as an ordered list of fields. BeginString,
+// BodyLength and CheckSum are added by Bytes and dropped by Parse.
+type Message struct {
+	Fields []Field
+}
+
+// NewMessage starts a message of type msgType.
+func NewMessage(msgType string) *Message {
+	return &Message{Fields: []Field{{TagMsgType, msgType}}}
+}
+
+func (m *Message) Get(tag int) (string, bool) {
+	for _, f := range m.Fields {
+		if f.Tag == tag {
+			return f.Value, true
+		}
+	}
+	return "", false
+}
+
+// Value returns the field's value, or "" when it is absent.
+func (m *Message) Value(tag int) string {
+	value, _ := m.Get(tag)
+	return value
+}
+
+func (m *Message) Int(tag int) (int, error) {
+	value, ok := m.Get(tag)
+	if !ok {
+		return 0, fmt.Errorf("missing tag %d", tag)
+	}
+	return strconv.Atoi(value)
+}
+
+func (m *Message) Float(tag int) (float64, error) {
+	value, ok := m.Get(tag)
+	if !ok {
+		return 0, fmt.Errorf("missing tag %d", tag)
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+func (m *Message) Time(tag int) (time.Time, error) {
+	value, ok := m.Get(tag)
+	if !ok {
+		return time.Time{}, fmt.Errorf("missing tag %d", tag)
+	}
+	return ParseTimestamp(value)
+}
+
+func (m *Message) MsgType() string {
+	return m.Value(TagMsgType)
+}
+
+func (m *Message) SeqNum() int {
+	seq, _ := m.Int(TagMsgSeqNum)
+	return seq
+}
+
+// Set replaces the field's value, or appends the field.
+func (m *Message) Set(tag int, value string) *Message {
+	for i := range m.Fields {
+		if m.Fields[i].Tag == tag {
+			m.Fields[i].Value = value
+			return m
+		}
+	}
+	m.Fields = append(m.Fields, Field{tag, value})
+	return m
+}
+
+func (m *Message) SetInt(tag, value int) *Message {
+	return m.Set(tag, strconv.Itoa(value))
+}
+
+func (m *Message) SetFloat(tag int, value float64) *Message {
+	return m.Set(tag, strconv.FormatFloat(value, 'f', -1, 64))
+}
+
+func (m *Message) SetTime(tag int, value time.Time) *Message {
+	return m.Set(tag, FormatTimestamp(value))
+}
+
+func (m *Message) Remove(tag int) *Message {
+	fields := m.Fields[:0]
+	for _, f := range m.Fields {
+		if f.Tag != tag {
+			fields = append(fields, f)
+		}
+	}
+	m.Fields = fields
+	return m
+}
+
+// headerTags are the standard header fields, which counterparties expect
+// ahead of the body.
+var headerTags = map[int]bool{
+	TagMsgSeqNum: true, TagPossDupFlag: true, TagSenderCompID: true, TagSendingTime: true,
+	TagTargetCompID: true, TagOrigSendingTime: true,
+	// Sub/location IDs, security and on-behalf-of/deliver-to routing
+	50: true, 57: true, 97: true, 115: true, 128: true, 129: true, 142: true, 143: true, 144: true, 145: true,
+}
+
+// Bytes encodes the message with MsgType first, then the other header
+// fields, then the body, with BodyLength and CheckSum computed.
+func (m *Message) Bytes() []byte {
+	var body bytes.Buffer
+	writeField(&body, TagMsgType, m.MsgType())
+	for _, header := range []bool{true, false} {
+		for _, f := range m.Fields {
+			switch f.Tag {
+			case TagBeginString, TagBodyLength, TagCheckSum, TagMsgType:
+				continue
+			}
+			if headerTags[f.Tag] == header {
+				writeField(&body, f.Tag, f.Value)
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	writeField(&out, TagBeginString, BeginString)
+	writeField(&out, TagBodyLength, strconv.Itoa(body.Len()))
+	out.Write(body.Bytes())
+	writeField(&out, TagCheckSum, fmt.Sprintf("%03d", checksum(out.Bytes())))
+	return out.Bytes()
+}
+
+// String shows the message with | for the field delimiter, for logs.
+func (m *Message) String() string {
+	return strings.ReplaceAll(string(m.Bytes()), string(soh), "|")
+}
+
+// Parse decodes one complete message, checking its length and checksum.
+func Parse(raw []byte) (*Message, error) {
+	return ReadMessage(bufio.NewReader(bytes.NewReader(raw)))
+}
+
+// ReadMessage reads the next message from r.
+func ReadMessage(r *bufio.Reader) (*Message, error) {
+	begin, err := r.ReadBytes(soh)
+	if err != nil {
+		return nil, err
+	}
+	if string(begin) != "8="+BeginString+string(soh) {
+		return nil, fmt.Errorf("%w: unexpected BeginString %q", ErrGarbled, strings.TrimRight(string(begin), string(soh)))
+	}
+	lengthField, err := r.ReadBytes(soh)
+	if err != nil {
+		return nil, err
+	}
+	lengthValue, ok := bytes.CutPrefix(bytes.TrimSuffix(lengthField, []byte{soh}), []byte("9="))
+	if !ok {
+		return nil, fmt.Errorf("%w: BodyLength must follow BeginString", ErrGarbled)
+	}
+	length, err := strconv.Atoi(string(lengthValue))
+	if err != nil || length <= 0 || length > maxBodyLength {
+		return nil, fmt.Errorf("%w: bad BodyLength %q", ErrGarbled, lengthValue)
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	trailer := make([]byte, 7)
+	if _, err := io.ReadFull(r, trailer); err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(trailer, []byte("10=")) || trailer[6] != soh {
+		return nil, fmt.Errorf("%w: CheckSum must follow the body", ErrGarbled)
+	}
+	want, err := strconv.Atoi(string(trailer[3:6]))
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad CheckSum", ErrGarbled)
+	}
+	sum := checksum(begin) + checksum(lengthField) + checksum(body)
+	if sum%256 != want {
+		return nil, ErrChecksum
+	}
+
+	msg := &Message{}
+	for _, part := range bytes.Split(bytes.TrimSuffix(body, []byte{soh}), []byte{soh}) {
+		tag, value, ok := bytes.Cut(part, []byte("="))
+		if !ok {
+			return nil, fmt.Errorf("%w: field %q", ErrGarbled, part)
+		}
+		n, err := strconv.Atoi(string(tag))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("%w: tag %q", ErrGarbled, tag)
+		}
+		msg.Fields = append(msg.Fields, Field{n, string(value)})
+	}
+	if len(msg.Fields) == 0 || msg.Fields[0].Tag != TagMsgType {
+		return nil, fmt.Errorf("%w: MsgType must follow BodyLength", ErrGarbled)
+	}
+	return msg, nil
+}
+
+func FormatTimestamp(t time.Time) string {
+	return t.UTC().Format(TimestampLayout)
+}
+
+// ParseTimestamp accepts UTCTimestamp with or without milliseconds.
+func ParseTimestamp(value string) (time.Time, error) {
+	if t, err := time.Parse(TimestampLayout, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("20060102-15:04:05", value)
+}
+
+// IsAdmin reports whether msgType is a session-level message.
+func IsAdmin(msgType string) bool {
+	switch msgType {
+	case MsgHeartbeat, MsgTestRequest, MsgResendRequest, MsgReject, MsgSequenceReset, MsgLogout, MsgLogon:
+		return true
+	}
+	return false
+}
+
+func writeField(buf *bytes.Buffer, tag int, value string) {
+	buf.WriteString(strconv.Itoa(tag))
+	buf.WriteByte('=')
+	buf.WriteString(value)
+	buf.WriteByte(soh)
+}
+
+func checksum(data []byte) int {
+	sum := 0
+	for _, b := range data {
+		sum += int(b)
+	}
+	return sum % 256
+}
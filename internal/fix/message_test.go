@@ -0,0 +1,104 @@
+package fix
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// wire writes a raw message with | for the field delimiter.
+func wire(s string) []byte {
+	return []byte(strings.ReplaceAll(s, "|", string(soh)))
+}
+
+func TestMessageBytes(t *testing.T) {
+	// Header fields set after the body still go ahead of it
+	msg := NewMessage(MsgNewOrderSingle).
+		Set(TagClOrdID, "sig-1").
+		Set(TagSymbol, "TATASTEEL.NS").
+		SetInt(TagMsgSeqNum, 7).
+		Set(TagSenderCompID, "EDGE").
+		Set(TagTargetCompID, "BROKER")
+	want := "8=FIX.4.4|9=53|35=D|34=7|49=EDGE|56=BROKER|11=sig-1|55=TATASTEEL.NS|10=083|"
+	if got := msg.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  *Message
+	}{
+		{"heartbeat", NewMessage(MsgHeartbeat).SetInt(TagMsgSeqNum, 1)},
+		{"order", NewMessage(MsgNewOrderSingle).
+			SetInt(TagMsgSeqNum, 12).
+			Set(TagSenderCompID, "EDGE").
+			Set(TagTargetCompID, "BROKER").
+			Set(TagClOrdID, "sig-1").
+			Set(TagSymbol, "INFY.NS").
+			Set(TagSide, "1").
+			SetFloat(TagOrderQty, 125.5)},
+		{"empty value", NewMessage(MsgReject).SetInt(TagMsgSeqNum, 3).Set(TagText, "")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := tt.msg.Bytes()
+			parsed, err := Parse(raw)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(parsed.Fields, tt.msg.Fields) {
+				t.Errorf("fields = %v, want %v", parsed.Fields, tt.msg.Fields)
+			}
+			if got := parsed.Bytes(); string(got) != string(raw) {
+				t.Errorf("Bytes() after Parse = %q, want %q", got, raw)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    []Field
+		wantErr error
+	}{
+		{
+			name: "heartbeat",
+			raw:  "8=FIX.4.4|9=5|35=0|10=163|",
+			want: []Field{{TagMsgType, MsgHeartbeat}},
+		},
+		{
+			name: "with sequence number",
+			raw:  "8=FIX.4.4|9=10|35=0|34=7|10=171|",
+			want: []Field{{TagMsgType, MsgHeartbeat}, {TagMsgSeqNum, "7"}},
+		},
+		{name: "checksum mismatch", raw: "8=FIX.4.4|9=5|35=0|10=164|", wantErr: ErrChecksum},
+		{name: "other BeginString", raw: "8=FIX.4.2|9=5|35=0|10=163|", wantErr: ErrGarbled},
+		{name: "BodyLength missing", raw: "8=FIX.4.4|35=0|10=163|", wantErr: ErrGarbled},
+		{name: "BodyLength zero", raw: "8=FIX.4.4|9=0|10=000|", wantErr: ErrGarbled},
+		{name: "CheckSum out of place", raw: "8=FIX.4.4|9=4|35=0|10=163|", wantErr: ErrGarbled},
+		{name: "MsgType not first", raw: "8=FIX.4.4|9=5|34=7|10=169|", wantErr: ErrGarbled},
+		{name: "field without =", raw: "8=FIX.4.4|9=5|35.0|10=148|", wantErr: ErrGarbled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := Parse(wire(tt.raw))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Parse() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(msg.Fields, tt.want) {
+				t.Errorf("fields = %v, want %v", msg.Fields, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,528 @@
+package fix
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/edgeesg/edge-esg-backend/internal/loggers"
+)
+
+const (
+	defaultHeartBtInt   = 30 * time.Second
+	defaultLogonTimeout = 10 * time.Second
+
+	writeTimeout  = 10 * time.Second
+	logoutTimeout = 2 * time.Second
+	maxBackoff    = 30 * time.Second
+)
+
+var (
+	ErrNotLoggedOn      = errors.New("FIX session not logged on")
+	ErrAlreadyConnected = errors.New("FIX session already connected")
+)
+
+// SessionConfig identifies a session and its timers. CompIDs are from this
+// side's point of view: SenderCompID is ours.
+type SessionConfig struct {
+	SenderCompID string
+	TargetCompID string
+	HeartBtInt   time.Duration
+	// ResetOnLogon starts both sequences at 1 on every logon instead of
+	// carrying them over from the store.
+	ResetOnLogon bool
+	LogonTimeout time.Duration
+}
+
+// Application receives a session's events and application messages. It is
+// called from the session's goroutine, so it must not block for long.
+type Application interface {
+	OnLogon()
+	OnLogout()
+	FromApp(msg *Message)
+}
+
+// Session is one FIX session, as initiator (Run) or acceptor (Serve). It
+// keeps sequence numbers in its Store, answers heartbeats and test
+// requests, requests resends on gaps, and answers resend requests with
+// the stored application messages and gap fills for everything else.
+type Session struct {
+	cfg   SessionConfig
+	store Store
+	app   Application
+
+	mu       sync.Mutex
+	conn     net.Conn
+	loggedOn bool
+	lastSent time.Time
+}
+
+func NewSession(cfg SessionConfig, store Store, app Application) *Session {
+	if cfg.HeartBtInt <= 0 {
+		cfg.HeartBtInt = defaultHeartBtInt
+	}
+	if cfg.LogonTimeout <= 0 {
+		cfg.LogonTimeout = defaultLogonTimeout
+	}
+	return &Session{cfg: cfg, store: store, app: app}
+}
+
+func (s *Session) LoggedOn() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loggedOn
+}
+
+// Send sends an application message. It is stored before it is written, so
+// a message lost with the connection is resent when the counterparty asks.
+func (s *Session) Send(msg *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.loggedOn {
+		return ErrNotLoggedOn
+	}
+	return s.sendLocked(msg)
+}
+
+// Run keeps an initiator session connected until ctx is done, dialing
+// again with backoff whenever the connection drops.
+func (s *Session) Run(ctx context.Context, dial func(ctx context.Context) (net.Conn, error)) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		conn, err := dial(ctx)
+		if err == nil {
+			started := time.Now()
+			err = s.serve(ctx, conn, true)
+			if time.Since(started) > maxBackoff {
+				backoff = time.Second
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		loggers.Warn("FIX session disconnected", map[string]interface{}{
+			"sender": s.cfg.SenderCompID,
+			"target": s.cfg.TargetCompID,
+			"error":  fmt.Sprint(err),
+			"retry":  backoff.String(),
+		})
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// Serve runs the acceptor side of the session on conn until it closes or
+// ctx is done.
+func (s *Session) Serve(ctx context.Context, conn net.Conn) error {
+	return s.serve(ctx, conn, false)
+}
+
+// connState is what a session tracks for one connection.
+type connState struct {
+	initiator    bool
+	connectedAt  time.Time
+	lastReceived time.Time
+	testReqID    string
+	resendEnd    int
+	logoutSent   bool
+}
+
+// errDisconnect ends a connection without it being a failure.
+var errDisconnect = errors.New("disconnect")
+
+func (s *Session) serve(ctx context.Context, conn net.Conn, initiator bool) error {
+	s.mu.Lock()
+	if s.conn != nil {
+		s.mu.Unlock()
+		conn.Close()
+		return ErrAlreadyConnected
+	}
+	s.conn = conn
+	s.mu.Unlock()
+
+	state := &connState{initiator: initiator, connectedAt: time.Now(), lastReceived: time.Now()}
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		s.conn = nil
+		wasLoggedOn := s.loggedOn
+		s.loggedOn = false
+		s.mu.Unlock()
+		if wasLoggedOn {
+			s.app.OnLogout()
+		}
+	}()
+
+	if initiator {
+		if err := s.sendLogon(false); err != nil {
+			return err
+		}
+	}
+
+	incoming := make(chan *Message)
+	readErr := make(chan error, 1)
+	quit := make(chan struct{})
+	defer close(quit)
+	go func() {
+		r := bufio.NewReader(conn)
+		for {
+			msg, err := ReadMessage(r)
+			if errors.Is(err, ErrChecksum) {
+				// Framing is intact; the message is dropped and will show up as a gap
+				continue
+			}
+			if err != nil {
+				readErr <- err
+				return
+			}
+			select {
+			case incoming <- msg:
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	var logoutDeadline <-chan time.Time
+	done := ctx.Done()
+	for {
+		select {
+		case <-done:
+			done = nil
+			if !s.LoggedOn() {
+				return ctx.Err()
+			}
+			if err := s.sendLogout(state, "shutting down"); err != nil {
+				return err
+			}
+			logoutDeadline = time.After(logoutTimeout)
+		case <-logoutDeadline:
+			return nil
+		case err := <-readErr:
+			if state.logoutSent {
+				return nil
+			}
+			return err
+		case msg := <-incoming:
+			state.lastReceived = time.Now()
+			if err := s.handle(state, msg); err != nil {
+				if errors.Is(err, errDisconnect) {
+					return nil
+				}
+				return err
+			}
+		case now := <-ticker.C:
+			if err := s.checkTimers(state, now); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Session) checkTimers(state *connState, now time.Time) error {
+	if !s.LoggedOn() {
+		if now.Sub(state.connectedAt) > s.cfg.LogonTimeout {
+			return errors.New("no Logon from counterparty")
+		}
+		return nil
+	}
+
+	silence := now.Sub(state.lastReceived)
+	switch {
+	case silence > 2*s.cfg.HeartBtInt+s.cfg.HeartBtInt/5:
+		return errors.New("counterparty stopped responding")
+	case silence > s.cfg.HeartBtInt+s.cfg.HeartBtInt/5 && state.testReqID == "":
+		state.testReqID = strconv.FormatInt(now.UnixNano(), 36)
+		if err := s.sendAdmin(NewMessage(MsgTestRequest).Set(TagTestReqID, state.testReqID)); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	idle := now.Sub(s.lastSent)
+	s.mu.Unlock()
+	if idle >= s.cfg.HeartBtInt {
+		return s.sendAdmin(NewMessage(MsgHeartbeat))
+	}
+	return nil
+}
+
+func (s *Session) handle(state *connState, msg *Message) error {
+	if msg.Value(TagSenderCompID) != s.cfg.TargetCompID || msg.Value(TagTargetCompID) != s.cfg.SenderCompID {
+		_ = s.sendLogout(state, "CompID problem")
+		return fmt.Errorf("unexpected CompIDs %s->%s", msg.Value(TagSenderCompID), msg.Value(TagTargetCompID))
+	}
+	seq := msg.SeqNum()
+	if seq <= 0 {
+		_ = s.sendLogout(state, "MsgSeqNum missing")
+		return errors.New("message without MsgSeqNum")
+	}
+	msgType := msg.MsgType()
+
+	if !s.LoggedOn() {
+		if msgType != MsgLogon {
+			return fmt.Errorf("first message was %s, not Logon", msgType)
+		}
+		if err := s.acceptLogon(state, msg); err != nil {
+			return err
+		}
+	}
+
+	expected := s.store.NextTargetSeq()
+	if msgType == MsgSequenceReset && msg.Value(TagGapFillFlag) != "Y" {
+		newSeq, err := msg.Int(TagNewSeqNo)
+		if err != nil || newSeq < expected {
+			return s.sendReject(seq, "invalid NewSeqNo")
+		}
+		return s.store.SetNextTargetSeq(newSeq)
+	}
+
+	switch {
+	case seq > expected:
+		if state.resendEnd == 0 {
+			state.resendEnd = seq
+			if err := s.sendAdmin(NewMessage(MsgResendRequest).
+				SetInt(TagBeginSeqNo, expected).
+				SetInt(TagEndSeqNo, 0)); err != nil {
+				return err
+			}
+		}
+		// Requests and logouts are acted on even with messages missing
+		switch msgType {
+		case MsgResendRequest:
+			return s.resend(msg)
+		case MsgLogout:
+			return s.handleLogout(state)
+		}
+		return nil
+	case seq < expected:
+		if msg.Value(TagPossDupFlag) == "Y" {
+			return nil
+		}
+		_ = s.sendLogout(state, fmt.Sprintf("MsgSeqNum too low, expecting %d but received %d", expected, seq))
+		return fmt.Errorf("MsgSeqNum %d below expected %d", seq, expected)
+	}
+
+	if msgType == MsgSequenceReset {
+		newSeq, err := msg.Int(TagNewSeqNo)
+		if err != nil || newSeq <= seq {
+			return s.sendReject(seq, "invalid NewSeqNo")
+		}
+		return s.advanceTarget(state, newSeq)
+	}
+	if err := s.advanceTarget(state, seq+1); err != nil {
+		return err
+	}
+
+	switch msgType {
+	case MsgLogon:
+		return nil
+	case MsgHeartbeat:
+		if msg.Value(TagTestReqID) == state.testReqID {
+			state.testReqID = ""
+		}
+		return nil
+	case MsgTestRequest:
+		return s.sendAdmin(NewMessage(MsgHeartbeat).Set(TagTestReqID, msg.Value(TagTestReqID)))
+	case MsgResendRequest:
+		return s.resend(msg)
+	case MsgReject:
+		loggers.Warn("FIX session reject", map[string]interface{}{
+			"ref_seq_num": msg.Value(TagRefSeqNum),
+			"reason":      msg.Value(TagSessionRejectReason),
+			"text":        msg.Value(TagText),
+		})
+		return nil
+	case MsgLogout:
+		return s.handleLogout(state)
+	}
+	s.app.FromApp(msg)
+	return nil
+}
+
+func (s *Session) advanceTarget(state *connState, next int) error {
+	if state.resendEnd != 0 && next > state.resendEnd {
+		state.resendEnd = 0
+	}
+	return s.store.SetNextTargetSeq(next)
+}
+
+// acceptLogon completes the logon: the acceptor answers the initiator's
+// Logon, the initiator takes the answer.
+func (s *Session) acceptLogon(state *connState, msg *Message) error {
+	reset := msg.Value(TagResetSeqNumFlag) == "Y"
+	if !state.initiator {
+		if reset {
+			if err := s.store.Reset(); err != nil {
+				return err
+			}
+		}
+		if err := s.sendLogon(reset); err != nil {
+			return err
+		}
+	} else if reset {
+		// The acceptor's sequence starts again at 1
+		if err := s.store.SetNextTargetSeq(1); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	s.loggedOn = true
+	s.mu.Unlock()
+	loggers.Info("FIX session logged on", map[string]interface{}{
+		"sender": s.cfg.SenderCompID,
+		"target": s.cfg.TargetCompID,
+	})
+	s.app.OnLogon()
+	return nil
+}
+
+func (s *Session) handleLogout(state *connState) error {
+	if !state.logoutSent {
+		_ = s.sendLogout(state, "")
+	}
+	return errDisconnect
+}
+
+// resend answers a ResendRequest: stored application messages go out again
+// flagged as possible duplicates; admin messages and anything not stored
+// are skipped with a gap fill.
+func (s *Session) resend(msg *Message) error {
+	begin, err := msg.Int(TagBeginSeqNo)
+	if err != nil || begin <= 0 {
+		return s.sendReject(msg.SeqNum(), "invalid BeginSeqNo")
+	}
+	end, _ := msg.Int(TagEndSeqNo)
+	last := s.store.NextSenderSeq() - 1
+	if end == 0 || end > last {
+		end = last
+	}
+	if begin > end {
+		return nil
+	}
+
+	stored, err := s.store.Messages(begin, end)
+	if err != nil {
+		return err
+	}
+	next := begin
+	for _, m := range stored {
+		if m.Seq > next {
+			if err := s.gapFill(next, m.Seq); err != nil {
+				return err
+			}
+		}
+		original, err := Parse(m.Raw)
+		if err != nil {
+			return err
+		}
+		original.Set(TagPossDupFlag, "Y").
+			Set(TagOrigSendingTime, original.Value(TagSendingTime)).
+			SetTime(TagSendingTime, time.Now())
+		if err := s.write(original.Bytes()); err != nil {
+			return err
+		}
+		next = m.Seq + 1
+	}
+	if next <= end {
+		return s.gapFill(next, end+1)
+	}
+	return nil
+}
+
+func (s *Session) gapFill(seq, newSeq int) error {
+	msg := NewMessage(MsgSequenceReset).
+		Set(TagSenderCompID, s.cfg.SenderCompID).
+		Set(TagTargetCompID, s.cfg.TargetCompID).
+		SetInt(TagMsgSeqNum, seq).
+		Set(TagPossDupFlag, "Y").
+		SetTime(TagSendingTime, time.Now()).
+		Set(TagGapFillFlag, "Y").
+		SetInt(TagNewSeqNo, newSeq)
+	return s.write(msg.Bytes())
+}
+
+func (s *Session) sendLogon(reset bool) error {
+	if s.cfg.ResetOnLogon {
+		if err := s.store.Reset(); err != nil {
+			return err
+		}
+		reset = true
+	}
+	msg := NewMessage(MsgLogon).
+		Set(TagEncryptMethod, "0").
+		SetInt(TagHeartBtInt, int(s.cfg.HeartBtInt/time.Second))
+	if reset {
+		msg.Set(TagResetSeqNumFlag, "Y")
+	}
+	return s.sendAdmin(msg)
+}
+
+func (s *Session) sendLogout(state *connState, text string) error {
+	state.logoutSent = true
+	msg := NewMessage(MsgLogout)
+	if text != "" {
+		msg.Set(TagText, text)
+	}
+	return s.sendAdmin(msg)
+}
+
+func (s *Session) sendReject(refSeq int, text string) error {
+	return s.sendAdmin(NewMessage(MsgReject).SetInt(TagRefSeqNum, refSeq).Set(TagText, text))
+}
+
+func (s *Session) sendAdmin(msg *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sendLocked(msg)
+}
+
+func (s *Session) sendLocked(msg *Message) error {
+	seq := s.store.NextSenderSeq()
+	msg.Set(TagSenderCompID, s.cfg.SenderCompID).
+		Set(TagTargetCompID, s.cfg.TargetCompID).
+		SetInt(TagMsgSeqNum, seq).
+		SetTime(TagSendingTime, time.Now())
+	raw := msg.Bytes()
+	if !IsAdmin(msg.MsgType()) {
+		if err := s.store.SaveMessage(seq, raw); err != nil {
+			return err
+		}
+	}
+	if err := s.store.SetNextSenderSeq(seq + 1); err != nil {
+		return err
+	}
+	return s.writeLocked(raw)
+}
+
+func (s *Session) write(raw []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writeLocked(raw)
+}
+
+func (s *Session) writeLocked(raw []byte) error {
+	if s.conn == nil {
+		return ErrNotLoggedOn
+	}
+	if err := s.conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return err
+	}
+	if _, err := s.conn.Write(raw); err != nil {
+		return err
+	}
+	s.lastSent = time.Now()
+	return nil
+}
@@ -0,0 +1,257 @@
+package fix
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// recordConn keeps what a session writes instead of sending it.
+type recordConn struct {
+	net.Conn
+	sent []*Message
+}
+
+func (c *recordConn) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+func (c *recordConn) Write(b []byte) (int, error) {
+	msg, err := Parse(b)
+	if err != nil {
+		return 0, err
+	}
+	c.sent = append(c.sent, msg)
+	return len(b), nil
+}
+
+type recordApp struct {
+	received []*Message
+}
+
+func (a *recordApp) OnLogon()  {}
+func (a *recordApp) OnLogout() {}
+
+func (a *recordApp) FromApp(msg *Message) {
+	a.received = append(a.received, msg)
+}
+
+// loggedOnSession is logged on to BROKER and expects its message 5 next.
+func loggedOnSession(t *testing.T) (*Session, *recordConn, *recordApp) {
+	t.Helper()
+	store := NewMemoryStore()
+	if err := store.SetNextTargetSeq(5); err != nil {
+		t.Fatal(err)
+	}
+	app := &recordApp{}
+	conn := &recordConn{}
+	s := NewSession(SessionConfig{SenderCompID: "EDGE", TargetCompID: "BROKER"}, store, app)
+	s.conn = conn
+	s.loggedOn = true
+	return s, conn, app
+}
+
+// fromBroker is a message as BROKER would send it.
+func fromBroker(msgType string, seq int) *Message {
+	return NewMessage(msgType).
+		Set(TagSenderCompID, "BROKER").
+		Set(TagTargetCompID, "EDGE").
+		SetInt(TagMsgSeqNum, seq)
+}
+
+func TestSessionHandle(t *testing.T) {
+	tests := []struct {
+		name       string
+		msg        *Message
+		wantErr    bool
+		wantTarget int
+		wantApp    int
+		// wantSent are the types of the messages sent in reply; wantFields
+		// are checked on the first of them
+		wantSent   []string
+		wantFields map[int]string
+	}{
+		{
+			name:       "in sequence",
+			msg:        fromBroker(MsgExecutionReport, 5),
+			wantTarget: 6,
+			wantApp:    1,
+		},
+		{
+			name:       "test request",
+			msg:        fromBroker(MsgTestRequest, 5).Set(TagTestReqID, "ping"),
+			wantTarget: 6,
+			wantSent:   []string{MsgHeartbeat},
+			wantFields: map[int]string{TagTestReqID: "ping", TagMsgSeqNum: "1"},
+		},
+		{
+			name:       "too high",
+			msg:        fromBroker(MsgExecutionReport, 8),
+			wantTarget: 5,
+			wantSent:   []string{MsgResendRequest},
+			wantFields: map[int]string{TagBeginSeqNo: "5", TagEndSeqNo: "0"},
+		},
+		{
+			name:       "too low",
+			msg:        fromBroker(MsgExecutionReport, 3),
+			wantErr:    true,
+			wantTarget: 5,
+			wantSent:   []string{MsgLogout},
+			wantFields: map[int]string{TagText: "MsgSeqNum too low, expecting 5 but received 3"},
+		},
+		{
+			name:       "too low, possible duplicate",
+			msg:        fromBroker(MsgExecutionReport, 3).Set(TagPossDupFlag, "Y"),
+			wantTarget: 5,
+		},
+		{
+			name:       "sequence reset",
+			msg:        fromBroker(MsgSequenceReset, 1).SetInt(TagNewSeqNo, 10),
+			wantTarget: 10,
+		},
+		{
+			name:       "sequence reset backwards",
+			msg:        fromBroker(MsgSequenceReset, 1).SetInt(TagNewSeqNo, 3),
+			wantTarget: 5,
+			wantSent:   []string{MsgReject},
+			wantFields: map[int]string{TagRefSeqNum: "1", TagText: "invalid NewSeqNo"},
+		},
+		{
+			name:       "gap fill",
+			msg:        fromBroker(MsgSequenceReset, 5).Set(TagGapFillFlag, "Y").SetInt(TagNewSeqNo, 9),
+			wantTarget: 9,
+		},
+		{
+			name:       "gap fill not moving forward",
+			msg:        fromBroker(MsgSequenceReset, 5).Set(TagGapFillFlag, "Y").SetInt(TagNewSeqNo, 5),
+			wantTarget: 5,
+			wantSent:   []string{MsgReject},
+			wantFields: map[int]string{TagRefSeqNum: "5"},
+		},
+		{
+			name:       "wrong CompIDs",
+			msg:        fromBroker(MsgExecutionReport, 5).Set(TagSenderCompID, "OTHER"),
+			wantErr:    true,
+			wantTarget: 5,
+			wantSent:   []string{MsgLogout},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, conn, app := loggedOnSession(t)
+			err := s.handle(&connState{}, tt.msg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("handle() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := s.store.NextTargetSeq(); got != tt.wantTarget {
+				t.Errorf("next target seq = %d, want %d", got, tt.wantTarget)
+			}
+			if len(app.received) != tt.wantApp {
+				t.Errorf("application got %d messages, want %d", len(app.received), tt.wantApp)
+			}
+			var sent []string
+			for _, msg := range conn.sent {
+				sent = append(sent, msg.MsgType())
+			}
+			if !reflect.DeepEqual(sent, tt.wantSent) {
+				t.Fatalf("sent %v, want %v", sent, tt.wantSent)
+			}
+			for tag, want := range tt.wantFields {
+				if got := conn.sent[0].Value(tag); got != want {
+					t.Errorf("sent tag %d = %q, want %q", tag, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSessionGapRecovery(t *testing.T) {
+	s, conn, app := loggedOnSession(t)
+	state := &connState{}
+
+	// 5 and 6 were lost: one ResendRequest covers the whole gap
+	for _, seq := range []int{7, 8} {
+		if err := s.handle(state, fromBroker(MsgExecutionReport, seq)); err != nil {
+			t.Fatalf("handle(%d) error = %v", seq, err)
+		}
+	}
+	if len(conn.sent) != 1 || conn.sent[0].MsgType() != MsgResendRequest {
+		t.Fatalf("sent %v, want one ResendRequest", conn.sent)
+	}
+
+	// The counterparty resends 5 and 6, then 7 and 8 again
+	for seq := 5; seq <= 8; seq++ {
+		msg := fromBroker(MsgExecutionReport, seq).Set(TagPossDupFlag, "Y")
+		if err := s.handle(state, msg); err != nil {
+			t.Fatalf("handle(%d) error = %v", seq, err)
+		}
+	}
+	if got := s.store.NextTargetSeq(); got != 9 {
+		t.Errorf("next target seq = %d, want 9", got)
+	}
+	if state.resendEnd != 0 {
+		t.Errorf("resendEnd = %d, want 0 once the gap is filled", state.resendEnd)
+	}
+	if len(app.received) != 4 {
+		t.Errorf("application got %d messages, want 4", len(app.received))
+	}
+
+	// A later gap asks again
+	if err := s.handle(state, fromBroker(MsgExecutionReport, 11)); err != nil {
+		t.Fatalf("handle(11) error = %v", err)
+	}
+	if len(conn.sent) != 2 || conn.sent[1].Value(TagBeginSeqNo) != "9" {
+		t.Errorf("sent %v, want a second ResendRequest from 9", conn.sent)
+	}
+}
+
+func TestSessionResend(t *testing.T) {
+	s, conn, _ := loggedOnSession(t)
+	// Sent: 1 a Logon (not stored), 2 an order, 3 a heartbeat, 4 an order
+	for _, msg := range []*Message{
+		NewMessage(MsgLogon),
+		NewMessage(MsgNewOrderSingle).Set(TagClOrdID, "a"),
+		NewMessage(MsgHeartbeat),
+		NewMessage(MsgNewOrderSingle).Set(TagClOrdID, "b"),
+	} {
+		if err := s.sendAdmin(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conn.sent = nil
+
+	request := fromBroker(MsgResendRequest, 5).SetInt(TagBeginSeqNo, 1).SetInt(TagEndSeqNo, 0)
+	if err := s.handle(&connState{}, request); err != nil {
+		t.Fatalf("handle() error = %v", err)
+	}
+
+	// Gap fill 1→2, order 2, gap fill 3→4, order 4
+	want := []struct {
+		msgType string
+		seq     int
+		newSeq  string
+		clOrdID string
+	}{
+		{MsgSequenceReset, 1, "2", ""},
+		{MsgNewOrderSingle, 2, "", "a"},
+		{MsgSequenceReset, 3, "4", ""},
+		{MsgNewOrderSingle, 4, "", "b"},
+	}
+	if len(conn.sent) != len(want) {
+		t.Fatalf("sent %d messages, want %d: %v", len(conn.sent), len(want), conn.sent)
+	}
+	for i, w := range want {
+		msg := conn.sent[i]
+		if msg.MsgType() != w.msgType || msg.SeqNum() != w.seq ||
+			msg.Value(TagNewSeqNo) != w.newSeq || msg.Value(TagClOrdID) != w.clOrdID {
+			t.Errorf("message %d = %v, want type %s seq %d", i, msg, w.msgType, w.seq)
+		}
+		if msg.Value(TagPossDupFlag) != "Y" {
+			t.Errorf("message %d is not flagged PossDup", i)
+		}
+	}
+	if got := s.store.NextSenderSeq(); got != 5 {
+		t.Errorf("next sender seq = %d, want 5: resends reuse their numbers", got)
+	}
+}
@@ -0,0 +1,248 @@
+package fix
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Store keeps a session's next sequence numbers and the messages it sent,
+// so a restarted session carries on where it stopped and can answer
+// resend requests.
+type Store interface {
+	NextSenderSeq() int
+	NextTargetSeq() int
+	SetNextSenderSeq(seq int) error
+	SetNextTargetSeq(seq int) error
+	// SaveMessage records the message sent as seq.
+	SaveMessage(seq int, raw []byte) error
+	// Messages returns the sent messages with sequence numbers in
+	// [begin, end], by sequence number.
+	Messages(begin, end int) ([]StoredMessage, error)
+	// Reset starts both sequences again at 1 and forgets sent messages.
+	Reset() error
+}
+
+type StoredMessage struct {
+	Seq int
+	Raw []byte
+}
+
+// MemoryStore is a Store that keeps nothing across restarts.
+type MemoryStore struct {
+	mu       sync.Mutex
+	sender   int
+	target   int
+	messages map[int][]byte
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sender: 1, target: 1, messages: make(map[int][]byte)}
+}
+
+func (s *MemoryStore) NextSenderSeq() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sender
+}
+
+func (s *MemoryStore) NextTargetSeq() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.target
+}
+
+func (s *MemoryStore) SetNextSenderSeq(seq int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sender = seq
+	return nil
+}
+
+func (s *MemoryStore) SetNextTargetSeq(seq int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.target = seq
+	return nil
+}
+
+func (s *MemoryStore) SaveMessage(seq int, raw []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages[seq] = raw
+	return nil
+}
+
+func (s *MemoryStore) Messages(begin, end int) ([]StoredMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return collect(s.messages, begin, end), nil
+}
+
+func (s *MemoryStore) Reset() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sender, s.target = 1, 1
+	s.messages = make(map[int][]byte)
+	return nil
+}
+
+// FileStore is a Store in a directory:
+//
+//	<dir>/<sender>-<target>.seqnums  next sender and target sequence numbers
+//	<dir>/<sender>-<target>.body     sent messages, "<seq> <length>\n<raw>\n"
+//
+// Sequence numbers are rewritten atomically on every change; the body file
+// is appended to and read back on open.
+type FileStore struct {
+	mu       sync.Mutex
+	seqPath  string
+	body     *os.File
+	sender   int
+	target   int
+	messages map[int][]byte
+}
+
+// NewFileStore opens, or creates, the store for a session.
+func NewFileStore(dir, senderCompID, targetCompID string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	name := senderCompID + "-" + targetCompID
+	s := &FileStore{
+		seqPath:  filepath.Join(dir, name+".seqnums"),
+		sender:   1,
+		target:   1,
+		messages: make(map[int][]byte),
+	}
+
+	data, err := os.ReadFile(s.seqPath)
+	switch {
+	case err == nil:
+		if _, err := fmt.Sscanf(string(data), "%d %d", &s.sender, &s.target); err != nil {
+			return nil, fmt.Errorf("read %s: %w", s.seqPath, err)
+		}
+	case !os.IsNotExist(err):
+		return nil, err
+	}
+
+	bodyPath := filepath.Join(dir, name+".body")
+	s.body, err = os.OpenFile(bodyPath, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600) // #nosec G304 -- path built from configured directory
+	if err != nil {
+		return nil, err
+	}
+	if err := s.load(); err != nil {
+		s.body.Close()
+		return nil, fmt.Errorf("read %s: %w", bodyPath, err)
+	}
+	return s, nil
+}
+
+func (s *FileStore) load() error {
+	r := bufio.NewReader(s.body)
+	for {
+		header, err := r.ReadString('\n')
+		if err == io.EOF && header == "" {
+			return nil
+		}
+		if err != nil {
+			// A record cut short by a crash is dropped
+			return nil
+		}
+		var seq, length int
+		if _, err := fmt.Sscanf(header, "%d %d", &seq, &length); err != nil || length < 0 {
+			return fmt.Errorf("bad record header %q", strings.TrimSpace(header))
+		}
+		raw := make([]byte, length+1)
+		if _, err := io.ReadFull(r, raw); err != nil {
+			return nil
+		}
+		s.messages[seq] = raw[:length]
+	}
+}
+
+func (s *FileStore) NextSenderSeq() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sender
+}
+
+func (s *FileStore) NextTargetSeq() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.target
+}
+
+func (s *FileStore) SetNextSenderSeq(seq int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sender = seq
+	return s.saveSeqNums()
+}
+
+func (s *FileStore) SetNextTargetSeq(seq int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.target = seq
+	return s.saveSeqNums()
+}
+
+func (s *FileStore) SaveMessage(seq int, raw []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record := make([]byte, 0, len(raw)+24)
+	record = append(record, strconv.Itoa(seq)+" "+strconv.Itoa(len(raw))+"\n"...)
+	record = append(record, raw...)
+	record = append(record, '\n')
+	if _, err := s.body.Write(record); err != nil {
+		return err
+	}
+	s.messages[seq] = raw
+	return nil
+}
+
+func (s *FileStore) Messages(begin, end int) ([]StoredMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return collect(s.messages, begin, end), nil
+}
+
+func (s *FileStore) Reset() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.body.Truncate(0); err != nil {
+		return err
+	}
+	s.sender, s.target = 1, 1
+	s.messages = make(map[int][]byte)
+	return s.saveSeqNums()
+}
+
+func (s *FileStore) Close() error {
+	return s.body.Close()
+}
+
+func (s *FileStore) saveSeqNums() error {
+	tmp := s.seqPath + ".tmp"
+	if err := os.WriteFile(tmp, []byte(fmt.Sprintf("%d %d\n", s.sender, s.target)), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.seqPath)
+}
+
+func collect(messages map[int][]byte, begin, end int) []StoredMessage {
+	var out []StoredMessage
+	for seq, raw := range messages {
+		if seq >= begin && seq <= end {
+			out = append(out, StoredMessage{Seq: seq, Raw: raw})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Seq < out[j].Seq })
+	return out
+}
@@ -1,10 +1,13 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
+	"github.com/edgeesg/edge-esg-backend/internal/credit"
 	"github.com/edgeesg/edge-esg-backend/internal/dtos"
 	"github.com/edgeesg/edge-esg-backend/internal/error_codes"
+	"github.com/edgeesg/edge-esg-backend/internal/middleware"
 	"github.com/edgeesg/edge-esg-backend/internal/services"
 	"github.com/edgeesg/edge-esg-backend/internal/validator"
 	"github.com/gin-gonic/gin"
@@ -12,10 +15,11 @@ import (
 
 type AnalyzeHandler struct {
 	orchestrator *services.Orchestrator
+	hub          *WSHub
 }
 
-func NewAnalyzeHandler(orchestrator *services.Orchestrator) *AnalyzeHandler {
-	return &AnalyzeHandler{orchestrator: orchestrator}
+func NewAnalyzeHandler(orchestrator *services.Orchestrator, hub *WSHub) *AnalyzeHandler {
+	return &AnalyzeHandler{orchestrator: orchestrator, hub: hub}
 }
 
 func (h *AnalyzeHandler) Analyze(c *gin.Context) {
@@ -29,6 +33,10 @@ func (h *AnalyzeHandler) Analyze(c *gin.Context) {
 		return
 	}
 
+	if bankID := middleware.ResolveBankID(c); bankID != "" {
+		req.BankID = bankID
+	}
+
 	if err := validator.ValidateStruct(&req); err != nil {
 		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
 			Code:    string(error_codes.ESGInvalidInput),
@@ -41,7 +49,15 @@ func (h *AnalyzeHandler) Analyze(c *gin.Context) {
 	role, _ := c.Get("user_role")
 	req.UserRole = role.(string)
 
-	response, err := h.orchestrator.Execute8LayerPipeline(c.Request.Context(), &req)
+	response, err := h.orchestrator.Execute8LayerPipeline(h.hub.pipelineContext(c), &req)
+	if errors.Is(err, credit.ErrInvalidObligor) {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ESGInvalidInput),
+			Message: "Invalid credit base",
+			Details: err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, dtos.ErrorResponse{
 			Code:    string(error_codes.ESGProcessingFailed),
@@ -51,5 +67,6 @@ func (h *AnalyzeHandler) Analyze(c *gin.Context) {
 		return
 	}
 
+	response.MaskedData = middleware.MaskResponse(c, response)
 	c.JSON(http.StatusOK, response)
 }
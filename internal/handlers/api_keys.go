@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/edgeesg/edge-esg-backend/internal/dtos"
+	"github.com/edgeesg/edge-esg-backend/internal/error_codes"
+	"github.com/edgeesg/edge-esg-backend/internal/middleware"
+	"github.com/edgeesg/edge-esg-backend/internal/models"
+	"github.com/edgeesg/edge-esg-backend/internal/services"
+	"github.com/edgeesg/edge-esg-backend/internal/validator"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const defaultRotationGrace = 24 * time.Hour
+
+type APIKeyHandler struct {
+	service *services.APIKeyService
+}
+
+func NewAPIKeyHandler(service *services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{service: service}
+}
+
+// Issue creates a new API key for the bank in the path
+func (h *APIKeyHandler) Issue(c *gin.Context) {
+	bankID, err := uuid.Parse(c.Param("bank_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ESGInvalidInput),
+			Message: "Invalid bank ID",
+		})
+		return
+	}
+
+	var req dtos.IssueAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ESGInvalidInput),
+			Message: "Invalid request body",
+			Details: err.Error(),
+		})
+		return
+	}
+	if err := validator.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ESGInvalidInput),
+			Message: "Validation failed",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	issueReq := &services.IssueAPIKeyRequest{
+		BankID:           bankID,
+		Name:             req.Name,
+		Scopes:           req.Scopes,
+		RequireSignature: req.RequireSignature,
+		CreatedBy:        c.GetString("user_email"),
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		issueReq.ExpiresAt = &expiresAt
+	}
+
+	issued, err := h.service.Issue(c.Request.Context(), issueReq)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidScope) {
+			c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+				Code:    string(error_codes.ESGInvalidInput),
+				Message: "Invalid scopes",
+				Details: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dtos.ErrorResponse{
+			Code:    string(error_codes.DBQueryFailed),
+			Message: "Failed to issue API key",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	middleware.AuditLog("API_KEY_ISSUED", map[string]interface{}{
+		"bank_id":    bankID.String(),
+		"key_id":     issued.Key.ID.String(),
+		"key_prefix": issued.Key.KeyPrefix,
+		"issued_by":  issueReq.CreatedBy,
+	})
+
+	c.JSON(http.StatusCreated, toIssuedAPIKeyResponse(issued))
+}
+
+// List returns the bank's keys without any secret material
+func (h *APIKeyHandler) List(c *gin.Context) {
+	bankID, err := uuid.Parse(c.Param("bank_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ESGInvalidInput),
+			Message: "Invalid bank ID",
+		})
+		return
+	}
+
+	keys, err := h.service.List(c.Request.Context(), bankID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dtos.ErrorResponse{
+			Code:    string(error_codes.DBQueryFailed),
+			Message: "Failed to list API keys",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	response := make([]dtos.APIKeyResponse, 0, len(keys))
+	for i := range keys {
+		response = append(response, toAPIKeyResponse(&keys[i]))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Revoke disables a key immediately
+func (h *APIKeyHandler) Revoke(c *gin.Context) {
+	keyID, ok := parseKeyID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Revoke(c.Request.Context(), keyID); err != nil {
+		h.writeKeyError(c, err, "Failed to revoke API key")
+		return
+	}
+
+	middleware.AuditLog("API_KEY_REVOKED", map[string]interface{}{
+		"key_id":     keyID.String(),
+		"revoked_by": c.GetString("user_email"),
+	})
+	c.Status(http.StatusNoContent)
+}
+
+// Rotate issues a replacement key; the old key expires after the grace period
+func (h *APIKeyHandler) Rotate(c *gin.Context) {
+	keyID, ok := parseKeyID(c)
+	if !ok {
+		return
+	}
+
+	grace := defaultRotationGrace
+	if c.Request.ContentLength > 0 {
+		var req dtos.RotateAPIKeyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+				Code:    string(error_codes.ESGInvalidInput),
+				Message: "Invalid request body",
+				Details: err.Error(),
+			})
+			return
+		}
+		if err := validator.ValidateStruct(&req); err != nil {
+			c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+				Code:    string(error_codes.ESGInvalidInput),
+				Message: "Validation failed",
+				Details: err.Error(),
+			})
+			return
+		}
+		grace = time.Duration(req.GracePeriodHours) * time.Hour
+	}
+
+	issued, err := h.service.Rotate(c.Request.Context(), keyID, grace, c.GetString("user_email"))
+	if err != nil {
+		h.writeKeyError(c, err, "Failed to rotate API key")
+		return
+	}
+
+	middleware.AuditLog("API_KEY_ROTATED", map[string]interface{}{
+		"old_key_id": keyID.String(),
+		"new_key_id": issued.Key.ID.String(),
+		"rotated_by": c.GetString("user_email"),
+	})
+	c.JSON(http.StatusCreated, toIssuedAPIKeyResponse(issued))
+}
+
+func (h *APIKeyHandler) writeKeyError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrAPIKeyNotFound):
+		c.JSON(http.StatusNotFound, dtos.ErrorResponse{
+			Code:    string(error_codes.APIKeyNotFound),
+			Message: "API key not found",
+		})
+	case errors.Is(err, services.ErrAPIKeyInvalid):
+		c.JSON(http.StatusConflict, dtos.ErrorResponse{
+			Code:    string(error_codes.AuthInvalidAPIKey),
+			Message: "API key is revoked or expired",
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, dtos.ErrorResponse{
+			Code:    string(error_codes.DBQueryFailed),
+			Message: message,
+			Details: err.Error(),
+		})
+	}
+}
+
+func parseKeyID(c *gin.Context) (uuid.UUID, bool) {
+	keyID, err := uuid.Parse(c.Param("key_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ESGInvalidInput),
+			Message: "Invalid API key ID",
+		})
+		return uuid.Nil, false
+	}
+	return keyID, true
+}
+
+func toAPIKeyResponse(key *models.BankAPIKey) dtos.APIKeyResponse {
+	response := dtos.APIKeyResponse{
+		ID:               key.ID.String(),
+		BankID:           key.BankID.String(),
+		Name:             key.Name,
+		KeyPrefix:        key.KeyPrefix,
+		Scopes:           key.ScopeList(),
+		RequireSignature: key.RequireSignature,
+		ExpiresAt:        key.ExpiresAt,
+		LastUsedAt:       key.LastUsedAt,
+		RevokedAt:        key.RevokedAt,
+		CreatedBy:        key.CreatedBy,
+		CreatedAt:        key.CreatedAt,
+	}
+	if key.RotatedFrom != nil {
+		response.RotatedFrom = key.RotatedFrom.String()
+	}
+	return response
+}
+
+func toIssuedAPIKeyResponse(issued *services.IssuedAPIKey) dtos.IssuedAPIKeyResponse {
+	return dtos.IssuedAPIKeyResponse{
+		APIKeyResponse: toAPIKeyResponse(issued.Key),
+		APIKey:         issued.PlaintextKey,
+		SigningSecret:  issued.SigningSecret,
+	}
+}
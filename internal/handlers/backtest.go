@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/edgeesg/edge-esg-backend/internal/backtest"
+	"github.com/edgeesg/edge-esg-backend/internal/dtos"
+	"github.com/edgeesg/edge-esg-backend/internal/error_codes"
+	"github.com/edgeesg/edge-esg-backend/internal/marketdata"
+	"github.com/edgeesg/edge-esg-backend/internal/validator"
+	"github.com/gin-gonic/gin"
+)
+
+type BacktestHandler struct {
+	engine *backtest.Engine
+}
+
+func NewBacktestHandler(engine *backtest.Engine) *BacktestHandler {
+	return &BacktestHandler{engine: engine}
+}
+
+// Run backtests the trading signal over the requested symbols and period
+func (h *BacktestHandler) Run(c *gin.Context) {
+	var req dtos.BacktestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+	if err := validator.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Validation failed",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Dates were validated above
+	start, _ := time.Parse(marketdata.DateLayout, req.StartDate)
+	end, _ := time.Parse(marketdata.DateLayout, req.EndDate)
+	result, err := h.engine.Run(c.Request.Context(), backtest.Config{
+		Symbols:        req.Symbols,
+		Benchmark:      req.Benchmark,
+		Start:          start,
+		End:            end,
+		InitialCapital: req.InitialCapital,
+		CostBps:        req.CostBps,
+		SlippageBps:    req.SlippageBps,
+		RiskFreeRate:   req.RiskFreeRate,
+	})
+	switch {
+	case errors.Is(err, backtest.ErrInvalidConfig), errors.Is(err, marketdata.ErrInvalidSymbol):
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Invalid backtest",
+			Details: err.Error(),
+		})
+		return
+	case errors.Is(err, marketdata.ErrNoData):
+		c.JSON(http.StatusNotFound, dtos.ErrorResponse{
+			Code:    string(error_codes.MarketDataNotFound),
+			Message: "Market data not available",
+			Details: err.Error(),
+		})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, dtos.ErrorResponse{
+			Code:    string(error_codes.ESGProcessingFailed),
+			Message: "Backtest failed",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	if !req.IncludeTrades {
+		result.Trades = nil
+	}
+	if !req.IncludeEquity {
+		result.Equity = nil
+	}
+	c.JSON(http.StatusOK, result)
+}
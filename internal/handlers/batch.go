@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/edgeesg/edge-esg-backend/internal/dtos"
+	"github.com/edgeesg/edge-esg-backend/internal/error_codes"
+	"github.com/edgeesg/edge-esg-backend/internal/middleware"
+	"github.com/edgeesg/edge-esg-backend/internal/services"
+	"github.com/edgeesg/edge-esg-backend/internal/types"
+	"github.com/edgeesg/edge-esg-backend/internal/validator"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// MaxBatchUploadBytes bounds batch request bodies, JSON or CSV.
+	MaxBatchUploadBytes = 1 << 20
+
+	utf8BOM = "\ufeff"
+)
+
+// SubmitBatch queues a batch analysis. The body is either JSON
+// ({"companies": [...]}), a text/csv body, or a multipart form with a CSV
+// "file" field. CSV files use the company_name (or company/name) column, or
+// the first column when there is no such header.
+func (h *JobsHandler) SubmitBatch(c *gin.Context) {
+	companies, err := readBatchCompanies(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ESGInvalidInput),
+			Message: "Invalid request body",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	req := dtos.BatchAnalyzeRequest{Companies: dedupeCompanies(companies)}
+	if err := validator.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ESGInvalidInput),
+			Message: "Validation failed",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	h.submit(c, types.JobBatchAnalyze, middleware.ResolveBankID(c), &services.BatchAnalyzeRequest{
+		Companies: req.Companies,
+		UserRole:  middleware.ResolveRole(c),
+	})
+}
+
+// Download returns a batch job's results as JSON, or as CSV with
+// ?format=csv (successful rows) and ?format=csv&part=failures.
+func (h *JobsHandler) Download(c *gin.Context) {
+	job, err := h.jobs.Get(c.Request.Context(), c.Param("job_id"), middleware.ResolveBankID(c))
+	if err != nil {
+		writeJobError(c, err)
+		return
+	}
+	if job.Type != types.JobBatchAnalyze {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ESGInvalidInput),
+			Message: "Only batch jobs can be downloaded",
+		})
+		return
+	}
+
+	report, err := h.batchReport(c, job)
+	if err != nil {
+		writeJobError(c, err)
+		return
+	}
+
+	c.Header("X-Job-Status", report.Status)
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		c.JSON(http.StatusOK, report)
+	case "csv":
+		part := c.DefaultQuery("part", "results")
+		if part != "results" && part != "failures" {
+			c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+				Code:    string(error_codes.ESGInvalidInput),
+				Message: "part must be results or failures",
+			})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="batch-%s-%s.csv"`, job.ID, part))
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Status(http.StatusOK)
+		_ = writeBatchCSV(c.Writer, report, part)
+	default:
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ESGInvalidInput),
+			Message: "format must be json or csv",
+		})
+	}
+}
+
+func (h *JobsHandler) batchReport(c *gin.Context, job *services.Job) (*dtos.BatchDownloadResponse, error) {
+	var req services.BatchAnalyzeRequest
+	if err := json.Unmarshal(job.Payload, &req); err != nil {
+		return nil, fmt.Errorf("invalid batch payload: %w", err)
+	}
+
+	items, err := h.jobs.Items(c.Request.Context(), job.ID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &dtos.BatchDownloadResponse{
+		JobID:    job.ID,
+		Status:   string(job.Status),
+		Total:    len(req.Companies),
+		Results:  []dtos.BatchResultRow{},
+		Failures: []dtos.BatchFailureRow{},
+	}
+
+	seen := make(map[int]bool, len(items))
+	for _, raw := range items {
+		var item services.BatchItem
+		if err := json.Unmarshal(raw, &item); err != nil || seen[item.Index] {
+			continue
+		}
+		seen[item.Index] = true
+
+		// Rows are masked before the analysis is attached, so it is not
+		// masked twice
+		if item.Status == types.JobSucceeded && item.Result != nil {
+			row := dtos.BatchResultRow{Index: item.Index, Company: item.Company}
+			if middleware.MaskResponse(c, &row) {
+				report.MaskedData = true
+			}
+			if middleware.MaskResponse(c, item.Result) {
+				item.Result.MaskedData = true
+				report.MaskedData = true
+			}
+			row.Analysis = item.Result
+			report.Results = append(report.Results, row)
+		} else {
+			row := dtos.BatchFailureRow{Index: item.Index, Company: item.Company, Error: item.Error}
+			if middleware.MaskResponse(c, &row) {
+				report.MaskedData = true
+			}
+			report.Failures = append(report.Failures, row)
+		}
+	}
+
+	sort.Slice(report.Results, func(i, j int) bool { return report.Results[i].Index < report.Results[j].Index })
+	sort.Slice(report.Failures, func(i, j int) bool { return report.Failures[i].Index < report.Failures[j].Index })
+	report.Succeeded = len(report.Results)
+	report.Failed = len(report.Failures)
+	report.Pending = report.Total - report.Succeeded - report.Failed
+	return report, nil
+}
+
+// writeBatchCSV writes a CSV that opens cleanly in Excel: UTF-8 BOM, CRLF
+// line endings, and cells that could be read as formulas escaped.
+func writeBatchCSV(w io.Writer, report *dtos.BatchDownloadResponse, part string) error {
+	if _, err := io.WriteString(w, utf8BOM); err != nil {
+		return err
+	}
+	out := csv.NewWriter(w)
+	out.UseCRLF = true
+
+	if part == "failures" {
+		_ = out.Write([]string{"index", "company", "error"})
+		for _, row := range report.Failures {
+			_ = out.Write([]string{strconv.Itoa(row.Index + 1), csvCell(row.Company), csvCell(row.Error)})
+		}
+	} else {
+		_ = out.Write([]string{"index", "company", "esg_score", "risk_action", "signal", "symbol",
+			"current_price", "target_price", "price_change", "confidence", "audit_hash"})
+		for _, row := range report.Results {
+			a := row.Analysis
+			_ = out.Write([]string{
+				strconv.Itoa(row.Index + 1),
+				csvCell(row.Company),
+				csvCell(a.ESGScore),
+				csvCell(a.RiskAction),
+				csvCell(a.TradingSignal.Action),
+				csvCell(a.TradingSignal.Symbol),
+				csvCell(a.TradingSignal.CurrentPrice),
+				csvCell(a.TradingSignal.TargetPrice),
+				csvCell(a.TradingSignal.PriceChange),
+				strconv.FormatFloat(a.TradingSignal.Confidence, 'f', -1, 64),
+				a.AuditHash,
+			})
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// csvCell stops spreadsheet applications evaluating untrusted text as a
+// formula. Signed numbers and percentages are left alone.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		if _, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64); err != nil {
+			return "'" + value
+		}
+	}
+	return value
+}
+
+func readBatchCompanies(c *gin.Context) ([]string, error) {
+	mediaType, _, _ := mime.ParseMediaType(c.ContentType())
+	switch mediaType {
+	case "text/csv":
+		return parseCompanyCSV(c.Request.Body)
+	case "multipart/form-data":
+		file, _, err := c.Request.FormFile("file")
+		if err != nil {
+			return nil, errors.New("multipart upload must include a CSV \"file\" field")
+		}
+		defer file.Close()
+		return parseCompanyCSV(file)
+	default:
+		var req dtos.BatchAnalyzeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			return nil, err
+		}
+		return req.Companies, nil
+	}
+}
+
+func parseCompanyCSV(r io.Reader) ([]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, errors.New("CSV file is empty")
+	}
+
+	column, start := 0, 0
+	for i, header := range records[0] {
+		name := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(header, utf8BOM)))
+		if name == "company_name" || name == "company" || name == "name" {
+			column, start = i, 1
+			break
+		}
+	}
+
+	companies := make([]string, 0, len(records)-start)
+	for _, record := range records[start:] {
+		if column < len(record) {
+			companies = append(companies, strings.TrimPrefix(record[column], utf8BOM))
+		}
+	}
+	return companies, nil
+}
+
+// dedupeCompanies trims names and drops blanks and repeats, so a loan book
+// listing an obligor twice costs one analysis.
+func dedupeCompanies(companies []string) []string {
+	seen := make(map[string]bool, len(companies))
+	out := make([]string, 0, len(companies))
+	for _, company := range companies {
+		company = middleware.SanitizeString(company)
+		key := strings.ToLower(company)
+		if company == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, company)
+	}
+	return out
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/edgeesg/edge-esg-backend/internal/dtos"
+	"github.com/edgeesg/edge-esg-backend/internal/error_codes"
+	"github.com/edgeesg/edge-esg-backend/internal/marketdata"
+	"github.com/gin-gonic/gin"
+)
+
+// BenchmarksHandler serves the benchmark definitions in the market data.
+type BenchmarksHandler struct {
+	store *marketdata.FileStore
+}
+
+func NewBenchmarksHandler(store *marketdata.FileStore) *BenchmarksHandler {
+	return &BenchmarksHandler{store: store}
+}
+
+// List names the benchmarks that portfolios can be measured against
+func (h *BenchmarksHandler) List(c *gin.Context) {
+	names, err := h.store.Benchmarks()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dtos.ErrorResponse{
+			Code:    string(error_codes.ESGProcessingFailed),
+			Message: "Failed to list benchmarks",
+			Details: err.Error(),
+		})
+		return
+	}
+	if names == nil {
+		names = []string{}
+	}
+	c.JSON(http.StatusOK, gin.H{"benchmarks": names})
+}
+
+// Get returns a benchmark's constituents and weights
+func (h *BenchmarksHandler) Get(c *gin.Context) {
+	benchmark, err := h.store.Benchmark(c.Param("name"))
+	if err != nil {
+		if !writeBenchmarkError(c, err) {
+			c.JSON(http.StatusInternalServerError, dtos.ErrorResponse{
+				Code:    string(error_codes.ESGProcessingFailed),
+				Message: "Failed to load benchmark",
+				Details: err.Error(),
+			})
+		}
+		return
+	}
+	response := dtos.BenchmarkResponse{
+		Name:         benchmark.Name,
+		Constituents: make([]dtos.ConstituentResponse, len(benchmark.Constituents)),
+	}
+	for i, constituent := range benchmark.Constituents {
+		response.Constituents[i] = dtos.ConstituentResponse{
+			Symbol:          constituent.Symbol,
+			Weight:          constituent.Weight,
+			Sector:          constituent.Sector,
+			Country:         constituent.Country,
+			CarbonIntensity: constituent.CarbonIntensity,
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// writeBenchmarkError reports a benchmark that is misnamed or missing, and
+// returns false for any other error.
+func writeBenchmarkError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, marketdata.ErrInvalidSymbol):
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Invalid benchmark name",
+			Details: err.Error(),
+		})
+	case errors.Is(err, marketdata.ErrNoData):
+		c.JSON(http.StatusNotFound, dtos.ErrorResponse{
+			Code:    string(error_codes.MarketDataNotFound),
+			Message: "Benchmark not available",
+			Details: err.Error(),
+		})
+	default:
+		return false
+	}
+	return true
+}
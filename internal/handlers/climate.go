@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/edgeesg/edge-esg-backend/internal/climate"
+	"github.com/edgeesg/edge-esg-backend/internal/dtos"
+	"github.com/edgeesg/edge-esg-backend/internal/error_codes"
+	"github.com/edgeesg/edge-esg-backend/internal/marketdata"
+	"github.com/edgeesg/edge-esg-backend/internal/middleware"
+	"github.com/edgeesg/edge-esg-backend/internal/services"
+	"github.com/edgeesg/edge-esg-backend/internal/validator"
+	"github.com/gin-gonic/gin"
+)
+
+// ClimateHandler serves climate scenarios and stress tests against them.
+type ClimateHandler struct {
+	stress *services.ClimateStressService
+}
+
+func NewClimateHandler(stress *services.ClimateStressService) *ClimateHandler {
+	return &ClimateHandler{stress: stress}
+}
+
+// Scenarios lists the climate scenarios that can be stress tested against
+func (h *ClimateHandler) Scenarios(c *gin.Context) {
+	scenarios, err := h.stress.Scenarios()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dtos.ErrorResponse{
+			Code:    string(error_codes.ESGProcessingFailed),
+			Message: "Failed to list scenarios",
+			Details: err.Error(),
+		})
+		return
+	}
+	response := make([]dtos.ScenarioResponse, len(scenarios))
+	for i, scenario := range scenarios {
+		response[i] = dtos.ScenarioResponse{
+			Name:        scenario.Name,
+			Category:    scenario.Category,
+			Description: scenario.Description,
+			Years:       scenario.Years,
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"scenarios": response})
+}
+
+// Scenario returns a scenario's paths, economy-wide and by sector
+func (h *ClimateHandler) Scenario(c *gin.Context) {
+	scenario, err := h.stress.Scenario(c.Param("name"))
+	if err != nil {
+		writeClimateError(c, err, "Failed to load scenario")
+		return
+	}
+	economy := toScenarioShocks(scenario.Economy)
+	response := dtos.ScenarioResponse{
+		Name:        scenario.Name,
+		Category:    scenario.Category,
+		Description: scenario.Description,
+		Years:       scenario.Years,
+		Economy:     &economy,
+		Sectors:     make(map[string]dtos.ScenarioShocks, len(scenario.Sectors)),
+	}
+	for sector, shocks := range scenario.Sectors {
+		response.Sectors[sector] = toScenarioShocks(shocks)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// StressTest projects companies, and the portfolio of their exposures,
+// through climate scenarios to 2050
+func (h *ClimateHandler) StressTest(c *gin.Context) {
+	var req dtos.ClimateStressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+	if err := validator.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Validation failed",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	companies := make([]climate.Company, len(req.Companies))
+	for i, company := range req.Companies {
+		companies[i] = climate.Company{
+			Name:            company.Name,
+			Sector:          company.Sector,
+			Revenue:         company.Revenue,
+			EBITDA:          company.EBITDA,
+			Emissions:       company.Emissions,
+			EnterpriseValue: company.EnterpriseValue,
+			Debt:            company.TotalDebt,
+			PD:              company.PD,
+			AssetVolatility: company.AssetVolatility,
+			Exposure:        company.Exposure,
+			AssetClass:      company.AssetClass,
+			LGD:             company.LGD,
+		}
+	}
+	results, err := h.stress.StressTest(c.Request.Context(), req.Scenarios, companies, req.DiscountRate)
+	if err != nil {
+		writeClimateError(c, err, "Failed to run stress test")
+		return
+	}
+
+	response := dtos.ClimateStressResponse{
+		Scenarios: make([]dtos.ClimateScenarioResult, len(results)),
+		Timestamp: time.Now(),
+	}
+	for i, result := range results {
+		response.Scenarios[i] = toClimateScenarioResult(result)
+	}
+	response.MaskedData = middleware.MaskResponse(c, &response)
+	c.JSON(http.StatusOK, response)
+}
+
+func writeClimateError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, climate.ErrInvalidCompany):
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Invalid company",
+			Details: err.Error(),
+		})
+	case errors.Is(err, marketdata.ErrInvalidSymbol):
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Invalid scenario name",
+			Details: err.Error(),
+		})
+	case errors.Is(err, marketdata.ErrNoData):
+		c.JSON(http.StatusNotFound, dtos.ErrorResponse{
+			Code:    string(error_codes.MarketDataNotFound),
+			Message: "Scenario not available",
+			Details: err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, dtos.ErrorResponse{
+			Code:    string(error_codes.ESGProcessingFailed),
+			Message: message,
+			Details: err.Error(),
+		})
+	}
+}
+
+func toScenarioShocks(shocks marketdata.Shocks) dtos.ScenarioShocks {
+	points := func(path marketdata.Path) []dtos.ScenarioPoint {
+		out := make([]dtos.ScenarioPoint, len(path))
+		for i, point := range path {
+			out[i] = dtos.ScenarioPoint{Year: point.Year, Value: point.Value}
+		}
+		return out
+	}
+	return dtos.ScenarioShocks{
+		CarbonPrice:     points(shocks.CarbonPrice),
+		RevenueShock:    points(shocks.RevenueShock),
+		EmissionsChange: points(shocks.EmissionsChange),
+		PassThrough:     points(shocks.PassThrough),
+	}
+}
+
+func toClimateScenarioResult(result *climate.Result) dtos.ClimateScenarioResult {
+	out := dtos.ClimateScenarioResult{
+		Scenario:    result.Scenario.Name,
+		Category:    result.Scenario.Category,
+		Description: result.Scenario.Description,
+		Companies:   make([]dtos.ClimateCompanyResult, len(result.Companies)),
+	}
+	for i, company := range result.Companies {
+		line := dtos.ClimateCompanyResult{
+			Name:               company.Name,
+			Sector:             company.Sector,
+			Emissions:          company.Emissions,
+			EmissionsEstimated: company.EmissionsEstimated,
+			BasePD:             company.BasePD,
+			Years:              make([]dtos.ClimateCompanyYear, len(company.Years)),
+		}
+		for k, year := range company.Years {
+			line.Years[k] = dtos.ClimateCompanyYear{
+				Year:            year.Year,
+				CarbonPrice:     year.CarbonPrice,
+				CarbonCost:      year.CarbonCost,
+				Revenue:         year.Revenue,
+				EBITDA:          year.EBITDA,
+				EBITDAChange:    year.EBITDAChange,
+				ValuationChange: year.ValuationChange,
+				EquityChange:    year.EquityChange,
+				PD:              year.PD,
+				PDChangeBps:     year.PDChange * 10000,
+			}
+		}
+		out.Companies[i] = line
+	}
+	for _, year := range result.Portfolio {
+		out.Portfolio = append(out.Portfolio, dtos.ClimatePortfolioYear{
+			Year:         year.Year,
+			Exposure:     year.Exposure,
+			EquityLoss:   year.EquityLoss,
+			CreditLoss:   year.CreditLoss,
+			Loss:         year.Loss,
+			LossShare:    year.LossShare,
+			EBITDAChange: year.EBITDAChange,
+			PD:           year.PD,
+		})
+	}
+	return out
+}
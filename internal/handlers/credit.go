@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/edgeesg/edge-esg-backend/internal/climate"
+	"github.com/edgeesg/edge-esg-backend/internal/credit"
+	"github.com/edgeesg/edge-esg-backend/internal/dtos"
+	"github.com/edgeesg/edge-esg-backend/internal/error_codes"
+	"github.com/edgeesg/edge-esg-backend/internal/middleware"
+	"github.com/edgeesg/edge-esg-backend/internal/models"
+	"github.com/edgeesg/edge-esg-backend/internal/services"
+	"github.com/edgeesg/edge-esg-backend/internal/types"
+	"github.com/edgeesg/edge-esg-backend/internal/validator"
+	"github.com/gin-gonic/gin"
+)
+
+// CreditHandler serves banks' ESG and climate credit overlay policies,
+// adjustments under them and the history of adjustments made.
+type CreditHandler struct {
+	overlays *services.CreditOverlayService
+}
+
+func NewCreditHandler(overlays *services.CreditOverlayService) *CreditHandler {
+	return &CreditHandler{overlays: overlays}
+}
+
+// Policy returns the bank's overlay policy in force
+func (h *CreditHandler) Policy(c *gin.Context) {
+	bankID, ok := signalBankID(c)
+	if !ok {
+		return
+	}
+	policy, version, err := h.overlays.Policy(c.Request.Context(), bankID)
+	if err != nil {
+		writeCreditError(c, err, "Failed to load credit overlay policy")
+		return
+	}
+	c.JSON(http.StatusOK, toCreditPolicyResponse(policy, version))
+}
+
+// ReplacePolicy stores a new version of the bank's overlay policy. Only a
+// signed-in RISK or ADMIN user may change it, so every version has someone
+// accountable for it.
+func (h *CreditHandler) ReplacePolicy(c *gin.Context) {
+	bankID, ok := signalBankID(c)
+	if !ok {
+		return
+	}
+	user := c.GetString("user_email")
+	if user == "" {
+		c.JSON(http.StatusForbidden, dtos.ErrorResponse{
+			Code:    string(error_codes.AuthForbidden),
+			Message: "Credit overlay policy changes require a signed-in user",
+		})
+		return
+	}
+	if !hasRole(middleware.UserRoles(c), types.RoleRisk, types.RoleAdmin) {
+		c.JSON(http.StatusForbidden, dtos.ErrorResponse{
+			Code:    string(error_codes.AuthForbidden),
+			Message: "Credit overlay policy changes require the RISK or ADMIN role",
+		})
+		return
+	}
+	var req dtos.CreditPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+	if err := validator.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Validation failed",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	policy := credit.Policy{
+		Rules:        make([]credit.Rule, len(req.Rules)),
+		MaxDowngrade: req.MaxDowngrade,
+		MaxUpgrade:   req.MaxUpgrade,
+		PDFloor:      req.PDFloor,
+	}
+	for i, rule := range req.Rules {
+		policy.Rules[i] = credit.Rule(rule)
+	}
+	version, err := h.overlays.ReplacePolicy(c.Request.Context(), bankID, user, policy)
+	if err != nil {
+		writeCreditError(c, err, "Failed to store credit overlay policy")
+		return
+	}
+
+	middleware.AuditLog("CREDIT_OVERLAY_POLICY_REPLACED", map[string]interface{}{
+		"bank_id": bankID.String(),
+		"user":    user,
+		"version": version,
+		"rules":   len(policy.Rules),
+	})
+	c.JSON(http.StatusOK, toCreditPolicyResponse(policy, version))
+}
+
+// Adjust adjusts obligors' ratings and PDs and records the adjustments
+func (h *CreditHandler) Adjust(c *gin.Context) {
+	bankID, ok := signalBankID(c)
+	if !ok {
+		return
+	}
+	var req dtos.CreditAdjustRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+	if err := validator.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Validation failed",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	obligors := make([]services.CreditObligor, len(req.Obligors))
+	for i, obligor := range req.Obligors {
+		obligors[i] = services.CreditObligor{
+			Obligor: credit.Obligor{
+				Name:    obligor.Name,
+				Rating:  obligor.Rating,
+				PD:      obligor.PD,
+				Factors: obligor.Factors,
+			},
+			Financials: toCreditFinancials(obligor.Financials),
+		}
+	}
+	adjustments, err := h.overlays.Adjust(c.Request.Context(), bankID, middleware.Actor(c), services.CreditSourceAPI, obligors)
+	if err != nil {
+		writeCreditError(c, err, "Failed to adjust credit risk")
+		return
+	}
+
+	middleware.AuditLog("CREDIT_ADJUSTMENTS_RECORDED", map[string]interface{}{
+		"bank_id":     bankID.String(),
+		"actor":       middleware.Actor(c),
+		"adjustments": len(adjustments),
+	})
+	response := toCreditAdjustmentsResponse(adjustments)
+	response.MaskedData = middleware.MaskResponse(c, &response)
+	c.JSON(http.StatusOK, response)
+}
+
+// History lists recorded adjustments, newest first, optionally for one
+// obligor
+func (h *CreditHandler) History(c *gin.Context) {
+	bankID, ok := signalBankID(c)
+	if !ok {
+		return
+	}
+	limit := defaultSignalPageSize
+	if n, err := strconv.Atoi(c.Query("limit")); err == nil && n > 0 {
+		limit = min(n, maxSignalPageSize)
+	}
+	offset := 0
+	if n, err := strconv.Atoi(c.Query("offset")); err == nil && n > 0 {
+		offset = n
+	}
+
+	adjustments, err := h.overlays.History(c.Request.Context(), bankID, c.Query("obligor"), limit, offset)
+	if err != nil {
+		writeCreditError(c, err, "Failed to list credit adjustments")
+		return
+	}
+	response := toCreditAdjustmentsResponse(adjustments)
+	response.MaskedData = middleware.MaskResponse(c, &response)
+	c.JSON(http.StatusOK, response)
+}
+
+// toCreditFinancials is the climate stress test's view of an obligor's
+// financials, or nil without them.
+func toCreditFinancials(financials *dtos.CreditFinancialsRequest) *climate.Company {
+	if financials == nil {
+		return nil
+	}
+	return &climate.Company{
+		Sector:          financials.Sector,
+		Revenue:         financials.Revenue,
+		EBITDA:          financials.EBITDA,
+		Emissions:       financials.Emissions,
+		EnterpriseValue: financials.EnterpriseValue,
+		Debt:            financials.TotalDebt,
+		AssetVolatility: financials.AssetVolatility,
+	}
+}
+
+func hasRole(roles []string, allowed ...types.UserRole) bool {
+	for _, role := range roles {
+		for _, a := range allowed {
+			if role == string(a) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func writeCreditError(c *gin.Context, err error, message string) {
+	if errors.Is(err, credit.ErrInvalidPolicy) || errors.Is(err, credit.ErrInvalidObligor) {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Invalid credit overlay input",
+			Details: err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, dtos.ErrorResponse{
+		Code:    string(error_codes.ESGProcessingFailed),
+		Message: message,
+		Details: err.Error(),
+	})
+}
+
+func toCreditPolicyResponse(policy credit.Policy, version int) dtos.CreditPolicyResponse {
+	response := dtos.CreditPolicyResponse{
+		Version: version,
+		CreditPolicyRequest: dtos.CreditPolicyRequest{
+			Rules:        make([]dtos.CreditRuleRequest, len(policy.Rules)),
+			MaxDowngrade: policy.MaxDowngrade,
+			MaxUpgrade:   policy.MaxUpgrade,
+			PDFloor:      policy.PDFloor,
+		},
+		MasterScale: make([]dtos.CreditGrade, len(credit.MasterScale)),
+	}
+	for i, rule := range policy.Rules {
+		response.Rules[i] = dtos.CreditRuleRequest(rule)
+	}
+	for i, grade := range credit.MasterScale {
+		response.MasterScale[i] = dtos.CreditGrade(grade)
+	}
+	return response
+}
+
+func toCreditAdjustmentsResponse(adjustments []models.CreditAdjustment) dtos.CreditAdjustmentsResponse {
+	response := dtos.CreditAdjustmentsResponse{Adjustments: make([]dtos.CreditAdjustmentResponse, len(adjustments))}
+	for i, row := range adjustments {
+		response.Adjustments[i] = dtos.CreditAdjustmentResponse{
+			ID:              row.ID.String(),
+			Obligor:         row.Obligor,
+			PolicyVersion:   row.PolicyVersion,
+			Source:          row.Source,
+			BaseRating:      row.BaseRating,
+			BasePD:          row.BasePD,
+			AdjustedRating:  row.AdjustedRating,
+			AdjustedPD:      row.AdjustedPD,
+			RuleNotches:     row.RuleNotches,
+			Notches:         row.Notches,
+			InvestmentGrade: credit.InvestmentGrade(row.AdjustedRating),
+			Factors:         row.Factors,
+			AppliedRules:    make([]dtos.CreditRuleRequest, len(row.AppliedRules)),
+			CreatedBy:       row.CreatedBy,
+			CreatedAt:       row.CreatedAt,
+		}
+		for j, rule := range row.AppliedRules {
+			response.Adjustments[i].AppliedRules[j] = dtos.CreditRuleRequest(rule)
+		}
+	}
+	return response
+}
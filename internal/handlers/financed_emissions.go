@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/edgeesg/edge-esg-backend/internal/dtos"
+	"github.com/edgeesg/edge-esg-backend/internal/error_codes"
+	"github.com/edgeesg/edge-esg-backend/internal/middleware"
+	"github.com/edgeesg/edge-esg-backend/internal/pcaf"
+	"github.com/edgeesg/edge-esg-backend/internal/services"
+	"github.com/edgeesg/edge-esg-backend/internal/validator"
+	"github.com/gin-gonic/gin"
+)
+
+// FinancedEmissionsHandler serves the loans and investments of stored
+// portfolios and their PCAF financed emissions reports.
+type FinancedEmissionsHandler struct {
+	emissions *services.FinancedEmissionsService
+}
+
+func NewFinancedEmissionsHandler(emissions *services.FinancedEmissionsService) *FinancedEmissionsHandler {
+	return &FinancedEmissionsHandler{emissions: emissions}
+}
+
+// ReplaceExposures overwrites a portfolio's exposures
+func (h *FinancedEmissionsHandler) ReplaceExposures(c *gin.Context) {
+	bankID, portfolioID, ok := portfolioParams(c)
+	if !ok {
+		return
+	}
+	var req dtos.ExposuresRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+	if err := validator.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Validation failed",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	exposures := make([]pcaf.Exposure, len(req.Exposures))
+	for i, exposure := range req.Exposures {
+		exposures[i] = pcaf.Exposure{
+			Counterparty:    exposure.Counterparty,
+			Symbol:          exposure.Symbol,
+			AssetClass:      exposure.AssetClass,
+			Sector:          exposure.Sector,
+			Outstanding:     exposure.OutstandingAmount,
+			EVIC:            exposure.EVIC,
+			EquityPlusDebt:  exposure.TotalEquityDebt,
+			Revenue:         exposure.Revenue,
+			Emissions:       exposure.Emissions,
+			Scope3:          exposure.Scope3Emissions,
+			EmissionsSource: exposure.EmissionsSource,
+		}
+	}
+	stored, err := h.emissions.ReplaceExposures(c.Request.Context(), bankID, portfolioID, exposures)
+	if err != nil {
+		writeFinancedEmissionsError(c, err, "Failed to store exposures")
+		return
+	}
+
+	middleware.AuditLog("PORTFOLIO_EXPOSURES_REPLACED", map[string]interface{}{
+		"bank_id":      bankID.String(),
+		"portfolio_id": portfolioID.String(),
+		"exposures":    len(stored),
+	})
+	response := toExposuresResponse(portfolioID.String(), stored)
+	response.MaskedData = middleware.MaskResponse(c, &response)
+	c.JSON(http.StatusOK, response)
+}
+
+// Exposures lists a portfolio's exposures
+func (h *FinancedEmissionsHandler) Exposures(c *gin.Context) {
+	bankID, portfolioID, ok := portfolioParams(c)
+	if !ok {
+		return
+	}
+	exposures, err := h.emissions.Exposures(c.Request.Context(), bankID, portfolioID)
+	if err != nil {
+		writeFinancedEmissionsError(c, err, "Failed to load exposures")
+		return
+	}
+	response := toExposuresResponse(portfolioID.String(), exposures)
+	response.MaskedData = middleware.MaskResponse(c, &response)
+	c.JSON(http.StatusOK, response)
+}
+
+// Report returns a portfolio's financed emissions, by sector and asset class
+func (h *FinancedEmissionsHandler) Report(c *gin.Context) {
+	bankID, portfolioID, ok := portfolioParams(c)
+	if !ok {
+		return
+	}
+	report, err := h.emissions.Report(c.Request.Context(), bankID, portfolioID)
+	if err != nil {
+		writeFinancedEmissionsError(c, err, "Failed to report financed emissions")
+		return
+	}
+
+	response := dtos.FinancedEmissionsResponse{
+		PortfolioID:              portfolioID.String(),
+		FinancedEmissionsSummary: toEmissionsSummary(report.Summary),
+		BySector:                 make([]dtos.FinancedEmissionsSummary, len(report.BySector)),
+		ByAssetClass:             make([]dtos.FinancedEmissionsSummary, len(report.ByAssetClass)),
+		Exposures:                make([]dtos.FinancedExposureResponse, len(report.Exposures)),
+		Timestamp:                time.Now(),
+	}
+	for i, summary := range report.BySector {
+		response.BySector[i] = toEmissionsSummary(summary)
+	}
+	for i, summary := range report.ByAssetClass {
+		response.ByAssetClass[i] = toEmissionsSummary(summary)
+	}
+	for i, financed := range report.Exposures {
+		response.Exposures[i] = dtos.FinancedExposureResponse{
+			Counterparty:      financed.Counterparty,
+			Symbol:            financed.Symbol,
+			AssetClass:        financed.AssetClass,
+			Sector:            financed.Sector,
+			OutstandingAmount: financed.Outstanding,
+			AttributionFactor: financed.AttributionFactor,
+			Method:            financed.Method,
+			DataQualityScore:  financed.DataQuality,
+			CompanyEmissions:  financed.CompanyEmissions,
+			FinancedEmissions: financed.FinancedEmissions,
+			FinancedScope3:    financed.FinancedScope3,
+			CarbonIntensity:   financed.CarbonIntensity,
+		}
+	}
+	response.MaskedData = middleware.MaskResponse(c, &response)
+	c.JSON(http.StatusOK, response)
+}
+
+func writeFinancedEmissionsError(c *gin.Context, err error, message string) {
+	if errors.Is(err, pcaf.ErrInvalidExposure) {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Invalid exposure",
+			Details: err.Error(),
+		})
+		return
+	}
+	writePortfolioError(c, err, message)
+}
+
+func toExposuresResponse(portfolioID string, exposures []pcaf.Exposure) dtos.ExposuresResponse {
+	response := dtos.ExposuresResponse{
+		PortfolioID: portfolioID,
+		Exposures:   make([]dtos.ExposureRequest, len(exposures)),
+	}
+	for i, exposure := range exposures {
+		response.Exposures[i] = dtos.ExposureRequest{
+			Counterparty:      exposure.Counterparty,
+			Symbol:            exposure.Symbol,
+			AssetClass:        exposure.AssetClass,
+			Sector:            exposure.Sector,
+			OutstandingAmount: exposure.Outstanding,
+			EVIC:              exposure.EVIC,
+			TotalEquityDebt:   exposure.EquityPlusDebt,
+			Revenue:           exposure.Revenue,
+			Emissions:         exposure.Emissions,
+			Scope3Emissions:   exposure.Scope3,
+			EmissionsSource:   exposure.EmissionsSource,
+		}
+	}
+	return response
+}
+
+func toEmissionsSummary(summary pcaf.Summary) dtos.FinancedEmissionsSummary {
+	return dtos.FinancedEmissionsSummary{
+		Group:             summary.Key,
+		Exposures:         summary.Count,
+		OutstandingAmount: summary.Outstanding,
+		FinancedEmissions: summary.FinancedEmissions,
+		FinancedScope3:    summary.FinancedScope3,
+		Share:             summary.Share,
+		Coverage:          summary.Coverage,
+		EmissionIntensity: summary.EmissionIntensity,
+		WACI:              summary.WACI,
+		WACICoverage:      summary.WACICoverage,
+		DataQualityScore:  summary.DataQuality,
+	}
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/edgeesg/edge-esg-backend/internal/dtos"
+	"github.com/edgeesg/edge-esg-backend/internal/error_codes"
+	"github.com/edgeesg/edge-esg-backend/internal/middleware"
+	"github.com/edgeesg/edge-esg-backend/internal/services"
+	"github.com/edgeesg/edge-esg-backend/internal/types"
+	"github.com/edgeesg/edge-esg-backend/internal/validator"
+	"github.com/gin-gonic/gin"
+)
+
+const jobStreamPoll = time.Second
+
+type JobsHandler struct {
+	jobs *services.JobService
+	hub  *WSHub
+}
+
+func NewJobsHandler(jobs *services.JobService, hub *WSHub) *JobsHandler {
+	return &JobsHandler{jobs: jobs, hub: hub}
+}
+
+// SubmitAnalyze queues an analysis and returns its job ID immediately
+func (h *JobsHandler) SubmitAnalyze(c *gin.Context) {
+	var req dtos.AnalyzeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ESGInvalidInput),
+			Message: "Invalid request body",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	bankID := middleware.ResolveBankID(c)
+	if bankID != "" {
+		req.BankID = bankID
+	}
+
+	if err := validator.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ESGInvalidInput),
+			Message: "Validation failed",
+			Details: err.Error(),
+		})
+		return
+	}
+	req.UserRole = middleware.ResolveRole(c)
+
+	h.submit(c, types.JobAnalyze, bankID, &req)
+}
+
+// Get returns a job's status, progress and, once finished, its result
+func (h *JobsHandler) Get(c *gin.Context) {
+	job, err := h.jobs.Get(c.Request.Context(), c.Param("job_id"), middleware.ResolveBankID(c))
+	if err != nil {
+		writeJobError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toJobResponse(c, job))
+}
+
+// Items streams a job's per-item results as NDJSON while it runs, ending once
+// the job has finished and every item has been sent.
+func (h *JobsHandler) Items(c *gin.Context) {
+	bankID := middleware.ResolveBankID(c)
+	job, err := h.jobs.Get(c.Request.Context(), c.Param("job_id"), bankID)
+	if err != nil {
+		writeJobError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	ctx := c.Request.Context()
+	var offset int64
+	c.Stream(func(w io.Writer) bool {
+		items, err := h.jobs.Items(ctx, job.ID, offset)
+		if err != nil {
+			return false
+		}
+		for _, raw := range items {
+			var item interface{}
+			if err := json.Unmarshal(raw, &item); err != nil {
+				continue
+			}
+			if middleware.MaskResponse(c, item) {
+				if fields, ok := item.(map[string]interface{}); ok {
+					if result, ok := fields["result"].(map[string]interface{}); ok {
+						result["masked_data"] = true
+					}
+				}
+			}
+			line, _ := json.Marshal(item)
+			if _, err := w.Write(append(line, '\n')); err != nil {
+				return false
+			}
+		}
+		offset += int64(len(items))
+		if len(items) > 0 {
+			return true
+		}
+
+		if job.IsFinished() {
+			return false
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(jobStreamPoll):
+		}
+		job, err = h.jobs.Get(ctx, job.ID, bankID)
+		return err == nil
+	})
+}
+
+// Cancel stops a queued or running job
+func (h *JobsHandler) Cancel(c *gin.Context) {
+	job, err := h.jobs.Cancel(c.Request.Context(), c.Param("job_id"), middleware.ResolveBankID(c))
+	if err != nil {
+		writeJobError(c, err)
+		return
+	}
+
+	middleware.AuditLog("JOB_CANCELLED", map[string]interface{}{
+		"job_id":  job.ID,
+		"bank_id": job.BankID,
+	})
+	c.JSON(http.StatusAccepted, toJobResponse(c, job))
+}
+
+func (h *JobsHandler) submit(c *gin.Context, jobType types.JobType, bankID string, payload interface{}) {
+	job, err := h.jobs.Submit(c.Request.Context(), jobType, bankID, payload)
+	if err != nil {
+		writeJobError(c, err)
+		return
+	}
+
+	// The submitting WebSocket connection follows the job's progress
+	if clientID := c.GetHeader(ClientIDHeader); clientID != "" && h.hub != nil {
+		h.hub.SubscribeClient(clientID, bankID, job.ID)
+	}
+
+	c.Header("Location", "/api/v1/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, toJobResponse(c, job))
+}
+
+func toJobResponse(c *gin.Context, job *services.Job) dtos.JobResponse {
+	response := dtos.JobResponse{
+		JobID:      job.ID,
+		Type:       string(job.Type),
+		Status:     string(job.Status),
+		Progress:   job.Progress,
+		Error:      job.Error,
+		CreatedAt:  job.CreatedAt,
+		StartedAt:  job.StartedAt,
+		FinishedAt: job.FinishedAt,
+	}
+
+	// Results are stored unmasked; each reader sees them as their role allows.
+	if len(job.Result) > 0 {
+		var result interface{}
+		if err := json.Unmarshal(job.Result, &result); err == nil {
+			response.MaskedData = middleware.MaskResponse(c, result)
+			response.Result = result
+		}
+	}
+	return response
+}
+
+func writeJobError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrJobNotFound):
+		c.JSON(http.StatusNotFound, dtos.ErrorResponse{
+			Code:    string(error_codes.JobNotFound),
+			Message: "Job not found",
+		})
+	case errors.Is(err, services.ErrJobFinished):
+		c.JSON(http.StatusConflict, dtos.ErrorResponse{
+			Code:    string(error_codes.JobAlreadyFinished),
+			Message: "Job already finished",
+		})
+	default:
+		c.JSON(http.StatusServiceUnavailable, dtos.ErrorResponse{
+			Code:    string(error_codes.JobQueueUnavailable),
+			Message: "Job queue unavailable",
+			Details: err.Error(),
+		})
+	}
+}
@@ -0,0 +1,379 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/edgeesg/edge-esg-backend/internal/dtos"
+	"github.com/edgeesg/edge-esg-backend/internal/error_codes"
+	"github.com/edgeesg/edge-esg-backend/internal/marketdata"
+	"github.com/edgeesg/edge-esg-backend/internal/middleware"
+	"github.com/edgeesg/edge-esg-backend/internal/models"
+	"github.com/edgeesg/edge-esg-backend/internal/services"
+	"github.com/edgeesg/edge-esg-backend/internal/validator"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPaperCash         = 1000000
+	defaultPaperPositionSize = 0.1
+
+	defaultPaperDays = 90
+	maxPaperDays     = 3650
+)
+
+type PaperTradingHandler struct {
+	paper *services.PaperTradingService
+}
+
+func NewPaperTradingHandler(paper *services.PaperTradingService) *PaperTradingHandler {
+	return &PaperTradingHandler{paper: paper}
+}
+
+// Create opens a paper portfolio, owned by the signed-in user unless shared
+func (h *PaperTradingHandler) Create(c *gin.Context) {
+	bankID, ok := signalBankID(c)
+	if !ok {
+		return
+	}
+
+	var req dtos.CreatePaperPortfolioRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+	if err := validator.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Validation failed",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	in := services.PaperPortfolioInput{
+		Name:          req.Name,
+		InitialCash:   req.InitialCash,
+		PositionSize:  req.PositionSize,
+		CostBps:       req.CostBps,
+		SlippageBps:   req.SlippageBps,
+		FollowSignals: req.FollowSignals == nil || *req.FollowSignals,
+		Shared:        req.Shared,
+	}
+	if in.InitialCash == 0 {
+		in.InitialCash = defaultPaperCash
+	}
+	if in.PositionSize == 0 {
+		in.PositionSize = defaultPaperPositionSize
+	}
+
+	portfolio, err := h.paper.CreatePortfolio(c.Request.Context(), bankID, c.GetString("user_email"), in)
+	if err != nil {
+		writePaperError(c, err, "Failed to create paper portfolio")
+		return
+	}
+
+	middleware.AuditLog("PAPER_PORTFOLIO_CREATED", map[string]interface{}{
+		"bank_id":      bankID.String(),
+		"portfolio_id": portfolio.ID.String(),
+		"owner":        portfolio.Owner,
+	})
+	c.JSON(http.StatusCreated, toPaperPortfolioResponse(portfolio))
+}
+
+// List returns the caller's and the bank's shared paper portfolios
+func (h *PaperTradingHandler) List(c *gin.Context) {
+	bankID, ok := signalBankID(c)
+	if !ok {
+		return
+	}
+	portfolios, err := h.paper.Portfolios(c.Request.Context(), bankID, c.GetString("user_email"))
+	if err != nil {
+		writePaperError(c, err, "Failed to list paper portfolios")
+		return
+	}
+	response := make([]dtos.PaperPortfolioResponse, 0, len(portfolios))
+	for i := range portfolios {
+		response = append(response, toPaperPortfolioResponse(&portfolios[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"portfolios": response})
+}
+
+// Get returns a paper portfolio marked to the latest quotes
+func (h *PaperTradingHandler) Get(c *gin.Context) {
+	bankID, portfolioID, ok := portfolioParams(c)
+	if !ok {
+		return
+	}
+	valuation, err := h.paper.Portfolio(c.Request.Context(), bankID, c.GetString("user_email"), portfolioID)
+	if err != nil {
+		writePaperError(c, err, "Failed to load paper portfolio")
+		return
+	}
+
+	response := toPaperPortfolioResponse(&valuation.Portfolio)
+	response.Valuation = &dtos.PaperValuationResponse{
+		MarketValue:      valuation.MarketValue,
+		Equity:           valuation.Equity,
+		ESGScore:         valuation.ESGScore,
+		ESGLeaderWeight:  valuation.ESGLeaderWeight,
+		ESGLaggardWeight: valuation.ESGLaggardWeight,
+		Positions:        make([]dtos.PaperPositionResponse, 0, len(valuation.Holdings)),
+	}
+	if valuation.Portfolio.InitialCash > 0 {
+		response.Valuation.TotalReturn = valuation.Equity/valuation.Portfolio.InitialCash - 1
+	}
+	for _, holding := range valuation.Holdings {
+		position := dtos.PaperPositionResponse{
+			Symbol:        holding.Symbol,
+			Quantity:      holding.Quantity,
+			CostBasis:     holding.CostBasis,
+			Quote:         holding.Quote,
+			MarketValue:   holding.MarketValue,
+			UnrealizedPnL: holding.UnrealizedPnL,
+			ESGScore:      holding.CurrentESG,
+			OpenedAt:      holding.OpenedAt,
+		}
+		if !holding.QuoteDate.IsZero() {
+			position.QuoteDate = holding.QuoteDate.Format(marketdata.DateLayout)
+		}
+		if valuation.Equity > 0 {
+			position.Weight = holding.MarketValue / valuation.Equity
+		}
+		response.Valuation.Positions = append(response.Valuation.Positions, position)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Update turns following approved signals on or off
+func (h *PaperTradingHandler) Update(c *gin.Context) {
+	bankID, portfolioID, ok := portfolioParams(c)
+	if !ok {
+		return
+	}
+
+	var req dtos.UpdatePaperPortfolioRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+	if err := validator.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Validation failed",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	portfolio, err := h.paper.SetFollowSignals(c.Request.Context(), bankID, c.GetString("user_email"), portfolioID, *req.FollowSignals)
+	if err != nil {
+		writePaperError(c, err, "Failed to update paper portfolio")
+		return
+	}
+	c.JSON(http.StatusOK, toPaperPortfolioResponse(portfolio))
+}
+
+// Delete removes a paper portfolio with its orders and history
+func (h *PaperTradingHandler) Delete(c *gin.Context) {
+	bankID, portfolioID, ok := portfolioParams(c)
+	if !ok {
+		return
+	}
+	if err := h.paper.DeletePortfolio(c.Request.Context(), bankID, c.GetString("user_email"), portfolioID); err != nil {
+		writePaperError(c, err, "Failed to delete paper portfolio")
+		return
+	}
+	middleware.AuditLog("PAPER_PORTFOLIO_DELETED", map[string]interface{}{
+		"bank_id":      bankID.String(),
+		"portfolio_id": portfolioID.String(),
+	})
+	c.Status(http.StatusNoContent)
+}
+
+// Orders lists a paper portfolio's orders, newest first
+func (h *PaperTradingHandler) Orders(c *gin.Context) {
+	bankID, portfolioID, ok := portfolioParams(c)
+	if !ok {
+		return
+	}
+	limit := defaultSignalPageSize
+	if n, err := strconv.Atoi(c.Query("limit")); err == nil && n > 0 {
+		limit = min(n, maxSignalPageSize)
+	}
+	offset := 0
+	if n, err := strconv.Atoi(c.Query("offset")); err == nil && n > 0 {
+		offset = n
+	}
+
+	orders, err := h.paper.Orders(c.Request.Context(), bankID, c.GetString("user_email"), portfolioID, limit, offset)
+	if err != nil {
+		writePaperError(c, err, "Failed to list paper orders")
+		return
+	}
+	response := make([]dtos.PaperOrderResponse, 0, len(orders))
+	for i := range orders {
+		response = append(response, toPaperOrderResponse(&orders[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"orders": response, "limit": limit, "offset": offset})
+}
+
+// PlaceOrder trades an approved signal in the portfolio. An order that
+// cannot fill is stored and returned with status REJECTED.
+func (h *PaperTradingHandler) PlaceOrder(c *gin.Context) {
+	bankID, portfolioID, ok := portfolioParams(c)
+	if !ok {
+		return
+	}
+
+	var req dtos.PaperOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+	if err := validator.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Validation failed",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Validated above
+	signalID, _ := uuid.Parse(req.SignalID)
+	order, err := h.paper.TradeSignal(c.Request.Context(), bankID, c.GetString("user_email"), portfolioID, signalID)
+	if err != nil {
+		writePaperError(c, err, "Failed to place paper order")
+		return
+	}
+	c.JSON(http.StatusCreated, toPaperOrderResponse(order))
+}
+
+// Performance reports daily equity, returns and ESG exposure
+func (h *PaperTradingHandler) Performance(c *gin.Context) {
+	bankID, portfolioID, ok := portfolioParams(c)
+	if !ok {
+		return
+	}
+	days := defaultPaperDays
+	if n, err := strconv.Atoi(c.Query("days")); err == nil && n > 0 {
+		days = min(n, maxPaperDays)
+	}
+
+	report, err := h.paper.Performance(c.Request.Context(), bankID, c.GetString("user_email"), portfolioID, days)
+	if err != nil {
+		writePaperError(c, err, "Failed to load paper performance")
+		return
+	}
+
+	response := dtos.PaperPerformanceResponse{
+		PortfolioID: portfolioID.String(),
+		Days:        days,
+		Performance: report.Performance,
+		Daily:       make([]dtos.PaperSnapshotResponse, 0, len(report.Snapshots)),
+	}
+	for i, snapshot := range report.Snapshots {
+		daily := dtos.PaperSnapshotResponse{
+			Date:             snapshot.Date.Format(marketdata.DateLayout),
+			Cash:             snapshot.Cash,
+			MarketValue:      snapshot.MarketValue,
+			Equity:           snapshot.Equity,
+			ESGScore:         snapshot.ESGScore,
+			ESGLeaderWeight:  snapshot.ESGLeaderWeight,
+			ESGLaggardWeight: snapshot.ESGLaggardWeight,
+		}
+		if i > 0 && report.Snapshots[i-1].Equity > 0 {
+			daily.DailyReturn = snapshot.Equity/report.Snapshots[i-1].Equity - 1
+		}
+		response.Daily = append(response.Daily, daily)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func portfolioParams(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	bankID, ok := signalBankID(c)
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+	portfolioID, err := uuid.Parse(c.Param("portfolio_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Invalid portfolio ID",
+		})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return bankID, portfolioID, true
+}
+
+func writePaperError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrPaperPortfolioNotFound):
+		c.JSON(http.StatusNotFound, dtos.ErrorResponse{
+			Code:    string(error_codes.PaperPortfolioNotFound),
+			Message: "Paper portfolio not found",
+		})
+	case errors.Is(err, services.ErrSignalNotApproved):
+		c.JSON(http.StatusConflict, dtos.ErrorResponse{
+			Code:    string(error_codes.SignalNotApproved),
+			Message: "Only approved signals can be traded",
+			Details: err.Error(),
+		})
+	default:
+		writeSignalError(c, err, message)
+	}
+}
+
+func toPaperPortfolioResponse(portfolio *models.PaperPortfolio) dtos.PaperPortfolioResponse {
+	return dtos.PaperPortfolioResponse{
+		ID:            portfolio.ID.String(),
+		Name:          portfolio.Name,
+		Owner:         portfolio.Owner,
+		InitialCash:   portfolio.InitialCash,
+		Cash:          portfolio.Cash,
+		PositionSize:  portfolio.PositionSize,
+		CostBps:       portfolio.CostBps,
+		SlippageBps:   portfolio.SlippageBps,
+		FollowSignals: portfolio.FollowSignals,
+		CreatedAt:     portfolio.CreatedAt,
+	}
+}
+
+func toPaperOrderResponse(order *models.PaperOrder) dtos.PaperOrderResponse {
+	response := dtos.PaperOrderResponse{
+		ID:          order.ID.String(),
+		Symbol:      order.Symbol,
+		Side:        order.Side,
+		Status:      order.Status,
+		Reason:      order.Reason,
+		Quantity:    order.Quantity,
+		Quote:       order.Quote,
+		Price:       order.Price,
+		Fees:        order.Fees,
+		RealizedPnL: order.RealizedPnL,
+		CreatedBy:   order.CreatedBy,
+		CreatedAt:   order.CreatedAt,
+	}
+	if order.SignalID != nil {
+		response.SignalID = order.SignalID.String()
+	}
+	if order.QuoteDate != nil {
+		response.QuoteDate = order.QuoteDate.Format(marketdata.DateLayout)
+	}
+	return response
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/edgeesg/edge-esg-backend/internal/dtos"
+	"github.com/edgeesg/edge-esg-backend/internal/error_codes"
+	"github.com/edgeesg/edge-esg-backend/internal/middleware"
+	"github.com/edgeesg/edge-esg-backend/internal/physical"
+	"github.com/edgeesg/edge-esg-backend/internal/services"
+	"github.com/edgeesg/edge-esg-backend/internal/validator"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// PhysicalRiskHandler serves the asset and collateral locations of
+// companies and the physical climate risk of companies and portfolios.
+type PhysicalRiskHandler struct {
+	physicalRisk *services.PhysicalRiskService
+}
+
+func NewPhysicalRiskHandler(physicalRisk *services.PhysicalRiskService) *PhysicalRiskHandler {
+	return &PhysicalRiskHandler{physicalRisk: physicalRisk}
+}
+
+// ReplaceLocations overwrites a company's locations
+func (h *PhysicalRiskHandler) ReplaceLocations(c *gin.Context) {
+	bankID, company, ok := companyParams(c)
+	if !ok {
+		return
+	}
+	var req dtos.LocationsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+	if err := validator.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Validation failed",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	locations := make([]physical.Location, len(req.Locations))
+	for i, location := range req.Locations {
+		locations[i] = physical.Location{
+			Name:       location.Name,
+			Latitude:   location.Latitude,
+			Longitude:  location.Longitude,
+			Region:     location.Region,
+			AssetValue: location.AssetValue,
+			Kind:       location.Kind,
+		}
+	}
+	stored, err := h.physicalRisk.ReplaceLocations(c.Request.Context(), bankID, company, locations)
+	if err != nil {
+		writePhysicalRiskError(c, err, "Failed to store locations")
+		return
+	}
+
+	middleware.AuditLog("COMPANY_LOCATIONS_REPLACED", map[string]interface{}{
+		"bank_id":   bankID.String(),
+		"company":   company,
+		"locations": len(stored),
+	})
+	c.JSON(http.StatusOK, dtos.LocationsResponse{Company: company, Locations: toLocationRequests(stored)})
+}
+
+// Locations lists a company's locations
+func (h *PhysicalRiskHandler) Locations(c *gin.Context) {
+	bankID, company, ok := companyParams(c)
+	if !ok {
+		return
+	}
+	locations, err := h.physicalRisk.Locations(c.Request.Context(), bankID, company)
+	if err != nil {
+		writePhysicalRiskError(c, err, "Failed to load locations")
+		return
+	}
+	c.JSON(http.StatusOK, dtos.LocationsResponse{Company: company, Locations: toLocationRequests(locations)})
+}
+
+// CompanyRisk scores the physical risk of a company's assets
+func (h *PhysicalRiskHandler) CompanyRisk(c *gin.Context) {
+	bankID, company, ok := companyParams(c)
+	if !ok {
+		return
+	}
+	risk, err := h.physicalRisk.CompanyRisk(c.Request.Context(), bankID, company)
+	if err != nil {
+		writePhysicalRiskError(c, err, "Failed to score physical risk")
+		return
+	}
+
+	response := dtos.CompanyPhysicalRiskResponse{
+		Company:             company,
+		PhysicalRiskSummary: toPhysicalRiskSummary(risk.Score, risk.Hazards, risk.Coverage),
+		Locations:           make([]dtos.LocationRiskResponse, len(risk.Locations)),
+		Timestamp:           time.Now(),
+	}
+	for i, location := range risk.Locations {
+		response.Locations[i] = dtos.LocationRiskResponse{
+			LocationRequest: toLocationRequest(location.Location),
+			Source:          location.Source,
+			Score:           location.Score,
+			Hazards:         location.Hazards,
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// PortfolioRisk scores the physical risk behind a portfolio's exposures
+func (h *PhysicalRiskHandler) PortfolioRisk(c *gin.Context) {
+	bankID, portfolioID, ok := portfolioParams(c)
+	if !ok {
+		return
+	}
+	risk, err := h.physicalRisk.PortfolioRisk(c.Request.Context(), bankID, portfolioID)
+	if err != nil {
+		writePhysicalRiskError(c, err, "Failed to score physical risk")
+		return
+	}
+
+	response := dtos.PortfolioPhysicalRiskResponse{
+		PortfolioID:         portfolioID.String(),
+		PhysicalRiskSummary: toPhysicalRiskSummary(risk.Score, risk.Hazards, risk.Coverage),
+		Exposures:           make([]dtos.ExposurePhysicalRisk, len(risk.Holdings)),
+		Timestamp:           time.Now(),
+	}
+	for i, holding := range risk.Holdings {
+		response.Exposures[i] = dtos.ExposurePhysicalRisk{
+			Counterparty:      holding.Name,
+			OutstandingAmount: holding.Amount,
+		}
+		if holding.Risk != nil {
+			summary := toPhysicalRiskSummary(holding.Risk.Score, holding.Risk.Hazards, holding.Risk.Coverage)
+			response.Exposures[i].PhysicalRisk = &summary
+		}
+	}
+	response.MaskedData = middleware.MaskResponse(c, &response)
+	c.JSON(http.StatusOK, response)
+}
+
+func companyParams(c *gin.Context) (uuid.UUID, string, bool) {
+	bankID, ok := signalBankID(c)
+	if !ok {
+		return uuid.Nil, "", false
+	}
+	company := strings.TrimSpace(c.Param("company"))
+	if company == "" || len(company) > 200 {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Invalid company name",
+		})
+		return uuid.Nil, "", false
+	}
+	return bankID, company, true
+}
+
+func writePhysicalRiskError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, physical.ErrInvalidLocation):
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Invalid location",
+			Details: err.Error(),
+		})
+	case errors.Is(err, services.ErrNoLocations):
+		c.JSON(http.StatusNotFound, dtos.ErrorResponse{
+			Code:    string(error_codes.LocationsNotFound),
+			Message: "Company has no asset locations",
+		})
+	default:
+		writePortfolioError(c, err, message)
+	}
+}
+
+func toPhysicalRiskSummary(score *float64, hazards map[string]float64, coverage float64) dtos.PhysicalRiskSummary {
+	return dtos.PhysicalRiskSummary{Score: score, Hazards: hazards, Coverage: coverage}
+}
+
+func toLocationRequests(locations []physical.Location) []dtos.LocationRequest {
+	out := make([]dtos.LocationRequest, len(locations))
+	for i, location := range locations {
+		out[i] = toLocationRequest(location)
+	}
+	return out
+}
+
+func toLocationRequest(location physical.Location) dtos.LocationRequest {
+	return dtos.LocationRequest{
+		Name:       location.Name,
+		Latitude:   location.Latitude,
+		Longitude:  location.Longitude,
+		Region:     location.Region,
+		AssetValue: location.AssetValue,
+		Kind:       location.LocationKind(),
+	}
+}
@@ -1,10 +1,15 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strings"
 
+	"github.com/edgeesg/edge-esg-backend/internal/agents"
 	"github.com/edgeesg/edge-esg-backend/internal/dtos"
 	"github.com/edgeesg/edge-esg-backend/internal/error_codes"
+	"github.com/edgeesg/edge-esg-backend/internal/middleware"
+	"github.com/edgeesg/edge-esg-backend/internal/quant"
 	"github.com/edgeesg/edge-esg-backend/internal/services"
 	"github.com/edgeesg/edge-esg-backend/internal/validator"
 	"github.com/gin-gonic/gin"
@@ -12,11 +17,13 @@ import (
 
 type PortfolioHandler struct {
 	orchestrator *services.Orchestrator
+	hub          *WSHub
 }
 
-func NewPortfolioHandler(orchestrator *services.Orchestrator) *PortfolioHandler {
+func NewPortfolioHandler(orchestrator *services.Orchestrator, hub *WSHub) *PortfolioHandler {
 	return &PortfolioHandler{
 		orchestrator: orchestrator,
+		hub:          hub,
 	}
 }
 
@@ -59,16 +66,113 @@ func (h *PortfolioHandler) ComparePortfolio(c *gin.Context) {
 		return
 	}
 
+	if name := unknownCompany(req.Companies, req.Attributes, req.Constraints); name != "" {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ESGInvalidInput),
+			Message: "Attributes and constraints may only name companies being compared",
+			Details: name,
+		})
+		return
+	}
+
 	// Execute portfolio comparison
-	response, err := h.orchestrator.ComparePortfolio(c.Request.Context(), &req)
+	response, err := h.orchestrator.ComparePortfolio(h.hub.pipelineContext(c), &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, dtos.ErrorResponse{
-			Code:    string(error_codes.ESGProcessingFailed),
-			Message: "Failed to compare portfolio",
+		writeOptimizationError(c, err, "Failed to compare portfolio")
+		return
+	}
+
+	response.MaskedData = middleware.MaskResponse(c, response)
+	c.JSON(http.StatusOK, response)
+}
+
+// Frontier traces the efficient frontier, and optionally the ESG surface,
+// for a set of companies.
+func (h *PortfolioHandler) Frontier(c *gin.Context) {
+	var req dtos.PortfolioFrontierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ESGInvalidInput),
+			Message: "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+	if err := validator.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ESGInvalidInput),
+			Message: "Validation failed",
 			Details: err.Error(),
 		})
 		return
 	}
+	if name := unknownCompany(req.Companies, req.Attributes, req.Constraints); name != "" {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ESGInvalidInput),
+			Message: "Attributes and constraints may only name companies on the frontier",
+			Details: name,
+		})
+		return
+	}
+
+	response, err := h.orchestrator.PortfolioFrontier(h.hub.pipelineContext(c), &req)
+	if err != nil {
+		writeOptimizationError(c, err, "Failed to compute efficient frontier")
+		return
+	}
 
+	response.MaskedData = middleware.MaskResponse(c, response)
 	c.JSON(http.StatusOK, response)
 }
+
+func writeOptimizationError(c *gin.Context, err error, message string) {
+	if writeBenchmarkError(c, err) {
+		return
+	}
+	var infeasible *quant.InfeasibleError
+	switch {
+	case errors.As(err, &infeasible):
+		c.JSON(http.StatusUnprocessableEntity, dtos.ErrorResponse{
+			Code:    string(error_codes.PortfolioInfeasible),
+			Message: "No portfolio satisfies these constraints together: " + strings.Join(infeasible.Conflicts, ", "),
+			Details: err.Error(),
+		})
+	case errors.Is(err, agents.ErrInvalidConstraints):
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ESGInvalidInput),
+			Message: "Invalid portfolio constraints",
+			Details: err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, dtos.ErrorResponse{
+			Code:    string(error_codes.ESGProcessingFailed),
+			Message: message,
+			Details: err.Error(),
+		})
+	}
+}
+
+// unknownCompany returns the first company named in attributes or
+// constraints that is not in the comparison, or "".
+func unknownCompany(companies []string, attributes map[string]dtos.CompanyAttributes, limits *dtos.PortfolioConstraints) string {
+	known := make(map[string]bool, len(companies))
+	for _, company := range companies {
+		known[strings.ToLower(strings.TrimSpace(company))] = true
+	}
+	names := make([]string, 0)
+	for name := range attributes {
+		names = append(names, name)
+	}
+	if limits != nil {
+		names = append(names, limits.Exclude...)
+		for name := range limits.WeightBounds {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		if !known[strings.ToLower(strings.TrimSpace(name))] {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,420 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/edgeesg/edge-esg-backend/internal/agents"
+	"github.com/edgeesg/edge-esg-backend/internal/dtos"
+	"github.com/edgeesg/edge-esg-backend/internal/error_codes"
+	"github.com/edgeesg/edge-esg-backend/internal/marketdata"
+	"github.com/edgeesg/edge-esg-backend/internal/middleware"
+	"github.com/edgeesg/edge-esg-backend/internal/models"
+	"github.com/edgeesg/edge-esg-backend/internal/quant"
+	"github.com/edgeesg/edge-esg-backend/internal/services"
+	"github.com/edgeesg/edge-esg-backend/internal/validator"
+	"github.com/gin-gonic/gin"
+)
+
+// PortfoliosHandler serves a bank's stored portfolios. Comparisons of
+// companies that are not held are PortfolioHandler's.
+type PortfoliosHandler struct {
+	portfolios *services.PortfolioService
+}
+
+func NewPortfoliosHandler(portfolios *services.PortfolioService) *PortfoliosHandler {
+	return &PortfoliosHandler{portfolios: portfolios}
+}
+
+// Create stores a portfolio for the bank
+func (h *PortfoliosHandler) Create(c *gin.Context) {
+	bankID, ok := signalBankID(c)
+	if !ok {
+		return
+	}
+	in, ok := bindPortfolio(c)
+	if !ok {
+		return
+	}
+
+	portfolio, err := h.portfolios.Create(c.Request.Context(), bankID, c.GetString("user_email"), in)
+	if err != nil {
+		writePortfolioError(c, err, "Failed to create portfolio")
+		return
+	}
+
+	middleware.AuditLog("PORTFOLIO_CREATED", map[string]interface{}{
+		"bank_id":      bankID.String(),
+		"portfolio_id": portfolio.ID.String(),
+	})
+	c.JSON(http.StatusCreated, toPortfolioResponse(portfolio))
+}
+
+// List returns the bank's portfolios with their holdings
+func (h *PortfoliosHandler) List(c *gin.Context) {
+	bankID, ok := signalBankID(c)
+	if !ok {
+		return
+	}
+	portfolios, err := h.portfolios.List(c.Request.Context(), bankID)
+	if err != nil {
+		writePortfolioError(c, err, "Failed to list portfolios")
+		return
+	}
+	response := make([]dtos.PortfolioResponse, 0, len(portfolios))
+	for i := range portfolios {
+		response = append(response, toPortfolioResponse(&portfolios[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"portfolios": response})
+}
+
+func (h *PortfoliosHandler) Get(c *gin.Context) {
+	bankID, portfolioID, ok := portfolioParams(c)
+	if !ok {
+		return
+	}
+	portfolio, err := h.portfolios.Get(c.Request.Context(), bankID, portfolioID)
+	if err != nil {
+		writePortfolioError(c, err, "Failed to load portfolio")
+		return
+	}
+	c.JSON(http.StatusOK, toPortfolioResponse(portfolio))
+}
+
+// Replace overwrites a portfolio's settings and holdings
+func (h *PortfoliosHandler) Replace(c *gin.Context) {
+	bankID, portfolioID, ok := portfolioParams(c)
+	if !ok {
+		return
+	}
+	in, ok := bindPortfolio(c)
+	if !ok {
+		return
+	}
+
+	portfolio, err := h.portfolios.Replace(c.Request.Context(), bankID, portfolioID, in)
+	if err != nil {
+		writePortfolioError(c, err, "Failed to update portfolio")
+		return
+	}
+	middleware.AuditLog("PORTFOLIO_UPDATED", map[string]interface{}{
+		"bank_id":      bankID.String(),
+		"portfolio_id": portfolioID.String(),
+	})
+	c.JSON(http.StatusOK, toPortfolioResponse(portfolio))
+}
+
+func (h *PortfoliosHandler) Delete(c *gin.Context) {
+	bankID, portfolioID, ok := portfolioParams(c)
+	if !ok {
+		return
+	}
+	if err := h.portfolios.Delete(c.Request.Context(), bankID, portfolioID); err != nil {
+		writePortfolioError(c, err, "Failed to delete portfolio")
+		return
+	}
+	middleware.AuditLog("PORTFOLIO_DELETED", map[string]interface{}{
+		"bank_id":      bankID.String(),
+		"portfolio_id": portfolioID.String(),
+	})
+	c.Status(http.StatusNoContent)
+}
+
+// Drift compares the portfolio at the latest quotes with its targets
+func (h *PortfoliosHandler) Drift(c *gin.Context) {
+	bankID, portfolioID, ok := portfolioParams(c)
+	if !ok {
+		return
+	}
+	drift, err := h.portfolios.Drift(c.Request.Context(), bankID, portfolioID)
+	if err != nil {
+		writePortfolioError(c, err, "Failed to check portfolio drift")
+		return
+	}
+	c.JSON(http.StatusOK, toDriftResponse(drift))
+}
+
+// Analytics measures a portfolio against a benchmark: the one named in
+// the query, or the portfolio's own.
+func (h *PortfoliosHandler) Analytics(c *gin.Context) {
+	bankID, portfolioID, ok := portfolioParams(c)
+	if !ok {
+		return
+	}
+	analytics, err := h.portfolios.Analytics(c.Request.Context(), bankID, portfolioID, c.Query("benchmark"))
+	if err != nil {
+		writePortfolioError(c, err, "Failed to compare portfolio with benchmark")
+		return
+	}
+	c.JSON(http.StatusOK, dtos.PortfolioAnalyticsResponse{
+		PortfolioID:                analytics.Portfolio.ID.String(),
+		MarketValue:                analytics.MarketValue,
+		BenchmarkAnalyticsResponse: analytics.Response(),
+	})
+}
+
+// Rebalance proposes trades back to the target weights. Nothing is traded.
+func (h *PortfoliosHandler) Rebalance(c *gin.Context) {
+	bankID, portfolioID, ok := portfolioParams(c)
+	if !ok {
+		return
+	}
+
+	// The body is optional
+	var req dtos.RebalanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+	if err := validator.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Validation failed",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	proposal, err := h.portfolios.Rebalance(c.Request.Context(), bankID, portfolioID, req.Cash)
+	if err != nil {
+		writePortfolioError(c, err, "Failed to propose rebalance")
+		return
+	}
+
+	response := dtos.RebalanceProposalResponse{
+		PortfolioID:          portfolioID.String(),
+		MarketValue:          proposal.MarketValue,
+		Cash:                 proposal.Cash,
+		Holdings:             make([]dtos.RebalanceLineResponse, 0, len(proposal.Lines)),
+		Trades:               make([]dtos.ProposedTradeResponse, 0, len(proposal.Trades)),
+		Turnover:             proposal.Turnover,
+		CurrentTrackingError: proposal.CurrentTrackingError,
+		TrackingError:        proposal.TrackingError,
+		CurrentESGScore:      proposal.CurrentESGScore,
+		ESGScore:             proposal.ESGScore,
+		CovarianceSource:     proposal.CovarianceSource,
+		BindingConstraints:   proposal.BindingConstraints,
+		Timestamp:            time.Now(),
+	}
+	for _, line := range proposal.Lines {
+		response.Holdings = append(response.Holdings, dtos.RebalanceLineResponse(line))
+	}
+	for _, trade := range proposal.Trades {
+		response.Trades = append(response.Trades, dtos.ProposedTradeResponse(trade))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// bindPortfolio reads a portfolio definition, writing the error response
+// when it is malformed.
+func bindPortfolio(c *gin.Context) (services.PortfolioInput, bool) {
+	var req dtos.PortfolioRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Invalid request format",
+			Details: err.Error(),
+		})
+		return services.PortfolioInput{}, false
+	}
+	if err := validator.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Validation failed",
+			Details: err.Error(),
+		})
+		return services.PortfolioInput{}, false
+	}
+	if req.Mandate != nil && len(req.Mandate.CurrentWeights) > 0 {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "A portfolio's current weights come from its holdings",
+			Details: "remove current_weights from the mandate",
+		})
+		return services.PortfolioInput{}, false
+	}
+
+	in := services.PortfolioInput{
+		Name:           req.Name,
+		Benchmark:      req.Benchmark,
+		Holdings:       make([]models.PortfolioHolding, 0, len(req.Holdings)),
+		DriftThreshold: req.DriftThreshold,
+		MinESGScore:    req.MinESGScore,
+	}
+	for _, holding := range req.Holdings {
+		in.Holdings = append(in.Holdings, models.PortfolioHolding{
+			Symbol:          holding.Symbol,
+			Quantity:        holding.Quantity,
+			TargetWeight:    holding.TargetWeight,
+			Sector:          holding.Sector,
+			Country:         holding.Country,
+			CarbonIntensity: holding.CarbonIntensity,
+		})
+	}
+	if limits := req.Mandate; limits != nil {
+		in.Mandate = &models.PortfolioMandate{
+			MinWeight:          limits.MinWeight,
+			MaxWeight:          limits.MaxWeight,
+			SectorCaps:         limits.SectorCaps,
+			CountryCaps:        limits.CountryCaps,
+			MinESGScore:        limits.MinESGScore,
+			MaxCarbonIntensity: limits.MaxCarbonIntensity,
+			Exclude:            limits.Exclude,
+			MaxTurnover:        limits.MaxTurnover,
+		}
+		for symbol, bounds := range limits.WeightBounds {
+			if in.Mandate.WeightBounds == nil {
+				in.Mandate.WeightBounds = make(map[string]models.MandateBounds, len(limits.WeightBounds))
+			}
+			in.Mandate.WeightBounds[symbol] = models.MandateBounds{Min: bounds.Min, Max: bounds.Max}
+		}
+	}
+	return in, true
+}
+
+func writePortfolioError(c *gin.Context, err error, message string) {
+	if writeBenchmarkError(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, services.ErrPortfolioNotFound):
+		c.JSON(http.StatusNotFound, dtos.ErrorResponse{
+			Code:    string(error_codes.PortfolioNotFound),
+			Message: "Portfolio not found",
+		})
+	case errors.Is(err, services.ErrInvalidPortfolio):
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Invalid portfolio",
+			Details: err.Error(),
+		})
+	case errors.Is(err, services.ErrPortfolioUnpriced):
+		c.JSON(http.StatusUnprocessableEntity, dtos.ErrorResponse{
+			Code:    string(error_codes.MarketDataNotFound),
+			Message: "Every holding needs a price in the market data",
+			Details: err.Error(),
+		})
+	case errors.Is(err, quant.ErrInfeasible), errors.Is(err, agents.ErrInvalidConstraints), errors.Is(err, quant.ErrNotConverged):
+		writeOptimizationError(c, err, message)
+	default:
+		c.JSON(http.StatusInternalServerError, dtos.ErrorResponse{
+			Code:    string(error_codes.DBQueryFailed),
+			Message: message,
+			Details: err.Error(),
+		})
+	}
+}
+
+func toPortfolioResponse(portfolio *models.Portfolio) dtos.PortfolioResponse {
+	response := dtos.PortfolioResponse{
+		ID:             portfolio.ID.String(),
+		Name:           portfolio.Name,
+		Benchmark:      portfolio.Benchmark,
+		Holdings:       make([]dtos.HoldingResponse, 0, len(portfolio.Holdings)),
+		DriftThreshold: portfolio.DriftThreshold,
+		MinESGScore:    portfolio.MinESGScore,
+		DriftBreaches:  []string{},
+		DriftCheckedAt: portfolio.DriftCheckedAt,
+		CreatedBy:      portfolio.CreatedBy,
+		CreatedAt:      portfolio.CreatedAt,
+		UpdatedAt:      portfolio.UpdatedAt,
+	}
+	for _, holding := range portfolio.Holdings {
+		response.Holdings = append(response.Holdings, dtos.HoldingResponse{
+			Symbol:          holding.Symbol,
+			Quantity:        holding.Quantity,
+			TargetWeight:    holding.TargetWeight,
+			Sector:          holding.Sector,
+			Country:         holding.Country,
+			CarbonIntensity: holding.CarbonIntensity,
+		})
+	}
+	if portfolio.DriftBreaches != "" {
+		response.DriftBreaches = strings.Split(portfolio.DriftBreaches, ",")
+	}
+	if mandate := portfolio.Mandate; mandate != nil {
+		response.Mandate = &dtos.PortfolioConstraints{
+			MinWeight:          mandate.MinWeight,
+			MaxWeight:          mandate.MaxWeight,
+			SectorCaps:         mandate.SectorCaps,
+			CountryCaps:        mandate.CountryCaps,
+			MinESGScore:        mandate.MinESGScore,
+			MaxCarbonIntensity: mandate.MaxCarbonIntensity,
+			Exclude:            mandate.Exclude,
+			MaxTurnover:        mandate.MaxTurnover,
+		}
+		for symbol, bounds := range mandate.WeightBounds {
+			if response.Mandate.WeightBounds == nil {
+				response.Mandate.WeightBounds = make(map[string]dtos.WeightBounds, len(mandate.WeightBounds))
+			}
+			response.Mandate.WeightBounds[symbol] = dtos.WeightBounds{Min: bounds.Min, Max: bounds.Max}
+		}
+	}
+	return response
+}
+
+func toDriftResponse(drift *services.PortfolioDrift) dtos.PortfolioDriftResponse {
+	response := dtos.PortfolioDriftResponse{
+		PortfolioID:    drift.Portfolio.ID.String(),
+		MarketValue:    drift.MarketValue,
+		ESGScore:       drift.ESGScore,
+		ESGCoverage:    drift.ESGCoverage,
+		MaxDrift:       drift.MaxDrift,
+		DriftThreshold: drift.Portfolio.DriftThreshold,
+		MinESGScore:    drift.Portfolio.MinESGScore,
+		Holdings:       make([]dtos.HoldingValueResponse, 0, len(drift.Holdings)),
+		Breaches:       driftBreaches(drift),
+		CheckedAt:      drift.CheckedAt,
+	}
+	for _, holding := range drift.Holdings {
+		response.Holdings = append(response.Holdings, dtos.HoldingValueResponse{
+			Symbol:       holding.Symbol,
+			Quantity:     holding.Quantity,
+			Quote:        holding.Quote,
+			QuoteDate:    holding.QuoteDate.Format(marketdata.DateLayout),
+			MarketValue:  holding.MarketValue,
+			Weight:       holding.Weight,
+			TargetWeight: holding.TargetWeight,
+			Drift:        holding.Drift,
+			ESGScore:     holding.ESGScore,
+		})
+	}
+	return response
+}
+
+func driftBreaches(drift *services.PortfolioDrift) []dtos.DriftBreachResponse {
+	breaches := make([]dtos.DriftBreachResponse, 0, len(drift.Breaches))
+	for _, breach := range drift.Breaches {
+		breaches = append(breaches, dtos.DriftBreachResponse(breach))
+	}
+	return breaches
+}
+
+// driftMessage alerts that a portfolio's drift breaches changed: status is
+// BREACHED with the breaches, or CLEARED once it is back within limits.
+func driftMessage(drift *services.PortfolioDrift) WSMessage {
+	status := "CLEARED"
+	if len(drift.Breaches) > 0 {
+		status = "BREACHED"
+	}
+	data := map[string]interface{}{
+		"portfolio_id": drift.Portfolio.ID.String(),
+		"name":         drift.Portfolio.Name,
+		"status":       status,
+		"breaches":     driftBreaches(drift),
+		"market_value": drift.MarketValue,
+		"max_drift":    drift.MaxDrift,
+		"checked_at":   drift.CheckedAt,
+	}
+	if drift.ESGScore != nil {
+		data["esg_score"] = *drift.ESGScore
+	}
+	return WSMessage{Type: "portfolio_drift", Data: data}
+}
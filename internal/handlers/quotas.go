@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/edgeesg/edge-esg-backend/internal/dtos"
+	"github.com/edgeesg/edge-esg-backend/internal/error_codes"
+	"github.com/edgeesg/edge-esg-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type QuotaHandler struct {
+	quota *services.QuotaManager
+}
+
+func NewQuotaHandler(quota *services.QuotaManager) *QuotaHandler {
+	return &QuotaHandler{quota: quota}
+}
+
+// Usage reports today's upstream API consumption per provider and key
+func (h *QuotaHandler) Usage(c *gin.Context) {
+	usage, err := h.quota.Usage(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, dtos.ErrorResponse{
+			Code:    string(error_codes.DBConnectionFailed),
+			Message: "Quota usage unavailable",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"providers": usage})
+}
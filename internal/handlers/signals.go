@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/edgeesg/edge-esg-backend/internal/dtos"
+	"github.com/edgeesg/edge-esg-backend/internal/error_codes"
+	"github.com/edgeesg/edge-esg-backend/internal/marketdata"
+	"github.com/edgeesg/edge-esg-backend/internal/middleware"
+	"github.com/edgeesg/edge-esg-backend/internal/models"
+	"github.com/edgeesg/edge-esg-backend/internal/repository"
+	"github.com/edgeesg/edge-esg-backend/internal/services"
+	"github.com/edgeesg/edge-esg-backend/internal/types"
+	"github.com/edgeesg/edge-esg-backend/internal/validator"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultSignalPageSize = 50
+	maxSignalPageSize     = 200
+)
+
+type SignalsHandler struct {
+	signals *services.SignalService
+}
+
+func NewSignalsHandler(signals *services.SignalService) *SignalsHandler {
+	return &SignalsHandler{signals: signals}
+}
+
+// List returns the bank's signals, newest first
+func (h *SignalsHandler) List(c *gin.Context) {
+	bankID, ok := signalBankID(c)
+	if !ok {
+		return
+	}
+
+	filter := repository.SignalFilter{Limit: defaultSignalPageSize}
+	if status := c.Query("status"); status != "" {
+		if !validSignalStatus(status) {
+			c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+				Code:    string(error_codes.ValidationFailed),
+				Message: "Invalid status",
+			})
+			return
+		}
+		filter.Status = status
+	}
+	if symbol := c.Query("symbol"); symbol != "" {
+		normalized, err := marketdata.NormalizeSymbol(symbol)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+				Code:    string(error_codes.ValidationFailed),
+				Message: "Invalid symbol",
+			})
+			return
+		}
+		filter.Symbol = normalized
+	}
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 {
+		filter.Limit = min(limit, maxSignalPageSize)
+	}
+	if offset, err := strconv.Atoi(c.Query("offset")); err == nil && offset > 0 {
+		filter.Offset = offset
+	}
+
+	signals, err := h.signals.List(c.Request.Context(), bankID, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dtos.ErrorResponse{
+			Code:    string(error_codes.DBQueryFailed),
+			Message: "Failed to list signals",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	response := make([]dtos.SignalResponse, 0, len(signals))
+	for i := range signals {
+		item := toSignalResponse(&signals[i], nil)
+		item.MaskedData = middleware.MaskResponse(c, &item)
+		response = append(response, item)
+	}
+	c.JSON(http.StatusOK, gin.H{"signals": response, "limit": filter.Limit, "offset": filter.Offset})
+}
+
+// Get returns one signal with its status history
+func (h *SignalsHandler) Get(c *gin.Context) {
+	bankID, ok := signalBankID(c)
+	if !ok {
+		return
+	}
+	signalID, ok := parseSignalID(c)
+	if !ok {
+		return
+	}
+
+	signal, events, err := h.signals.Get(c.Request.Context(), bankID, signalID)
+	if err != nil {
+		writeSignalError(c, err, "Failed to load signal")
+		return
+	}
+	response := toSignalResponse(signal, events)
+	response.MaskedData = middleware.MaskResponse(c, &response)
+	c.JSON(http.StatusOK, response)
+}
+
+// Approve clears a pending signal for execution
+func (h *SignalsHandler) Approve(c *gin.Context) {
+	h.decide(c, "SIGNAL_APPROVED", h.signals.Approve)
+}
+
+// Reject closes a pending signal
+func (h *SignalsHandler) Reject(c *gin.Context) {
+	h.decide(c, "SIGNAL_REJECTED", h.signals.Reject)
+}
+
+// Cancel withdraws an open signal
+func (h *SignalsHandler) Cancel(c *gin.Context) {
+	h.decide(c, "SIGNAL_CANCELLED", h.signals.Cancel)
+}
+
+type signalDecision func(ctx context.Context, bankID, id uuid.UUID, actor services.SignalActor, reason string) (*models.TradeSignal, error)
+
+// decide applies a decision for the signed-in user. API keys identify a
+// bank's systems, not a person, so they cannot decide signals.
+func (h *SignalsHandler) decide(c *gin.Context, event string, apply signalDecision) {
+	bankID, ok := signalBankID(c)
+	if !ok {
+		return
+	}
+	signalID, ok := parseSignalID(c)
+	if !ok {
+		return
+	}
+
+	actor := services.SignalActor{ID: c.GetString("user_email"), Roles: middleware.UserRoles(c)}
+	if actor.ID == "" {
+		c.JSON(http.StatusForbidden, dtos.ErrorResponse{
+			Code:    string(error_codes.AuthForbidden),
+			Message: "Signal decisions require a signed-in user",
+		})
+		return
+	}
+
+	var req dtos.SignalDecisionRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+				Code:    string(error_codes.ValidationFailed),
+				Message: "Invalid request format",
+				Details: err.Error(),
+			})
+			return
+		}
+	}
+	if err := validator.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Validation failed",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	signal, err := apply(c.Request.Context(), bankID, signalID, actor, req.Reason)
+	if err != nil {
+		writeSignalError(c, err, "Failed to update signal")
+		return
+	}
+
+	middleware.AuditLog(event, map[string]interface{}{
+		"bank_id":   bankID.String(),
+		"signal_id": signal.ID.String(),
+		"symbol":    signal.Symbol,
+		"action":    signal.Action,
+		"actor":     actor.ID,
+		"role":      signal.DecidedRole,
+		"reason":    req.Reason,
+	})
+
+	response := toSignalResponse(signal, nil)
+	response.MaskedData = middleware.MaskResponse(c, &response)
+	c.JSON(http.StatusOK, response)
+}
+
+func signalBankID(c *gin.Context) (uuid.UUID, bool) {
+	bankID, err := uuid.Parse(middleware.ResolveBankID(c))
+	if err != nil {
+		c.JSON(http.StatusForbidden, dtos.ErrorResponse{
+			Code:    string(error_codes.AuthForbidden),
+			Message: "Signals are only available to bank callers",
+		})
+		return uuid.Nil, false
+	}
+	return bankID, true
+}
+
+func parseSignalID(c *gin.Context) (uuid.UUID, bool) {
+	signalID, err := uuid.Parse(c.Param("signal_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Invalid signal ID",
+		})
+		return uuid.Nil, false
+	}
+	return signalID, true
+}
+
+func writeSignalError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrSignalNotFound):
+		c.JSON(http.StatusNotFound, dtos.ErrorResponse{
+			Code:    string(error_codes.SignalNotFound),
+			Message: "Signal not found",
+		})
+	case errors.Is(err, services.ErrSignalForbidden):
+		c.JSON(http.StatusForbidden, dtos.ErrorResponse{
+			Code:    string(error_codes.AuthForbidden),
+			Message: "Insufficient permissions",
+			Details: "signals the risk assessment sent for review need a RISK officer; others need RISK or TRADER",
+		})
+	case errors.Is(err, services.ErrSignalTransition):
+		c.JSON(http.StatusConflict, dtos.ErrorResponse{
+			Code:    string(error_codes.SignalInvalidTransition),
+			Message: "Signal cannot make that change",
+			Details: err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, dtos.ErrorResponse{
+			Code:    string(error_codes.DBQueryFailed),
+			Message: message,
+			Details: err.Error(),
+		})
+	}
+}
+
+func validSignalStatus(status string) bool {
+	switch types.SignalStatus(status) {
+	case types.SignalPending, types.SignalApproved, types.SignalRejected, types.SignalSent,
+		types.SignalFilled, types.SignalExpired, types.SignalCancelled:
+		return true
+	}
+	return false
+}
+
+func toSignalResponse(signal *models.TradeSignal, events []models.TradeSignalEvent) dtos.SignalResponse {
+	response := dtos.SignalResponse{
+		ID:           signal.ID.String(),
+		Company:      signal.Company,
+		AnalysisID:   signal.AnalysisID,
+		Symbol:       signal.Symbol,
+		Action:       signal.Action,
+		CurrentPrice: signal.CurrentPrice,
+		TargetPrice:  signal.TargetPrice,
+		Confidence:   signal.Confidence,
+		ESGScore:     signal.ESGScore,
+		RiskAction:   signal.RiskAction,
+		Reasoning:    signal.Reasoning,
+		Status:       signal.Status,
+		StatusReason: signal.StatusReason,
+		DecidedBy:    signal.DecidedBy,
+		DecidedRole:  signal.DecidedRole,
+		DecidedAt:    signal.DecidedAt,
+		ExpiresAt:    signal.ExpiresAt,
+		ExecutedAt:   signal.ExecutedAt,
+		CreatedAt:    signal.CreatedAt,
+		UpdatedAt:    signal.UpdatedAt,
+	}
+	for _, e := range events {
+		response.History = append(response.History, dtos.SignalEventResponse{
+			FromStatus: e.FromStatus,
+			ToStatus:   e.ToStatus,
+			Actor:      e.Actor,
+			ActorRole:  e.ActorRole,
+			Reason:     e.Reason,
+			CreatedAt:  e.CreatedAt,
+		})
+	}
+	return response
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/edgeesg/edge-esg-backend/internal/dtos"
+	"github.com/edgeesg/edge-esg-backend/internal/error_codes"
+	"github.com/edgeesg/edge-esg-backend/internal/middleware"
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// Comment lines keep idle streams open through proxies
+	sseKeepAlive = 15 * time.Second
+	// sseRetry is the reconnection delay suggested to EventSource clients
+	sseRetry = 3000 * time.Millisecond
+)
+
+// HandleSSE serves /events, the /ws event stream as Server-Sent Events for
+// clients behind proxies that strip WebSocket upgrades. Topics are chosen
+// when connecting (?topic=job:<id>&topic=alerts) and follow the same rules
+// as /ws. A reconnecting client's Last-Event-ID replays what it missed from
+// the hub's buffer. Run it behind middleware.BankAuth.
+func (h *WSHub) HandleSSE(c *gin.Context) {
+	subscriptions := make(map[string]bool)
+	for _, param := range c.QueryArray("topic") {
+		for _, raw := range strings.Split(param, ",") {
+			topic, err := normalizeTopic(strings.TrimSpace(raw))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+					Code:    string(error_codes.ValidationFailed),
+					Message: "Invalid topic",
+					Details: err.Error(),
+				})
+				return
+			}
+			subscriptions[topic] = true
+		}
+	}
+	if len(subscriptions) > wsMaxSubscriptions {
+		c.JSON(http.StatusBadRequest, dtos.ErrorResponse{
+			Code:    string(error_codes.ValidationFailed),
+			Message: "Too many topics",
+			Details: "a stream may subscribe to at most 100 topics",
+		})
+		return
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+
+	// A resuming client may be sent the whole replay buffer at once.
+	buffer := h.sendBuffer
+	if lastEventID != "" {
+		buffer += h.replaySize
+	}
+	client := &wsClient{
+		id:            uuid.New().String(),
+		role:          middleware.ResolveRole(c),
+		bankID:        middleware.ResolveBankID(c),
+		subscriptions: subscriptions,
+		send:          make(chan WSMessage, buffer),
+		resumeAfter:   lastEventID,
+	}
+
+	// Counted before registering: once the hub knows the client, Shutdown
+	// must wait for this stream to finish.
+	h.writers.Add(1)
+	defer h.writers.Done()
+	select {
+	case h.register <- client:
+	case <-h.done:
+		c.JSON(http.StatusServiceUnavailable, dtos.ErrorResponse{
+			Code:    string(error_codes.StreamUnavailable),
+			Message: "Server shutting down",
+		})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	rc := http.NewResponseController(c.Writer)
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	retry := uint(sseRetry.Milliseconds())
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			h.unregisterSSE(client)
+			return
+
+		case message, ok := <-client.send:
+			if !ok {
+				// Removed by the hub: slow consumer or shutdown
+				return
+			}
+			_ = rc.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			err := sse.Encode(c.Writer, sse.Event{Id: message.ID, Event: message.Type, Retry: retry, Data: message})
+			if err != nil {
+				h.unregisterSSE(client)
+				return
+			}
+			c.Writer.Flush()
+			retry = 0
+			wsMessagesSent.Inc()
+
+		case <-keepAlive.C:
+			_ = rc.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if _, err := c.Writer.WriteString(": keepalive\n\n"); err != nil {
+				h.unregisterSSE(client)
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+func (h *WSHub) unregisterSSE(client *wsClient) {
+	select {
+	case h.unregister <- client:
+	case <-h.done:
+	}
+}
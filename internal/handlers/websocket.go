@@ -1,111 +1,678 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/edgeesg/edge-esg-backend/internal/masking"
+	"github.com/edgeesg/edge-esg-backend/internal/metrics"
+	"github.com/edgeesg/edge-esg-backend/internal/middleware"
+	"github.com/edgeesg/edge-esg-backend/internal/services"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
+// ClientIDHeader lets an HTTP request name the WebSocket connection (by the
+// client_id from its welcome message) that should receive its progress.
+const ClientIDHeader = "X-Client-ID"
+
+const (
+	wsMaxMessageBytes  = 4096
+	wsMaxSubscriptions = 100
+
+	wsWriteWait  = 10 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = wsPongWait * 9 / 10
+)
+
+// SlowClientPolicy decides what happens when a connection's send queue is
+// full: drop the message for that client, or disconnect it.
+type SlowClientPolicy string
+
+const (
+	SlowClientDrop       SlowClientPolicy = "drop"
+	SlowClientDisconnect SlowClientPolicy = "disconnect"
+)
+
+var (
+	wsConnectedClients = metrics.NewGauge("edge_ws_connected_clients",
+		"WebSocket connections currently registered with the hub")
+	wsMessagesSent = metrics.NewCounter("edge_ws_messages_sent_total",
+		"Messages written to WebSocket clients")
+	wsMessagesDropped = metrics.NewCounter("edge_ws_messages_dropped_total",
+		"WebSocket messages dropped (slow_consumer, hub_backlog, fanout_backlog)", "reason")
+	wsDisconnects = metrics.NewCounter("edge_ws_disconnects_total",
+		"WebSocket disconnections (client, slow_consumer, shutdown)", "reason")
+)
+
+// Topics a connection may subscribe to. Every topic is scoped to the
+// connection's bank: the same topic name in two banks never shares messages.
+const (
+	TopicAlerts          = "alerts"
+	topicJobPrefix       = "job:"
+	topicCompanyPrefix   = "company:"
+	topicPortfolioPrefix = "portfolio:"
+)
+
 var upgrader = websocket.Upgrader{
+	Subprotocols: []string{middleware.WSProtocol},
 	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins for now
+		// Non-browser clients send no Origin; they still had to authenticate
+		origin := r.Header.Get("Origin")
+		return origin == "" || middleware.AllowedOrigin(origin)
 	},
 }
 
+// WSMessage is a message to a WebSocket or SSE client. Events carry an ID
+// that is unique across replicas and usable as an SSE Last-Event-ID.
 type WSMessage struct {
+	ID      string                 `json:"id,omitempty"`
 	Type    string                 `json:"type"`
 	Data    map[string]interface{} `json:"data"`
 	AgentID string                 `json:"agentId,omitempty"`
 }
 
+// wsClient carries what the hub needs to mask and route messages for one
+// connection, WebSocket or SSE. Only the hub's Run goroutine sends on or
+// closes send; the connection's writer goroutine drains it.
+type wsClient struct {
+	id            string
+	conn          *websocket.Conn
+	role          string
+	bankID        string
+	subscriptions map[string]bool
+	send          chan WSMessage
+
+	// Close frame the writer sends once send is closed
+	closeCode int
+	closeText string
+
+	// resumeAfter is the last event an SSE client saw before reconnecting
+	resumeAfter string
+}
+
+// wsDelivery is a message for one client and/or the subscribers of some
+// topics, restricted to connections of one bank, or for every connection.
+type wsDelivery struct {
+	id       string
+	clientID string
+	topics   []string
+	bankID   string
+	all      bool
+	message  WSMessage
+}
+
+// WSHub routes messages to WebSocket connections. Run never touches the
+// network: each connection has a bounded queue and its own writer, so one
+// slow client cannot hold up the others.
 type WSHub struct {
-	clients    map[*websocket.Conn]bool
-	broadcast  chan WSMessage
-	register   chan *websocket.Conn
-	unregister chan *websocket.Conn
+	byID       map[string]*wsClient
+	deliver    chan wsDelivery
+	register   chan *wsClient
+	unregister chan *wsClient
+	policy     *masking.Policy
+	slowPolicy SlowClientPolicy
+	sendBuffer int
+	fanout     *wsFanout
 	mu         sync.RWMutex
+
+	// origin and seq make event IDs unique across replicas. replay keeps the
+	// latest topic events, local and remote, for SSE resumption; only Run
+	// touches it.
+	origin     string
+	seq        atomic.Uint64
+	replay     []wsDelivery
+	replaySize int
+
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
+	done         chan struct{}
+	writers      sync.WaitGroup
 }
 
-func NewWSHub() *WSHub {
+func NewWSHub(policy *masking.Policy, slowPolicy SlowClientPolicy, sendBuffer, replaySize int) *WSHub {
+	if slowPolicy != SlowClientDisconnect {
+		slowPolicy = SlowClientDrop
+	}
+	if sendBuffer <= 0 {
+		sendBuffer = 64
+	}
 	return &WSHub{
-		clients:    make(map[*websocket.Conn]bool),
-		broadcast:  make(chan WSMessage, 256),
-		register:   make(chan *websocket.Conn),
-		unregister: make(chan *websocket.Conn),
+		byID:       make(map[string]*wsClient),
+		deliver:    make(chan wsDelivery, 256),
+		register:   make(chan *wsClient),
+		unregister: make(chan *wsClient),
+		policy:     policy,
+		slowPolicy: slowPolicy,
+		sendBuffer: sendBuffer,
+		origin:     uuid.New().String()[:8],
+		replaySize: replaySize,
+		shutdown:   make(chan struct{}),
+		done:       make(chan struct{}),
 	}
 }
 
 func (h *WSHub) Run() {
+	defer close(h.done)
+	if h.fanout != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go h.fanout.run(ctx, h)
+	}
 	for {
 		select {
 		case client := <-h.register:
 			h.mu.Lock()
-			h.clients[client] = true
+			h.byID[client.id] = client
 			h.mu.Unlock()
+			wsConnectedClients.Inc()
+			h.enqueue(client, WSMessage{
+				Type: "welcome",
+				Data: map[string]interface{}{"client_id": client.id},
+			})
+			if client.resumeAfter != "" {
+				h.replayTo(client)
+			}
 
 		case client := <-h.unregister:
-			h.mu.Lock()
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				_ = client.Close()
+			h.remove(client, websocket.CloseNormalClosure, "", "client")
+
+		case d := <-h.deliver:
+			d.message.ID = d.id
+			h.record(d)
+			targets := h.targets(d)
+
+			// Each bank and role gets its own masked copy of the message.
+			masked := make(map[string]WSMessage)
+			for _, client := range targets {
+				audience := client.bankID + "/" + client.role
+				out, ok := masked[audience]
+				if !ok {
+					out = h.maskFor(d.message, client.bankID, client.role)
+					masked[audience] = out
+				}
+				h.enqueue(client, out)
 			}
-			h.mu.Unlock()
 
-		case message := <-h.broadcast:
+		case <-h.shutdown:
 			h.mu.RLock()
-			for client := range h.clients {
-				err := client.WriteJSON(message)
-				if err != nil {
-					_ = client.Close()
-					delete(h.clients, client)
-				}
+			clients := make([]*wsClient, 0, len(h.byID))
+			for _, client := range h.byID {
+				clients = append(clients, client)
 			}
 			h.mu.RUnlock()
+			for _, client := range clients {
+				h.remove(client, websocket.CloseGoingAway, "server shutting down", "shutdown")
+			}
+			return
+		}
+	}
+}
+
+// Shutdown sends every client a going-away close frame and waits for the
+// writers to finish, or for ctx to expire.
+func (h *WSHub) Shutdown(ctx context.Context) error {
+	h.shutdownOnce.Do(func() { close(h.shutdown) })
+
+	select {
+	case <-h.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	flushed := make(chan struct{})
+	go func() {
+		h.writers.Wait()
+		close(flushed)
+	}()
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// record keeps a topic event for replay.
+func (h *WSHub) record(d wsDelivery) {
+	if d.id == "" || len(d.topics) == 0 || h.replaySize <= 0 {
+		return
+	}
+	h.replay = append(h.replay, d)
+	if len(h.replay) > h.replaySize {
+		h.replay = h.replay[len(h.replay)-h.replaySize:]
+	}
+}
+
+// replayTo re-sends the buffered events after the client's resumeAfter that
+// it is subscribed to. When that event has left the buffer the client is
+// told to resync instead. Only Run calls it.
+func (h *WSHub) replayTo(client *wsClient) {
+	start := -1
+	for i := len(h.replay) - 1; i >= 0; i-- {
+		if h.replay[i].id == client.resumeAfter {
+			start = i + 1
+			break
+		}
+	}
+	if start < 0 {
+		h.enqueue(client, resyncMessage("replay_unavailable"))
+		return
+	}
+
+	h.mu.RLock()
+	var missed []wsDelivery
+	for _, d := range h.replay[start:] {
+		if d.bankID != client.bankID {
+			continue
+		}
+		for _, topic := range d.topics {
+			if client.subscriptions[topic] {
+				missed = append(missed, d)
+				break
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, d := range missed {
+		h.enqueue(client, h.maskFor(d.message, client.bankID, client.role))
+	}
+}
+
+// enqueue queues a message without blocking, applying the slow client
+// policy when the queue is full. Only Run calls it.
+func (h *WSHub) enqueue(client *wsClient, message WSMessage) {
+	select {
+	case client.send <- message:
+	default:
+		wsMessagesDropped.Inc("slow_consumer")
+		if h.slowPolicy == SlowClientDisconnect {
+			h.remove(client, websocket.CloseTryAgainLater, "slow consumer", "slow_consumer")
+		}
+	}
+}
+
+// remove unregisters a client and has its writer send a close frame. Only
+// Run calls it, so send is closed exactly once.
+func (h *WSHub) remove(client *wsClient, code int, text, reason string) {
+	h.mu.Lock()
+	if _, ok := h.byID[client.id]; !ok {
+		h.mu.Unlock()
+		return
+	}
+	delete(h.byID, client.id)
+	h.mu.Unlock()
+
+	client.closeCode = code
+	client.closeText = text
+	close(client.send)
+	wsConnectedClients.Dec()
+	wsDisconnects.Inc(reason)
+}
+
+// targets resolves a delivery to connections of its bank: the named client
+// plus every subscriber of any of its topics, each once.
+func (h *WSHub) targets(d wsDelivery) []*wsClient {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var targets []*wsClient
+	if d.all {
+		for _, client := range h.byID {
+			targets = append(targets, client)
 		}
+		return targets
+	}
+	if client, ok := h.byID[d.clientID]; ok && client.bankID == d.bankID {
+		targets = append(targets, client)
+	}
+	if len(d.topics) == 0 {
+		return targets
 	}
+	for _, client := range h.byID {
+		if client.bankID != d.bankID || client.id == d.clientID {
+			continue
+		}
+		for _, topic := range d.topics {
+			if client.subscriptions[topic] {
+				targets = append(targets, client)
+				break
+			}
+		}
+	}
+	return targets
 }
 
+// HandleWebSocket serves /ws. Run it behind middleware.BankAuth so every
+// connection belongs to a bank.
 func (h *WSHub) HandleWebSocket(c *gin.Context) {
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		return
 	}
 
-	h.register <- conn
+	client := &wsClient{
+		id:            uuid.New().String(),
+		conn:          conn,
+		role:          middleware.ResolveRole(c),
+		bankID:        middleware.ResolveBankID(c),
+		subscriptions: make(map[string]bool),
+		send:          make(chan WSMessage, h.sendBuffer),
+	}
+
+	// Counted before registering: once the hub knows the client, Shutdown
+	// must wait for its writer to send the close frame.
+	h.writers.Add(1)
+	select {
+	case h.register <- client:
+	case <-h.done:
+		h.writers.Done()
+		_ = conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"), time.Now().Add(wsWriteWait))
+		_ = conn.Close()
+		return
+	}
+
+	go h.writePump(client)
+
+	h.readPump(client)
+	select {
+	case h.unregister <- client:
+	case <-h.done:
+	}
+}
 
+// writePump drains a client's queue and keeps the connection alive with
+// pings. When the hub closes the queue it sends the close frame and hangs up.
+func (h *WSHub) writePump(client *wsClient) {
+	ticker := time.NewTicker(wsPingPeriod)
 	defer func() {
-		h.unregister <- conn
+		ticker.Stop()
+		_ = client.conn.Close()
+		h.writers.Done()
 	}()
 
 	for {
+		select {
+		case message, ok := <-client.send:
+			_ = client.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if !ok {
+				_ = client.conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(client.closeCode, client.closeText))
+				return
+			}
+			if err := client.conn.WriteJSON(message); err != nil {
+				return
+			}
+			wsMessagesSent.Inc()
+
+		case <-ticker.C:
+			_ = client.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := client.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readPump handles client messages until the connection fails or a pong is
+// overdue.
+func (h *WSHub) readPump(client *wsClient) {
+	conn := client.conn
+	conn.SetReadLimit(wsMaxMessageBytes)
+	_ = conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		messageType, raw, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if messageType != websocket.TextMessage {
+			h.replyError(client, "unsupported_frame", "only text frames are accepted")
+			continue
+		}
+
 		var msg WSMessage
-		err := conn.ReadJSON(&msg)
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			h.replyError(client, "invalid_message", "message must be a JSON object")
+			continue
+		}
+		h.handleClientMessage(client, msg)
+	}
+}
+
+// handleClientMessage applies a client's control message. Clients never
+// publish: nothing they send is relayed to other connections.
+func (h *WSHub) handleClientMessage(client *wsClient, msg WSMessage) {
+	switch msg.Type {
+	case "ping":
+		h.reply(client, WSMessage{Type: "pong", Data: map[string]interface{}{}})
+
+	case "subscribe", "unsubscribe":
+		// {"type": "subscribe", "data": {"topic": "job:<id>"}}
+		raw, _ := msg.Data["topic"].(string)
+		topic, err := normalizeTopic(raw)
 		if err != nil {
-			break
+			h.replyError(client, "invalid_topic", err.Error())
+			return
 		}
-		// Echo back or process message
-		h.broadcast <- msg
+
+		h.mu.Lock()
+		if msg.Type == "subscribe" && !client.subscriptions[topic] && len(client.subscriptions) >= wsMaxSubscriptions {
+			h.mu.Unlock()
+			h.replyError(client, "too_many_subscriptions", "subscription limit reached")
+			return
+		}
+		if msg.Type == "subscribe" {
+			client.subscriptions[topic] = true
+		} else {
+			delete(client.subscriptions, topic)
+		}
+		h.mu.Unlock()
+
+		h.reply(client, WSMessage{Type: msg.Type + "d", Data: map[string]interface{}{"topic": topic}})
+
+	default:
+		h.replyError(client, "unsupported_type", "supported message types are subscribe, unsubscribe and ping")
 	}
 }
 
-func (h *WSHub) BroadcastAgentUpdate(agentID string, status string, progress int) {
-	h.broadcast <- WSMessage{
-		Type:    "agent_update",
-		AgentID: agentID,
-		Data: map[string]interface{}{
-			"status":   status,
-			"progress": progress,
-		},
+func (h *WSHub) reply(client *wsClient, message WSMessage) {
+	select {
+	case h.deliver <- wsDelivery{clientID: client.id, bankID: client.bankID, message: message}:
+	case <-h.done:
 	}
 }
 
-func (h *WSHub) BroadcastConsensus(consensus int) {
-	h.broadcast <- WSMessage{
-		Type: "consensus_update",
-		Data: map[string]interface{}{
-			"consensus": consensus,
-		},
+func (h *WSHub) replyError(client *wsClient, code, message string) {
+	h.reply(client, WSMessage{Type: "error", Data: map[string]interface{}{"code": code, "message": message}})
+}
+
+// maskFor returns a deep copy of message redacted for the given audience,
+// leaving the shared message untouched for other clients.
+func (h *WSHub) maskFor(message WSMessage, bankID, role string) WSMessage {
+	raw, err := json.Marshal(message)
+	if err != nil {
+		return WSMessage{Type: message.Type}
+	}
+	var out WSMessage
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return WSMessage{Type: message.Type}
+	}
+	h.policy.Apply(&out, bankID, role)
+	return out
+}
+
+// Publish sends a message to a bank's subscribers of topic.
+func (h *WSHub) Publish(bankID, topic string, message WSMessage) {
+	h.send(wsDelivery{topics: []string{topic}, bankID: bankID, message: message})
+}
+
+// SubscribeClient subscribes a connection of bankID to a job's progress, as
+// when the client submits the job over HTTP with X-Client-ID. The connection
+// may be held by another replica.
+func (h *WSHub) SubscribeClient(clientID, bankID, jobID string) {
+	topic := JobTopic(jobID)
+	if !h.subscribeLocal(clientID, bankID, topic) && h.fanout != nil {
+		h.fanout.enqueue(wsEnvelope{Origin: h.fanout.origin, ClientID: clientID, BankID: bankID, Subscribe: topic})
+	}
+}
+
+// subscribeLocal subscribes a connection held by this replica, reporting
+// whether it found the connection.
+func (h *WSHub) subscribeLocal(clientID, bankID, topic string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	client, ok := h.byID[clientID]
+	if !ok || client.bankID != bankID {
+		return false
+	}
+	if len(client.subscriptions) < wsMaxSubscriptions {
+		client.subscriptions[topic] = true
+	}
+	return true
+}
+
+// ClientObserver returns a pipeline observer that streams progress to one
+// connection, if it belongs to bankID, and to the bank's watchers of the
+// company.
+func (h *WSHub) ClientObserver(clientID, bankID string) services.PipelineObserver {
+	return func(e services.PipelineEvent) {
+		h.send(wsDelivery{clientID: clientID, topics: companyTopics(e), bankID: bankID, message: pipelineMessage(e)})
+	}
+}
+
+// PublishJobEvent sends a job's pipeline progress to its subscribers and the
+// bank's watchers of the company.
+func (h *WSHub) PublishJobEvent(job *services.Job, e services.PipelineEvent) {
+	topics := append([]string{JobTopic(job.ID)}, companyTopics(e)...)
+	h.send(wsDelivery{topics: topics, bankID: job.BankID, message: pipelineMessage(e)})
+}
+
+// PublishPortfolioDrift alerts the bank's subscribers of alerts and of the
+// portfolio that its drift breaches changed.
+func (h *WSHub) PublishPortfolioDrift(ctx context.Context, drift *services.PortfolioDrift) {
+	portfolioID := drift.Portfolio.ID.String()
+	h.send(wsDelivery{topics: []string{TopicAlerts, PortfolioTopic(portfolioID)}, bankID: drift.Portfolio.BankID.String(), message: driftMessage(drift)})
+}
+
+// send delivers to this replica's connections and, with fan-out enabled,
+// to the other replicas'.
+func (h *WSHub) send(d wsDelivery) {
+	d.id = h.origin + "-" + strconv.FormatUint(h.seq.Add(1), 10)
+	h.sendLocal(d)
+	if h.fanout != nil {
+		h.fanout.publish(d)
+	}
+}
+
+// sendLocal never blocks the pipeline: progress is dropped if the hub is
+// backed up.
+func (h *WSHub) sendLocal(d wsDelivery) {
+	select {
+	case h.deliver <- d:
+	default:
+		wsMessagesDropped.Inc("hub_backlog")
+	}
+}
+
+// pipelineContext prepares the context for an analysis run on behalf of the
+// request, streaming its progress to the caller's WebSocket connection when
+// the request names one.
+func (h *WSHub) pipelineContext(c *gin.Context) context.Context {
+	bankID := middleware.ResolveBankID(c)
+	ctx := services.WithBankID(c.Request.Context(), bankID)
+	ctx = services.WithActor(ctx, middleware.Actor(c))
+	if clientID := c.GetHeader(ClientIDHeader); clientID != "" && h != nil {
+		ctx = services.WithPipelineObserver(ctx, h.ClientObserver(clientID, bankID))
+	}
+	return ctx
+}
+
+func resyncMessage(reason string) WSMessage {
+	return WSMessage{Type: "resync", Data: map[string]interface{}{"reason": reason}}
+}
+
+func pipelineMessage(e services.PipelineEvent) WSMessage {
+	data := map[string]interface{}{
+		"analysis_id": e.AnalysisID,
+		"status":      e.Status,
+		"step":        e.Step,
+		"steps":       e.Steps,
+		"progress":    e.Step * 100 / e.Steps,
+		"timestamp":   e.Timestamp,
+	}
+	if e.Status == services.PipelineStarted {
+		data["progress"] = (e.Step - 1) * 100 / e.Steps
+	}
+	if e.JobID != "" {
+		data["job_id"] = e.JobID
+	}
+	if e.Company != "" {
+		data["company"] = e.Company
+	}
+	if e.Output != nil {
+		data["output"] = e.Output
+	}
+	if e.Error != "" {
+		data["error"] = e.Error
+	}
+	return WSMessage{Type: "agent_update", AgentID: e.Agent, Data: data}
+}
+
+func companyTopics(e services.PipelineEvent) []string {
+	if e.Company == "" {
+		return nil
+	}
+	return []string{CompanyTopic(e.Company)}
+}
+
+func JobTopic(jobID string) string {
+	return topicJobPrefix + strings.ToLower(jobID)
+}
+
+func CompanyTopic(company string) string {
+	return topicCompanyPrefix + strings.ToLower(strings.TrimSpace(company))
+}
+
+func PortfolioTopic(portfolioID string) string {
+	return topicPortfolioPrefix + strings.ToLower(portfolioID)
+}
+
+// normalizeTopic validates a topic a client asked for and returns its
+// canonical form.
+func normalizeTopic(topic string) (string, error) {
+	switch {
+	case topic == TopicAlerts:
+		return topic, nil
+
+	case strings.HasPrefix(topic, topicJobPrefix), strings.HasPrefix(topic, topicPortfolioPrefix):
+		prefix, id, _ := strings.Cut(topic, ":")
+		if _, err := uuid.Parse(id); err != nil {
+			return "", errors.New(prefix + " topics take a UUID")
+		}
+		return prefix + ":" + strings.ToLower(id), nil
+
+	case strings.HasPrefix(topic, topicCompanyPrefix):
+		name := strings.TrimSpace(strings.TrimPrefix(topic, topicCompanyPrefix))
+		if len(name) < 2 || len(name) > 100 {
+			return "", errors.New("company topics take a name of 2 to 100 characters")
+		}
+		return CompanyTopic(name), nil
+
+	default:
+		return "", errors.New("topic must be alerts, job:<id>, company:<name> or portfolio:<id>")
 	}
 }
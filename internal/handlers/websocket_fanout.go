@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/edgeesg/edge-esg-backend/internal/loggers"
+	"github.com/edgeesg/edge-esg-backend/internal/metrics"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	wsFanoutBacklog    = 1024
+	wsFanoutPublishTTL = 2 * time.Second
+	// A quiet subscription is pinged this often; a missing pong means the
+	// connection is dead and a new one is opened.
+	wsFanoutPingPeriod = 30 * time.Second
+	wsFanoutMaxBackoff = 30 * time.Second
+)
+
+var (
+	wsFanoutPublished = metrics.NewCounter("edge_ws_fanout_published_total",
+		"WebSocket deliveries published to other gateway replicas")
+	wsFanoutReceived = metrics.NewCounter("edge_ws_fanout_received_total",
+		"WebSocket deliveries received from other gateway replicas")
+	wsFanoutErrors = metrics.NewCounter("edge_ws_fanout_errors_total",
+		"Redis errors in WebSocket fan-out (publish, subscribe, decode)", "op")
+)
+
+// wsEnvelope is a delivery as it travels between replicas. Messages are
+// masked by the receiving replica, per connection, exactly as local ones.
+type wsEnvelope struct {
+	ID        string     `json:"id,omitempty"`
+	Origin    string     `json:"origin"`
+	ClientID  string     `json:"client_id,omitempty"`
+	BankID    string     `json:"bank_id"`
+	Topics    []string   `json:"topics,omitempty"`
+	Subscribe string     `json:"subscribe,omitempty"`
+	Message   *WSMessage `json:"message,omitempty"`
+}
+
+// wsFanout relays hub deliveries through a Redis channel so a connection on
+// one replica sees events produced on another. Each replica delivers its own
+// events locally and ignores their echo. Per-topic order holds because a
+// replica publishes from a single goroutine and Redis keeps the order of
+// one connection's publishes.
+type wsFanout struct {
+	client  *redis.Client
+	channel string
+	origin  string
+	outbox  chan wsEnvelope
+}
+
+// EnableRedisFanout shares deliveries with the other gateway replicas over
+// a Redis pub/sub channel. Call it before Run.
+func (h *WSHub) EnableRedisFanout(client *redis.Client, channel string) {
+	h.fanout = &wsFanout{
+		client:  client,
+		channel: channel,
+		origin:  h.origin,
+		outbox:  make(chan wsEnvelope, wsFanoutBacklog),
+	}
+}
+
+// publish queues a delivery for the other replicas without blocking.
+func (f *wsFanout) publish(d wsDelivery) {
+	env := wsEnvelope{ID: d.id, Origin: f.origin, ClientID: d.clientID, BankID: d.bankID, Topics: d.topics}
+	message := d.message
+	env.Message = &message
+	f.enqueue(env)
+}
+
+func (f *wsFanout) enqueue(env wsEnvelope) {
+	select {
+	case f.outbox <- env:
+	default:
+		wsMessagesDropped.Inc("fanout_backlog")
+	}
+}
+
+// run publishes queued deliveries and relays other replicas' deliveries to
+// the hub until ctx is cancelled.
+func (f *wsFanout) run(ctx context.Context, h *WSHub) {
+	go f.publishLoop(ctx)
+	f.subscribeLoop(ctx, h)
+}
+
+func (f *wsFanout) publishLoop(ctx context.Context) {
+	failing := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case env := <-f.outbox:
+			payload, err := json.Marshal(env)
+			if err != nil {
+				wsFanoutErrors.Inc("encode")
+				continue
+			}
+
+			pubCtx, cancel := context.WithTimeout(ctx, wsFanoutPublishTTL)
+			err = f.client.Publish(pubCtx, f.channel, payload).Err()
+			cancel()
+			if err != nil {
+				// Local clients already have the message; only other
+				// replicas miss it.
+				wsFanoutErrors.Inc("publish")
+				if !failing && ctx.Err() == nil {
+					loggers.Error("WebSocket fan-out publish failed", err, map[string]interface{}{
+						"channel": f.channel,
+					})
+				}
+				failing = true
+				continue
+			}
+			if failing {
+				loggers.Info("WebSocket fan-out publishing again", map[string]interface{}{
+					"channel": f.channel,
+				})
+				failing = false
+			}
+			wsFanoutPublished.Inc()
+		}
+	}
+}
+
+// subscribeLoop keeps a subscription open, reconnecting with backoff. When
+// a subscription comes back after an outage, local clients are told to
+// resync because events published in between are lost.
+func (f *wsFanout) subscribeLoop(ctx context.Context, h *WSHub) {
+	backoff := time.Second
+	everSubscribed := false
+	for ctx.Err() == nil {
+		pubsub := f.client.Subscribe(ctx, f.channel)
+		subscribed, err := f.receive(ctx, pubsub, h, everSubscribed)
+		_ = pubsub.Close()
+		if ctx.Err() != nil {
+			return
+		}
+
+		if subscribed {
+			everSubscribed = true
+			backoff = time.Second
+			loggers.Warn("WebSocket fan-out subscription lost", map[string]interface{}{
+				"channel": f.channel,
+				"error":   errorString(err),
+			})
+		}
+		wsFanoutErrors.Inc("subscribe")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > wsFanoutMaxBackoff {
+			backoff = wsFanoutMaxBackoff
+		}
+	}
+}
+
+// receive reads one subscription until it fails, reporting whether it was
+// ever confirmed by Redis.
+func (f *wsFanout) receive(ctx context.Context, pubsub *redis.PubSub, h *WSHub, resubscribed bool) (bool, error) {
+	subscribed := false
+	awaitingPong := false
+	for {
+		msg, err := pubsub.ReceiveTimeout(ctx, wsFanoutPingPeriod)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() && !awaitingPong {
+				if err := pubsub.Ping(ctx); err != nil {
+					return subscribed, err
+				}
+				awaitingPong = true
+				continue
+			}
+			return subscribed, err
+		}
+		awaitingPong = false
+
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			if m.Kind == "subscribe" && !subscribed {
+				subscribed = true
+				if resubscribed {
+					loggers.Info("WebSocket fan-out subscription restored", map[string]interface{}{
+						"channel": f.channel,
+					})
+					h.resync()
+				}
+			}
+
+		case *redis.Message:
+			var env wsEnvelope
+			if err := json.Unmarshal([]byte(m.Payload), &env); err != nil {
+				wsFanoutErrors.Inc("decode")
+				continue
+			}
+			if env.Origin == f.origin {
+				continue
+			}
+			wsFanoutReceived.Inc()
+			h.deliverRemote(env)
+		}
+	}
+}
+
+// deliverRemote hands another replica's delivery to the local clients.
+func (h *WSHub) deliverRemote(env wsEnvelope) {
+	if env.Subscribe != "" {
+		h.subscribeLocal(env.ClientID, env.BankID, env.Subscribe)
+		return
+	}
+	if env.Message == nil {
+		return
+	}
+	h.sendLocal(wsDelivery{id: env.ID, clientID: env.ClientID, topics: env.Topics, bankID: env.BankID, message: *env.Message})
+}
+
+// resync tells every local client that events may have been missed, so it
+// can refetch job and portfolio state over HTTP.
+func (h *WSHub) resync() {
+	h.sendLocal(wsDelivery{all: true, message: resyncMessage("event_stream_interrupted")})
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
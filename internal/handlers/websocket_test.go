@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/edgeesg/edge-esg-backend/internal/masking"
+	"github.com/edgeesg/edge-esg-backend/internal/services"
+)
+
+func TestWSHubMaskFor(t *testing.T) {
+	hub := NewWSHub(masking.DefaultPolicy(), SlowClientDrop, 0, 0)
+	message := pipelineMessage(services.PipelineEvent{
+		AnalysisID: "a1",
+		Company:    "Tata Steel",
+		Agent:      "esg_analyst",
+		Status:     services.PipelineCompleted,
+		Step:       2,
+		Steps:      4,
+		Output:     map[string]interface{}{"company_name": "Tata Steel", "esg_score": "6.1"},
+	})
+
+	tests := []struct {
+		name        string
+		role        string
+		wantCompany string
+	}{
+		{"trader", "TRADER", "*****Steel"},
+		{"admin", "ADMIN", "Tata Steel"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := hub.maskFor(message, "bank-1", tt.role)
+			if company := got.Data["company"]; company != tt.wantCompany {
+				t.Errorf("company = %v, want %v", company, tt.wantCompany)
+			}
+			output, _ := got.Data["output"].(map[string]interface{})
+			if name := output["company_name"]; name != tt.wantCompany {
+				t.Errorf("output company_name = %v, want %v", name, tt.wantCompany)
+			}
+			if score := output["esg_score"]; score != "6.1" {
+				t.Errorf("output esg_score = %v, want 6.1", score)
+			}
+		})
+	}
+
+	// Other clients share the published message
+	if company := message.Data["company"]; company != "Tata Steel" {
+		t.Errorf("published company = %v, want it unmasked", company)
+	}
+}
@@ -0,0 +1,158 @@
+package marketdata
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// MaxConstituents bounds the size of a benchmark file.
+const MaxConstituents = 2000
+
+// Constituent is a member of a benchmark and its weight, as a fraction of
+// the benchmark. CarbonIntensity is in tCO2e per $m revenue, nil when the
+// file has none.
+type Constituent struct {
+	Symbol          string
+	Weight          float64
+	Sector          string
+	Country         string
+	CarbonIntensity *float64
+}
+
+// Benchmark is an index defined by its constituents, in file order.
+type Benchmark struct {
+	Name         string
+	Constituents []Constituent
+}
+
+// BenchmarkSource provides benchmark definitions by name.
+type BenchmarkSource interface {
+	Benchmark(name string) (*Benchmark, error)
+}
+
+// Benchmarks lists the benchmarks that have a definition file.
+func (s *FileStore) Benchmarks() ([]string, error) {
+	names, err := s.list("benchmarks")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return names, err
+}
+
+// Benchmark loads a benchmark's constituents. Weights are scaled to add up
+// to 1, so files may give them in percent. Names follow the symbol rules,
+// so a benchmark can share its name with its price series.
+func (s *FileStore) Benchmark(name string) (*Benchmark, error) {
+	name, err := NormalizeSymbol(name)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := filepath.Join(s.dir, "benchmarks", name+".csv")
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: no benchmark %s", ErrNoData, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	cached, ok := s.benchmarks[name]
+	if !ok || !cached.modTime.Equal(info.ModTime()) {
+		rows, err := readConstituents(path)
+		if err != nil {
+			return nil, fmt.Errorf("benchmarks/%s.csv: %w", name, err)
+		}
+		cached = cachedFile[Constituent]{modTime: info.ModTime(), rows: rows}
+		s.benchmarks[name] = cached
+	}
+	return &Benchmark{Name: name, Constituents: cached.rows}, nil
+}
+
+func readConstituents(path string) ([]Constituent, error) {
+	f, err := os.Open(path) // #nosec G304 -- name is validated by NormalizeSymbol
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, name := range []string{"symbol", "weight"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing column %q", name)
+		}
+	}
+
+	var constituents []Constituent
+	seen := make(map[string]bool)
+	total := 0.0
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		symbol, err := NormalizeSymbol(field("symbol"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if seen[symbol] {
+			return nil, fmt.Errorf("line %d: %s is listed twice", line, symbol)
+		}
+		weight, err := strconv.ParseFloat(field("weight"), 64)
+		if err != nil || weight <= 0 {
+			return nil, fmt.Errorf("line %d: weight must be a positive number", line)
+		}
+		constituent := Constituent{
+			Symbol:  symbol,
+			Weight:  weight,
+			Sector:  field("sector"),
+			Country: field("country"),
+		}
+		if value := field("carbon_intensity"); value != "" {
+			intensity, err := strconv.ParseFloat(value, 64)
+			if err != nil || intensity < 0 {
+				return nil, fmt.Errorf("line %d: carbon_intensity must be a number of at least 0", line)
+			}
+			constituent.CarbonIntensity = &intensity
+		}
+		if len(constituents) == MaxConstituents {
+			return nil, fmt.Errorf("more than %d constituents", MaxConstituents)
+		}
+		seen[symbol] = true
+		total += weight
+		constituents = append(constituents, constituent)
+	}
+	if len(constituents) == 0 {
+		return nil, fmt.Errorf("%w: benchmark has no constituents", ErrNoData)
+	}
+	for i := range constituents {
+		constituents[i].Weight /= total
+	}
+	return constituents, nil
+}
@@ -0,0 +1,115 @@
+package marketdata
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// EmissionFactor is a sector's average emissions, in tCO2e per million of
+// revenue and per million of assets, for estimating the emissions of
+// companies that report none. Either may be nil.
+type EmissionFactor struct {
+	Sector  string
+	Revenue *float64
+	Assets  *float64
+}
+
+// EmissionFactorSource provides sector emission factors keyed by
+// lower-case sector name.
+type EmissionFactorSource interface {
+	EmissionFactors() (map[string]EmissionFactor, error)
+}
+
+// EmissionFactors loads <dir>/emission_factors.csv, with columns
+// sector,revenue_factor,asset_factor. Without the file there are none.
+func (s *FileStore) EmissionFactors() (map[string]EmissionFactor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := filepath.Join(s.dir, "emission_factors.csv")
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]EmissionFactor{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.factors == nil || !s.factors.modTime.Equal(info.ModTime()) {
+		rows, err := readEmissionFactors(path)
+		if err != nil {
+			return nil, fmt.Errorf("emission_factors.csv: %w", err)
+		}
+		s.factors = &cachedFile[EmissionFactor]{modTime: info.ModTime(), rows: rows}
+	}
+	factors := make(map[string]EmissionFactor, len(s.factors.rows))
+	for _, factor := range s.factors.rows {
+		factors[strings.ToLower(factor.Sector)] = factor
+	}
+	return factors, nil
+}
+
+func readEmissionFactors(path string) ([]EmissionFactor, error) {
+	f, err := os.Open(path) // #nosec G304 -- fixed name under the configured directory
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	if _, ok := columns["sector"]; !ok {
+		return nil, fmt.Errorf("missing column %q", "sector")
+	}
+
+	var factors []EmissionFactor
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		factor := EmissionFactor{Sector: field("sector")}
+		if factor.Sector == "" {
+			return nil, fmt.Errorf("line %d: sector is required", line)
+		}
+		for _, col := range []struct {
+			name string
+			dst  **float64
+		}{{"revenue_factor", &factor.Revenue}, {"asset_factor", &factor.Assets}} {
+			value := field(col.name)
+			if value == "" {
+				continue
+			}
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("line %d: %s must be a number of at least 0", line, col.name)
+			}
+			*col.dst = &n
+		}
+		factors = append(factors, factor)
+	}
+	return factors, nil
+}
@@ -0,0 +1,180 @@
+package marketdata
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Physical hazards scored in the hazard files
+const (
+	HazardFlood        = "flood"
+	HazardHeat         = "heat"
+	HazardDrought      = "drought"
+	HazardCyclone      = "cyclone"
+	HazardSeaLevelRise = "sea_level_rise"
+)
+
+// Hazards lists every hazard, in the order reported.
+var Hazards = []string{HazardFlood, HazardHeat, HazardDrought, HazardCyclone, HazardSeaLevelRise}
+
+const (
+	// MaxHazardCells bounds the size of the hazard grid.
+	MaxHazardCells = 100000
+	// MaxHazardDistanceKm is the farthest a location may be from the
+	// centre of the grid cell that scores it.
+	MaxHazardDistanceKm = 400
+)
+
+// HazardScores are hazard scores from 0 (none) to 1 (extreme), by hazard.
+// A hazard the data does not score is absent.
+type HazardScores map[string]float64
+
+// HazardCell is a cell of the hazard grid, by its centre.
+type HazardCell struct {
+	Latitude  float64
+	Longitude float64
+	Scores    HazardScores
+}
+
+// RegionHazards are the hazard scores of an administrative region, by
+// ISO 3166 code: a country such as IN or a subdivision such as IN-MH.
+type RegionHazards struct {
+	Region string
+	Scores HazardScores
+}
+
+// HazardSource scores physical hazards by location. Both lookups return
+// false when the data has no score for the place.
+type HazardSource interface {
+	HazardsAt(latitude, longitude float64) (HazardScores, bool, error)
+	RegionHazards(region string) (HazardScores, bool, error)
+}
+
+// HazardsAt returns the scores of the nearest cell of
+// <dir>/hazards/grid.csv, if it is within MaxHazardDistanceKm.
+func (s *FileStore) HazardsAt(latitude, longitude float64) (HazardScores, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cells, err := loadFixed(s, &s.hazardGrid, "grid.csv", []string{"lat", "lon"}, readHazardCell)
+	if err != nil {
+		return nil, false, err
+	}
+	best, distance := -1, math.Inf(1)
+	for i, cell := range cells {
+		if d := DistanceKm(latitude, longitude, cell.Latitude, cell.Longitude); d < distance {
+			best, distance = i, d
+		}
+	}
+	if best < 0 || distance > MaxHazardDistanceKm {
+		return nil, false, nil
+	}
+	return cells[best].Scores, true, nil
+}
+
+// RegionHazards returns the scores of a region in
+// <dir>/hazards/regions.csv. A subdivision without scores of its own takes
+// its country's.
+func (s *FileStore) RegionHazards(region string) (HazardScores, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	regions, err := loadFixed(s, &s.hazardRegions, "regions.csv", []string{"region"}, readRegionHazards)
+	if err != nil {
+		return nil, false, err
+	}
+	region = strings.ToUpper(strings.TrimSpace(region))
+	for _, code := range []string{region, strings.SplitN(region, "-", 2)[0]} {
+		for _, row := range regions {
+			if row.Region == code {
+				return row.Scores, true, nil
+			}
+		}
+	}
+	return nil, false, nil
+}
+
+// DistanceKm is the great-circle distance between two points, in km.
+func DistanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// loadFixed loads a file of the hazards directory through its cache. A
+// missing file has no rows. The caller holds s.mu.
+func loadFixed[T any](s *FileStore, cache **cachedFile[T], name string, required []string, parse func(field func(string) string) (T, error)) ([]T, error) {
+	path := filepath.Join(s.dir, "hazards", name)
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if *cache != nil && (*cache).modTime.Equal(info.ModTime()) {
+		return (*cache).rows, nil
+	}
+	var rows []T
+	err = readRows(path, required, func(field func(string) string) error {
+		if len(rows) == MaxHazardCells {
+			return fmt.Errorf("more than %d rows", MaxHazardCells)
+		}
+		row, err := parse(field)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("hazards/%s: %w", name, err)
+	}
+	*cache = &cachedFile[T]{modTime: info.ModTime(), rows: rows}
+	return rows, nil
+}
+
+func readHazardCell(field func(string) string) (HazardCell, error) {
+	latitude, err := strconv.ParseFloat(field("lat"), 64)
+	if err != nil || latitude < -90 || latitude > 90 {
+		return HazardCell{}, fmt.Errorf("lat must be a number from -90 to 90")
+	}
+	longitude, err := strconv.ParseFloat(field("lon"), 64)
+	if err != nil || longitude < -180 || longitude > 180 {
+		return HazardCell{}, fmt.Errorf("lon must be a number from -180 to 180")
+	}
+	scores, err := readHazardScores(field)
+	return HazardCell{Latitude: latitude, Longitude: longitude, Scores: scores}, err
+}
+
+func readRegionHazards(field func(string) string) (RegionHazards, error) {
+	region := strings.ToUpper(field("region"))
+	if region == "" {
+		return RegionHazards{}, fmt.Errorf("region is required")
+	}
+	scores, err := readHazardScores(field)
+	return RegionHazards{Region: region, Scores: scores}, err
+}
+
+func readHazardScores(field func(string) string) (HazardScores, error) {
+	scores := make(HazardScores, len(Hazards))
+	for _, hazard := range Hazards {
+		value := field(hazard)
+		if value == "" {
+			continue
+		}
+		score, err := strconv.ParseFloat(value, 64)
+		if err != nil || score < 0 || score > 1 {
+			return nil, fmt.Errorf("%s must be a number from 0 to 1", hazard)
+		}
+		scores[hazard] = score
+	}
+	return scores, nil
+}
@@ -0,0 +1,303 @@
+// Package marketdata reads end-of-day prices and point-in-time ESG and
+// sentiment inputs from CSV files, for backtests and simulations that must
+// not depend on live upstream APIs.
+package marketdata
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DateLayout is the date format of every data file.
+const DateLayout = "2006-01-02"
+
+var (
+	ErrNoData        = errors.New("no market data")
+	ErrInvalidSymbol = errors.New("invalid symbol")
+)
+
+// Symbols double as file names, so they are restricted to a safe alphabet.
+var symbolPattern = regexp.MustCompile(`^[A-Z0-9][A-Z0-9.\-_]{0,19}$`)
+
+// Bar is one day of prices. Open is 0 when the file has no open column.
+type Bar struct {
+	Date   time.Time
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume float64
+}
+
+// Inputs are the ESG score (0-10) and news sentiment (0-1) as they were
+// known on Date. They stay in force until the next row.
+type Inputs struct {
+	Date      time.Time
+	ESGScore  float64
+	Sentiment float64
+}
+
+// Source provides historical data by symbol, ordered by date.
+type Source interface {
+	Bars(symbol string) ([]Bar, error)
+	Inputs(symbol string) ([]Inputs, error)
+}
+
+// FileStore is a Source over a directory laid out as
+//
+//	<dir>/prices/<SYMBOL>.csv      date,open,high,low,close,volume
+//	<dir>/inputs/<SYMBOL>.csv      date,esg_score,sentiment
+//	<dir>/benchmarks/<NAME>.csv    symbol,weight,sector,country,carbon_intensity
+//	<dir>/emission_factors.csv     sector,revenue_factor,asset_factor
+//	<dir>/scenarios.csv            name,category,description
+//	<dir>/scenarios/<NAME>.csv     year,sector,carbon_price,revenue_shock,emissions_change,pass_through
+//	<dir>/hazards/grid.csv         lat,lon,flood,heat,drought,cyclone,sea_level_rise
+//	<dir>/hazards/regions.csv      region,flood,heat,drought,cyclone,sea_level_rise
+//
+// Columns are found by header name; only date and close (prices), date,
+// esg_score and sentiment (inputs), symbol and weight (benchmarks), name
+// and category (scenarios.csv), year (scenario files), lat and lon (hazard
+// grid) or region (hazard regions) are required. Files are cached and
+// reloaded when they change.
+type FileStore struct {
+	dir        string
+	mu         sync.Mutex
+	bars       map[string]cachedFile[Bar]
+	inputs     map[string]cachedFile[Inputs]
+	benchmarks map[string]cachedFile[Constituent]
+	factors    *cachedFile[EmissionFactor]
+	scenarios  map[string]cachedFile[scenarioRow]
+	// scenarioList is scenarios.csv
+	scenarioList  *cachedFile[scenarioInfo]
+	hazardGrid    *cachedFile[HazardCell]
+	hazardRegions *cachedFile[RegionHazards]
+}
+
+type cachedFile[T any] struct {
+	modTime time.Time
+	rows    []T
+}
+
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{
+		dir:        dir,
+		bars:       make(map[string]cachedFile[Bar]),
+		inputs:     make(map[string]cachedFile[Inputs]),
+		benchmarks: make(map[string]cachedFile[Constituent]),
+		scenarios:  make(map[string]cachedFile[scenarioRow]),
+	}
+}
+
+// NormalizeSymbol upper-cases a symbol and checks it is usable as a file name.
+func NormalizeSymbol(symbol string) (string, error) {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	if !symbolPattern.MatchString(symbol) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidSymbol, symbol)
+	}
+	return symbol, nil
+}
+
+// Symbols lists the symbols that have a price file.
+func (s *FileStore) Symbols() ([]string, error) {
+	return s.list("prices")
+}
+
+// list returns the symbols with a file in the kind directory.
+func (s *FileStore) list(kind string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, kind))
+	if err != nil {
+		return nil, err
+	}
+	var symbols []string
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".csv")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		if symbol, err := NormalizeSymbol(name); err == nil {
+			symbols = append(symbols, symbol)
+		}
+	}
+	sort.Strings(symbols)
+	return symbols, nil
+}
+
+func (s *FileStore) Bars(symbol string) ([]Bar, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return load(s.dir, "prices", symbol, s.bars, []string{"date", "close"}, parseBar)
+}
+
+func (s *FileStore) Inputs(symbol string) ([]Inputs, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return load(s.dir, "inputs", symbol, s.inputs, []string{"date", "esg_score", "sentiment"}, parseInputs)
+}
+
+// AsOf returns the latest inputs dated on or before date, so a simulation
+// only ever sees what was known at the time.
+func AsOf(inputs []Inputs, date time.Time) (Inputs, bool) {
+	i := sort.Search(len(inputs), func(i int) bool { return inputs[i].Date.After(date) })
+	if i == 0 {
+		return Inputs{}, false
+	}
+	return inputs[i-1], true
+}
+
+// BarAsOf returns the latest bar dated on or before date: the quote a
+// simulation trading on date would see.
+func BarAsOf(bars []Bar, date time.Time) (Bar, bool) {
+	i := sort.Search(len(bars), func(i int) bool { return bars[i].Date.After(date) })
+	if i == 0 {
+		return Bar{}, false
+	}
+	return bars[i-1], true
+}
+
+func load[T any](dir, kind, symbol string, cache map[string]cachedFile[T], required []string, parse func(row func(string) string) (T, time.Time, error)) ([]T, error) {
+	symbol, err := NormalizeSymbol(symbol)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, kind, symbol+".csv")
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: no %s for %s", ErrNoData, kind, symbol)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cached, ok := cache[symbol]; ok && cached.modTime.Equal(info.ModTime()) {
+		return cached.rows, nil
+	}
+
+	rows, err := readCSV(path, required, parse)
+	if err != nil {
+		return nil, fmt.Errorf("%s/%s.csv: %w", kind, symbol, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: %s for %s is empty", ErrNoData, kind, symbol)
+	}
+	cache[symbol] = cachedFile[T]{modTime: info.ModTime(), rows: rows}
+	return rows, nil
+}
+
+// readCSV parses a headed CSV file into rows sorted by date. A later row for
+// the same date replaces an earlier one.
+func readCSV[T any](path string, required []string, parse func(row func(string) string) (T, time.Time, error)) ([]T, error) {
+	f, err := os.Open(path) // #nosec G304 -- symbol is validated by NormalizeSymbol
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, name := range required {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing column %q", name)
+		}
+	}
+
+	byDate := make(map[time.Time]T)
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		row, date, err := parse(field)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		byDate[date] = row
+	}
+
+	dates := make([]time.Time, 0, len(byDate))
+	for date := range byDate {
+		dates = append(dates, date)
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	rows := make([]T, len(dates))
+	for i, date := range dates {
+		rows[i] = byDate[date]
+	}
+	return rows, nil
+}
+
+func parseBar(field func(string) string) (Bar, time.Time, error) {
+	date, err := time.Parse(DateLayout, field("date"))
+	if err != nil {
+		return Bar{}, date, fmt.Errorf("invalid date %q", field("date"))
+	}
+	bar := Bar{Date: date}
+	for _, col := range []struct {
+		name string
+		dst  *float64
+	}{
+		{"open", &bar.Open}, {"high", &bar.High}, {"low", &bar.Low},
+		{"close", &bar.Close}, {"volume", &bar.Volume},
+	} {
+		if *col.dst, err = parseNumber(field(col.name)); err != nil {
+			return Bar{}, date, fmt.Errorf("%s: %w", col.name, err)
+		}
+	}
+	if bar.Close <= 0 {
+		return Bar{}, date, fmt.Errorf("close must be positive")
+	}
+	return bar, date, nil
+}
+
+func parseInputs(field func(string) string) (Inputs, time.Time, error) {
+	date, err := time.Parse(DateLayout, field("date"))
+	if err != nil {
+		return Inputs{}, date, fmt.Errorf("invalid date %q", field("date"))
+	}
+	in := Inputs{Date: date}
+	if in.ESGScore, err = parseNumber(field("esg_score")); err != nil || in.ESGScore < 0 || in.ESGScore > 10 {
+		return Inputs{}, date, fmt.Errorf("esg_score must be a number from 0 to 10")
+	}
+	if in.Sentiment, err = parseNumber(field("sentiment")); err != nil || in.Sentiment < 0 || in.Sentiment > 1 {
+		return Inputs{}, date, fmt.Errorf("sentiment must be a number from 0 to 1")
+	}
+	return in, date, nil
+}
+
+// parseNumber treats an empty field as 0.
+func parseNumber(value string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", value)
+	}
+	return n, nil
+}
@@ -0,0 +1,363 @@
+package marketdata
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Scenario categories, after the NGFS scenario framework
+const (
+	ScenarioOrderly    = "orderly"
+	ScenarioDisorderly = "disorderly"
+	ScenarioHotHouse   = "hot_house"
+)
+
+// MaxScenarioRows bounds the size of a scenario file.
+const MaxScenarioRows = 2000
+
+// Point is a value in a year.
+type Point struct {
+	Year  int
+	Value float64
+}
+
+// Path is a yearly series, in year order.
+type Path []Point
+
+// At interpolates the path linearly between its points and holds its first
+// and last values beyond them. It returns false for an empty path.
+func (p Path) At(year int) (float64, bool) {
+	if len(p) == 0 {
+		return 0, false
+	}
+	i := sort.Search(len(p), func(i int) bool { return p[i].Year >= year })
+	switch {
+	case i == len(p):
+		return p[len(p)-1].Value, true
+	case p[i].Year == year || i == 0:
+		return p[i].Value, true
+	}
+	before, after := p[i-1], p[i]
+	share := float64(year-before.Year) / float64(after.Year-before.Year)
+	return before.Value + share*(after.Value-before.Value), true
+}
+
+// Shocks are the paths a scenario sets for a sector or the whole economy.
+// CarbonPrice is per tCO2e, in the reporting currency, on top of what
+// companies pay today. RevenueShock and EmissionsChange are fractional
+// changes from today's revenue and emissions. PassThrough is the share of
+// carbon costs passed on to customers, 0 to 1.
+type Shocks struct {
+	CarbonPrice     Path
+	RevenueShock    Path
+	EmissionsChange Path
+	PassThrough     Path
+}
+
+// Scenario is a climate scenario: economy-wide paths, with overrides for
+// some sectors. Years are those the file gives values for.
+type Scenario struct {
+	Name        string
+	Category    string
+	Description string
+	Years       []int
+	Economy     Shocks
+	Sectors     map[string]Shocks
+}
+
+// For returns a sector's shocks: the sector's own paths where the scenario
+// has them and the economy's otherwise. Sectors match case-insensitively.
+func (s *Scenario) For(sector string) Shocks {
+	shocks := s.Economy
+	own, ok := s.Sectors[strings.ToLower(strings.TrimSpace(sector))]
+	if !ok {
+		return shocks
+	}
+	for _, path := range []struct{ own, dst *Path }{
+		{&own.CarbonPrice, &shocks.CarbonPrice},
+		{&own.RevenueShock, &shocks.RevenueShock},
+		{&own.EmissionsChange, &shocks.EmissionsChange},
+		{&own.PassThrough, &shocks.PassThrough},
+	} {
+		if len(*path.own) > 0 {
+			*path.dst = *path.own
+		}
+	}
+	return shocks
+}
+
+// ScenarioSource provides climate scenarios by name.
+type ScenarioSource interface {
+	Scenarios() ([]Scenario, error)
+	Scenario(name string) (*Scenario, error)
+}
+
+// scenarioInfo is a row of <dir>/scenarios.csv.
+type scenarioInfo struct {
+	Name        string
+	Category    string
+	Description string
+}
+
+// scenarioRow is a row of a scenario file. Sector is empty for the
+// economy; nil values are not set by the row.
+type scenarioRow struct {
+	Year            int
+	Sector          string
+	CarbonPrice     *float64
+	RevenueShock    *float64
+	EmissionsChange *float64
+	PassThrough     *float64
+}
+
+// Scenarios loads every scenario listed in <dir>/scenarios.csv, in file
+// order. Without the file there are none.
+func (s *FileStore) Scenarios() ([]Scenario, error) {
+	s.mu.Lock()
+	infos, err := s.scenarioIndex()
+	s.mu.Unlock()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	scenarios := make([]Scenario, 0, len(infos))
+	for _, info := range infos {
+		scenario, err := s.Scenario(info.Name)
+		if err != nil {
+			return nil, err
+		}
+		scenarios = append(scenarios, *scenario)
+	}
+	return scenarios, nil
+}
+
+// Scenario loads a scenario listed in <dir>/scenarios.csv from
+// scenarios/<NAME>.csv. Names follow the symbol rules.
+func (s *FileStore) Scenario(name string) (*Scenario, error) {
+	name, err := NormalizeSymbol(name)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	infos, err := s.scenarioIndex()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: no scenarios", ErrNoData)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var info *scenarioInfo
+	for i := range infos {
+		if infos[i].Name == name {
+			info = &infos[i]
+		}
+	}
+	if info == nil {
+		return nil, fmt.Errorf("%w: no scenario %s", ErrNoData, name)
+	}
+
+	path := filepath.Join(s.dir, "scenarios", name+".csv")
+	stat, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: scenarios/%s.csv is missing", ErrNoData, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	cached, ok := s.scenarios[name]
+	if !ok || !cached.modTime.Equal(stat.ModTime()) {
+		rows, err := readScenario(path)
+		if err != nil {
+			return nil, fmt.Errorf("scenarios/%s.csv: %w", name, err)
+		}
+		cached = cachedFile[scenarioRow]{modTime: stat.ModTime(), rows: rows}
+		s.scenarios[name] = cached
+	}
+	return buildScenario(*info, cached.rows), nil
+}
+
+// scenarioIndex returns the rows of <dir>/scenarios.csv. The caller holds
+// s.mu.
+func (s *FileStore) scenarioIndex() ([]scenarioInfo, error) {
+	path := filepath.Join(s.dir, "scenarios.csv")
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if s.scenarioList == nil || !s.scenarioList.modTime.Equal(info.ModTime()) {
+		rows, err := readScenarioIndex(path)
+		if err != nil {
+			return nil, fmt.Errorf("scenarios.csv: %w", err)
+		}
+		s.scenarioList = &cachedFile[scenarioInfo]{modTime: info.ModTime(), rows: rows}
+	}
+	return s.scenarioList.rows, nil
+}
+
+func buildScenario(info scenarioInfo, rows []scenarioRow) *Scenario {
+	scenario := &Scenario{
+		Name:        info.Name,
+		Category:    info.Category,
+		Description: info.Description,
+		Sectors:     make(map[string]Shocks),
+	}
+	years := make(map[int]bool)
+	for _, row := range rows {
+		years[row.Year] = true
+		shocks := &scenario.Economy
+		key := strings.ToLower(row.Sector)
+		if key != "" {
+			sector := scenario.Sectors[key]
+			shocks = &sector
+		}
+		for _, value := range []struct {
+			value *float64
+			path  *Path
+		}{
+			{row.CarbonPrice, &shocks.CarbonPrice},
+			{row.RevenueShock, &shocks.RevenueShock},
+			{row.EmissionsChange, &shocks.EmissionsChange},
+			{row.PassThrough, &shocks.PassThrough},
+		} {
+			if value.value != nil {
+				*value.path = append(*value.path, Point{Year: row.Year, Value: *value.value})
+			}
+		}
+		if key != "" {
+			scenario.Sectors[key] = *shocks
+		}
+	}
+	for year := range years {
+		scenario.Years = append(scenario.Years, year)
+	}
+	sort.Ints(scenario.Years)
+	return scenario
+}
+
+func readScenarioIndex(path string) ([]scenarioInfo, error) {
+	var infos []scenarioInfo
+	seen := make(map[string]bool)
+	err := readRows(path, []string{"name", "category"}, func(field func(string) string) error {
+		name, err := NormalizeSymbol(field("name"))
+		if err != nil {
+			return err
+		}
+		if seen[name] {
+			return fmt.Errorf("%s is listed twice", name)
+		}
+		category := strings.ToLower(field("category"))
+		if category != ScenarioOrderly && category != ScenarioDisorderly && category != ScenarioHotHouse {
+			return fmt.Errorf("category must be %s, %s or %s", ScenarioOrderly, ScenarioDisorderly, ScenarioHotHouse)
+		}
+		seen[name] = true
+		infos = append(infos, scenarioInfo{Name: name, Category: category, Description: field("description")})
+		return nil
+	})
+	return infos, err
+}
+
+func readScenario(path string) ([]scenarioRow, error) {
+	var rows []scenarioRow
+	seen := make(map[string]bool)
+	err := readRows(path, []string{"year"}, func(field func(string) string) error {
+		year, err := strconv.Atoi(field("year"))
+		if err != nil || year < 2000 || year > 2100 {
+			return fmt.Errorf("year must be from 2000 to 2100")
+		}
+		row := scenarioRow{Year: year, Sector: field("sector")}
+		key := fmt.Sprintf("%d/%s", year, strings.ToLower(row.Sector))
+		if seen[key] {
+			return fmt.Errorf("%d %q is given twice", year, row.Sector)
+		}
+		for _, col := range []struct {
+			name     string
+			dst      **float64
+			min, max float64
+		}{
+			{"carbon_price", &row.CarbonPrice, 0, 1e6},
+			{"revenue_shock", &row.RevenueShock, -1, 10},
+			{"emissions_change", &row.EmissionsChange, -1, 10},
+			{"pass_through", &row.PassThrough, 0, 1},
+		} {
+			value := field(col.name)
+			if value == "" {
+				continue
+			}
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil || n < col.min || n > col.max {
+				return fmt.Errorf("%s must be a number from %g to %g", col.name, col.min, col.max)
+			}
+			*col.dst = &n
+		}
+		if len(rows) == MaxScenarioRows {
+			return fmt.Errorf("more than %d rows", MaxScenarioRows)
+		}
+		seen[key] = true
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: scenario has no rows", ErrNoData)
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].Year < rows[j].Year })
+	return rows, nil
+}
+
+// readRows calls row for each record of a headed CSV file, with a lookup
+// of the record's fields by column name.
+func readRows(path string, required []string, row func(field func(string) string) error) error {
+	f, err := os.Open(path) // #nosec G304 -- name is fixed or validated by NormalizeSymbol
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("reading header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, name := range required {
+		if _, ok := columns[name]; !ok {
+			return fmt.Errorf("missing column %q", name)
+		}
+	}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		if err := row(field); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+}
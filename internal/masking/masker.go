@@ -0,0 +1,132 @@
+package masking
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// Apply redacts, in place, every field of v that the role at bankID may not
+// see. Struct fields are matched by their `mask` tag and map entries by key.
+// It reports whether anything was redacted so callers can set MaskedData.
+// v must be a pointer (or a map/slice) for the redaction to be visible.
+func (p *Policy) Apply(v interface{}, bankID, role string) bool {
+	if v == nil {
+		return false
+	}
+	m := &masker{policy: p, bankID: bankID, role: role}
+	m.walk(reflect.ValueOf(v))
+	return m.masked
+}
+
+type masker struct {
+	policy *Policy
+	bankID string
+	role   string
+	masked bool
+}
+
+func (m *masker) redacts(field string) bool {
+	return m.policy.Redacts(m.bankID, m.role, field)
+}
+
+func (m *masker) walk(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			m.walk(v.Elem())
+		}
+
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			sf := t.Field(i)
+			if !sf.IsExported() {
+				continue
+			}
+			field := v.Field(i)
+			if class := sf.Tag.Get("mask"); class != "" {
+				if m.redacts(class) && field.CanSet() && !field.IsZero() {
+					field.Set(redactValue(class, field))
+					m.masked = true
+				}
+				continue
+			}
+			m.walk(field)
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			m.walk(v.Index(i))
+		}
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			key, value := iter.Key(), iter.Value()
+			if class, ok := m.policy.Keys[key.String()]; ok {
+				if m.redacts(class) && !value.IsZero() {
+					v.SetMapIndex(key, redactValue(class, value))
+					m.masked = true
+				}
+				continue
+			}
+			// Map values are not addressable; nested maps, slices and
+			// pointers are still redacted through their references.
+			m.walk(value)
+		}
+	}
+}
+
+// redactValue returns the replacement for a redacted value of the same type.
+// Strings, and slices of them, keep a recognisable shape; everything else
+// becomes its zero value.
+func redactValue(class string, v reflect.Value) reflect.Value {
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		inner := v.Elem()
+		if inner.Kind() == reflect.String {
+			out := reflect.New(v.Type()).Elem()
+			out.Set(reflect.ValueOf(redactString(class, inner.String())))
+			return out
+		}
+		return reflect.Zero(v.Type())
+	}
+
+	if v.Kind() == reflect.String {
+		out := reflect.New(v.Type()).Elem()
+		out.SetString(redactString(class, v.String()))
+		return out
+	}
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String {
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).SetString(redactString(class, v.Index(i).String()))
+		}
+		return out
+	}
+	return reflect.Zero(v.Type())
+}
+
+func redactString(class, value string) string {
+	switch class {
+	case FieldRevenue:
+		return "₹XXCr"
+	case FieldEmissions:
+		return "X.XM tCO2e"
+	case FieldCounterparty:
+		half := len([]rune(value)) / 2
+		runes := []rune(value)
+		return strings.Repeat("*", half) + string(runes[half:])
+	default:
+		// Keep currency symbols and separators so the frontend layout holds.
+		return strings.Map(func(r rune) rune {
+			if unicode.IsDigit(r) {
+				return 'X'
+			}
+			return r
+		}, value)
+	}
+}
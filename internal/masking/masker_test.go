@@ -0,0 +1,278 @@
+package masking_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/edgeesg/edge-esg-backend/internal/dtos"
+	"github.com/edgeesg/edge-esg-backend/internal/masking"
+)
+
+func float(v float64) *float64 {
+	return &v
+}
+
+func analyzeResponse() *dtos.AnalyzeResponse {
+	return &dtos.AnalyzeResponse{
+		ESGScore: "7.2",
+		TradingSignal: dtos.TradingSignal{
+			Action:       "BUY",
+			Symbol:       "SUZLON.NS",
+			CurrentPrice: "$52.10",
+			TargetPrice:  "$61.25",
+		},
+		InvestmentProjections: []map[string]interface{}{
+			{"period": "1 Year", "current_price": 52.1, "future_price": 58.4},
+		},
+		CreditRisk: &dtos.CreditAdjustmentResponse{Obligor: "Suzlon Energy", AdjustedRating: "BB"},
+	}
+}
+
+func TestApplyAnalyzeResponse(t *testing.T) {
+	policy := masking.DefaultPolicy()
+	tests := []struct {
+		name        string
+		role        string
+		wantMasked  bool
+		wantTarget  string
+		wantFuture  interface{}
+		wantObligor string
+	}{
+		{"trader", "TRADER", true, "$XX.XX", nil, "****** Energy"},
+		{"unknown role is the default", "ANALYST", true, "$XX.XX", nil, "****** Energy"},
+		{"role is case insensitive", "trader", true, "$XX.XX", nil, "****** Energy"},
+		{"risk", "RISK", false, "$61.25", 58.4, "Suzlon Energy"},
+		{"compliance", "COMPLIANCE", false, "$61.25", 58.4, "Suzlon Energy"},
+		{"admin", "ADMIN", false, "$61.25", 58.4, "Suzlon Energy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := analyzeResponse()
+			if got := policy.Apply(response, "", tt.role); got != tt.wantMasked {
+				t.Errorf("Apply() = %v, want %v", got, tt.wantMasked)
+			}
+			if got := response.TradingSignal.TargetPrice; got != tt.wantTarget {
+				t.Errorf("target price = %q, want %q", got, tt.wantTarget)
+			}
+			if got := response.InvestmentProjections[0]["future_price"]; got != tt.wantFuture {
+				t.Errorf("future price = %v, want %v", got, tt.wantFuture)
+			}
+			if got := response.CreditRisk.Obligor; got != tt.wantObligor {
+				t.Errorf("obligor = %q, want %q", got, tt.wantObligor)
+			}
+			if got := response.TradingSignal.CurrentPrice; got != "$52.10" {
+				t.Errorf("current price = %q, want it untouched", got)
+			}
+		})
+	}
+}
+
+func TestApplyFinancedExposures(t *testing.T) {
+	policy := masking.DefaultPolicy()
+	tests := []struct {
+		name             string
+		role             string
+		wantMasked       bool
+		wantCounterparty string
+		wantEmissions    *float64
+		wantRevenue      *float64
+	}{
+		{"trader", "TRADER", true, "*****Steel", nil, nil},
+		{"risk", "RISK", true, "Tata Steel", nil, nil},
+		{"compliance", "COMPLIANCE", false, "Tata Steel", float(1.2e6), float(2.4e4)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := &dtos.FinancedEmissionsResponse{
+				Exposures: []dtos.FinancedExposureResponse{{
+					Counterparty:      "Tata Steel",
+					CompanyEmissions:  float(1.2e6),
+					FinancedEmissions: float(3000),
+				}},
+			}
+			exposures := &dtos.ExposuresResponse{
+				Exposures: []dtos.ExposureRequest{{
+					Counterparty: "Tata Steel",
+					Revenue:      float(2.4e4),
+					Emissions:    float(1.2e6),
+				}},
+			}
+			if got := policy.Apply(report, "", tt.role); got != tt.wantMasked {
+				t.Errorf("Apply(report) = %v, want %v", got, tt.wantMasked)
+			}
+			if got := policy.Apply(exposures, "", tt.role); got != tt.wantMasked {
+				t.Errorf("Apply(exposures) = %v, want %v", got, tt.wantMasked)
+			}
+
+			financed := report.Exposures[0]
+			if financed.Counterparty != tt.wantCounterparty {
+				t.Errorf("counterparty = %q, want %q", financed.Counterparty, tt.wantCounterparty)
+			}
+			if !reflect.DeepEqual(financed.CompanyEmissions, tt.wantEmissions) {
+				t.Errorf("company emissions = %v, want %v", financed.CompanyEmissions, tt.wantEmissions)
+			}
+			if financed.FinancedEmissions == nil || *financed.FinancedEmissions != 3000 {
+				t.Errorf("financed emissions = %v, want 3000 for every role", financed.FinancedEmissions)
+			}
+
+			exposure := exposures.Exposures[0]
+			if exposure.Counterparty != tt.wantCounterparty {
+				t.Errorf("exposure counterparty = %q, want %q", exposure.Counterparty, tt.wantCounterparty)
+			}
+			if !reflect.DeepEqual(exposure.Revenue, tt.wantRevenue) {
+				t.Errorf("revenue = %v, want %v", exposure.Revenue, tt.wantRevenue)
+			}
+			if !reflect.DeepEqual(exposure.Emissions, tt.wantEmissions) {
+				t.Errorf("emissions = %v, want %v", exposure.Emissions, tt.wantEmissions)
+			}
+		})
+	}
+}
+
+func TestApplyCompanyNames(t *testing.T) {
+	response := &dtos.PortfolioFrontierResponse{
+		Companies:        []dtos.FrontierCompany{{CompanyName: "Infosys", Symbol: "INFY.NS"}},
+		SkippedCompanies: []string{"Adani Green", "NTPC"},
+	}
+	if !masking.DefaultPolicy().Apply(response, "", "TRADER") {
+		t.Fatal("Apply() = false, want true")
+	}
+	if got := response.Companies[0].CompanyName; got != "***osys" {
+		t.Errorf("company name = %q, want %q", got, "***osys")
+	}
+	if got := response.Companies[0].Symbol; got != "INFY.NS" {
+		t.Errorf("symbol = %q, want it untouched", got)
+	}
+	want := []string{"***** Green", "**PC"}
+	if !reflect.DeepEqual(response.SkippedCompanies, want) {
+		t.Errorf("skipped companies = %q, want %q", response.SkippedCompanies, want)
+	}
+}
+
+func TestApplySignal(t *testing.T) {
+	policy := masking.DefaultPolicy()
+	tests := []struct {
+		name        string
+		role        string
+		wantMasked  bool
+		wantCompany string
+		wantTarget  float64
+	}{
+		{"trader", "TRADER", true, "****alco", 0},
+		{"risk", "RISK", false, "Hindalco", 612.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signal := &dtos.SignalResponse{Company: "Hindalco", Symbol: "HINDALCO.NS", CurrentPrice: 580, TargetPrice: 612.5}
+			if got := policy.Apply(signal, "", tt.role); got != tt.wantMasked {
+				t.Errorf("Apply() = %v, want %v", got, tt.wantMasked)
+			}
+			if signal.Company != tt.wantCompany {
+				t.Errorf("company = %q, want %q", signal.Company, tt.wantCompany)
+			}
+			if signal.TargetPrice != tt.wantTarget {
+				t.Errorf("target price = %v, want %v", signal.TargetPrice, tt.wantTarget)
+			}
+			if signal.Symbol != "HINDALCO.NS" || signal.CurrentPrice != 580 {
+				t.Errorf("symbol and current price = %q, %v, want them untouched", signal.Symbol, signal.CurrentPrice)
+			}
+		})
+	}
+}
+
+func TestApplyBatchRows(t *testing.T) {
+	policy := masking.DefaultPolicy()
+	row := &dtos.BatchFailureRow{Index: 3, Company: "Tata Steel", Error: "no data"}
+	if !policy.Apply(row, "", "TRADER") {
+		t.Error("Apply(row) = false, want true")
+	}
+	if row.Company != "*****Steel" {
+		t.Errorf("row company = %q, want %q", row.Company, "*****Steel")
+	}
+
+	// Streamed job items are decoded into maps, so only the keys apply
+	item := map[string]interface{}{"index": 3.0, "company": "Tata Steel", "status": "FAILED"}
+	if !policy.Apply(item, "", "TRADER") {
+		t.Error("Apply(item) = false, want true")
+	}
+	if item["company"] != "*****Steel" {
+		t.Errorf("item company = %v, want %q", item["company"], "*****Steel")
+	}
+	if item["status"] != "FAILED" {
+		t.Errorf("item status = %v, want it untouched", item["status"])
+	}
+}
+
+func TestApplyBankOverride(t *testing.T) {
+	policy := masking.DefaultPolicy()
+	policy.Banks = map[string]masking.BankPolicy{
+		"bank-a": {Roles: map[string]masking.RolePolicy{"TRADER": {Redact: []string{}}}},
+	}
+
+	response := analyzeResponse()
+	if policy.Apply(response, "bank-a", "TRADER") {
+		t.Error("Apply() for bank-a = true, want false")
+	}
+	if got := response.TradingSignal.TargetPrice; got != "$61.25" {
+		t.Errorf("bank-a target price = %q, want it untouched", got)
+	}
+
+	response = analyzeResponse()
+	if !policy.Apply(response, "bank-b", "TRADER") {
+		t.Error("Apply() for bank-b = false, want true")
+	}
+}
+
+func TestApplyNothingToMask(t *testing.T) {
+	policy := masking.DefaultPolicy()
+	tests := []struct {
+		name string
+		v    interface{}
+	}{
+		{"nil", nil},
+		{"empty fields", &dtos.AnalyzeResponse{}},
+		{"untagged payload", &dtos.HealthResponse{Status: "ok"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if policy.Apply(tt.v, "", "TRADER") {
+				t.Error("Apply() = true, want false")
+			}
+		})
+	}
+}
+
+func TestLoadPolicyRejectsUnknownClasses(t *testing.T) {
+	if _, err := masking.LoadPolicy(""); err != nil {
+		t.Fatalf("LoadPolicy(\"\") error = %v", err)
+	}
+	if _, err := masking.LoadPolicy("testdata/unknown_class.json"); err == nil {
+		t.Error("LoadPolicy() with an unknown field class: want an error")
+	}
+}
+
+func TestPrimaryRole(t *testing.T) {
+	policy := masking.DefaultPolicy()
+	policy.Banks = map[string]masking.BankPolicy{
+		"bank-a": {Roles: map[string]masking.RolePolicy{"TRADER": {Redact: []string{}}}},
+	}
+	tests := []struct {
+		name   string
+		bankID string
+		roles  []string
+		want   string
+	}{
+		{"no roles", "", nil, "TRADER"},
+		{"unknown roles", "", []string{"offline_access", "uma_authorization"}, "TRADER"},
+		{"least restricted wins", "", []string{"offline_access", "TRADER", "RISK"}, "RISK"},
+		{"earlier role on a tie", "", []string{"compliance", "ADMIN"}, "COMPLIANCE"},
+		{"bank override", "bank-a", []string{"RISK", "TRADER"}, "TRADER"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.PrimaryRole(tt.bankID, tt.roles); got != tt.want {
+				t.Errorf("PrimaryRole(%q, %v) = %q, want %q", tt.bankID, tt.roles, got, tt.want)
+			}
+		})
+	}
+}
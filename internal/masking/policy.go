@@ -0,0 +1,155 @@
+package masking
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Field classes that can be redacted from outbound payloads. DTO fields opt in
+// with a `mask:"<class>"` struct tag; map payloads are matched by key.
+const (
+	FieldRevenue      = "revenue"
+	FieldEmissions    = "emissions"
+	FieldCounterparty = "counterparty"
+	FieldTargetPrice  = "target_price"
+)
+
+//go:embed default_policy.json
+var defaultPolicyJSON []byte
+
+type RolePolicy struct {
+	Redact []string `json:"redact"`
+}
+
+type BankPolicy struct {
+	Roles map[string]RolePolicy `json:"roles"`
+}
+
+// Policy decides which field classes each role may see. Bank entries override
+// the global role rules for that bank only.
+type Policy struct {
+	DefaultRole string                `json:"default_role"`
+	Roles       map[string]RolePolicy `json:"roles"`
+	Keys        map[string]string     `json:"keys"`
+	Banks       map[string]BankPolicy `json:"banks"`
+}
+
+// DefaultPolicy returns the built-in policy: COMPLIANCE and ADMIN see
+// everything, RISK loses revenue and emissions figures, and TRADER, the
+// default, also loses counterparty names and target prices.
+func DefaultPolicy() *Policy {
+	policy, err := parsePolicy(defaultPolicyJSON)
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded masking policy: %v", err))
+	}
+	return policy
+}
+
+// LoadPolicy reads a JSON policy file, falling back to the default policy
+// when path is empty.
+func LoadPolicy(path string) (*Policy, error) {
+	if path == "" {
+		return DefaultPolicy(), nil
+	}
+
+	data, err := os.ReadFile(path) // #nosec G304 -- path comes from operator configuration
+	if err != nil {
+		return nil, fmt.Errorf("failed to read masking policy: %w", err)
+	}
+
+	policy, err := parsePolicy(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid masking policy %s: %w", path, err)
+	}
+	return policy, nil
+}
+
+func parsePolicy(data []byte) (*Policy, error) {
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, err
+	}
+
+	if policy.DefaultRole == "" {
+		return nil, fmt.Errorf("default_role is required")
+	}
+	if _, ok := policy.Roles[policy.DefaultRole]; !ok {
+		return nil, fmt.Errorf("default_role %s has no rules", policy.DefaultRole)
+	}
+
+	known := map[string]bool{
+		FieldRevenue:      true,
+		FieldEmissions:    true,
+		FieldCounterparty: true,
+		FieldTargetPrice:  true,
+	}
+	check := func(roles map[string]RolePolicy) error {
+		for role, rules := range roles {
+			for _, field := range rules.Redact {
+				if !known[field] {
+					return fmt.Errorf("role %s redacts unknown field class %q", role, field)
+				}
+			}
+		}
+		return nil
+	}
+	if err := check(policy.Roles); err != nil {
+		return nil, err
+	}
+	for bankID, bank := range policy.Banks {
+		if err := check(bank.Roles); err != nil {
+			return nil, fmt.Errorf("bank %s: %w", bankID, err)
+		}
+	}
+	for key, field := range policy.Keys {
+		if !known[field] {
+			return nil, fmt.Errorf("key %s maps to unknown field class %q", key, field)
+		}
+	}
+
+	return &policy, nil
+}
+
+// Redacts reports whether the given role at the given bank must not see
+// fields of the given class. Unknown roles are treated as the default role.
+func (p *Policy) Redacts(bankID, role, field string) bool {
+	rules, ok := p.rulesFor(bankID, strings.ToUpper(role))
+	if !ok {
+		rules = p.Roles[p.DefaultRole]
+	}
+
+	for _, redacted := range rules.Redact {
+		if redacted == field {
+			return true
+		}
+	}
+	return false
+}
+
+// PrimaryRole picks, from a user's roles, the one the policy redacts least
+// for the given bank, preferring earlier roles on a tie. Without a role the
+// policy knows, it returns the default role.
+func (p *Policy) PrimaryRole(bankID string, roles []string) string {
+	primary, redacted := p.DefaultRole, -1
+	for _, role := range roles {
+		role = strings.ToUpper(role)
+		rules, ok := p.rulesFor(bankID, role)
+		if ok && (redacted < 0 || len(rules.Redact) < redacted) {
+			primary, redacted = role, len(rules.Redact)
+		}
+	}
+	return primary
+}
+
+func (p *Policy) rulesFor(bankID, role string) (RolePolicy, bool) {
+	if bank, ok := p.Banks[bankID]; ok && bankID != "" {
+		if rules, ok := bank.Roles[role]; ok {
+			return rules, true
+		}
+	}
+	rules, ok := p.Roles[role]
+	return rules, ok
+}
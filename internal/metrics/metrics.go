@@ -0,0 +1,174 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// A deliberately small Prometheus-compatible registry: counters and gauges
+// with labels, exposed in the text format on /metrics.
+
+type metricKind string
+
+const (
+	kindCounter metricKind = "counter"
+	kindGauge   metricKind = "gauge"
+)
+
+type metric struct {
+	name   string
+	help   string
+	kind   metricKind
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]float64
+	series map[string][]string
+}
+
+type registry struct {
+	mu      sync.Mutex
+	metrics map[string]*metric
+}
+
+var defaultRegistry = &registry{metrics: make(map[string]*metric)}
+
+func (r *registry) register(name, help string, kind metricKind, labels []string) *metric {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.metrics[name]; ok {
+		if existing.kind != kind {
+			panic(fmt.Sprintf("metric %s already registered as %s", name, existing.kind))
+		}
+		return existing
+	}
+
+	m := &metric{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		labels: labels,
+		values: make(map[string]float64),
+		series: make(map[string][]string),
+	}
+	r.metrics[name] = m
+	return m
+}
+
+func (m *metric) add(delta float64, labelValues []string) {
+	key := m.key(labelValues)
+	m.mu.Lock()
+	m.values[key] += delta
+	m.mu.Unlock()
+}
+
+func (m *metric) set(value float64, labelValues []string) {
+	key := m.key(labelValues)
+	m.mu.Lock()
+	m.values[key] = value
+	m.mu.Unlock()
+}
+
+func (m *metric) key(labelValues []string) string {
+	if len(labelValues) != len(m.labels) {
+		panic(fmt.Sprintf("metric %s expects %d labels, got %d", m.name, len(m.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	m.mu.Lock()
+	if _, ok := m.series[key]; !ok {
+		m.series[key] = append([]string(nil), labelValues...)
+	}
+	m.mu.Unlock()
+	return key
+}
+
+// Counter only goes up.
+type Counter struct{ m *metric }
+
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{m: defaultRegistry.register(name, help, kindCounter, labels)}
+}
+
+func (c *Counter) Inc(labelValues ...string) { c.m.add(1, labelValues) }
+
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		return
+	}
+	c.m.add(delta, labelValues)
+}
+
+// Gauge can be set to any value.
+type Gauge struct{ m *metric }
+
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{m: defaultRegistry.register(name, help, kindGauge, labels)}
+}
+
+func (g *Gauge) Set(value float64, labelValues ...string) { g.m.set(value, labelValues) }
+func (g *Gauge) Inc(labelValues ...string)                { g.m.add(1, labelValues) }
+func (g *Gauge) Dec(labelValues ...string)                { g.m.add(-1, labelValues) }
+
+// WriteText writes every registered metric in the Prometheus text format.
+func WriteText(w io.Writer) error {
+	defaultRegistry.mu.Lock()
+	names := make([]string, 0, len(defaultRegistry.metrics))
+	for name := range defaultRegistry.metrics {
+		names = append(names, name)
+	}
+	defaultRegistry.mu.Unlock()
+	sort.Strings(names)
+
+	for _, name := range names {
+		defaultRegistry.mu.Lock()
+		m := defaultRegistry.metrics[name]
+		defaultRegistry.mu.Unlock()
+
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind); err != nil {
+			return err
+		}
+
+		m.mu.Lock()
+		keys := make([]string, 0, len(m.values))
+		for key := range m.values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			line := m.name + formatLabels(m.labels, m.series[key]) + " " + strconv.FormatFloat(m.values[key], 'g', -1, 64) + "\n"
+			if _, err := io.WriteString(w, line); err != nil {
+				m.mu.Unlock()
+				return err
+			}
+		}
+		m.mu.Unlock()
+	}
+	return nil
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(values[i])
+		pairs[i] = fmt.Sprintf(`%s="%s"`, name, value)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Handler serves the registry for Prometheus scraping.
+func Handler(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Status(http.StatusOK)
+	_ = WriteText(c.Writer)
+}
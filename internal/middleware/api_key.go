@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/edgeesg/edge-esg-backend/internal/error_codes"
+	"github.com/edgeesg/edge-esg-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyAuth resolves the calling bank from an API key sent in X-API-Key or
+// as "Authorization: ApiKey <key>". Requests without a key pass through
+// anonymously; use RequireAPIKey to insist on one. Keys flagged
+// RequireSignature must also carry X-Signature and X-Signature-Timestamp.
+// A signed body is read to check it, so it must be at most maxBodyBytes,
+// the most any route accepts.
+func APIKeyAuth(service *services.APIKeyService, maxBodyBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rawKey := extractAPIKey(c)
+		if rawKey == "" {
+			c.Next()
+			return
+		}
+
+		key, err := service.Authenticate(c.Request.Context(), rawKey)
+		if err != nil {
+			LogAuthenticationAttempt(false, "", c.ClientIP(), "api key: "+err.Error())
+			status := http.StatusUnauthorized
+			if !errors.Is(err, services.ErrAPIKeyInvalid) {
+				status = http.StatusServiceUnavailable
+			}
+			c.JSON(status, gin.H{
+				"code":    error_codes.AuthInvalidAPIKey,
+				"message": "Invalid or expired API key",
+			})
+			c.Abort()
+			return
+		}
+
+		signature := c.GetHeader("X-Signature")
+		if key.RequireSignature || signature != "" {
+			body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBodyBytes))
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+					"code":    error_codes.ValidationFailed,
+					"message": "Request body too large",
+				})
+				c.Abort()
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"code":    error_codes.ValidationFailed,
+					"message": "Unable to read request body",
+				})
+				c.Abort()
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+			err = service.VerifySignature(key, c.Request.Method, c.Request.URL.RequestURI(), body,
+				c.GetHeader("X-Signature-Timestamp"), signature)
+			if err != nil {
+				LogAuthenticationAttempt(false, key.KeyPrefix, c.ClientIP(), "signature: "+err.Error())
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"code":    error_codes.AuthInvalidSignature,
+					"message": "Request signature verification failed",
+				})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Set("bank_id", key.BankID.String())
+		c.Set("api_key_id", key.ID.String())
+		c.Set("api_key_scopes", key.ScopeList())
+		c.Next()
+	}
+}
+
+// RequireAPIKey rejects requests that were not authenticated by APIKeyAuth.
+func RequireAPIKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("api_key_id"); !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"code":    error_codes.AuthUnauthorized,
+				"message": "API key required",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireScope rejects API-key requests whose key lacks scope. Requests not
+// made with an API key are left to the other auth middleware.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scopes, ok := c.Get("api_key_scopes")
+		if !ok {
+			c.Next()
+			return
+		}
+
+		for _, s := range scopes.([]string) {
+			if s == scope {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{
+			"code":    error_codes.AuthForbidden,
+			"message": "API key lacks scope: " + scope,
+		})
+		c.Abort()
+	}
+}
+
+func extractAPIKey(c *gin.Context) string {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return strings.TrimSpace(key)
+	}
+	if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "ApiKey ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "ApiKey "))
+	}
+	return ""
+}
@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/edgeesg/edge-esg-backend/internal/error_codes"
+	"github.com/edgeesg/edge-esg-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// WebSocket subprotocols. Browsers cannot set headers on a WebSocket
+// handshake, so credentials travel as subprotocols next to WSProtocol, which
+// the server selects:
+//
+//	new WebSocket(url, ["edge.v1", "bearer." + token])
+//	new WebSocket(url, ["edge.v1", "apikey." + key])
+//
+// This keeps credentials out of URLs and access logs.
+const (
+	WSProtocol            = "edge.v1"
+	wsBearerProtocol      = "bearer."
+	wsAPIKeyProtocol      = "apikey."
+	wsBankRequiredMessage = "a bank API key or a token with a bank_id claim is required"
+)
+
+// BankAuth authenticates a request that must act for a bank: WebSocket
+// handshakes (before the upgrade), the SSE stream and the signal endpoints.
+// It accepts whatever APIKeyAuth already resolved from headers, a bearer
+// token in the Authorization header, or, on WebSockets, the credentials
+// subprotocols above. Every session must resolve to a bank. keycloak may be
+// nil, in which case only API keys are accepted.
+func BankAuth(apiKeys *services.APIKeyService, keycloak *KeycloakMiddleware) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ResolveBankID(c) == "" {
+			if err := authenticateBank(c, apiKeys, keycloak); err != nil {
+				LogAuthenticationAttempt(false, "", c.ClientIP(), c.Request.URL.Path+": "+err.Error())
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"code":    error_codes.AuthUnauthorized,
+					"message": err.Error(),
+				})
+				c.Abort()
+				return
+			}
+		}
+
+		if ResolveBankID(c) == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"code":    error_codes.AuthForbidden,
+				"message": wsBankRequiredMessage,
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func authenticateBank(c *gin.Context, apiKeys *services.APIKeyService, keycloak *KeycloakMiddleware) error {
+	var token, apiKey string
+	if bearer := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "); bearer != c.GetHeader("Authorization") {
+		token = bearer
+	}
+	for _, protocol := range websocketProtocols(c.Request) {
+		switch {
+		case strings.HasPrefix(protocol, wsBearerProtocol):
+			token = strings.TrimPrefix(protocol, wsBearerProtocol)
+		case strings.HasPrefix(protocol, wsAPIKeyProtocol):
+			apiKey = strings.TrimPrefix(protocol, wsAPIKeyProtocol)
+		}
+	}
+
+	switch {
+	case apiKey != "":
+		key, err := apiKeys.Authenticate(c.Request.Context(), apiKey)
+		if err != nil {
+			return errors.New("invalid or expired API key")
+		}
+		c.Set("bank_id", key.BankID.String())
+		c.Set("api_key_id", key.ID.String())
+		c.Set("api_key_scopes", key.ScopeList())
+		return nil
+
+	case token != "":
+		if keycloak == nil {
+			return errors.New("token authentication is not configured")
+		}
+		claims, err := keycloak.Verify(c.Request.Context(), token)
+		if err != nil {
+			return errors.New("token verification failed")
+		}
+		setTokenClaims(c, claims)
+		return nil
+
+	default:
+		return errors.New("missing credentials")
+	}
+}
+
+func websocketProtocols(r *http.Request) []string {
+	var protocols []string
+	for _, header := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, protocol := range strings.Split(header, ",") {
+			if protocol = strings.TrimSpace(protocol); protocol != "" {
+				protocols = append(protocols, protocol)
+			}
+		}
+	}
+	return protocols
+}
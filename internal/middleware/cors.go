@@ -7,32 +7,34 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func CORS() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		origin := c.Request.Header.Get("Origin")
-		allowedOrigins := []string{
-			"http://localhost:3000",
-			"http://localhost:8000",
-		}
+// AllowedOrigin reports whether a browser origin may call the API. The
+// WebSocket endpoint applies the same list.
+func AllowedOrigin(origin string) bool {
+	allowedOrigins := []string{
+		"http://localhost:3000",
+		"http://localhost:8000",
+	}
 
-		// Add production frontend URL from environment
-		if prodOrigin := os.Getenv("FRONTEND_URL"); prodOrigin != "" {
-			allowedOrigins = append(allowedOrigins, prodOrigin)
-		}
+	// Add production frontend URL from environment
+	if prodOrigin := os.Getenv("FRONTEND_URL"); prodOrigin != "" {
+		allowedOrigins = append(allowedOrigins, prodOrigin)
+	}
 
-		// Check if origin is allowed
-		allowed := false
-		for _, allowedOrigin := range allowedOrigins {
-			if origin == allowedOrigin {
-				allowed = true
-				break
-			}
+	// Check if origin is allowed
+	for _, allowedOrigin := range allowedOrigins {
+		if origin == allowedOrigin {
+			return true
 		}
+	}
 
-		// Allow all Vercel deployments
-		if strings.HasSuffix(origin, ".vercel.app") {
-			allowed = true
-		}
+	// Allow all Vercel deployments
+	return strings.HasSuffix(origin, ".vercel.app")
+}
+
+func CORS() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.Request.Header.Get("Origin")
+		allowed := AllowedOrigin(origin)
 
 		if allowed && origin != "" {
 			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
@@ -41,8 +43,9 @@ func CORS() gin.HandlerFunc {
 		}
 
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Bank-ID")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, X-Signature, X-Signature-Timestamp, X-Client-ID")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, Retry-After, Location")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
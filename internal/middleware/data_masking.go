@@ -1,22 +1,50 @@
 package middleware
 
 import (
+	"github.com/edgeesg/edge-esg-backend/internal/masking"
 	"github.com/gin-gonic/gin"
 )
 
-func DataMasking() gin.HandlerFunc {
+const maskingPolicyKey = "masking_policy"
+
+// fallbackPolicy keeps responses masked on routes mounted without DataMasking.
+var fallbackPolicy = masking.DefaultPolicy()
+
+// DataMasking attaches the masking policy to the request and records the
+// caller's role. Handlers redact their payloads with MaskResponse just before
+// writing, once authentication has populated the final role.
+func DataMasking(policy *masking.Policy) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		roles, exists := c.Get("user_roles")
-		if !exists {
-			c.Set("user_role", "TRADER")
-		} else {
-			userRoles := roles.([]string)
-			if len(userRoles) > 0 {
-				c.Set("user_role", userRoles[0])
-			} else {
-				c.Set("user_role", "TRADER")
-			}
-		}
+		c.Set(maskingPolicyKey, policy)
+		c.Set("user_role", ResolveRole(c))
 		c.Next()
 	}
 }
+
+// ResolveRole returns the caller's role that the masking policy restricts
+// least, or the policy's default role when none of them is known.
+func ResolveRole(c *gin.Context) string {
+	policy := fallbackPolicy
+	if p, ok := c.Get(maskingPolicyKey); ok {
+		policy = p.(*masking.Policy)
+	}
+	roles, _ := c.Get("user_roles")
+	userRoles, _ := roles.([]string)
+	return policy.PrimaryRole(ResolveBankID(c), userRoles)
+}
+
+// ResolveBankID returns the bank the request was authenticated for, or ""
+// for anonymous requests. Client-supplied bank headers are never trusted.
+func ResolveBankID(c *gin.Context) string {
+	return c.GetString("bank_id")
+}
+
+// MaskResponse redacts v in place according to the caller's role and bank
+// and reports whether anything was hidden.
+func MaskResponse(c *gin.Context, v interface{}) bool {
+	policy := fallbackPolicy
+	if p, ok := c.Get(maskingPolicyKey); ok {
+		policy = p.(*masking.Policy)
+	}
+	return policy.Apply(v, ResolveBankID(c), ResolveRole(c))
+}
@@ -1,64 +1,457 @@
 package middleware
 
 import (
+	"bytes"
 	"context"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
+	"math"
+	"mime"
 	"net/http"
+	"os"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/edgeesg/edge-esg-backend/internal/error_codes"
+	"github.com/edgeesg/edge-esg-backend/internal/loggers"
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
 )
 
+// RateLimitQuota is a token bucket: Burst tokens refilled at
+// RequestsPerMinute. Burst defaults to RequestsPerMinute.
+type RateLimitQuota struct {
+	RequestsPerMinute int `json:"requests_per_minute"`
+	Burst             int `json:"burst,omitempty"`
+}
+
+func (q RateLimitQuota) capacity() int {
+	if q.Burst > 0 {
+		return q.Burst
+	}
+	return q.RequestsPerMinute
+}
+
+// tokensPerMs is the refill rate used by both the Redis script and the
+// in-memory fallback.
+func (q RateLimitQuota) tokensPerMs() float64 {
+	return float64(q.RequestsPerMinute) / float64(time.Minute/time.Millisecond)
+}
+
+// RateLimitPlan is the overall quota for a bank plus optional tighter quotas
+// for individual routes, keyed "METHOD /path" as registered in gin.
+type RateLimitPlan struct {
+	RateLimitQuota
+	Routes map[string]RateLimitQuota `json:"routes,omitempty"`
+}
+
+type RateLimitConfig struct {
+	DefaultPlan   string                   `json:"default_plan"`
+	AnonymousPlan string                   `json:"anonymous_plan"`
+	Plans         map[string]RateLimitPlan `json:"plans"`
+	// Banks assigns bank IDs to plans; unlisted banks get DefaultPlan.
+	Banks map[string]string `json:"banks"`
+	// RouteCosts weights routes in tokens; unlisted routes cost 1.
+	RouteCosts map[string]int `json:"route_costs"`
+	// FailOpen lets requests through when neither Redis nor the in-memory
+	// fallback can decide; otherwise they get 503.
+	FailOpen bool `json:"fail_open"`
+	// MemoryFallback limits per replica while Redis is unavailable.
+	MemoryFallback bool `json:"memory_fallback"`
+}
+
+// DefaultRateLimitConfig allows each bank 10,000 requests per minute and each
+// anonymous client IP 600.
+func DefaultRateLimitConfig() *RateLimitConfig {
+	return &RateLimitConfig{
+		DefaultPlan:   "standard",
+		AnonymousPlan: "anonymous",
+		Plans: map[string]RateLimitPlan{
+			"standard":  {RateLimitQuota: RateLimitQuota{RequestsPerMinute: 10000}},
+			"anonymous": {RateLimitQuota: RateLimitQuota{RequestsPerMinute: 600}},
+		},
+		Banks:          map[string]string{},
+		RouteCosts:     map[string]int{},
+		FailOpen:       true,
+		MemoryFallback: true,
+	}
+}
+
+// LoadRateLimitConfig reads plans from a JSON file, or returns the defaults
+// when path is empty.
+func LoadRateLimitConfig(path string) (*RateLimitConfig, error) {
+	if path == "" {
+		return DefaultRateLimitConfig(), nil
+	}
+
+	data, err := os.ReadFile(path) // #nosec G304 -- path comes from operator configuration
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rate limit config: %w", err)
+	}
+
+	cfg := DefaultRateLimitConfig()
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("invalid rate limit config %s: %w", path, err)
+	}
+
+	for _, name := range []string{cfg.DefaultPlan, cfg.AnonymousPlan} {
+		if _, ok := cfg.Plans[name]; !ok {
+			return nil, fmt.Errorf("rate limit plan %q is not defined", name)
+		}
+	}
+	for bankID, name := range cfg.Banks {
+		if _, ok := cfg.Plans[name]; !ok {
+			return nil, fmt.Errorf("bank %s uses undefined rate limit plan %q", bankID, name)
+		}
+	}
+	for name, plan := range cfg.Plans {
+		if plan.RequestsPerMinute <= 0 {
+			return nil, fmt.Errorf("rate limit plan %q needs requests_per_minute > 0", name)
+		}
+		for route, quota := range plan.Routes {
+			if quota.RequestsPerMinute <= 0 {
+				return nil, fmt.Errorf("rate limit plan %q route %q needs requests_per_minute > 0", name, route)
+			}
+		}
+	}
+
+	return cfg, nil
+}
+
+// CostFunc returns how many tokens a request consumes.
+type CostFunc func(c *gin.Context) int
+
+// CompanyCountCost charges one token per company, so comparing N
+// companies costs N analyses. Companies are the entries of a JSON body's
+// "companies" array, or the rows of a text/csv body or of a multipart
+// form's CSV "file". The body is read up to maxBytes; a larger one costs 1
+// and fails in the handler.
+func CompanyCountCost(maxBytes int64) CostFunc {
+	return func(c *gin.Context) int {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		mediaType, _, _ := mime.ParseMediaType(c.ContentType())
+		if mediaType == "multipart/form-data" {
+			// The parsed form is kept on the request for the handler
+			file, _, err := c.Request.FormFile("file")
+			if err != nil {
+				return 1
+			}
+			defer file.Close()
+			return max(1, countCSVRows(file))
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return 1
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		if mediaType == "text/csv" {
+			return max(1, countCSVRows(bytes.NewReader(body)))
+		}
+
+		var payload struct {
+			Companies []json.RawMessage `json:"companies"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil || len(payload.Companies) == 0 {
+			return 1
+		}
+		return len(payload.Companies)
+	}
+}
+
+// countCSVRows counts the records of a CSV, header included, up to the
+// first malformed one.
+func countCSVRows(r io.Reader) int {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	rows := 0
+	for {
+		if _, err := reader.Read(); err != nil {
+			return rows
+		}
+		rows++
+	}
+}
+
+// tokenBucketScript atomically refills and debits every bucket in KEYS.
+// The request is admitted only if all buckets hold enough tokens, in which
+// case all are debited. Redis' own clock is used so replicas agree.
+// ARGV: cost, then capacity and tokens-per-ms for each key.
+// Returns {allowed, remaining, retry_after_ms, reset_ms} for the tightest bucket.
+var tokenBucketScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local cost = tonumber(ARGV[1])
+local allowed = 1
+local retry = 0
+local levels = {}
+
+for i, key in ipairs(KEYS) do
+  local capacity = tonumber(ARGV[2 * i])
+  local rate = tonumber(ARGV[2 * i + 1])
+  local state = redis.call('HMGET', key, 'tokens', 'ts')
+  local tokens = tonumber(state[1])
+  local ts = tonumber(state[2])
+  if tokens == nil or ts == nil then
+    tokens = capacity
+    ts = now
+  end
+  tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+  levels[i] = tokens
+  if tokens < cost then
+    allowed = 0
+    local wait = math.ceil((cost - tokens) / rate)
+    if wait > retry then
+      retry = wait
+    end
+  end
+end
+
+local remaining = -1
+local reset = 0
+for i, key in ipairs(KEYS) do
+  local capacity = tonumber(ARGV[2 * i])
+  local rate = tonumber(ARGV[2 * i + 1])
+  local tokens = levels[i]
+  if allowed == 1 then
+    tokens = tokens - cost
+  end
+  redis.call('HSET', key, 'tokens', tostring(tokens), 'ts', tostring(now))
+  redis.call('PEXPIRE', key, math.ceil(capacity / rate) + 1000)
+  if remaining < 0 or math.floor(tokens) < remaining then
+    remaining = math.floor(tokens)
+    reset = math.ceil((capacity - tokens) / rate)
+  end
+end
+
+return {allowed, remaining, retry, reset}
+`)
+
+type bucketSpec struct {
+	key   string
+	quota RateLimitQuota
+}
+
+type limitResult struct {
+	allowed    bool
+	limit      int
+	remaining  int
+	retryAfter time.Duration
+	reset      time.Duration
+}
+
 type RateLimiter struct {
 	client *redis.Client
-	limit  int
-	window time.Duration
+	config *RateLimitConfig
+	costs  map[string]CostFunc
+	memory *memoryLimiter
 }
 
-func NewRateLimiter(redisClient *redis.Client, limit int, window time.Duration) *RateLimiter {
+func NewRateLimiter(redisClient *redis.Client, config *RateLimitConfig) *RateLimiter {
 	return &RateLimiter{
 		client: redisClient,
-		limit:  limit,
-		window: window,
+		config: config,
+		costs:  make(map[string]CostFunc),
+		memory: newMemoryLimiter(),
 	}
 }
 
+// SetCost registers a dynamic cost for a route. The result is multiplied by
+// any static cost configured for the route.
+func (rl *RateLimiter) SetCost(method, path string, fn CostFunc) {
+	rl.costs[method+" "+path] = fn
+}
+
 func (rl *RateLimiter) Limit() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		bankID := c.GetHeader("X-Bank-ID")
-		if bankID == "" {
-			bankID = c.Query("bank_id")
+		// Banks are identified by their API key (see APIKeyAuth); anonymous
+		// callers are limited per client IP.
+		bankID := ResolveBankID(c)
+		subject := bankID
+		planName := rl.config.AnonymousPlan
+		if bankID != "" {
+			planName = rl.config.DefaultPlan
+			if assigned, ok := rl.config.Banks[bankID]; ok {
+				planName = assigned
+			}
+		} else {
+			subject = "anon:" + c.ClientIP()
+		}
+		plan := rl.config.Plans[planName]
+
+		route := c.Request.Method + " " + c.FullPath()
+		cost := 1
+		if static, ok := rl.config.RouteCosts[route]; ok {
+			cost = static
 		}
-		if bankID == "" {
-			bankID = "default"
+		if fn, ok := rl.costs[route]; ok {
+			cost *= fn(c)
 		}
 
-		key := fmt.Sprintf("rate_limit:%s", bankID)
-		ctx := context.Background()
+		// The {subject} hash tag keeps all of a subject's buckets in one
+		// Redis Cluster slot so the script can touch them together.
+		buckets := []bucketSpec{{key: fmt.Sprintf("rate_limit:{%s}", subject), quota: plan.RateLimitQuota}}
+		if quota, ok := plan.Routes[route]; ok {
+			buckets = append(buckets, bucketSpec{key: fmt.Sprintf("rate_limit:{%s}:%s", subject, route), quota: quota})
+		}
 
-		count, err := rl.client.Incr(ctx, key).Result()
-		if err != nil {
-			c.Next()
-			return
+		// Waiting would never let through a request costing more than a
+		// bucket holds, so it is refused outright rather than throttled.
+		for _, b := range buckets {
+			if cost > b.quota.capacity() {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+					"code":    error_codes.RateLimitBurstExceeded,
+					"message": fmt.Sprintf("Request exceeds plan burst: costs %d tokens, plan %s allows %d at once", cost, planName, b.quota.capacity()),
+				})
+				c.Abort()
+				return
+			}
 		}
 
-		if count == 1 {
-			rl.client.Expire(ctx, key, rl.window)
+		result, err := rl.take(c.Request.Context(), buckets, cost)
+		if err != nil {
+			if rl.config.MemoryFallback {
+				result = rl.memory.take(buckets, cost, time.Now())
+			} else if rl.config.FailOpen {
+				loggers.Warn("Rate limiter unavailable, failing open", map[string]interface{}{"error": err.Error()})
+				c.Next()
+				return
+			} else {
+				c.JSON(http.StatusServiceUnavailable, gin.H{
+					"code":    error_codes.RateLimitUnavailable,
+					"message": "Rate limiter unavailable",
+				})
+				c.Abort()
+				return
+			}
 		}
 
-		if count > int64(rl.limit) {
+		resetSeconds := strconv.Itoa(int(math.Ceil(result.reset.Seconds())))
+		c.Header("RateLimit-Limit", strconv.Itoa(result.limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.remaining))
+		c.Header("RateLimit-Reset", resetSeconds)
+		c.Header("X-RateLimit-Limit", strconv.Itoa(result.limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.remaining))
+
+		if !result.allowed {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(result.retryAfter.Seconds()))))
 			c.JSON(http.StatusTooManyRequests, gin.H{
 				"code":    error_codes.RateLimitExceeded,
-				"message": fmt.Sprintf("Rate limit exceeded: %d requests per minute", rl.limit),
+				"message": fmt.Sprintf("Rate limit exceeded: %d requests per minute on plan %s", plan.RequestsPerMinute, planName),
 			})
 			c.Abort()
 			return
 		}
 
-		c.Header("X-RateLimit-Limit", fmt.Sprintf("%d", rl.limit))
-		c.Header("X-RateLimit-Remaining", fmt.Sprintf("%d", rl.limit-int(count)))
 		c.Next()
 	}
 }
+
+func (rl *RateLimiter) take(ctx context.Context, buckets []bucketSpec, cost int) (*limitResult, error) {
+	if rl.client == nil {
+		return nil, fmt.Errorf("redis client not configured")
+	}
+
+	keys := make([]string, len(buckets))
+	args := make([]interface{}, 0, 1+2*len(buckets))
+	args = append(args, cost)
+	limit := 0
+	for i, b := range buckets {
+		keys[i] = b.key
+		args = append(args, b.quota.capacity(), strconv.FormatFloat(b.quota.tokensPerMs(), 'f', -1, 64))
+		if limit == 0 || b.quota.capacity() < limit {
+			limit = b.quota.capacity()
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 250*time.Millisecond)
+	defer cancel()
+
+	values, err := tokenBucketScript.Run(ctx, rl.client, keys, args...).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 4 {
+		return nil, fmt.Errorf("unexpected rate limit script result: %v", values)
+	}
+
+	return &limitResult{
+		allowed:    values[0] == 1,
+		limit:      limit,
+		remaining:  int(values[1]),
+		retryAfter: time.Duration(values[2]) * time.Millisecond,
+		reset:      time.Duration(values[3]) * time.Millisecond,
+	}, nil
+}
+
+// memoryLimiter mirrors the Redis token bucket inside one replica. It only
+// runs while Redis is unreachable, so limits are per replica during outages.
+type memoryLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+}
+
+type memoryBucket struct {
+	tokens float64
+	ts     time.Time
+}
+
+const memoryLimiterMaxBuckets = 100000
+
+func newMemoryLimiter() *memoryLimiter {
+	return &memoryLimiter{buckets: make(map[string]*memoryBucket)}
+}
+
+func (m *memoryLimiter) take(specs []bucketSpec, cost int, now time.Time) *limitResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.buckets) > memoryLimiterMaxBuckets {
+		m.buckets = make(map[string]*memoryBucket)
+	}
+
+	result := &limitResult{allowed: true, remaining: -1}
+	levels := make([]float64, len(specs))
+	for i, spec := range specs {
+		capacity := float64(spec.quota.capacity())
+		rate := spec.quota.tokensPerMs()
+		b, ok := m.buckets[spec.key]
+		if !ok {
+			b = &memoryBucket{tokens: capacity, ts: now}
+			m.buckets[spec.key] = b
+		}
+		elapsed := float64(now.Sub(b.ts).Milliseconds())
+		levels[i] = math.Min(capacity, b.tokens+math.Max(0, elapsed)*rate)
+
+		if levels[i] < float64(cost) {
+			result.allowed = false
+			wait := math.Ceil((float64(cost) - levels[i]) / rate)
+			if d := time.Duration(wait) * time.Millisecond; d > result.retryAfter {
+				result.retryAfter = d
+			}
+		}
+	}
+
+	for i, spec := range specs {
+		capacity := float64(spec.quota.capacity())
+		rate := spec.quota.tokensPerMs()
+		if result.allowed {
+			levels[i] -= float64(cost)
+		}
+		m.buckets[spec.key].tokens = levels[i]
+		m.buckets[spec.key].ts = now
+
+		if result.remaining < 0 || int(levels[i]) < result.remaining {
+			result.remaining = int(math.Floor(levels[i]))
+			result.reset = time.Duration(math.Ceil((capacity-levels[i])/rate)) * time.Millisecond
+		}
+		if result.limit == 0 || spec.quota.capacity() < result.limit {
+			result.limit = spec.quota.capacity()
+		}
+	}
+
+	return result
+}
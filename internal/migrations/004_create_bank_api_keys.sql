@@ -0,0 +1,24 @@
+-- Machine-to-machine API keys issued to bank integrations
+CREATE TABLE IF NOT EXISTS bank_api_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    bank_id UUID NOT NULL,
+    name TEXT NOT NULL,
+    key_prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    scopes TEXT NOT NULL DEFAULT '',
+    signing_secret_encrypted TEXT,
+    require_signature BOOLEAN NOT NULL DEFAULT FALSE,
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    rotated_from UUID REFERENCES bank_api_keys(id),
+    created_by TEXT,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- No RLS here: keys are looked up by hash before the bank is known.
+-- Access is restricted to the gateway and admin endpoints instead.
+
+-- Indexes
+CREATE INDEX idx_bank_api_keys_bank_id ON bank_api_keys(bank_id);
+CREATE INDEX idx_bank_api_keys_key_prefix ON bank_api_keys(key_prefix);
@@ -0,0 +1,45 @@
+-- Trade signal lifecycle: who decided what, when signals lapse, and the full
+-- status history.
+ALTER TABLE trade_signals
+    ADD COLUMN IF NOT EXISTS company TEXT,
+    ADD COLUMN IF NOT EXISTS analysis_id TEXT,
+    ADD COLUMN IF NOT EXISTS current_price NUMERIC(12,2),
+    ADD COLUMN IF NOT EXISTS risk_action TEXT,
+    ADD COLUMN IF NOT EXISTS reasoning TEXT,
+    ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS decided_by TEXT,
+    ADD COLUMN IF NOT EXISTS decided_role TEXT,
+    ADD COLUMN IF NOT EXISTS decided_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS status_reason TEXT,
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP DEFAULT NOW();
+
+-- ESG scores run 0-10, which NUMERIC(3,2) cannot hold
+ALTER TABLE trade_signals ALTER COLUMN esg_score TYPE NUMERIC(4,2);
+ALTER TABLE trade_signals ALTER COLUMN status SET NOT NULL;
+ALTER TABLE trade_signals ADD CONSTRAINT trade_signals_status_check
+    CHECK (status IN ('PENDING', 'APPROVED', 'REJECTED', 'SENT', 'FILLED', 'EXPIRED', 'CANCELLED'));
+
+CREATE INDEX IF NOT EXISTS idx_trade_signals_expiry ON trade_signals(expires_at)
+    WHERE status IN ('PENDING', 'APPROVED', 'SENT');
+CREATE INDEX IF NOT EXISTS idx_trade_signals_analysis_id ON trade_signals(bank_id, analysis_id);
+CREATE INDEX IF NOT EXISTS idx_trade_signals_bank_created ON trade_signals(bank_id, created_at DESC);
+
+-- Every status change, including creation, with the user or process behind it
+CREATE TABLE IF NOT EXISTS trade_signal_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    signal_id UUID NOT NULL REFERENCES trade_signals(id) ON DELETE CASCADE,
+    bank_id UUID NOT NULL,
+    from_status TEXT,
+    to_status TEXT NOT NULL,
+    actor TEXT NOT NULL,
+    actor_role TEXT,
+    reason TEXT,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+ALTER TABLE trade_signal_events ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY trade_signal_events_bank_isolation ON trade_signal_events
+    USING (bank_id = current_setting('app.current_bank')::uuid);
+
+CREATE INDEX idx_trade_signal_events_signal_id ON trade_signal_events(signal_id, created_at);
@@ -0,0 +1,84 @@
+-- Paper trading: simulated portfolios that follow approved trade signals
+CREATE TABLE IF NOT EXISTS paper_portfolios (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    bank_id UUID NOT NULL,
+    -- Empty for bank-wide portfolios, otherwise the owning user
+    owner TEXT NOT NULL DEFAULT '',
+    name TEXT NOT NULL,
+    initial_cash NUMERIC(18,2) NOT NULL,
+    cash NUMERIC(18,2) NOT NULL,
+    position_size NUMERIC(5,4) NOT NULL,
+    cost_bps NUMERIC(8,2) NOT NULL DEFAULT 0,
+    slippage_bps NUMERIC(8,2) NOT NULL DEFAULT 0,
+    follow_signals BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS paper_positions (
+    portfolio_id UUID NOT NULL REFERENCES paper_portfolios(id) ON DELETE CASCADE,
+    symbol TEXT NOT NULL,
+    bank_id UUID NOT NULL,
+    quantity NUMERIC(20,6) NOT NULL,
+    cost_basis NUMERIC(18,2) NOT NULL,
+    esg_score NUMERIC(4,2),
+    opened_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (portfolio_id, symbol)
+);
+
+CREATE TABLE IF NOT EXISTS paper_orders (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    portfolio_id UUID NOT NULL REFERENCES paper_portfolios(id) ON DELETE CASCADE,
+    bank_id UUID NOT NULL,
+    signal_id UUID REFERENCES trade_signals(id) ON DELETE SET NULL,
+    symbol TEXT NOT NULL,
+    side TEXT NOT NULL CHECK (side IN ('BUY', 'SELL')),
+    status TEXT NOT NULL CHECK (status IN ('FILLED', 'REJECTED')),
+    reason TEXT,
+    quantity NUMERIC(20,6) NOT NULL DEFAULT 0,
+    quote NUMERIC(12,2),
+    quote_date DATE,
+    price NUMERIC(12,4),
+    fees NUMERIC(18,2) NOT NULL DEFAULT 0,
+    realized_pnl NUMERIC(18,2),
+    created_by TEXT,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- One mark-to-market per portfolio per day
+CREATE TABLE IF NOT EXISTS paper_snapshots (
+    portfolio_id UUID NOT NULL REFERENCES paper_portfolios(id) ON DELETE CASCADE,
+    date DATE NOT NULL,
+    bank_id UUID NOT NULL,
+    cash NUMERIC(18,2) NOT NULL,
+    market_value NUMERIC(18,2) NOT NULL,
+    equity NUMERIC(18,2) NOT NULL,
+    esg_score NUMERIC(4,2),
+    esg_leader_weight NUMERIC(5,4) NOT NULL DEFAULT 0,
+    esg_laggard_weight NUMERIC(5,4) NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (portfolio_id, date)
+);
+
+-- Enable RLS
+ALTER TABLE paper_portfolios ENABLE ROW LEVEL SECURITY;
+ALTER TABLE paper_positions ENABLE ROW LEVEL SECURITY;
+ALTER TABLE paper_orders ENABLE ROW LEVEL SECURITY;
+ALTER TABLE paper_snapshots ENABLE ROW LEVEL SECURITY;
+
+-- RLS Policies
+CREATE POLICY paper_portfolios_bank_isolation ON paper_portfolios
+    USING (bank_id = current_setting('app.current_bank')::uuid);
+CREATE POLICY paper_positions_bank_isolation ON paper_positions
+    USING (bank_id = current_setting('app.current_bank')::uuid);
+CREATE POLICY paper_orders_bank_isolation ON paper_orders
+    USING (bank_id = current_setting('app.current_bank')::uuid);
+CREATE POLICY paper_snapshots_bank_isolation ON paper_snapshots
+    USING (bank_id = current_setting('app.current_bank')::uuid);
+
+-- Indexes
+CREATE INDEX idx_paper_portfolios_bank_id ON paper_portfolios(bank_id, owner);
+CREATE INDEX idx_paper_portfolios_following ON paper_portfolios(bank_id) WHERE follow_signals;
+CREATE INDEX idx_paper_orders_portfolio_id ON paper_orders(portfolio_id, created_at DESC);
+-- A signal fills at most once per portfolio
+CREATE UNIQUE INDEX idx_paper_orders_signal ON paper_orders(portfolio_id, signal_id) WHERE signal_id IS NOT NULL;
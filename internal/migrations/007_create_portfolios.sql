@@ -0,0 +1,43 @@
+-- Stored portfolios: holdings with target weights, a mandate and drift
+-- thresholds
+CREATE TABLE IF NOT EXISTS portfolios (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    bank_id UUID NOT NULL,
+    name TEXT NOT NULL,
+    benchmark TEXT NOT NULL DEFAULT '',
+    -- Mandate limits as JSON, keyed by symbol
+    mandate JSONB,
+    drift_threshold NUMERIC(5,4) NOT NULL,
+    min_esg_score NUMERIC(4,2),
+    -- Breaches last alerted on, e.g. 'esg,weight:TSLA'
+    drift_breaches TEXT NOT NULL DEFAULT '',
+    drift_checked_at TIMESTAMP,
+    created_by TEXT,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS portfolio_holdings (
+    portfolio_id UUID NOT NULL REFERENCES portfolios(id) ON DELETE CASCADE,
+    symbol TEXT NOT NULL,
+    bank_id UUID NOT NULL,
+    quantity NUMERIC(20,6) NOT NULL CHECK (quantity >= 0),
+    target_weight NUMERIC(7,6) NOT NULL CHECK (target_weight BETWEEN 0 AND 1),
+    sector TEXT NOT NULL DEFAULT '',
+    country TEXT NOT NULL DEFAULT '',
+    carbon_intensity NUMERIC(12,2),
+    PRIMARY KEY (portfolio_id, symbol)
+);
+
+-- Enable RLS
+ALTER TABLE portfolios ENABLE ROW LEVEL SECURITY;
+ALTER TABLE portfolio_holdings ENABLE ROW LEVEL SECURITY;
+
+-- RLS Policies
+CREATE POLICY portfolios_bank_isolation ON portfolios
+    USING (bank_id = current_setting('app.current_bank')::uuid);
+CREATE POLICY portfolio_holdings_bank_isolation ON portfolio_holdings
+    USING (bank_id = current_setting('app.current_bank')::uuid);
+
+-- Indexes
+CREATE INDEX idx_portfolios_bank_id ON portfolios(bank_id, created_at);
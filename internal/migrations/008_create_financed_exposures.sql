@@ -0,0 +1,29 @@
+-- Loans and investments of stored portfolios, for PCAF financed emissions
+CREATE TABLE IF NOT EXISTS financed_exposures (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    portfolio_id UUID NOT NULL REFERENCES portfolios(id) ON DELETE CASCADE,
+    bank_id UUID NOT NULL,
+    position INTEGER NOT NULL,
+    counterparty TEXT NOT NULL,
+    symbol TEXT NOT NULL DEFAULT '',
+    asset_class TEXT NOT NULL CHECK (asset_class IN ('listed_equity', 'corporate_bond', 'business_loan')),
+    sector TEXT NOT NULL DEFAULT '',
+    outstanding_amount NUMERIC(20,2) NOT NULL CHECK (outstanding_amount > 0),
+    evic NUMERIC(20,2),
+    total_equity_debt NUMERIC(20,2),
+    revenue NUMERIC(20,2),
+    -- Scope 1-2 and scope 3 emissions as encrypted JSON; empty when unknown
+    carbon_emissions_encrypted TEXT NOT NULL DEFAULT '',
+    emissions_source TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Enable RLS
+ALTER TABLE financed_exposures ENABLE ROW LEVEL SECURITY;
+
+-- RLS Policies
+CREATE POLICY financed_exposures_bank_isolation ON financed_exposures
+    USING (bank_id = current_setting('app.current_bank')::uuid);
+
+-- Indexes
+CREATE INDEX idx_financed_exposures_portfolio ON financed_exposures(portfolio_id, position);
@@ -0,0 +1,27 @@
+-- Sites of companies and collateral behind loans to them, for physical
+-- climate risk scoring
+CREATE TABLE IF NOT EXISTS asset_locations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    bank_id UUID NOT NULL,
+    -- Lower-case company name, so lookups ignore case
+    company_key TEXT NOT NULL,
+    company TEXT NOT NULL,
+    position INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    latitude DOUBLE PRECISION CHECK (latitude BETWEEN -90 AND 90),
+    longitude DOUBLE PRECISION CHECK (longitude BETWEEN -180 AND 180),
+    region TEXT NOT NULL DEFAULT '',
+    asset_value NUMERIC(20,2) NOT NULL CHECK (asset_value > 0),
+    kind TEXT NOT NULL DEFAULT 'asset' CHECK (kind IN ('asset', 'collateral')),
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Enable RLS
+ALTER TABLE asset_locations ENABLE ROW LEVEL SECURITY;
+
+-- RLS Policies
+CREATE POLICY asset_locations_bank_isolation ON asset_locations
+    USING (bank_id = current_setting('app.current_bank')::uuid);
+
+-- Indexes
+CREATE INDEX idx_asset_locations_company ON asset_locations(bank_id, company_key, position);
@@ -0,0 +1,49 @@
+-- ESG and climate credit overlay policies, one row per version, and the
+-- adjustments made under them, kept for model risk validation
+CREATE TABLE IF NOT EXISTS credit_overlay_policies (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    bank_id UUID NOT NULL,
+    version INTEGER NOT NULL CHECK (version > 0),
+    rules JSONB NOT NULL,
+    max_downgrade INTEGER NOT NULL,
+    max_upgrade INTEGER NOT NULL,
+    pd_floor NUMERIC(8,6) NOT NULL,
+    created_by TEXT,
+    created_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE (bank_id, version)
+);
+
+CREATE TABLE IF NOT EXISTS credit_adjustments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    bank_id UUID NOT NULL,
+    -- Lower-case obligor name, so lookups ignore case
+    obligor_key TEXT NOT NULL,
+    obligor TEXT NOT NULL,
+    -- 0 for the default policy
+    policy_version INTEGER NOT NULL,
+    source TEXT NOT NULL CHECK (source IN ('api', 'analysis')),
+    base_rating TEXT NOT NULL,
+    base_pd NUMERIC(8,6) NOT NULL,
+    adjusted_rating TEXT NOT NULL,
+    adjusted_pd NUMERIC(8,6) NOT NULL,
+    rule_notches INTEGER NOT NULL,
+    notches INTEGER NOT NULL,
+    factors JSONB NOT NULL,
+    applied_rules JSONB NOT NULL,
+    created_by TEXT,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Enable RLS
+ALTER TABLE credit_overlay_policies ENABLE ROW LEVEL SECURITY;
+ALTER TABLE credit_adjustments ENABLE ROW LEVEL SECURITY;
+
+-- RLS Policies
+CREATE POLICY credit_overlay_policies_bank_isolation ON credit_overlay_policies
+    USING (bank_id = current_setting('app.current_bank')::uuid);
+CREATE POLICY credit_adjustments_bank_isolation ON credit_adjustments
+    USING (bank_id = current_setting('app.current_bank')::uuid);
+
+-- Indexes
+CREATE INDEX idx_credit_adjustments_obligor ON credit_adjustments(bank_id, obligor_key, created_at DESC);
+CREATE INDEX idx_credit_adjustments_created ON credit_adjustments(bank_id, created_at DESC);
@@ -0,0 +1,27 @@
+-- FIX export state shared by gateway replicas: when each signal was
+-- exported, and the session's sequence numbers and sent messages, so the
+-- replica that takes the session over carries on where the last one stopped
+ALTER TABLE trade_signals ADD COLUMN IF NOT EXISTS exported_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_trade_signals_unexported ON trade_signals(updated_at)
+    WHERE status = 'APPROVED' AND exported_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS fix_sessions (
+    sender_comp_id TEXT NOT NULL,
+    target_comp_id TEXT NOT NULL,
+    next_sender_seq INTEGER NOT NULL DEFAULT 1,
+    next_target_seq INTEGER NOT NULL DEFAULT 1,
+    updated_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (sender_comp_id, target_comp_id)
+);
+
+CREATE TABLE IF NOT EXISTS fix_messages (
+    sender_comp_id TEXT NOT NULL,
+    target_comp_id TEXT NOT NULL,
+    seq INTEGER NOT NULL,
+    raw BYTEA NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (sender_comp_id, target_comp_id, seq),
+    FOREIGN KEY (sender_comp_id, target_comp_id)
+        REFERENCES fix_sessions(sender_comp_id, target_comp_id) ON DELETE CASCADE
+);
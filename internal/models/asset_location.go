@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AssetLocation is a site of a company, or collateral behind a loan to it,
+// for physical climate risk. Companies are keyed by lower-case name.
+type AssetLocation struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	BankID     uuid.UUID `gorm:"type:uuid;not null"`
+	CompanyKey string    `gorm:"type:text;not null"`
+	Company    string    `gorm:"type:text;not null"`
+	Position   int       `gorm:"not null"`
+	Name       string    `gorm:"type:text;not null"`
+	Latitude   *float64  `gorm:"type:double precision"`
+	Longitude  *float64  `gorm:"type:double precision"`
+	Region     string    `gorm:"type:text;not null;default:''"`
+	AssetValue float64   `gorm:"type:numeric(20,2);not null"`
+	Kind       string    `gorm:"type:text;not null;default:'asset'"`
+	CreatedAt  time.Time `gorm:"default:now()"`
+}
+
+func (AssetLocation) TableName() string {
+	return "asset_locations"
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type BankAPIKey struct {
+	ID                     uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	BankID                 uuid.UUID `gorm:"type:uuid;not null;index"`
+	Name                   string    `gorm:"type:text;not null"`
+	KeyPrefix              string    `gorm:"type:text;not null;index"`
+	KeyHash                string    `gorm:"type:text;not null;uniqueIndex"`
+	Scopes                 string    `gorm:"type:text;not null"` // space-separated, OAuth style
+	SigningSecretEncrypted string    `gorm:"type:text"`
+	RequireSignature       bool      `gorm:"not null;default:false"`
+	ExpiresAt              *time.Time
+	LastUsedAt             *time.Time
+	RevokedAt              *time.Time
+	RotatedFrom            *uuid.UUID `gorm:"type:uuid"`
+	CreatedBy              string     `gorm:"type:text"`
+	CreatedAt              time.Time  `gorm:"default:now()"`
+}
+
+func (BankAPIKey) TableName() string {
+	return "bank_api_keys"
+}
+
+// ScopeList returns the scopes granted to the key.
+func (k *BankAPIKey) ScopeList() []string {
+	return strings.Fields(k.Scopes)
+}
+
+// HasScope reports whether the key grants scope.
+func (k *BankAPIKey) HasScope(scope string) bool {
+	for _, s := range k.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsActive reports whether the key can still authenticate requests.
+func (k *BankAPIKey) IsActive(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
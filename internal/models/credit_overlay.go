@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CreditOverlayPolicy is a version of a bank's ESG and climate credit
+// overlay; the highest version is in force. See credit.Policy for the
+// meaning of its fields.
+type CreditOverlayPolicy struct {
+	ID           uuid.UUID           `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	BankID       uuid.UUID           `gorm:"type:uuid;not null"`
+	Version      int                 `gorm:"not null"`
+	Rules        []CreditOverlayRule `gorm:"type:jsonb;serializer:json;not null"`
+	MaxDowngrade int                 `gorm:"not null"`
+	MaxUpgrade   int                 `gorm:"not null"`
+	PDFloor      float64             `gorm:"column:pd_floor;type:numeric(8,6);not null"`
+	CreatedBy    string              `gorm:"type:text"`
+	CreatedAt    time.Time           `gorm:"default:now()"`
+}
+
+func (CreditOverlayPolicy) TableName() string {
+	return "credit_overlay_policies"
+}
+
+// CreditOverlayRule is an overlay rule, stored as JSON.
+type CreditOverlayRule struct {
+	Name      string  `json:"name"`
+	Factor    string  `json:"factor"`
+	Operator  string  `json:"operator"`
+	Threshold float64 `json:"threshold"`
+	Notches   int     `json:"notches"`
+}
+
+// CreditAdjustment records an obligor's rating and PD before and after an
+// overlay, with the factors and rules behind it. PolicyVersion is 0 for the
+// default policy. Obligors are keyed by lower-case name.
+type CreditAdjustment struct {
+	ID             uuid.UUID           `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	BankID         uuid.UUID           `gorm:"type:uuid;not null"`
+	ObligorKey     string              `gorm:"type:text;not null"`
+	Obligor        string              `gorm:"type:text;not null"`
+	PolicyVersion  int                 `gorm:"not null"`
+	Source         string              `gorm:"type:text;not null"`
+	BaseRating     string              `gorm:"type:text;not null"`
+	BasePD         float64             `gorm:"column:base_pd;type:numeric(8,6);not null"`
+	AdjustedRating string              `gorm:"type:text;not null"`
+	AdjustedPD     float64             `gorm:"column:adjusted_pd;type:numeric(8,6);not null"`
+	RuleNotches    int                 `gorm:"not null"`
+	Notches        int                 `gorm:"not null"`
+	Factors        map[string]float64  `gorm:"type:jsonb;serializer:json;not null"`
+	AppliedRules   []CreditOverlayRule `gorm:"type:jsonb;serializer:json;not null"`
+	CreatedBy      string              `gorm:"type:text"`
+	CreatedAt      time.Time           `gorm:"default:now()"`
+}
+
+func (CreditAdjustment) TableName() string {
+	return "credit_adjustments"
+}
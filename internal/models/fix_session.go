@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// FIXSession is a FIX session's next sequence numbers, shared by the
+// gateway replicas that may run it.
+type FIXSession struct {
+	SenderCompID  string `gorm:"type:text;primaryKey"`
+	TargetCompID  string `gorm:"type:text;primaryKey"`
+	NextSenderSeq int    `gorm:"not null;default:1"`
+	NextTargetSeq int    `gorm:"not null;default:1"`
+	UpdatedAt     time.Time
+}
+
+func (FIXSession) TableName() string {
+	return "fix_sessions"
+}
+
+// FIXMessage is a message a FIX session sent, kept to answer resend
+// requests.
+type FIXMessage struct {
+	SenderCompID string    `gorm:"type:text;primaryKey"`
+	TargetCompID string    `gorm:"type:text;primaryKey"`
+	Seq          int       `gorm:"primaryKey;autoIncrement:false"`
+	Raw          []byte    `gorm:"type:bytea;not null"`
+	CreatedAt    time.Time `gorm:"default:now()"`
+}
+
+func (FIXMessage) TableName() string {
+	return "fix_messages"
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PaperPortfolio is a simulated portfolio. Owner is empty for bank-wide
+// portfolios and otherwise the user that created it.
+type PaperPortfolio struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	BankID      uuid.UUID `gorm:"type:uuid;not null;index"`
+	Owner       string    `gorm:"type:text;not null;default:''"`
+	Name        string    `gorm:"type:text;not null"`
+	InitialCash float64   `gorm:"type:numeric(18,2);not null"`
+	Cash        float64   `gorm:"type:numeric(18,2);not null"`
+	// PositionSize is the fraction of equity a BUY invests
+	PositionSize  float64   `gorm:"type:numeric(5,4);not null"`
+	CostBps       float64   `gorm:"type:numeric(8,2);not null;default:0"`
+	SlippageBps   float64   `gorm:"type:numeric(8,2);not null;default:0"`
+	FollowSignals bool      `gorm:"not null;default:true"`
+	CreatedAt     time.Time `gorm:"default:now()"`
+	UpdatedAt     time.Time `gorm:"default:now()"`
+}
+
+func (PaperPortfolio) TableName() string {
+	return "paper_portfolios"
+}
+
+// PaperPosition is a holding; CostBasis includes the fees paid to open it.
+type PaperPosition struct {
+	PortfolioID uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Symbol      string    `gorm:"type:text;primaryKey"`
+	BankID      uuid.UUID `gorm:"type:uuid;not null"`
+	Quantity    float64   `gorm:"type:numeric(20,6);not null"`
+	CostBasis   float64   `gorm:"type:numeric(18,2);not null"`
+	ESGScore    float64   `gorm:"type:numeric(4,2)"`
+	OpenedAt    time.Time `gorm:"default:now()"`
+}
+
+func (PaperPosition) TableName() string {
+	return "paper_positions"
+}
+
+// PaperOrder is a simulated order. Orders fill immediately or are rejected
+// with a Reason; Quote is the market price the fill was based on.
+type PaperOrder struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	PortfolioID uuid.UUID  `gorm:"type:uuid;not null;index"`
+	BankID      uuid.UUID  `gorm:"type:uuid;not null"`
+	SignalID    *uuid.UUID `gorm:"type:uuid"`
+	Symbol      string     `gorm:"type:text;not null"`
+	Side        string     `gorm:"type:text;not null"`
+	Status      string     `gorm:"type:text;not null"`
+	Reason      string     `gorm:"type:text"`
+	Quantity    float64    `gorm:"type:numeric(20,6);not null;default:0"`
+	Quote       float64    `gorm:"type:numeric(12,2)"`
+	QuoteDate   *time.Time `gorm:"type:date"`
+	Price       float64    `gorm:"type:numeric(12,4)"`
+	Fees        float64    `gorm:"type:numeric(18,2);not null;default:0"`
+	RealizedPnL *float64   `gorm:"column:realized_pnl;type:numeric(18,2)"`
+	CreatedBy   string     `gorm:"type:text"`
+	CreatedAt   time.Time  `gorm:"default:now()"`
+}
+
+func (PaperOrder) TableName() string {
+	return "paper_orders"
+}
+
+// PaperSnapshot is a portfolio marked to market at the end of a day.
+// ESGScore is the value-weighted ESG score of the holdings; the leader and
+// laggard weights are the shares of equity in high and low ESG names.
+type PaperSnapshot struct {
+	PortfolioID      uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Date             time.Time `gorm:"type:date;primaryKey"`
+	BankID           uuid.UUID `gorm:"type:uuid;not null"`
+	Cash             float64   `gorm:"type:numeric(18,2);not null"`
+	MarketValue      float64   `gorm:"type:numeric(18,2);not null"`
+	Equity           float64   `gorm:"type:numeric(18,2);not null"`
+	ESGScore         *float64  `gorm:"type:numeric(4,2)"`
+	ESGLeaderWeight  float64   `gorm:"column:esg_leader_weight;type:numeric(5,4);not null;default:0"`
+	ESGLaggardWeight float64   `gorm:"column:esg_laggard_weight;type:numeric(5,4);not null;default:0"`
+	CreatedAt        time.Time `gorm:"default:now()"`
+}
+
+func (PaperSnapshot) TableName() string {
+	return "paper_snapshots"
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Portfolio is a bank's book of holdings, kept to target weights under a
+// mandate. DriftBreaches holds the breaches last alerted on, so an
+// unchanged breach is not alerted again.
+type Portfolio struct {
+	ID        uuid.UUID         `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	BankID    uuid.UUID         `gorm:"type:uuid;not null;index"`
+	Name      string            `gorm:"type:text;not null"`
+	Benchmark string            `gorm:"type:text;not null;default:''"`
+	Mandate   *PortfolioMandate `gorm:"type:jsonb;serializer:json"`
+	// DriftThreshold is how far a holding's weight may stray from its
+	// target; MinESGScore, when set, is the lowest acceptable portfolio score
+	DriftThreshold float64  `gorm:"type:numeric(5,4);not null"`
+	MinESGScore    *float64 `gorm:"column:min_esg_score;type:numeric(4,2)"`
+	DriftBreaches  string   `gorm:"type:text;not null;default:''"`
+	DriftCheckedAt *time.Time
+	Holdings       []PortfolioHolding `gorm:"foreignKey:PortfolioID"`
+	CreatedBy      string             `gorm:"type:text"`
+	CreatedAt      time.Time          `gorm:"default:now()"`
+	UpdatedAt      time.Time          `gorm:"default:now()"`
+}
+
+func (Portfolio) TableName() string {
+	return "portfolios"
+}
+
+// PortfolioHolding is a position and the weight the portfolio aims to hold
+// it at. Sector, country and carbon intensity feed mandate limits.
+type PortfolioHolding struct {
+	PortfolioID     uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Symbol          string    `gorm:"type:text;primaryKey"`
+	BankID          uuid.UUID `gorm:"type:uuid;not null"`
+	Quantity        float64   `gorm:"type:numeric(20,6);not null"`
+	TargetWeight    float64   `gorm:"type:numeric(7,6);not null"`
+	Sector          string    `gorm:"type:text;not null;default:''"`
+	Country         string    `gorm:"type:text;not null;default:''"`
+	CarbonIntensity *float64  `gorm:"type:numeric(12,2)"`
+}
+
+func (PortfolioHolding) TableName() string {
+	return "portfolio_holdings"
+}
+
+// PortfolioMandate is a portfolio's investment limits, stored as JSON and
+// keyed by symbol; see dtos.PortfolioConstraints for their meaning. The
+// turnover limit applies to each rebalance.
+type PortfolioMandate struct {
+	MinWeight          float64                  `json:"min_weight,omitempty"`
+	MaxWeight          float64                  `json:"max_weight,omitempty"`
+	WeightBounds       map[string]MandateBounds `json:"weight_bounds,omitempty"`
+	SectorCaps         map[string]float64       `json:"sector_caps,omitempty"`
+	CountryCaps        map[string]float64       `json:"country_caps,omitempty"`
+	MinESGScore        float64                  `json:"min_esg_score,omitempty"`
+	MaxCarbonIntensity *float64                 `json:"max_carbon_intensity,omitempty"`
+	Exclude            []string                 `json:"exclude,omitempty"`
+	MaxTurnover        *float64                 `json:"max_turnover,omitempty"`
+}
+
+type MandateBounds struct {
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+}
+
+// FinancedExposure is a loan or investment held in a portfolio, for
+// financed emissions accounting. The company's emissions are encrypted,
+// as they are in esg_scores.
+type FinancedExposure struct {
+	ID                       uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	PortfolioID              uuid.UUID `gorm:"type:uuid;not null"`
+	BankID                   uuid.UUID `gorm:"type:uuid;not null"`
+	Position                 int       `gorm:"not null"`
+	Counterparty             string    `gorm:"type:text;not null"`
+	Symbol                   string    `gorm:"type:text;not null;default:''"`
+	AssetClass               string    `gorm:"type:text;not null"`
+	Sector                   string    `gorm:"type:text;not null;default:''"`
+	OutstandingAmount        float64   `gorm:"type:numeric(20,2);not null"`
+	EVIC                     *float64  `gorm:"column:evic;type:numeric(20,2)"`
+	EquityPlusDebt           *float64  `gorm:"column:total_equity_debt;type:numeric(20,2)"`
+	Revenue                  *float64  `gorm:"type:numeric(20,2)"`
+	CarbonEmissionsEncrypted string    `gorm:"type:text;not null;default:''"`
+	EmissionsSource          string    `gorm:"type:text;not null;default:''"`
+	CreatedAt                time.Time `gorm:"default:now()"`
+}
+
+func (FinancedExposure) TableName() string {
+	return "financed_exposures"
+}
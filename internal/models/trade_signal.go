@@ -7,18 +7,50 @@ import (
 )
 
 type TradeSignal struct {
-	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	BankID      uuid.UUID `gorm:"type:uuid;not null;index"`
-	Symbol      string    `gorm:"type:text;not null"`
-	Action      string    `gorm:"type:text;not null"`
-	TargetPrice float64   `gorm:"type:numeric(10,2)"`
-	Confidence  float64   `gorm:"type:numeric(3,2)"`
-	ESGScore    float64   `gorm:"type:numeric(3,2)"`
-	Status      string    `gorm:"type:text;default:'PENDING'"`
+	ID           uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	BankID       uuid.UUID `gorm:"type:uuid;not null;index"`
+	Company      string    `gorm:"type:text"`
+	AnalysisID   string    `gorm:"type:text"`
+	Symbol       string    `gorm:"type:text;not null"`
+	Action       string    `gorm:"type:text;not null"`
+	CurrentPrice float64   `gorm:"type:numeric(12,2)"`
+	TargetPrice  float64   `gorm:"type:numeric(10,2)"`
+	Confidence   float64   `gorm:"type:numeric(3,2)"`
+	ESGScore     float64   `gorm:"type:numeric(4,2)"`
+	RiskAction   string    `gorm:"type:text"`
+	Reasoning    string    `gorm:"type:text"`
+	Status       string    `gorm:"type:text;not null;default:'PENDING'"`
+	StatusReason string    `gorm:"type:text"`
+	// DecidedBy is the user (or agent) that approved or rejected the signal
+	DecidedBy   string `gorm:"type:text"`
+	DecidedRole string `gorm:"type:text"`
+	DecidedAt   *time.Time
+	ExpiresAt   *time.Time
 	ExecutedAt  *time.Time
-	CreatedAt   time.Time `gorm:"default:now()"`
+	// ExportedAt is when the signal's order was sent or, in a FIX dry run,
+	// written out
+	ExportedAt *time.Time
+	CreatedAt  time.Time `gorm:"default:now()"`
+	UpdatedAt  time.Time `gorm:"default:now()"`
 }
 
 func (TradeSignal) TableName() string {
 	return "trade_signals"
 }
+
+// TradeSignalEvent records one status change of a trade signal.
+type TradeSignalEvent struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	SignalID   uuid.UUID `gorm:"type:uuid;not null;index"`
+	BankID     uuid.UUID `gorm:"type:uuid;not null"`
+	FromStatus string    `gorm:"type:text"`
+	ToStatus   string    `gorm:"type:text;not null"`
+	Actor      string    `gorm:"type:text;not null"`
+	ActorRole  string    `gorm:"type:text"`
+	Reason     string    `gorm:"type:text"`
+	CreatedAt  time.Time `gorm:"default:now()"`
+}
+
+func (TradeSignalEvent) TableName() string {
+	return "trade_signal_events"
+}
@@ -0,0 +1,282 @@
+// Package pcaf attributes the emissions of a bank's counterparties to its
+// loans and investments following the PCAF Global GHG Accounting and
+// Reporting Standard for listed equity, corporate bonds and business
+// loans, and scores the quality of the data behind each estimate.
+package pcaf
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/edgeesg/edge-esg-backend/internal/marketdata"
+)
+
+// Asset classes
+const (
+	ListedEquity  = "listed_equity"
+	CorporateBond = "corporate_bond"
+	BusinessLoan  = "business_loan"
+)
+
+// Sources of reported emissions, best first: emissions verified by a
+// third party (score 1), reported unverified (score 2), or calculated
+// from the company's physical activity, such as energy use (score 3).
+const (
+	SourceVerified = "verified"
+	SourceReported = "reported"
+	SourcePhysical = "physical"
+)
+
+// Methods by which an exposure's emissions were found
+const (
+	MethodReported = "reported"
+	MethodPhysical = "physical_activity"
+	MethodRevenue  = "revenue_based"
+	MethodAssets   = "asset_based"
+)
+
+// Unclassified groups exposures without a sector.
+const Unclassified = "Unclassified"
+
+var ErrInvalidExposure = errors.New("invalid exposure")
+
+// Exposure is a loan or investment and what is known about the company
+// behind it. Amounts are in the reporting currency; Emissions are the
+// company's scope 1 and 2 emissions and Scope3 its scope 3, in tCO2e.
+// EVIC is enterprise value including cash; EquityPlusDebt is total equity
+// and debt, used for bonds and loans to companies without an EVIC.
+type Exposure struct {
+	Counterparty    string
+	Symbol          string
+	AssetClass      string
+	Sector          string
+	Outstanding     float64
+	EVIC            *float64
+	EquityPlusDebt  *float64
+	Revenue         *float64
+	Emissions       *float64
+	Scope3          *float64
+	EmissionsSource string
+}
+
+// Validate checks an exposure has what PCAF attribution needs.
+func (e Exposure) Validate() error {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s: %s", ErrInvalidExposure, e.Counterparty, fmt.Sprintf(format, args...))
+	}
+	positive := func(v *float64) bool { return v == nil || *v > 0 }
+	switch {
+	case strings.TrimSpace(e.Counterparty) == "":
+		return fmt.Errorf("%w: a counterparty is required", ErrInvalidExposure)
+	case e.AssetClass != ListedEquity && e.AssetClass != CorporateBond && e.AssetClass != BusinessLoan:
+		return invalid("asset class must be %s, %s or %s", ListedEquity, CorporateBond, BusinessLoan)
+	case e.Outstanding <= 0:
+		return invalid("the outstanding amount must be positive")
+	case !positive(e.EVIC) || !positive(e.EquityPlusDebt) || !positive(e.Revenue):
+		return invalid("EVIC, equity plus debt and revenue must be positive when given")
+	case e.AssetClass == ListedEquity && e.EVIC == nil:
+		return invalid("listed equity is attributed by EVIC")
+	case e.EVIC == nil && e.EquityPlusDebt == nil:
+		return invalid("attribution needs EVIC or total equity plus debt")
+	case e.Emissions != nil && *e.Emissions < 0, e.Scope3 != nil && *e.Scope3 < 0:
+		return invalid("emissions cannot be negative")
+	case e.Emissions != nil && e.EmissionsSource != SourceVerified && e.EmissionsSource != SourceReported && e.EmissionsSource != SourcePhysical:
+		return invalid("emissions need a source of %s, %s or %s", SourceVerified, SourceReported, SourcePhysical)
+	case e.Emissions == nil && e.EmissionsSource != "":
+		return invalid("an emissions source needs emissions")
+	}
+	return nil
+}
+
+// Financed is an exposure's share of its company's emissions. The
+// attribution factor is the outstanding amount over EVIC, or over equity
+// plus debt for a bond or loan to a company without one, at most 1.
+// DataQuality is the PCAF score, 1 (best) to 5, and 0 with a nil
+// FinancedEmissions when nothing could be estimated. CarbonIntensity is
+// the company's emissions per million of revenue.
+type Financed struct {
+	Exposure
+	AttributionFactor float64
+	Method            string
+	DataQuality       int
+	CompanyEmissions  *float64
+	FinancedEmissions *float64
+	FinancedScope3    *float64
+	CarbonIntensity   *float64
+}
+
+// Attribute finds an exposure's financed emissions. Reported emissions are
+// used where given. Otherwise they are estimated from the sector's
+// emission factor: per million of revenue when the revenue is known
+// (score 4), or else per million of the attribution denominator, standing
+// in for the company's assets (score 5). Factors are keyed by sector as
+// SectorKey gives it.
+func Attribute(e Exposure, factors map[string]marketdata.EmissionFactor) Financed {
+	f := Financed{Exposure: e}
+	denominator := e.EquityPlusDebt
+	if e.EVIC != nil {
+		denominator = e.EVIC
+	}
+	if denominator == nil {
+		return f
+	}
+	f.AttributionFactor = math.Min(1, e.Outstanding / *denominator)
+
+	factor, hasFactor := factors[SectorKey(e.Sector)]
+	var emissions float64
+	switch {
+	case e.Emissions != nil:
+		emissions = *e.Emissions
+		f.Method, f.DataQuality = MethodReported, 2
+		switch e.EmissionsSource {
+		case SourceVerified:
+			f.DataQuality = 1
+		case SourcePhysical:
+			f.Method, f.DataQuality = MethodPhysical, 3
+		}
+	case hasFactor && factor.Revenue != nil && e.Revenue != nil:
+		emissions = *factor.Revenue * *e.Revenue / 1e6
+		f.Method, f.DataQuality = MethodRevenue, 4
+	case hasFactor && factor.Assets != nil:
+		emissions = *factor.Assets * *denominator / 1e6
+		f.Method, f.DataQuality = MethodAssets, 5
+	default:
+		return f
+	}
+	financed := f.AttributionFactor * emissions
+	f.CompanyEmissions = &emissions
+	f.FinancedEmissions = &financed
+	if e.Scope3 != nil {
+		scope3 := f.AttributionFactor * *e.Scope3
+		f.FinancedScope3 = &scope3
+	}
+	if e.Revenue != nil {
+		intensity := emissions / (*e.Revenue / 1e6)
+		f.CarbonIntensity = &intensity
+	}
+	return f
+}
+
+// SectorKey normalises a sector name for matching.
+func SectorKey(sector string) string {
+	return strings.ToLower(strings.TrimSpace(sector))
+}
+
+// Summary totals a group of exposures. FinancedEmissions covers the
+// exposures with an estimate, which make up Coverage of the outstanding
+// amount. EmissionIntensity is financed emissions per million invested
+// and DataQuality the outstanding-weighted PCAF score, both over those
+// exposures. WACI is the weighted average carbon intensity, per million of
+// revenue, over the exposures with a known revenue, weighted by
+// outstanding amount; WACICoverage is their share. Share is the group's
+// part of the portfolio's financed emissions.
+type Summary struct {
+	Key               string
+	Outstanding       float64
+	FinancedEmissions float64
+	FinancedScope3    float64
+	Share             float64
+	Coverage          float64
+	EmissionIntensity *float64
+	DataQuality       *float64
+	WACI              *float64
+	WACICoverage      float64
+	Count             int
+
+	covered, quality, waci, waciWeight float64
+}
+
+func (s *Summary) add(f Financed) {
+	s.Count++
+	s.Outstanding += f.Outstanding
+	if f.FinancedEmissions == nil {
+		return
+	}
+	s.covered += f.Outstanding
+	s.FinancedEmissions += *f.FinancedEmissions
+	s.quality += f.Outstanding * float64(f.DataQuality)
+	if f.FinancedScope3 != nil {
+		s.FinancedScope3 += *f.FinancedScope3
+	}
+	if f.CarbonIntensity != nil {
+		s.waci += f.Outstanding * *f.CarbonIntensity
+		s.waciWeight += f.Outstanding
+	}
+}
+
+func (s *Summary) finish(total float64) {
+	if s.Outstanding > 0 {
+		s.Coverage = s.covered / s.Outstanding
+		s.WACICoverage = s.waciWeight / s.Outstanding
+	}
+	if s.covered > 0 {
+		intensity := s.FinancedEmissions / (s.covered / 1e6)
+		quality := s.quality / s.covered
+		s.EmissionIntensity = &intensity
+		s.DataQuality = &quality
+	}
+	if s.waciWeight > 0 {
+		waci := s.waci / s.waciWeight
+		s.WACI = &waci
+	}
+	if total > 0 {
+		s.Share = s.FinancedEmissions / total
+	}
+}
+
+// Report is a portfolio's financed emissions, in total and by sector and
+// asset class, with each exposure's attribution.
+type Report struct {
+	Summary
+	Exposures    []Financed
+	BySector     []Summary
+	ByAssetClass []Summary
+}
+
+// NewReport attributes every exposure and totals them. Groups are in
+// order of financed emissions, largest first.
+func NewReport(exposures []Exposure, factors map[string]marketdata.EmissionFactor) *Report {
+	report := &Report{Exposures: make([]Financed, len(exposures))}
+	sectors := make(map[string]*Summary)
+	classes := make(map[string]*Summary)
+	group := func(groups map[string]*Summary, key string) *Summary {
+		if groups[key] == nil {
+			groups[key] = &Summary{Key: key}
+		}
+		return groups[key]
+	}
+	for i, exposure := range exposures {
+		financed := Attribute(exposure, factors)
+		report.Exposures[i] = financed
+		sector := strings.TrimSpace(exposure.Sector)
+		if sector == "" {
+			sector = Unclassified
+		}
+		report.Summary.add(financed)
+		group(sectors, sector).add(financed)
+		group(classes, exposure.AssetClass).add(financed)
+	}
+	total := report.FinancedEmissions
+	report.Summary.finish(total)
+	report.BySector = finishGroups(sectors, total)
+	report.ByAssetClass = finishGroups(classes, total)
+	return report
+}
+
+func finishGroups(groups map[string]*Summary, total float64) []Summary {
+	out := make([]Summary, 0, len(groups))
+	for _, group := range groups {
+		group.finish(total)
+		out = append(out, *group)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].FinancedEmissions != out[j].FinancedEmissions {
+			return out[i].FinancedEmissions > out[j].FinancedEmissions
+		}
+		return out[i].Key < out[j].Key
+	})
+	return out
+}
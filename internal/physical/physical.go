@@ -0,0 +1,229 @@
+// Package physical scores the physical climate risk of companies from the
+// locations of their assets: how exposed each site is to flood, heat,
+// drought, cyclones and sea-level rise, weighted by what the site is
+// worth.
+package physical
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/edgeesg/edge-esg-backend/internal/marketdata"
+)
+
+// Kinds of location
+const (
+	// Asset is a site the company owns or operates.
+	Asset = "asset"
+	// Collateral is property pledged against a loan to the company.
+	Collateral = "collateral"
+)
+
+// Where a location's hazard scores come from
+const (
+	SourceGrid   = "grid"
+	SourceRegion = "region"
+)
+
+// A location's score blends its worst hazard with the average of all of
+// them, so one extreme hazard counts for more than several mild ones.
+const (
+	worstWeight   = 0.6
+	averageWeight = 0.4
+)
+
+var ErrInvalidLocation = errors.New("invalid location")
+
+// Location is a site of a company, by coordinates or by ISO 3166 region
+// (a country such as IN or a subdivision such as IN-MH). Coordinates are
+// scored from the hazard grid and fall back to the region when the grid
+// has no cell near them. AssetValue, in the reporting currency, weights
+// the site in its company's score.
+type Location struct {
+	Name       string
+	Latitude   *float64
+	Longitude  *float64
+	Region     string
+	AssetValue float64
+	Kind       string
+}
+
+// Validate checks a location can be placed and weighted.
+func (l Location) Validate() error {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s: %s", ErrInvalidLocation, l.Name, fmt.Sprintf(format, args...))
+	}
+	switch {
+	case strings.TrimSpace(l.Name) == "":
+		return fmt.Errorf("%w: a name is required", ErrInvalidLocation)
+	case (l.Latitude == nil) != (l.Longitude == nil):
+		return invalid("latitude and longitude go together")
+	case l.Latitude == nil && strings.TrimSpace(l.Region) == "":
+		return invalid("coordinates or a region are required")
+	case l.Latitude != nil && (*l.Latitude < -90 || *l.Latitude > 90):
+		return invalid("latitude must be from -90 to 90")
+	case l.Longitude != nil && (*l.Longitude < -180 || *l.Longitude > 180):
+		return invalid("longitude must be from -180 to 180")
+	case l.AssetValue <= 0:
+		return invalid("asset value must be positive")
+	case l.Kind != "" && l.Kind != Asset && l.Kind != Collateral:
+		return invalid("kind must be %s or %s", Asset, Collateral)
+	}
+	return nil
+}
+
+// LocationKind returns the location's kind, Asset when unset.
+func (l Location) LocationKind() string {
+	if l.Kind == "" {
+		return Asset
+	}
+	return l.Kind
+}
+
+// LocationRisk is a location's hazard scores, 0 to 1, and its score, 0 to
+// 100. Source is empty and Score nil when the hazard data does not cover
+// the location.
+type LocationRisk struct {
+	Location
+	Source  string
+	Hazards marketdata.HazardScores
+	Score   *float64
+}
+
+// Risk is the physical risk of a set of locations: each hazard and the
+// score averaged over the scored locations, weighted by asset value.
+// Coverage is the share of asset value scored; Score is nil when none is.
+type Risk struct {
+	Score     *float64
+	Hazards   marketdata.HazardScores
+	Coverage  float64
+	Locations []LocationRisk
+}
+
+// Assess scores locations against the hazard data.
+func Assess(locations []Location, hazards marketdata.HazardSource) (*Risk, error) {
+	risk := &Risk{Hazards: marketdata.HazardScores{}, Locations: make([]LocationRisk, len(locations))}
+	var total, scored, score float64
+	weights := make(map[string]float64, len(marketdata.Hazards))
+	for i, location := range locations {
+		if err := location.Validate(); err != nil {
+			return nil, err
+		}
+		located, err := locate(location, hazards)
+		if err != nil {
+			return nil, err
+		}
+		risk.Locations[i] = located
+		total += location.AssetValue
+		if located.Score == nil {
+			continue
+		}
+		scored += location.AssetValue
+		score += location.AssetValue * *located.Score
+		for hazard, value := range located.Hazards {
+			risk.Hazards[hazard] += location.AssetValue * value
+			weights[hazard] += location.AssetValue
+		}
+	}
+	for hazard := range risk.Hazards {
+		risk.Hazards[hazard] /= weights[hazard]
+	}
+	if total > 0 {
+		risk.Coverage = scored / total
+	}
+	if scored > 0 {
+		score /= scored
+		risk.Score = &score
+	}
+	return risk, nil
+}
+
+// Score is the 0-100 score of a location's hazard scores, nil when there
+// are none.
+func Score(hazards marketdata.HazardScores) *float64 {
+	if len(hazards) == 0 {
+		return nil
+	}
+	worst, sum := 0.0, 0.0
+	for _, value := range hazards {
+		sum += value
+		if value > worst {
+			worst = value
+		}
+	}
+	score := 100 * (worstWeight*worst + averageWeight*sum/float64(len(hazards)))
+	return &score
+}
+
+func locate(location Location, hazards marketdata.HazardSource) (LocationRisk, error) {
+	located := LocationRisk{Location: location}
+	if location.Latitude != nil {
+		scores, ok, err := hazards.HazardsAt(*location.Latitude, *location.Longitude)
+		if err != nil {
+			return located, err
+		}
+		if ok && len(scores) > 0 {
+			located.Source, located.Hazards, located.Score = SourceGrid, scores, Score(scores)
+			return located, nil
+		}
+	}
+	if strings.TrimSpace(location.Region) != "" {
+		scores, ok, err := hazards.RegionHazards(location.Region)
+		if err != nil {
+			return located, err
+		}
+		if ok && len(scores) > 0 {
+			located.Source, located.Hazards, located.Score = SourceRegion, scores, Score(scores)
+		}
+	}
+	return located, nil
+}
+
+// Holding is an amount held in a company, with the physical risk of the
+// locations behind it; Risk is nil when the company has none.
+type Holding struct {
+	Name   string
+	Amount float64
+	Risk   *Risk
+}
+
+// PortfolioRisk is the physical risk of a portfolio: each hazard and the
+// score averaged over the holdings that have one, weighted by amount.
+// Coverage is the share of the amount held that is scored.
+type PortfolioRisk struct {
+	Score    *float64
+	Hazards  marketdata.HazardScores
+	Coverage float64
+	Holdings []Holding
+}
+
+// Portfolio aggregates the physical risk of holdings.
+func Portfolio(holdings []Holding) *PortfolioRisk {
+	portfolio := &PortfolioRisk{Hazards: marketdata.HazardScores{}, Holdings: holdings}
+	var total, scored, score float64
+	weights := make(map[string]float64, len(marketdata.Hazards))
+	for _, holding := range holdings {
+		total += holding.Amount
+		if holding.Risk == nil || holding.Risk.Score == nil {
+			continue
+		}
+		scored += holding.Amount
+		score += holding.Amount * *holding.Risk.Score
+		for hazard, value := range holding.Risk.Hazards {
+			portfolio.Hazards[hazard] += holding.Amount * value
+			weights[hazard] += holding.Amount
+		}
+	}
+	for hazard := range portfolio.Hazards {
+		portfolio.Hazards[hazard] /= weights[hazard]
+	}
+	if total > 0 {
+		portfolio.Coverage = scored / total
+	}
+	if scored > 0 {
+		score /= scored
+		portfolio.Score = &score
+	}
+	return portfolio
+}
@@ -0,0 +1,79 @@
+package quant
+
+import "sort"
+
+// GroupAttribution is one group's part in the difference between a
+// portfolio's weighted-average score and its benchmark's. Scores are the
+// weighted averages within the group; a side with no weight in the group
+// has a score of 0. Allocation is the effect of over- or underweighting
+// the group, Selection that of holding better or worse scoring assets
+// within it.
+type GroupAttribution struct {
+	Group           string
+	Weight          float64
+	BenchmarkWeight float64
+	Score           float64
+	BenchmarkScore  float64
+	Allocation      float64
+	Selection       float64
+}
+
+// Attribute splits the difference between the weighted-average scores of
+// two portfolios over the same assets by group, Brinson-Fachler style:
+//
+//	allocation = (w_g − b_g)·(B_g − B)
+//	selection  = w_g·(P_g − B_g)
+//
+// where w and b are group weights, P_g and B_g group scores and B the
+// benchmark's score. The effects add up to P − B. A group the benchmark
+// does not hold is measured against B. Weights are scaled to add up to 1
+// on each side; groups are returned in name order.
+func Attribute(groups []string, weights, benchmark, scores []float64) []GroupAttribution {
+	byName := make(map[string]*GroupAttribution)
+	var total, benchmarkTotal float64
+	for i, name := range groups {
+		group, ok := byName[name]
+		if !ok {
+			group = &GroupAttribution{Group: name}
+			byName[name] = group
+		}
+		group.Weight += weights[i]
+		group.BenchmarkWeight += benchmark[i]
+		group.Score += weights[i] * scores[i]
+		group.BenchmarkScore += benchmark[i] * scores[i]
+		total += weights[i]
+		benchmarkTotal += benchmark[i]
+	}
+
+	out := make([]GroupAttribution, 0, len(byName))
+	overall := 0.0
+	for _, group := range byName {
+		if group.Weight > 0 {
+			group.Score /= group.Weight
+		}
+		if group.BenchmarkWeight > 0 {
+			group.BenchmarkScore /= group.BenchmarkWeight
+		}
+		if total > 0 {
+			group.Weight /= total
+		}
+		if benchmarkTotal > 0 {
+			group.BenchmarkWeight /= benchmarkTotal
+		}
+		overall += group.BenchmarkWeight * group.BenchmarkScore
+		out = append(out, *group)
+	}
+	for i := range out {
+		group := &out[i]
+		reference := group.BenchmarkScore
+		if group.BenchmarkWeight == 0 {
+			reference = overall
+		}
+		group.Allocation = (group.Weight - group.BenchmarkWeight) * (reference - overall)
+		if group.Weight > 0 {
+			group.Selection = group.Weight * (group.Score - reference)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Group < out[j].Group })
+	return out
+}
@@ -0,0 +1,208 @@
+// Package quant holds the numerical pieces of portfolio construction:
+// covariance estimation from return histories, a long-only mean-variance
+// optimiser and value at risk. It is plain Go with no external solver,
+// sized for the tens of assets a portfolio comparison or mandate holds.
+package quant
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// PeriodsPerYear annualises daily statistics.
+const PeriodsPerYear = 252
+
+var ErrInsufficientData = errors.New("insufficient return history")
+
+// Matrix is a dense square matrix, row by row.
+type Matrix [][]float64
+
+func NewMatrix(n int) Matrix {
+	m := make(Matrix, n)
+	for i := range m {
+		m[i] = make([]float64, n)
+	}
+	return m
+}
+
+// MulVec returns m·v.
+func (m Matrix) MulVec(v []float64) []float64 {
+	out := make([]float64, len(m))
+	for i, row := range m {
+		out[i] = Dot(row, v)
+	}
+	return out
+}
+
+// Quad returns vᵀ·m·v.
+func (m Matrix) Quad(v []float64) float64 {
+	return Dot(v, m.MulVec(v))
+}
+
+// Scale returns m multiplied by k.
+func (m Matrix) Scale(k float64) Matrix {
+	out := NewMatrix(len(m))
+	for i := range m {
+		for j := range m[i] {
+			out[i][j] = m[i][j] * k
+		}
+	}
+	return out
+}
+
+func Dot(a, b []float64) float64 {
+	sum := 0.0
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+// Estimate is a shrunk covariance matrix with how it was obtained.
+type Estimate struct {
+	Covariance Matrix
+	// Shrinkage is the weight given to the constant-correlation target
+	Shrinkage    float64
+	Observations int
+}
+
+// ShrunkCovariance estimates the covariance of returns, one series per
+// asset and all of the same length, with Ledoit-Wolf shrinkage towards the
+// constant-correlation matrix ("Honey, I Shrunk the Sample Covariance
+// Matrix", 2004). Shrinkage matters here because histories are short
+// relative to the number of assets, which makes the sample matrix noisy
+// and the optimiser chase that noise. The result is per period; use
+// Matrix.Scale to annualise.
+func ShrunkCovariance(returns [][]float64) (Estimate, error) {
+	n := len(returns)
+	if n == 0 {
+		return Estimate{}, ErrInsufficientData
+	}
+	t := len(returns[0])
+	for _, series := range returns {
+		if len(series) != t {
+			return Estimate{}, fmt.Errorf("return series have different lengths")
+		}
+	}
+	if t < 2 {
+		return Estimate{}, ErrInsufficientData
+	}
+
+	// Demeaned returns
+	x := make([][]float64, n)
+	for i, series := range returns {
+		mean := 0.0
+		for _, r := range series {
+			mean += r
+		}
+		mean /= float64(t)
+		x[i] = make([]float64, t)
+		for k, r := range series {
+			x[i][k] = r - mean
+		}
+	}
+
+	sample := NewMatrix(n)
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			sample[i][j] = Dot(x[i], x[j]) / float64(t)
+			sample[j][i] = sample[i][j]
+		}
+	}
+	if n == 1 {
+		return Estimate{Covariance: sample, Observations: t}, nil
+	}
+
+	sd := make([]float64, n)
+	for i := range sd {
+		sd[i] = math.Sqrt(sample[i][i])
+	}
+
+	// Average pairwise correlation, over assets that move at all
+	meanCorr, pairs := 0.0, 0
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			if sd[i] > 0 && sd[j] > 0 {
+				meanCorr += sample[i][j] / (sd[i] * sd[j])
+				pairs++
+			}
+		}
+	}
+	if pairs > 0 {
+		meanCorr /= float64(pairs)
+	}
+
+	target := NewMatrix(n)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if i == j {
+				target[i][j] = sample[i][i]
+			} else {
+				target[i][j] = meanCorr * sd[i] * sd[j]
+			}
+		}
+	}
+
+	// pi: sum of asymptotic variances of the sample entries; rho: their
+	// covariance with the target; gamma: the target's misspecification.
+	var pi, rho, gamma float64
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			piIJ := 0.0
+			for k := 0; k < t; k++ {
+				d := x[i][k]*x[j][k] - sample[i][j]
+				piIJ += d * d
+			}
+			piIJ /= float64(t)
+			pi += piIJ
+
+			if i == j {
+				rho += piIJ
+			} else if sd[i] > 0 && sd[j] > 0 {
+				var thetaII, thetaJJ float64
+				for k := 0; k < t; k++ {
+					cross := x[i][k]*x[j][k] - sample[i][j]
+					thetaII += (x[i][k]*x[i][k] - sample[i][i]) * cross
+					thetaJJ += (x[j][k]*x[j][k] - sample[j][j]) * cross
+				}
+				thetaII /= float64(t)
+				thetaJJ /= float64(t)
+				rho += meanCorr / 2 * (sd[j]/sd[i]*thetaII + sd[i]/sd[j]*thetaJJ)
+			}
+
+			diff := target[i][j] - sample[i][j]
+			gamma += diff * diff
+		}
+	}
+
+	shrinkage := 0.0
+	if gamma > 0 {
+		shrinkage = math.Max(0, math.Min(1, (pi-rho)/gamma/float64(t)))
+	}
+
+	cov := NewMatrix(n)
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			cov[i][j] = shrinkage*target[i][j] + (1-shrinkage)*sample[i][j]
+			cov[j][i] = cov[i][j]
+		}
+	}
+	return Estimate{Covariance: cov, Shrinkage: shrinkage, Observations: t}, nil
+}
+
+// ConstantCorrelation builds a covariance matrix from volatilities and a
+// single pairwise correlation, for assets with no usable history.
+func ConstantCorrelation(vols []float64, corr float64) Matrix {
+	cov := NewMatrix(len(vols))
+	for i := range vols {
+		for j := range vols {
+			if i == j {
+				cov[i][j] = vols[i] * vols[i]
+			} else {
+				cov[i][j] = corr * vols[i] * vols[j]
+			}
+		}
+	}
+	return cov
+}
@@ -0,0 +1,117 @@
+package quant
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestShrunkCovariance(t *testing.T) {
+	tests := []struct {
+		name          string
+		returns       [][]float64
+		want          Matrix
+		wantShrinkage float64
+	}{
+		{
+			// Population variance: (1 + 1 + 9 + 9)·10⁻⁴ / 4
+			name:    "one asset",
+			returns: [][]float64{{0.01, -0.01, 0.03, -0.03}},
+			want:    Matrix{{5e-4}},
+		},
+		{
+			// Perfectly correlated series are their own constant-correlation
+			// target, so there is nothing to shrink
+			name:    "perfectly correlated",
+			returns: [][]float64{{0.01, -0.01, 0.03, -0.03}, {0.02, -0.02, 0.06, -0.06}},
+			want:    Matrix{{5e-4, 1e-3}, {1e-3, 2e-3}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			estimate, err := ShrunkCovariance(tt.returns)
+			if err != nil {
+				t.Fatalf("ShrunkCovariance() error = %v", err)
+			}
+			if estimate.Observations != len(tt.returns[0]) {
+				t.Errorf("observations = %d, want %d", estimate.Observations, len(tt.returns[0]))
+			}
+			if math.Abs(estimate.Shrinkage-tt.wantShrinkage) > 1e-12 {
+				t.Errorf("shrinkage = %v, want %v", estimate.Shrinkage, tt.wantShrinkage)
+			}
+			for i := range tt.want {
+				for j := range tt.want[i] {
+					if math.Abs(estimate.Covariance[i][j]-tt.want[i][j]) > 1e-12 {
+						t.Fatalf("covariance = %v, want %v", estimate.Covariance, tt.want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestShrunkCovarianceShrinks(t *testing.T) {
+	returns := [][]float64{
+		{0.012, -0.004, 0.007, -0.015, 0.003, 0.009, -0.006, 0.001},
+		{0.004, 0.010, -0.008, -0.002, 0.011, -0.007, 0.005, -0.003},
+		{-0.006, 0.002, 0.013, 0.004, -0.010, 0.006, 0.001, -0.009},
+	}
+	estimate, err := ShrunkCovariance(returns)
+	if err != nil {
+		t.Fatalf("ShrunkCovariance() error = %v", err)
+	}
+	if estimate.Shrinkage <= 0 || estimate.Shrinkage > 1 {
+		t.Errorf("shrinkage = %v, want it in (0, 1]", estimate.Shrinkage)
+	}
+	for i := range returns {
+		// The target keeps the sample variances, so shrinkage leaves them
+		mean, variance := 0.0, 0.0
+		for _, r := range returns[i] {
+			mean += r / float64(len(returns[i]))
+		}
+		for _, r := range returns[i] {
+			variance += (r - mean) * (r - mean) / float64(len(returns[i]))
+		}
+		if math.Abs(estimate.Covariance[i][i]-variance) > 1e-15 {
+			t.Errorf("variance %d = %v, want the sample's %v", i, estimate.Covariance[i][i], variance)
+		}
+		for j := range returns {
+			if estimate.Covariance[i][j] != estimate.Covariance[j][i] {
+				t.Fatalf("covariance is not symmetric: %v", estimate.Covariance)
+			}
+		}
+	}
+}
+
+func TestShrunkCovarianceErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		returns [][]float64
+	}{
+		{"no assets", nil},
+		{"one observation", [][]float64{{0.01}}},
+		{"different lengths", [][]float64{{0.01, 0.02}, {0.01}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ShrunkCovariance(tt.returns); err == nil {
+				t.Error("ShrunkCovariance() error = nil, want an error")
+			}
+		})
+	}
+	if _, err := ShrunkCovariance([][]float64{{0.01}}); !errors.Is(err, ErrInsufficientData) {
+		t.Errorf("one observation: error = %v, want ErrInsufficientData", err)
+	}
+}
+
+func TestConstantCorrelation(t *testing.T) {
+	got := ConstantCorrelation([]float64{0.1, 0.2}, 0.5)
+	want := Matrix{{0.01, 0.01}, {0.01, 0.04}}
+	for i := range want {
+		for j := range want[i] {
+			if math.Abs(got[i][j]-want[i][j]) > 1e-15 {
+				t.Fatalf("ConstantCorrelation() = %v, want %v", got, want)
+			}
+		}
+	}
+}
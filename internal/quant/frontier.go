@@ -0,0 +1,192 @@
+package quant
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+// Frontier and surface sizes accepted
+const (
+	MaxFrontierPoints = 50
+	MaxSurfaceLevels  = 10
+)
+
+// Tiny variance penalty when maximising return or ESG score, so ties go to
+// the least risky portfolio
+const tieBreakRisk = 1e-6
+
+// FrontierPoint is one portfolio on a frontier, with its metrics.
+type FrontierPoint struct {
+	Weights        []float64
+	ExpectedReturn float64
+	Volatility     float64
+	Sharpe         float64
+	ESGScore       float64
+}
+
+// FrontierCurve is the efficient frontier among portfolios with an ESG
+// score of at least MinESGScore.
+type FrontierCurve struct {
+	MinESGScore float64
+	Points      []FrontierPoint
+}
+
+// Frontier traces the efficient frontier of p: for target returns evenly
+// spaced from the minimum-variance portfolio's return to the highest the
+// constraints allow, the least risky portfolio that reaches each. The
+// first point is the minimum-variance portfolio. The objective's risk
+// aversion and ESG preference play no part; ESG enters through ESGSurface.
+func Frontier(p Problem, points int) ([]FrontierPoint, error) {
+	if points < 1 || points > MaxFrontierPoints {
+		return nil, fmt.Errorf("%w: a frontier has 1 to %d points", ErrInvalidProblem, MaxFrontierPoints)
+	}
+	if p.RiskAversion <= 0 {
+		p.RiskAversion = 1
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	n := len(p.ExpectedReturns)
+
+	minRisk, err := p.minimise(p.Covariance, make([]float64, n), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	negReturns := make([]float64, n)
+	for i, r := range p.ExpectedReturns {
+		negReturns[i] = -r
+	}
+	maxReturn, err := p.minimise(p.Covariance.Scale(tieBreakRisk), negReturns, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	low := Dot(minRisk.x[:n], p.ExpectedReturns)
+	high := Dot(maxReturn.x[:n], p.ExpectedReturns)
+	frontier := []FrontierPoint{p.point(minRisk.x[:n])}
+	var previous *qpSolution
+	for k := 1; k < points; k++ {
+		if k == points-1 {
+			frontier = append(frontier, p.point(maxReturn.x[:n]))
+			break
+		}
+		target := low + (high-low)*float64(k)/float64(points-1)
+		solution, err := p.minimise(p.Covariance, make([]float64, n), []Constraint{{
+			Name:         "target_return",
+			Coefficients: p.ExpectedReturns,
+			Lower:        target,
+			Upper:        math.Inf(1),
+		}}, previous)
+		if err != nil {
+			return nil, err
+		}
+		previous = solution
+		frontier = append(frontier, p.point(solution.x[:n]))
+	}
+	return frontier, nil
+}
+
+// ESGSurface traces one frontier per ESG floor, with levels floors evenly
+// spaced from the lowest ESG score on the unconstrained frontier towards
+// the highest score the constraints allow. The highest score itself is
+// left out, since only one portfolio reaches it. Together the curves show
+// how much return or risk each step up in ESG score costs.
+func ESGSurface(p Problem, points, levels int) ([]FrontierCurve, error) {
+	if levels < 2 || levels > MaxSurfaceLevels {
+		return nil, fmt.Errorf("%w: a surface has 2 to %d ESG levels", ErrInvalidProblem, MaxSurfaceLevels)
+	}
+	if len(p.ESGScores) != len(p.ExpectedReturns) {
+		return nil, fmt.Errorf("%w: a surface needs ESG scores", ErrInvalidProblem)
+	}
+	base, err := Frontier(p, points)
+	if err != nil {
+		return nil, err
+	}
+	low := math.Inf(1)
+	for _, point := range base {
+		low = math.Min(low, point.ESGScore)
+	}
+	negESG := make([]float64, len(p.ESGScores))
+	for i, s := range p.ESGScores {
+		negESG[i] = -s
+	}
+	best, err := p.minimise(p.Covariance.Scale(tieBreakRisk), negESG, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	high := Dot(best.x[:len(negESG)], p.ESGScores)
+
+	// The levels are independent problems, so they are traced in parallel
+	surface := make([]FrontierCurve, levels)
+	surface[0] = FrontierCurve{MinESGScore: low, Points: base}
+	errs := make([]error, levels)
+	var wg sync.WaitGroup
+	for j := 1; j < levels; j++ {
+		floor := low + (high-low)*float64(j)/float64(levels)
+		constrained := p
+		constrained.Constraints = append(append([]Constraint(nil), p.Constraints...), Constraint{
+			Name:         "esg_floor",
+			Coefficients: p.ESGScores,
+			Lower:        floor,
+			Upper:        math.Inf(1),
+		})
+		wg.Add(1)
+		go func(j int) {
+			defer wg.Done()
+			surface[j].MinESGScore = floor
+			surface[j].Points, errs[j] = Frontier(constrained, points)
+		}(j)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return surface, nil
+}
+
+// MaxSharpe returns the index of the point with the highest Sharpe ratio.
+func MaxSharpe(points []FrontierPoint) int {
+	best := 0
+	for i, point := range points {
+		if point.Sharpe > points[best].Sharpe {
+			best = i
+		}
+	}
+	return best
+}
+
+// minimise solves min ½·wᵀ·risk·w + linear·w under p's constraints and
+// extra, starting from warm when given. Infeasibility of p itself is
+// reported with its conflicts.
+func (p Problem) minimise(risk Matrix, linear []float64, extra []Constraint, warm *qpSolution) (*qpSolution, error) {
+	constraints := append(append([]Constraint(nil), p.Constraints...), extra...)
+	program, _ := p.program(risk, linear, constraints, p.Turnover)
+	program.warm = warm
+	solution := program.solve()
+	switch solution.status {
+	case qpInfeasible:
+		if len(extra) > 0 {
+			// A return target on the frontier is reachable by
+			// construction, so this is solver tolerance
+			return nil, ErrNotConverged
+		}
+		return nil, &InfeasibleError{Conflicts: p.conflicts()}
+	case qpMaxIterations:
+		return nil, ErrNotConverged
+	}
+	return &solution, nil
+}
+
+func (p Problem) point(weights []float64) FrontierPoint {
+	result := Evaluate(weights, p.ExpectedReturns, p.Covariance, p.ESGScores, p.RiskFreeRate)
+	return FrontierPoint{
+		Weights:        result.Weights,
+		ExpectedReturn: result.ExpectedReturn,
+		Volatility:     result.Volatility,
+		Sharpe:         result.Sharpe,
+		ESGScore:       result.ESGScore,
+	}
+}
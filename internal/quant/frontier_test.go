@@ -0,0 +1,104 @@
+package quant
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func frontierProblem() Problem {
+	return Problem{
+		ExpectedReturns: []float64{0.04, 0.06, 0.10},
+		Covariance:      diagonal(0.01, 0.02, 0.04),
+		ESGScores:       []float64{8, 5, 3},
+	}
+}
+
+func TestFrontier(t *testing.T) {
+	frontier, err := Frontier(frontierProblem(), 5)
+	if err != nil {
+		t.Fatalf("Frontier() error = %v", err)
+	}
+	if len(frontier) != 5 {
+		t.Fatalf("%d points, want 5", len(frontier))
+	}
+
+	// From minimum variance, wᵢ ∝ 1/σᵢ², to everything in the best return
+	ends := []struct {
+		name  string
+		point FrontierPoint
+		want  []float64
+	}{
+		{"first", frontier[0], []float64{4.0 / 7, 2.0 / 7, 1.0 / 7}},
+		{"last", frontier[4], []float64{0, 0, 1}},
+	}
+	for _, end := range ends {
+		for i, w := range end.point.Weights {
+			if math.Abs(w-end.want[i]) > weightTolerance {
+				t.Errorf("%s point weights = %v, want %v", end.name, end.point.Weights, end.want)
+				break
+			}
+		}
+	}
+
+	step := (frontier[4].ExpectedReturn - frontier[0].ExpectedReturn) / 4
+	for k := 1; k < len(frontier); k++ {
+		want := frontier[0].ExpectedReturn + step*float64(k)
+		if math.Abs(frontier[k].ExpectedReturn-want) > weightTolerance {
+			t.Errorf("point %d return = %v, want %v", k, frontier[k].ExpectedReturn, want)
+		}
+		if frontier[k].Volatility < frontier[k-1].Volatility-weightTolerance {
+			t.Errorf("point %d volatility %v is below point %d's %v", k, frontier[k].Volatility, k-1, frontier[k-1].Volatility)
+		}
+	}
+}
+
+func TestESGSurface(t *testing.T) {
+	p := frontierProblem()
+	surface, err := ESGSurface(p, 4, 3)
+	if err != nil {
+		t.Fatalf("ESGSurface() error = %v", err)
+	}
+	if len(surface) != 3 {
+		t.Fatalf("%d curves, want 3", len(surface))
+	}
+	for j, curve := range surface {
+		if j > 0 && curve.MinESGScore <= surface[j-1].MinESGScore {
+			t.Errorf("curve %d floor %v is not above curve %d's %v", j, curve.MinESGScore, j-1, surface[j-1].MinESGScore)
+		}
+		for k, point := range curve.Points {
+			if point.ESGScore < curve.MinESGScore-weightTolerance {
+				t.Errorf("curve %d point %d ESG score %v is below the floor %v", j, k, point.ESGScore, curve.MinESGScore)
+			}
+		}
+		// A higher floor can only cost risk at minimum variance
+		if j > 0 && curve.Points[0].Volatility < surface[j-1].Points[0].Volatility-weightTolerance {
+			t.Errorf("curve %d minimum volatility %v is below curve %d's %v",
+				j, curve.Points[0].Volatility, j-1, surface[j-1].Points[0].Volatility)
+		}
+	}
+}
+
+func TestFrontierSizes(t *testing.T) {
+	p := frontierProblem()
+	if _, err := Frontier(p, 0); !errors.Is(err, ErrInvalidProblem) {
+		t.Errorf("Frontier(0 points) error = %v, want ErrInvalidProblem", err)
+	}
+	if _, err := Frontier(p, MaxFrontierPoints+1); !errors.Is(err, ErrInvalidProblem) {
+		t.Errorf("Frontier(too many points) error = %v, want ErrInvalidProblem", err)
+	}
+	if _, err := ESGSurface(p, 4, 1); !errors.Is(err, ErrInvalidProblem) {
+		t.Errorf("ESGSurface(1 level) error = %v, want ErrInvalidProblem", err)
+	}
+	p.ESGScores = nil
+	if _, err := ESGSurface(p, 4, 3); !errors.Is(err, ErrInvalidProblem) {
+		t.Errorf("ESGSurface() without ESG scores error = %v, want ErrInvalidProblem", err)
+	}
+}
+
+func TestMaxSharpe(t *testing.T) {
+	points := []FrontierPoint{{Sharpe: 0.4}, {Sharpe: 0.7}, {Sharpe: 0.5}}
+	if got := MaxSharpe(points); got != 1 {
+		t.Errorf("MaxSharpe() = %d, want 1", got)
+	}
+}
@@ -0,0 +1,344 @@
+package quant
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Tolerance for treating a constraint as met with equality
+const bindingTolerance = 1e-6
+
+var (
+	ErrInvalidProblem = errors.New("invalid optimisation problem")
+	ErrInfeasible     = errors.New("portfolio constraints are infeasible")
+	ErrNotConverged   = errors.New("portfolio optimisation did not converge")
+)
+
+// Problem is a long-only, fully invested mean-variance problem with an ESG
+// preference:
+//
+//	maximise  μ·w − (λ/2)·wᵀΣw + γ·(s·w)/10   subject to  w ≥ 0, Σw = 1
+//
+// plus any Constraints and Turnover limit. ExpectedReturns and Covariance
+// share a period (annual, in practice) and ESGScores are on the 0-10 scale,
+// so ESGPreference is the return the investor would give up to move the
+// portfolio from an ESG score of 0 to 10.
+type Problem struct {
+	ExpectedReturns []float64
+	Covariance      Matrix
+	ESGScores       []float64
+	RiskAversion    float64
+	ESGPreference   float64
+	RiskFreeRate    float64
+	Constraints     []Constraint
+	Turnover        *TurnoverLimit
+}
+
+// Constraint is Lower ≤ Coefficients·w ≤ Upper, with ±Inf for a one-sided
+// constraint. Constraints that share a Name are one mandate rule (a
+// maximum weight applied to every asset, say) and are reported together.
+type Constraint struct {
+	Name         string
+	Coefficients []float64
+	Lower        float64
+	Upper        float64
+}
+
+// TurnoverLimit caps one-way turnover, ½·Σ|w − Current|, at Max. Outside
+// is weight currently held in assets that are not in the problem, which
+// has to be sold and so counts towards turnover.
+type TurnoverLimit struct {
+	Name    string
+	Current []float64
+	Outside float64
+	Max     float64
+}
+
+// Result is the optimal portfolio and its risk decomposition.
+// MarginalRisk is ∂σ/∂wᵢ; RiskContributions are wᵢ·∂σ/∂wᵢ / σ, the share
+// of volatility each asset is responsible for, and sum to 1. Binding names
+// the constraints that hold with equality and cost the objective something.
+type Result struct {
+	Weights           []float64
+	ExpectedReturn    float64
+	Volatility        float64
+	Sharpe            float64
+	ESGScore          float64
+	MarginalRisk      []float64
+	RiskContributions []float64
+	Binding           []string
+	Iterations        int
+}
+
+// InfeasibleError names a set of constraints that cannot hold together for
+// a long-only, fully invested portfolio, and that can once any one of them
+// is dropped.
+type InfeasibleError struct {
+	Conflicts []string
+}
+
+func (e *InfeasibleError) Error() string {
+	if len(e.Conflicts) == 0 {
+		return ErrInfeasible.Error()
+	}
+	return fmt.Sprintf("%s: no long-only, fully invested portfolio meets %s", ErrInfeasible, strings.Join(e.Conflicts, " and "))
+}
+
+func (e *InfeasibleError) Unwrap() error {
+	return ErrInfeasible
+}
+
+func (p Problem) validate() error {
+	n := len(p.ExpectedReturns)
+	switch {
+	case n == 0:
+		return fmt.Errorf("%w: no assets", ErrInvalidProblem)
+	case len(p.Covariance) != n:
+		return fmt.Errorf("%w: covariance is %dx%d for %d assets", ErrInvalidProblem, len(p.Covariance), len(p.Covariance), n)
+	case len(p.ESGScores) != 0 && len(p.ESGScores) != n:
+		return fmt.Errorf("%w: %d ESG scores for %d assets", ErrInvalidProblem, len(p.ESGScores), n)
+	case p.RiskAversion <= 0:
+		return fmt.Errorf("%w: risk aversion must be positive", ErrInvalidProblem)
+	}
+	for i, row := range p.Covariance {
+		if len(row) != n {
+			return fmt.Errorf("%w: covariance row %d has %d entries", ErrInvalidProblem, i, len(row))
+		}
+		for j, v := range row {
+			if math.IsNaN(v) || math.IsInf(v, 0) || math.Abs(v-p.Covariance[j][i]) > 1e-12*math.Max(1, math.Abs(v)) {
+				return fmt.Errorf("%w: covariance must be finite and symmetric", ErrInvalidProblem)
+			}
+		}
+	}
+	for _, c := range p.Constraints {
+		if c.Name == "" || len(c.Coefficients) != n || math.IsNaN(c.Lower) || math.IsNaN(c.Upper) || c.Lower > c.Upper {
+			return fmt.Errorf("%w: constraint %q is malformed", ErrInvalidProblem, c.Name)
+		}
+	}
+	if t := p.Turnover; t != nil && (len(t.Current) != n || t.Max < 0 || t.Outside < 0) {
+		return fmt.Errorf("%w: turnover limit is malformed", ErrInvalidProblem)
+	}
+	return nil
+}
+
+// MeanVariance solves the problem. The objective is concave and the
+// constraints linear, so the solution is the global optimum. When the
+// constraints are infeasible the error is an *InfeasibleError naming a
+// minimal conflicting set of them.
+func MeanVariance(p Problem) (Result, error) {
+	if err := p.validate(); err != nil {
+		return Result{}, err
+	}
+	n := len(p.ExpectedReturns)
+
+	// Minimise ½·wᵀ(λΣ)w − (μ + γs/10)·w
+	linear := make([]float64, n)
+	for i := range linear {
+		linear[i] = -p.ExpectedReturns[i]
+		if len(p.ESGScores) > 0 {
+			linear[i] -= p.ESGPreference * p.ESGScores[i] / 10
+		}
+	}
+	return p.solve(p.Covariance.Scale(p.RiskAversion), linear)
+}
+
+// solve minimises ½·wᵀ·risk·w + linear·w under p's constraints and
+// evaluates the optimum with its binding constraints.
+func (p Problem) solve(risk Matrix, linear []float64) (Result, error) {
+	n := len(p.ExpectedReturns)
+	program, rows := p.program(risk, linear, p.Constraints, p.Turnover)
+	solution := program.solve()
+	switch solution.status {
+	case qpInfeasible:
+		return Result{}, &InfeasibleError{Conflicts: p.conflicts()}
+	case qpMaxIterations:
+		return Result{}, ErrNotConverged
+	}
+
+	weights := solution.x[:n]
+	result := Evaluate(weights, p.ExpectedReturns, p.Covariance, p.ESGScores, p.RiskFreeRate)
+	result.Iterations = solution.iterations
+
+	// A constraint binds when it is met with equality and has a nonzero
+	// multiplier, i.e. relaxing it would improve the objective.
+	seen := make(map[string]bool)
+	for r, name := range rows {
+		if name == "" || seen[name] || math.Abs(solution.y[r]) < 1e-7 {
+			continue
+		}
+		value := Dot(program.a[r], solution.x)
+		if math.Abs(value-program.u[r]) <= bindingTolerance || math.Abs(value-program.l[r]) <= bindingTolerance {
+			seen[name] = true
+			result.Binding = append(result.Binding, name)
+		}
+	}
+	return result, nil
+}
+
+// program builds the QP over weights, plus one variable per asset bounding
+// |w − current| when turnover is limited. rows names each row of A, with
+// "" for the structural rows that are always present.
+func (p Problem) program(risk Matrix, linear []float64, constraints []Constraint, turnover *TurnoverLimit) (qp, []string) {
+	n := len(linear)
+	size := n
+	if turnover != nil {
+		size = 2 * n
+	}
+	prog := qp{p: NewMatrix(size), q: make([]float64, size)}
+	var rows []string
+	add := func(name string, coefficients []float64, lower, upper float64) {
+		row := make([]float64, size)
+		copy(row, coefficients)
+		prog.a = append(prog.a, row)
+		prog.l = append(prog.l, lower)
+		prog.u = append(prog.u, upper)
+		rows = append(rows, name)
+	}
+
+	for i := 0; i < n; i++ {
+		copy(prog.p[i][:n], risk[i])
+		prog.q[i] = linear[i]
+	}
+
+	ones := make([]float64, n)
+	for i := range ones {
+		ones[i] = 1
+	}
+	add("", ones, 1, 1)
+	for i := 0; i < n; i++ {
+		unit := make([]float64, n)
+		unit[i] = 1
+		add("", unit, 0, 1)
+	}
+	for _, c := range constraints {
+		add(c.Name, c.Coefficients, c.Lower, c.Upper)
+	}
+
+	if turnover != nil {
+		// tᵢ ≥ |wᵢ − currentᵢ| and Σt ≤ 2·Max − Outside
+		total := make([]float64, size)
+		for i := 0; i < n; i++ {
+			above := make([]float64, size)
+			above[i], above[n+i] = -1, 1
+			add("", above, -turnover.Current[i], math.Inf(1))
+			below := make([]float64, size)
+			below[i], below[n+i] = 1, 1
+			add("", below, turnover.Current[i], math.Inf(1))
+			total[n+i] = 1
+		}
+		name := turnover.Name
+		if name == "" {
+			name = "max_turnover"
+		}
+		add(name, total, math.Inf(-1), 2*turnover.Max-turnover.Outside)
+	}
+	return prog, rows
+}
+
+// conflicts finds a minimal infeasible set of named constraints with a
+// deletion filter: drop each name in turn and keep it dropped if what is
+// left is still infeasible.
+func (p Problem) conflicts() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, c := range p.Constraints {
+		if !seen[c.Name] {
+			seen[c.Name] = true
+			names = append(names, c.Name)
+		}
+	}
+	turnover := p.Turnover != nil
+
+	feasible := func(kept map[string]bool, withTurnover bool) bool {
+		var constraints []Constraint
+		for _, c := range p.Constraints {
+			if kept[c.Name] {
+				constraints = append(constraints, c)
+			}
+		}
+		var limit *TurnoverLimit
+		if withTurnover {
+			limit = p.Turnover
+		}
+		n := len(p.ExpectedReturns)
+		program, _ := p.program(NewMatrix(n), make([]float64, n), constraints, limit)
+		// Undecided counts as feasible, so nothing is blamed wrongly
+		return program.solve().status != qpInfeasible
+	}
+
+	kept := make(map[string]bool, len(names))
+	for _, name := range names {
+		kept[name] = true
+	}
+	for _, name := range names {
+		kept[name] = false
+		if feasible(kept, turnover) {
+			kept[name] = true
+		}
+	}
+	if turnover && !feasible(kept, false) {
+		turnover = false
+	}
+
+	var conflicts []string
+	for _, name := range names {
+		if kept[name] {
+			conflicts = append(conflicts, name)
+		}
+	}
+	if turnover {
+		name := p.Turnover.Name
+		if name == "" {
+			name = "max_turnover"
+		}
+		conflicts = append(conflicts, name)
+	}
+	return conflicts
+}
+
+// Evaluate computes the metrics and risk decomposition of given weights.
+func Evaluate(weights, expectedReturns []float64, cov Matrix, esgScores []float64, riskFreeRate float64) Result {
+	n := len(weights)
+	result := Result{
+		Weights:           cleanWeights(weights),
+		MarginalRisk:      make([]float64, n),
+		RiskContributions: make([]float64, n),
+	}
+	result.ExpectedReturn = Dot(result.Weights, expectedReturns)
+	if len(esgScores) == n {
+		result.ESGScore = Dot(result.Weights, esgScores)
+	}
+
+	sigmaW := cov.MulVec(result.Weights)
+	variance := Dot(result.Weights, sigmaW)
+	if variance <= 0 {
+		return result
+	}
+	result.Volatility = math.Sqrt(variance)
+	result.Sharpe = (result.ExpectedReturn - riskFreeRate) / result.Volatility
+	for i := range weights {
+		result.MarginalRisk[i] = sigmaW[i] / result.Volatility
+		result.RiskContributions[i] = result.Weights[i] * result.MarginalRisk[i] / result.Volatility
+	}
+	return result
+}
+
+// cleanWeights zeroes numerical dust and renormalises to sum to 1.
+func cleanWeights(weights []float64) []float64 {
+	out := make([]float64, len(weights))
+	sum := 0.0
+	for i, w := range weights {
+		if w > 1e-9 {
+			out[i] = w
+			sum += w
+		}
+	}
+	if sum > 0 {
+		for i := range out {
+			out[i] /= sum
+		}
+	}
+	return out
+}
@@ -0,0 +1,262 @@
+package quant
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+const weightTolerance = 1e-6
+
+func diagonal(variances ...float64) Matrix {
+	m := NewMatrix(len(variances))
+	for i, v := range variances {
+		m[i][i] = v
+	}
+	return m
+}
+
+func everyAsset(name string, n int, lower, upper float64) []Constraint {
+	constraints := make([]Constraint, n)
+	for i := range constraints {
+		coefficients := make([]float64, n)
+		coefficients[i] = 1
+		constraints[i] = Constraint{Name: name, Coefficients: coefficients, Lower: lower, Upper: upper}
+	}
+	return constraints
+}
+
+func TestMeanVariance(t *testing.T) {
+	tests := []struct {
+		name        string
+		problem     Problem
+		wantWeights []float64
+		wantBinding []string
+	}{
+		{
+			// Minimum variance with uncorrelated assets: wᵢ ∝ 1/σᵢ²
+			name: "minimum variance, uncorrelated",
+			problem: Problem{
+				ExpectedReturns: []float64{0, 0, 0},
+				Covariance:      diagonal(0.01, 0.02, 0.04),
+				RiskAversion:    1,
+			},
+			wantWeights: []float64{4.0 / 7, 2.0 / 7, 1.0 / 7},
+		},
+		{
+			// w₁ = (σ₂² − σ₁₂) / (σ₁² + σ₂² − 2σ₁₂) = 0.084 / 0.118
+			name: "minimum variance, correlated pair",
+			problem: Problem{
+				ExpectedReturns: []float64{0, 0},
+				Covariance:      Matrix{{0.04, 0.006}, {0.006, 0.09}},
+				RiskAversion:    1,
+			},
+			wantWeights: []float64{0.084 / 0.118, 0.034 / 0.118},
+		},
+		{
+			// λΣw = μ + ν·1 gives w₁ − w₂ = (0.10 − 0.06) / (2 · 0.04)
+			name: "return tilt",
+			problem: Problem{
+				ExpectedReturns: []float64{0.10, 0.06},
+				Covariance:      diagonal(0.04, 0.04),
+				RiskAversion:    2,
+			},
+			wantWeights: []float64{0.75, 0.25},
+		},
+		{
+			// The ESG term adds γ·s/10 to the returns: w₂ − w₁ = 0.024 / 0.08
+			name: "ESG tilt",
+			problem: Problem{
+				ExpectedReturns: []float64{0.08, 0.08},
+				Covariance:      diagonal(0.04, 0.04),
+				ESGScores:       []float64{2, 8},
+				RiskAversion:    2,
+				ESGPreference:   0.04,
+			},
+			wantWeights: []float64{0.35, 0.65},
+		},
+		{
+			// Unconstrained, w₁ − w₂ = 2.5; long-only holds the corner
+			name: "long only",
+			problem: Problem{
+				ExpectedReturns: []float64{0.20, 0},
+				Covariance:      diagonal(0.04, 0.04),
+				RiskAversion:    2,
+			},
+			wantWeights: []float64{1, 0},
+		},
+		{
+			// Minimum variance wants 0.8 of the second asset
+			name: "max weight",
+			problem: Problem{
+				ExpectedReturns: []float64{0, 0},
+				Covariance:      diagonal(0.04, 0.01),
+				RiskAversion:    1,
+				Constraints:     everyAsset("max_weight", 2, math.Inf(-1), 0.6),
+			},
+			wantWeights: []float64{0.4, 0.6},
+			wantBinding: []string{"max_weight"},
+		},
+		{
+			// Minimum variance puts 6/7 in the first two; capped at half,
+			// they split it 2:1 as before
+			name: "weight sum cap",
+			problem: Problem{
+				ExpectedReturns: []float64{0, 0, 0},
+				Covariance:      diagonal(0.01, 0.02, 0.04),
+				RiskAversion:    1,
+				Constraints: []Constraint{
+					{Name: "sector_cap", Coefficients: []float64{1, 1, 0}, Lower: math.Inf(-1), Upper: 0.5},
+					{Name: "loose_cap", Coefficients: []float64{0, 0, 1}, Lower: math.Inf(-1), Upper: 0.9},
+				},
+			},
+			wantWeights: []float64{1.0 / 3, 1.0 / 6, 0.5},
+			wantBinding: []string{"sector_cap"},
+		},
+		{
+			// Halfway from holding only the first asset to minimum variance's
+			// 0.2 of it
+			name: "turnover",
+			problem: Problem{
+				ExpectedReturns: []float64{0, 0},
+				Covariance:      diagonal(0.04, 0.01),
+				RiskAversion:    1,
+				Turnover:        &TurnoverLimit{Current: []float64{1, 0}, Max: 0.4},
+			},
+			wantWeights: []float64{0.6, 0.4},
+			wantBinding: []string{"max_turnover"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := MeanVariance(tt.problem)
+			if err != nil {
+				t.Fatalf("MeanVariance() error = %v", err)
+			}
+			sum := 0.0
+			for i, w := range result.Weights {
+				sum += w
+				if math.Abs(w-tt.wantWeights[i]) > weightTolerance {
+					t.Errorf("weights = %v, want %v", result.Weights, tt.wantWeights)
+					break
+				}
+			}
+			if math.Abs(sum-1) > 1e-9 {
+				t.Errorf("weights add up to %v, want 1", sum)
+			}
+			if !reflect.DeepEqual(result.Binding, tt.wantBinding) {
+				t.Errorf("binding = %v, want %v", result.Binding, tt.wantBinding)
+			}
+		})
+	}
+}
+
+func TestMeanVarianceRiskContributions(t *testing.T) {
+	result, err := MeanVariance(Problem{
+		ExpectedReturns: []float64{0, 0, 0},
+		Covariance:      diagonal(0.01, 0.02, 0.04),
+		RiskAversion:    1,
+	})
+	if err != nil {
+		t.Fatalf("MeanVariance() error = %v", err)
+	}
+	// At minimum variance every asset's marginal risk is the same, so each
+	// contributes its weight
+	for i, contribution := range result.RiskContributions {
+		if math.Abs(contribution-result.Weights[i]) > weightTolerance {
+			t.Errorf("risk contributions = %v, want the weights %v", result.RiskContributions, result.Weights)
+			break
+		}
+	}
+	// σ² = 1 / Σ(1/σᵢ²) = 1/175
+	if want := math.Sqrt(1.0 / 175); math.Abs(result.Volatility-want) > weightTolerance {
+		t.Errorf("volatility = %v, want %v", result.Volatility, want)
+	}
+}
+
+func TestMeanVarianceInfeasible(t *testing.T) {
+	base := Problem{
+		ExpectedReturns: []float64{0.05, 0.06, 0.07},
+		Covariance:      diagonal(0.04, 0.04, 0.04),
+		RiskAversion:    1,
+	}
+	tests := []struct {
+		name        string
+		constraints []Constraint
+		turnover    *TurnoverLimit
+		want        []string
+	}{
+		{
+			name:        "max weight below 1/n",
+			constraints: everyAsset("max_weight", 3, math.Inf(-1), 0.3),
+			want:        []string{"max_weight"},
+		},
+		{
+			name: "ESG floor above every score",
+			constraints: []Constraint{
+				{Name: "sector_cap", Coefficients: []float64{1, 0, 0}, Lower: math.Inf(-1), Upper: 0.5},
+				{Name: "min_esg", Coefficients: []float64{2, 5, 6}, Lower: 8, Upper: math.Inf(1)},
+			},
+			want: []string{"min_esg"},
+		},
+		{
+			name: "two rules in conflict",
+			constraints: []Constraint{
+				{Name: "min_green", Coefficients: []float64{1, 0, 0}, Lower: 0.6, Upper: math.Inf(1)},
+				{Name: "loose_cap", Coefficients: []float64{0, 0, 1}, Lower: math.Inf(-1), Upper: 0.9},
+				{Name: "sector_cap", Coefficients: []float64{1, 1, 0}, Lower: math.Inf(-1), Upper: 0.5},
+			},
+			want: []string{"min_green", "sector_cap"},
+		},
+		{
+			name: "turnover",
+			constraints: []Constraint{
+				{Name: "cap_first", Coefficients: []float64{1, 0, 0}, Lower: math.Inf(-1), Upper: 0.5},
+			},
+			turnover: &TurnoverLimit{Current: []float64{1, 0, 0}, Max: 0.1},
+			want:     []string{"cap_first", "max_turnover"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := base
+			p.Constraints = tt.constraints
+			p.Turnover = tt.turnover
+			_, err := MeanVariance(p)
+			if !errors.Is(err, ErrInfeasible) {
+				t.Fatalf("MeanVariance() error = %v, want ErrInfeasible", err)
+			}
+			var infeasible *InfeasibleError
+			if !errors.As(err, &infeasible) {
+				t.Fatalf("MeanVariance() error = %T, want *InfeasibleError", err)
+			}
+			if !reflect.DeepEqual(infeasible.Conflicts, tt.want) {
+				t.Errorf("conflicts = %v, want %v", infeasible.Conflicts, tt.want)
+			}
+		})
+	}
+}
+
+func TestMeanVarianceInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		problem Problem
+	}{
+		{"no assets", Problem{RiskAversion: 1}},
+		{"covariance size", Problem{ExpectedReturns: []float64{0, 0}, Covariance: diagonal(0.01), RiskAversion: 1}},
+		{"asymmetric covariance", Problem{ExpectedReturns: []float64{0, 0}, Covariance: Matrix{{0.01, 0.002}, {0, 0.01}}, RiskAversion: 1}},
+		{"risk aversion", Problem{ExpectedReturns: []float64{0}, Covariance: diagonal(0.01)}},
+		{"unnamed constraint", Problem{
+			ExpectedReturns: []float64{0}, Covariance: diagonal(0.01), RiskAversion: 1,
+			Constraints: []Constraint{{Coefficients: []float64{1}, Upper: 1}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := MeanVariance(tt.problem); !errors.Is(err, ErrInvalidProblem) {
+				t.Errorf("MeanVariance() error = %v, want ErrInvalidProblem", err)
+			}
+		})
+	}
+}
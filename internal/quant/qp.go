@@ -0,0 +1,399 @@
+package quant
+
+import (
+	"math"
+)
+
+// The solver is ADMM for quadratic programs as in OSQP (Stellato et al.,
+// 2020): minimise ½xᵀPx + qᵀx subject to l ≤ Ax ≤ u, with over-relaxation,
+// adaptive step size and detection of primal infeasibility. Problems here
+// are small and dense, so the linear system is solved by Cholesky.
+const (
+	admmSigma       = 1e-6
+	admmAlpha       = 1.6
+	admmRho         = 0.1
+	admmEqualityRho = 1e3 // multiplier for equality rows
+	admmEpsAbs      = 1e-9
+	admmEpsRel      = 1e-9
+	admmEpsInfeas   = 1e-8
+	admmMaxIter     = 200000
+	admmAdaptEvery  = 50
+	admmPolishEvery = 25
+	polishDelta     = 1e-7
+	polishTolerance = 1e-9
+)
+
+type qpStatus int
+
+const (
+	qpSolved qpStatus = iota
+	qpInfeasible
+	qpMaxIterations
+)
+
+type qp struct {
+	p    Matrix
+	q    []float64
+	a    [][]float64
+	l, u []float64
+	// warm is a solution to a neighbouring problem of the same shape to
+	// start from, which saves most of the iterations along a frontier
+	warm *qpSolution
+}
+
+type qpSolution struct {
+	status     qpStatus
+	x, z, y    []float64
+	rho        float64
+	iterations int
+}
+
+func (s qp) solve() qpSolution {
+	n, m := len(s.q), len(s.a)
+	x := make([]float64, n)
+	z := make([]float64, m)
+	y := make([]float64, m)
+
+	rho := admmRho
+	if w := s.warm; w != nil && len(w.x) == n && len(w.z) == m {
+		copy(x, w.x)
+		copy(z, w.z)
+		copy(y, w.y)
+		rho = w.rho
+	}
+	rhoVec := make([]float64, m)
+	factor := func() Matrix {
+		for i := range rhoVec {
+			rhoVec[i] = rho
+			if s.l[i] == s.u[i] {
+				rhoVec[i] = rho * admmEqualityRho
+			}
+		}
+		k := NewMatrix(n)
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				if s.p != nil {
+					k[i][j] = s.p[i][j]
+				}
+			}
+			k[i][i] += admmSigma
+		}
+		for r, row := range s.a {
+			for i, ai := range row {
+				if ai == 0 {
+					continue
+				}
+				for j, aj := range row {
+					k[i][j] += rhoVec[r] * ai * aj
+				}
+			}
+		}
+		return cholesky(k)
+	}
+	chol := factor()
+
+	rhs := make([]float64, n)
+	for iter := 1; iter <= admmMaxIter; iter++ {
+		for i := range rhs {
+			rhs[i] = admmSigma*x[i] - s.q[i]
+		}
+		for r, row := range s.a {
+			coef := rhoVec[r]*z[r] - y[r]
+			for i, v := range row {
+				rhs[i] += v * coef
+			}
+		}
+		xTilde := cholSolve(chol, rhs)
+
+		dy := make([]float64, m)
+		for i := range x {
+			x[i] = admmAlpha*xTilde[i] + (1-admmAlpha)*x[i]
+		}
+		for r, row := range s.a {
+			relaxed := admmAlpha*Dot(row, xTilde) + (1-admmAlpha)*z[r]
+			next := math.Max(s.l[r], math.Min(s.u[r], relaxed+y[r]/rhoVec[r]))
+			dy[r] = rhoVec[r] * (relaxed - next)
+			y[r] += dy[r]
+			z[r] = next
+		}
+
+		// Residuals
+		ax := make([]float64, m)
+		primal, normAx, normZ := 0.0, 0.0, 0.0
+		for r, row := range s.a {
+			ax[r] = Dot(row, x)
+			primal = math.Max(primal, math.Abs(ax[r]-z[r]))
+			normAx = math.Max(normAx, math.Abs(ax[r]))
+			normZ = math.Max(normZ, math.Abs(z[r]))
+		}
+		px := make([]float64, n)
+		if s.p != nil {
+			px = s.p.MulVec(x)
+		}
+		aty := transposeMul(s.a, y, n)
+		dual, normPx, normATy, normQ := 0.0, 0.0, 0.0, 0.0
+		for i := range x {
+			dual = math.Max(dual, math.Abs(px[i]+s.q[i]+aty[i]))
+			normPx = math.Max(normPx, math.Abs(px[i]))
+			normATy = math.Max(normATy, math.Abs(aty[i]))
+			normQ = math.Max(normQ, math.Abs(s.q[i]))
+		}
+		primalScale := math.Max(normAx, normZ)
+		dualScale := math.Max(normPx, math.Max(normATy, normQ))
+		if primal <= admmEpsAbs+admmEpsRel*primalScale && dual <= admmEpsAbs+admmEpsRel*dualScale {
+			return qpSolution{status: qpSolved, x: x, z: z, y: y, rho: rho, iterations: iter}
+		}
+		if s.infeasible(dy, n) {
+			return qpSolution{status: qpInfeasible, iterations: iter}
+		}
+		if iter%admmPolishEvery == 0 && primal <= 1e-4*math.Max(1, primalScale) && dual <= 1e-4*math.Max(1, dualScale) {
+			if px, py, ok := s.polish(z, y); ok {
+				pz := make([]float64, m)
+				for r, row := range s.a {
+					pz[r] = math.Max(s.l[r], math.Min(s.u[r], Dot(row, px)))
+				}
+				return qpSolution{status: qpSolved, x: px, z: pz, y: py, rho: rho, iterations: iter}
+			}
+		}
+
+		if iter%admmAdaptEvery == 0 {
+			ratio := math.Sqrt((primal / math.Max(primalScale, 1e-12)) / math.Max(dual/math.Max(dualScale, 1e-12), 1e-12))
+			if ratio > 5 || ratio < 0.2 {
+				rho = math.Max(1e-6, math.Min(1e6, rho*ratio))
+				chol = factor()
+			}
+		}
+	}
+	return qpSolution{status: qpMaxIterations, x: x, z: z, y: y, rho: rho, iterations: admmMaxIter}
+}
+
+// polish guesses the active constraints from an approximate solution and
+// solves the KKT system for them exactly, as OSQP does. ADMM reaches the
+// right active set long before it reaches high accuracy, so this returns
+// an exact solution many iterations early. The result is only accepted if
+// it is feasible and optimal to polishTolerance.
+func (s qp) polish(z, y []float64) ([]float64, []float64, bool) {
+	n := len(s.q)
+	var active []int
+	var bounds []float64
+	for r := range s.a {
+		switch {
+		case z[r]-s.l[r] < -y[r]:
+			active = append(active, r)
+			bounds = append(bounds, s.l[r])
+		case s.u[r]-z[r] < y[r]:
+			active = append(active, r)
+			bounds = append(bounds, s.u[r])
+		}
+	}
+
+	// [P A'; A 0] with δ on the diagonal for solvability, refined
+	// against the exact system
+	size := n + len(active)
+	exact := NewMatrix(size)
+	for i := 0; i < n; i++ {
+		if s.p != nil {
+			copy(exact[i][:n], s.p[i])
+		}
+	}
+	for k, r := range active {
+		for i, v := range s.a[r] {
+			exact[n+k][i] = v
+			exact[i][n+k] = v
+		}
+	}
+	regularised := NewMatrix(size)
+	for i := range exact {
+		copy(regularised[i], exact[i])
+		if i < n {
+			regularised[i][i] += polishDelta
+		} else {
+			regularised[i][i] -= polishDelta
+		}
+	}
+	lu, pivots, ok := luDecompose(regularised)
+	if !ok {
+		return nil, nil, false
+	}
+	rhs := make([]float64, size)
+	for i := 0; i < n; i++ {
+		rhs[i] = -s.q[i]
+	}
+	copy(rhs[n:], bounds)
+	sol := luSolve(lu, pivots, rhs)
+	for refine := 0; refine < 5; refine++ {
+		residual := exact.MulVec(sol)
+		for i := range residual {
+			residual[i] = rhs[i] - residual[i]
+		}
+		step := luSolve(lu, pivots, residual)
+		for i := range sol {
+			sol[i] += step[i]
+		}
+	}
+
+	x := sol[:n]
+	full := make([]float64, len(s.a))
+	for k, r := range active {
+		full[r] = sol[n+k]
+	}
+	for r, row := range s.a {
+		value := Dot(row, x)
+		if value < s.l[r]-polishTolerance || value > s.u[r]+polishTolerance {
+			return nil, nil, false
+		}
+		// Multipliers push only against the bound that is active
+		if (full[r] > polishTolerance && s.u[r]-value > polishTolerance) || (full[r] < -polishTolerance && value-s.l[r] > polishTolerance) {
+			return nil, nil, false
+		}
+	}
+	px := make([]float64, n)
+	if s.p != nil {
+		px = s.p.MulVec(x)
+	}
+	aty := transposeMul(s.a, full, n)
+	for i := range x {
+		if math.Abs(px[i]+s.q[i]+aty[i]) > polishTolerance {
+			return nil, nil, false
+		}
+	}
+	return append([]float64(nil), x...), full, true
+}
+
+// luDecompose factors m in place-style into a copy with partial pivoting.
+func luDecompose(m Matrix) (Matrix, []int, bool) {
+	n := len(m)
+	lu := NewMatrix(n)
+	for i := range m {
+		copy(lu[i], m[i])
+	}
+	pivots := make([]int, n)
+	for k := 0; k < n; k++ {
+		pivot := k
+		for i := k + 1; i < n; i++ {
+			if math.Abs(lu[i][k]) > math.Abs(lu[pivot][k]) {
+				pivot = i
+			}
+		}
+		if math.Abs(lu[pivot][k]) < 1e-14 {
+			return nil, nil, false
+		}
+		pivots[k] = pivot
+		lu[k], lu[pivot] = lu[pivot], lu[k]
+		for i := k + 1; i < n; i++ {
+			lu[i][k] /= lu[k][k]
+			for j := k + 1; j < n; j++ {
+				lu[i][j] -= lu[i][k] * lu[k][j]
+			}
+		}
+	}
+	return lu, pivots, true
+}
+
+func luSolve(lu Matrix, pivots []int, b []float64) []float64 {
+	n := len(lu)
+	x := append([]float64(nil), b...)
+	for k := 0; k < n; k++ {
+		x[k], x[pivots[k]] = x[pivots[k]], x[k]
+	}
+	for i := 0; i < n; i++ {
+		for j := 0; j < i; j++ {
+			x[i] -= lu[i][j] * x[j]
+		}
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := i + 1; j < n; j++ {
+			x[i] -= lu[i][j] * x[j]
+		}
+		x[i] /= lu[i][i]
+	}
+	return x
+}
+
+// infeasible reports whether the change in y certifies that no x satisfies
+// l ≤ Ax ≤ u: Aᵀδy ≈ 0 with uᵀδy⁺ + lᵀδy⁻ < 0.
+func (s qp) infeasible(dy []float64, n int) bool {
+	norm := 0.0
+	for _, v := range dy {
+		norm = math.Max(norm, math.Abs(v))
+	}
+	if norm < 1e-12 {
+		return false
+	}
+	for _, v := range transposeMul(s.a, dy, n) {
+		if math.Abs(v) > admmEpsInfeas*norm {
+			return false
+		}
+	}
+	support := 0.0
+	for r, v := range dy {
+		switch {
+		case v > 0:
+			if math.IsInf(s.u[r], 1) {
+				return false
+			}
+			support += s.u[r] * v
+		case v < 0:
+			if math.IsInf(s.l[r], -1) {
+				return false
+			}
+			support += s.l[r] * v
+		}
+	}
+	return support < -admmEpsInfeas*norm
+}
+
+func transposeMul(a [][]float64, v []float64, n int) []float64 {
+	out := make([]float64, n)
+	for r, row := range a {
+		if v[r] == 0 {
+			continue
+		}
+		for i, x := range row {
+			out[i] += x * v[r]
+		}
+	}
+	return out
+}
+
+// cholesky returns the lower-triangular L with LLᵀ = k, for k symmetric
+// positive definite.
+func cholesky(k Matrix) Matrix {
+	n := len(k)
+	l := NewMatrix(n)
+	for i := 0; i < n; i++ {
+		for j := 0; j <= i; j++ {
+			sum := k[i][j]
+			for p := 0; p < j; p++ {
+				sum -= l[i][p] * l[j][p]
+			}
+			if i == j {
+				l[i][i] = math.Sqrt(math.Max(sum, 1e-18))
+			} else {
+				l[i][j] = sum / l[j][j]
+			}
+		}
+	}
+	return l
+}
+
+func cholSolve(l Matrix, b []float64) []float64 {
+	n := len(l)
+	y := make([]float64, n)
+	for i := 0; i < n; i++ {
+		sum := b[i]
+		for p := 0; p < i; p++ {
+			sum -= l[i][p] * y[p]
+		}
+		y[i] = sum / l[i][i]
+	}
+	x := make([]float64, n)
+	for i := n - 1; i >= 0; i-- {
+		sum := y[i]
+		for p := i + 1; p < n; p++ {
+			sum -= l[p][i] * x[p]
+		}
+		x[i] = sum / l[i][i]
+	}
+	return x
+}
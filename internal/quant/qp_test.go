@@ -0,0 +1,94 @@
+package quant
+
+import (
+	"math"
+	"testing"
+)
+
+func TestQPSolve(t *testing.T) {
+	tests := []struct {
+		name   string
+		prog   qp
+		status qpStatus
+		want   []float64
+	}{
+		{
+			// min ½x² − x has its minimum at 1, beyond the upper bound
+			name: "bound clips the minimum",
+			prog: qp{
+				p: Matrix{{1}}, q: []float64{-1},
+				a: [][]float64{{1}}, l: []float64{0}, u: []float64{0.5},
+			},
+			status: qpSolved,
+			want:   []float64{0.5},
+		},
+		{
+			name: "interior minimum",
+			prog: qp{
+				p: Matrix{{2, 0}, {0, 2}}, q: []float64{-2, -4},
+				a: [][]float64{{1, 0}, {0, 1}}, l: []float64{-10, -10}, u: []float64{10, 10},
+			},
+			status: qpSolved,
+			want:   []float64{1, 2},
+		},
+		{
+			// min x² + y² on x + y = 1 is x = y = ½
+			name: "equality",
+			prog: qp{
+				p: Matrix{{2, 0}, {0, 2}}, q: []float64{0, 0},
+				a: [][]float64{{1, 1}}, l: []float64{1}, u: []float64{1},
+			},
+			status: qpSolved,
+			want:   []float64{0.5, 0.5},
+		},
+		{
+			name: "linear objective on a box",
+			prog: qp{
+				p: NewMatrix(2), q: []float64{1, -1},
+				a: [][]float64{{1, 0}, {0, 1}}, l: []float64{0, 0}, u: []float64{1, 1},
+			},
+			status: qpSolved,
+			want:   []float64{0, 1},
+		},
+		{
+			name: "x ≥ 1 and x ≤ 0",
+			prog: qp{
+				p: Matrix{{1}}, q: []float64{0},
+				a: [][]float64{{1}, {1}}, l: []float64{1, math.Inf(-1)}, u: []float64{math.Inf(1), 0},
+			},
+			status: qpInfeasible,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			solution := tt.prog.solve()
+			if solution.status != tt.status {
+				t.Fatalf("status = %v, want %v", solution.status, tt.status)
+			}
+			for i, want := range tt.want {
+				if math.Abs(solution.x[i]-want) > 1e-6 {
+					t.Errorf("x = %v, want %v", solution.x, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestCholeskySolve(t *testing.T) {
+	k := Matrix{{4, 2}, {2, 3}}
+	l := cholesky(k)
+	want := Matrix{{2, 0}, {1, math.Sqrt(2)}}
+	for i := range want {
+		for j := range want[i] {
+			if math.Abs(l[i][j]-want[i][j]) > 1e-12 {
+				t.Fatalf("cholesky = %v, want %v", l, want)
+			}
+		}
+	}
+	// 4x + 2y = 8, 2x + 3y = 8
+	x := cholSolve(l, []float64{8, 8})
+	if math.Abs(x[0]-1) > 1e-12 || math.Abs(x[1]-2) > 1e-12 {
+		t.Errorf("cholSolve = %v, want [1 2]", x)
+	}
+}
@@ -0,0 +1,43 @@
+package quant
+
+import (
+	"fmt"
+	"math"
+)
+
+// Tracking finds the portfolio closest to target, by tracking error, that
+// meets p's constraints and turnover limit:
+//
+//	minimise  ½·(w − t)ᵀΣ(w − t)  subject to  w ≥ 0, Σw = 1
+//
+// When target itself is allowed the result is target. The objective's
+// expected returns, risk aversion and ESG preference play no part; the
+// returns and ESG scores are only used to report on the result.
+func Tracking(p Problem, target []float64) (Result, error) {
+	if p.RiskAversion <= 0 {
+		p.RiskAversion = 1
+	}
+	if err := p.validate(); err != nil {
+		return Result{}, err
+	}
+	if len(target) != len(p.ExpectedReturns) {
+		return Result{}, fmt.Errorf("%w: %d target weights for %d assets", ErrInvalidProblem, len(target), len(p.ExpectedReturns))
+	}
+
+	// ½·(w − t)ᵀΣ(w − t) is ½·wᵀΣw − (Σt)·w plus a constant
+	linear := p.Covariance.MulVec(target)
+	for i := range linear {
+		linear[i] = -linear[i]
+	}
+	return p.solve(p.Covariance, linear)
+}
+
+// TrackingError returns the volatility of the difference between two
+// portfolios, √((w − t)ᵀΣ(w − t)), in the period of cov.
+func TrackingError(cov Matrix, weights, target []float64) float64 {
+	active := make([]float64, len(weights))
+	for i := range weights {
+		active[i] = weights[i] - target[i]
+	}
+	return math.Sqrt(math.Max(0, cov.Quad(active)))
+}
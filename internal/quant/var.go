@@ -0,0 +1,184 @@
+package quant
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Defaults for VaRParams fields left at zero
+const (
+	DefaultConfidence  = 0.99
+	DefaultSimulations = 10000
+)
+
+// VaRParams set how value at risk is measured. Horizon is in trading days;
+// longer horizons compound daily returns. Monte Carlo draws Simulations
+// scenarios from a generator seeded with Seed, so a measurement can be
+// repeated exactly.
+type VaRParams struct {
+	Confidence  float64
+	Horizon     int
+	Simulations int
+	Seed        int64
+}
+
+// TailRisk is a loss over the horizon as a fraction of the value held,
+// positive for a loss. VaR is the loss exceeded with probability
+// 1 − confidence and ExpectedShortfall the average loss beyond it.
+type TailRisk struct {
+	VaR               float64
+	ExpectedShortfall float64
+}
+
+// MarketRisk is value at risk and expected shortfall measured three ways:
+//
+//   - historical: from the portfolio's own returns over every overlapping
+//     horizon in the history, nil when the history has too few of them to
+//     reach the tail;
+//   - parametric: assuming normal returns with the history's mean and the
+//     shrunk covariance's volatility;
+//   - Monte Carlo: from simulated daily returns with that mean and
+//     covariance, compounded over the horizon.
+//
+// Volatility is annual and, like the losses, a fraction.
+type MarketRisk struct {
+	Confidence   float64
+	Horizon      int
+	Observations int
+	Volatility   float64
+	Historical   *TailRisk
+	Parametric   TailRisk
+	MonteCarlo   TailRisk
+}
+
+// ValueAtRisk measures the market risk of holding weights in assets with
+// the given daily returns, one series per asset and all of the same
+// length. Weights are scaled to add up to 1 and held constant over the
+// horizon.
+func ValueAtRisk(returns [][]float64, weights []float64, p VaRParams) (MarketRisk, error) {
+	n := len(returns)
+	if n == 0 || len(weights) != n {
+		return MarketRisk{}, fmt.Errorf("%d return series for %d weights", n, len(weights))
+	}
+	if p.Confidence == 0 {
+		p.Confidence = DefaultConfidence
+	}
+	if p.Horizon == 0 {
+		p.Horizon = 1
+	}
+	if p.Simulations == 0 {
+		p.Simulations = DefaultSimulations
+	}
+	if p.Confidence <= 0.5 || p.Confidence >= 1 || p.Horizon < 0 || p.Simulations < 0 {
+		return MarketRisk{}, fmt.Errorf("confidence must be between 0.5 and 1, horizon and simulations positive")
+	}
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	if total <= 0 {
+		return MarketRisk{}, fmt.Errorf("weights must add up to more than 0")
+	}
+	w := make([]float64, n)
+	for i := range weights {
+		w[i] = weights[i] / total
+	}
+
+	estimate, err := ShrunkCovariance(returns)
+	if err != nil {
+		return MarketRisk{}, err
+	}
+	t := estimate.Observations
+	if t <= p.Horizon {
+		return MarketRisk{}, fmt.Errorf("%w: %d days of returns for a %d-day horizon", ErrInsufficientData, t, p.Horizon)
+	}
+	means := make([]float64, n)
+	for i, series := range returns {
+		for _, r := range series {
+			means[i] += r
+		}
+		means[i] /= float64(t)
+	}
+	variance := estimate.Covariance.Quad(w)
+	risk := MarketRisk{
+		Confidence:   p.Confidence,
+		Horizon:      p.Horizon,
+		Observations: t,
+		Volatility:   math.Sqrt(variance * PeriodsPerYear),
+	}
+
+	// Historical, over overlapping horizons of the portfolio's returns
+	daily := make([]float64, t)
+	for k := range daily {
+		for i := range returns {
+			daily[k] += w[i] * returns[i][k]
+		}
+	}
+	windows := t - p.Horizon + 1
+	if float64(windows)*(1-p.Confidence) >= 1 {
+		losses := make([]float64, windows)
+		for k := range losses {
+			growth := 1.0
+			for _, r := range daily[k : k+p.Horizon] {
+				growth *= 1 + r
+			}
+			losses[k] = 1 - growth
+		}
+		historical := tail(losses, p.Confidence)
+		risk.Historical = &historical
+	}
+
+	// Parametric, with daily moments scaled to the horizon
+	h := float64(p.Horizon)
+	mean := Dot(w, means) * h
+	sd := math.Sqrt(variance * h)
+	z := math.Sqrt2 * math.Erfinv(2*p.Confidence-1)
+	density := math.Exp(-z*z/2) / math.Sqrt(2*math.Pi)
+	risk.Parametric = TailRisk{
+		VaR:               z*sd - mean,
+		ExpectedShortfall: sd*density/(1-p.Confidence) - mean,
+	}
+
+	// Monte Carlo, drawing correlated daily returns
+	l := cholesky(estimate.Covariance)
+	rng := rand.New(rand.NewSource(p.Seed))
+	losses := make([]float64, p.Simulations)
+	draws := make([]float64, n)
+	growth := make([]float64, n)
+	for s := range losses {
+		for i := range growth {
+			growth[i] = 1
+		}
+		for d := 0; d < p.Horizon; d++ {
+			for i := range draws {
+				draws[i] = rng.NormFloat64()
+			}
+			for i := range growth {
+				growth[i] *= 1 + means[i] + Dot(l[i][:i+1], draws[:i+1])
+			}
+		}
+		value := 0.0
+		for i := range growth {
+			value += w[i] * growth[i]
+		}
+		losses[s] = 1 - value
+	}
+	risk.MonteCarlo = tail(losses, p.Confidence)
+	return risk, nil
+}
+
+// tail finds the VaR and expected shortfall of a sample of losses.
+func tail(losses []float64, confidence float64) TailRisk {
+	sort.Float64s(losses)
+	k := int(math.Ceil(confidence*float64(len(losses)))) - 1
+	if k < 0 {
+		k = 0
+	}
+	shortfall := 0.0
+	for _, loss := range losses[k:] {
+		shortfall += loss
+	}
+	return TailRisk{VaR: losses[k], ExpectedShortfall: shortfall / float64(len(losses)-k)}
+}
@@ -0,0 +1,163 @@
+package quant
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// tailReturns are 98 days of +1% and two losing days, of 5% and 3%.
+func tailReturns() []float64 {
+	returns := make([]float64, 100)
+	for i := range returns {
+		returns[i] = 0.01
+	}
+	returns[17], returns[62] = -0.05, -0.03
+	return returns
+}
+
+func TestValueAtRiskHistorical(t *testing.T) {
+	tests := []struct {
+		name    string
+		returns [][]float64
+		weights []float64
+		params  VaRParams
+		wantVaR float64
+		wantES  float64
+	}{
+		{
+			// The 99th of 100 sorted losses is the second largest; the
+			// shortfall averages it with the largest
+			name:    "one day",
+			returns: [][]float64{tailReturns()},
+			weights: []float64{1},
+			params:  VaRParams{Confidence: 0.99, Simulations: 1000},
+			wantVaR: 0.03,
+			wantES:  0.04,
+		},
+		{
+			// Ten overlapping two-day windows; the 80% VaR is the third
+			// worst, 1 − 0.97·1.01 = 0.0203, ahead of 1 − 1.04·0.94 = 0.0224
+			// and 1 − 0.94·1.02 = 0.0412
+			name:    "two days, compounded",
+			returns: [][]float64{{0.01, 0.02, -0.03, 0.01, 0.04, -0.06, 0.02, 0, 0.01, -0.02, 0.03}},
+			weights: []float64{1},
+			params:  VaRParams{Confidence: 0.8, Horizon: 2, Simulations: 1000},
+			wantVaR: 0.0203,
+			wantES:  0.0839 / 3,
+		},
+		{
+			// Weights are scaled to add up to 1: half in each of two copies
+			// of the series is the series
+			name:    "weights scaled",
+			returns: [][]float64{tailReturns(), tailReturns()},
+			weights: []float64{2, 2},
+			params:  VaRParams{Confidence: 0.99, Simulations: 1000},
+			wantVaR: 0.03,
+			wantES:  0.04,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			risk, err := ValueAtRisk(tt.returns, tt.weights, tt.params)
+			if err != nil {
+				t.Fatalf("ValueAtRisk() error = %v", err)
+			}
+			if risk.Historical == nil {
+				t.Fatal("historical = nil, want a measurement")
+			}
+			if math.Abs(risk.Historical.VaR-tt.wantVaR) > 1e-12 {
+				t.Errorf("historical VaR = %v, want %v", risk.Historical.VaR, tt.wantVaR)
+			}
+			if math.Abs(risk.Historical.ExpectedShortfall-tt.wantES) > 1e-12 {
+				t.Errorf("historical ES = %v, want %v", risk.Historical.ExpectedShortfall, tt.wantES)
+			}
+		})
+	}
+}
+
+func TestValueAtRiskShortHistory(t *testing.T) {
+	// 50 days hold no 1% tail
+	risk, err := ValueAtRisk([][]float64{tailReturns()[:50]}, []float64{1}, VaRParams{Simulations: 1000})
+	if err != nil {
+		t.Fatalf("ValueAtRisk() error = %v", err)
+	}
+	if risk.Historical != nil {
+		t.Errorf("historical = %+v, want nil", *risk.Historical)
+	}
+}
+
+func TestValueAtRiskParametric(t *testing.T) {
+	// Mean 0.009 and variance 0.000132 − 0.009² = 0.000051 a day;
+	// z₀.₉₉ = 2.3263479 and φ(z) = 0.0266521
+	tests := []struct {
+		name    string
+		horizon int
+		wantVaR float64
+		wantES  float64
+	}{
+		{"one day", 1, 0.0076134468, 0.0100334366},
+		// √2 the volatility, twice the mean
+		{"two days", 2, 0.0054949618, 0.0089173442},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			risk, err := ValueAtRisk([][]float64{tailReturns()}, []float64{1}, VaRParams{Horizon: tt.horizon, Simulations: 1000})
+			if err != nil {
+				t.Fatalf("ValueAtRisk() error = %v", err)
+			}
+			if math.Abs(risk.Parametric.VaR-tt.wantVaR) > 1e-9 {
+				t.Errorf("parametric VaR = %v, want %v", risk.Parametric.VaR, tt.wantVaR)
+			}
+			if math.Abs(risk.Parametric.ExpectedShortfall-tt.wantES) > 1e-9 {
+				t.Errorf("parametric ES = %v, want %v", risk.Parametric.ExpectedShortfall, tt.wantES)
+			}
+			if want := math.Sqrt(0.000051 * PeriodsPerYear); math.Abs(risk.Volatility-want) > 1e-12 {
+				t.Errorf("volatility = %v, want %v", risk.Volatility, want)
+			}
+		})
+	}
+}
+
+func TestValueAtRiskMonteCarlo(t *testing.T) {
+	returns := [][]float64{tailReturns()}
+	params := VaRParams{Seed: 7, Simulations: 20000}
+	first, err := ValueAtRisk(returns, []float64{1}, params)
+	if err != nil {
+		t.Fatalf("ValueAtRisk() error = %v", err)
+	}
+	second, _ := ValueAtRisk(returns, []float64{1}, params)
+	if first.MonteCarlo != second.MonteCarlo {
+		t.Errorf("same seed gave %+v and %+v", first.MonteCarlo, second.MonteCarlo)
+	}
+	// Over one day the simulation draws the parametric model's normal
+	if math.Abs(first.MonteCarlo.VaR-first.Parametric.VaR) > 0.1*first.Parametric.VaR {
+		t.Errorf("Monte Carlo VaR = %v, want near parametric %v", first.MonteCarlo.VaR, first.Parametric.VaR)
+	}
+}
+
+func TestValueAtRiskErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		returns [][]float64
+		weights []float64
+		params  VaRParams
+		wantErr error
+	}{
+		{"weights mismatch", [][]float64{tailReturns()}, []float64{0.5, 0.5}, VaRParams{}, nil},
+		{"zero weights", [][]float64{tailReturns()}, []float64{0}, VaRParams{}, nil},
+		{"confidence", [][]float64{tailReturns()}, []float64{1}, VaRParams{Confidence: 0.4}, nil},
+		{"horizon beyond history", [][]float64{tailReturns()[:5]}, []float64{1}, VaRParams{Horizon: 5}, ErrInsufficientData},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ValueAtRisk(tt.returns, tt.weights, tt.params)
+			if err == nil {
+				t.Fatal("ValueAtRisk() error = nil, want an error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("ValueAtRisk() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/edgeesg/edge-esg-backend/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type APIKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+func (r *APIKeyRepository) Create(ctx context.Context, key *models.BankAPIKey) error {
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+func (r *APIKeyRepository) FindByHash(ctx context.Context, keyHash string) (*models.BankAPIKey, error) {
+	var key models.BankAPIKey
+	err := r.db.WithContext(ctx).First(&key, "key_hash = ?", keyHash).Error
+	return &key, err
+}
+
+func (r *APIKeyRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.BankAPIKey, error) {
+	var key models.BankAPIKey
+	err := r.db.WithContext(ctx).First(&key, "id = ?", id).Error
+	return &key, err
+}
+
+func (r *APIKeyRepository) FindByBankID(ctx context.Context, bankID uuid.UUID) ([]models.BankAPIKey, error) {
+	var keys []models.BankAPIKey
+	err := r.db.WithContext(ctx).
+		Where("bank_id = ?", bankID).
+		Order("created_at DESC").
+		Find(&keys).Error
+	return keys, err
+}
+
+func (r *APIKeyRepository) Revoke(ctx context.Context, id uuid.UUID, at time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&models.BankAPIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", at).Error
+}
+
+// Rotate stores the replacement key and schedules the old one to stop working
+// at oldExpiresAt, in a single transaction.
+func (r *APIKeyRepository) Rotate(ctx context.Context, oldID uuid.UUID, oldExpiresAt time.Time, replacement *models.BankAPIKey) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(replacement).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.BankAPIKey{}).
+			Where("id = ?", oldID).
+			Update("expires_at", oldExpiresAt).Error
+	})
+}
+
+func (r *APIKeyRepository) TouchLastUsed(ctx context.Context, id uuid.UUID, at time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&models.BankAPIKey{}).
+		Where("id = ?", id).
+		Update("last_used_at", at).Error
+}
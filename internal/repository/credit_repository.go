@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/edgeesg/edge-esg-backend/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type CreditRepository struct {
+	db *gorm.DB
+}
+
+func NewCreditRepository(db *gorm.DB) *CreditRepository {
+	return &CreditRepository{db: db}
+}
+
+// CurrentPolicy returns a bank's latest overlay policy, or nil when it has
+// none.
+func (r *CreditRepository) CurrentPolicy(ctx context.Context, bankID uuid.UUID) (*models.CreditOverlayPolicy, error) {
+	var policy models.CreditOverlayPolicy
+	err := r.db.WithContext(ctx).
+		Where("bank_id = ?", bankID).
+		Order("version DESC").
+		First(&policy).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &policy, err
+}
+
+// CreatePolicy stores a policy as the bank's next version.
+func (r *CreditRepository) CreatePolicy(ctx context.Context, policy *models.CreditOverlayPolicy) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Concurrent replacements for a bank would read the same latest
+		// version; the lock makes them take turns until commit.
+		key := advisoryLockKey("credit_policy:" + policy.BankID.String())
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", key).Error; err != nil {
+			return err
+		}
+		var latest int
+		err := tx.Model(&models.CreditOverlayPolicy{}).
+			Where("bank_id = ?", policy.BankID).
+			Select("COALESCE(MAX(version), 0)").
+			Scan(&latest).Error
+		if err != nil {
+			return err
+		}
+		policy.Version = latest + 1
+		return tx.Create(policy).Error
+	})
+}
+
+func (r *CreditRepository) CreateAdjustments(ctx context.Context, adjustments []models.CreditAdjustment) error {
+	if len(adjustments) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Create(&adjustments).Error
+}
+
+// Adjustments lists a bank's adjustments, newest first, for one obligor
+// when obligorKey is set.
+func (r *CreditRepository) Adjustments(ctx context.Context, bankID uuid.UUID, obligorKey string, limit, offset int) ([]models.CreditAdjustment, error) {
+	query := r.db.WithContext(ctx).Where("bank_id = ?", bankID)
+	if obligorKey != "" {
+		query = query.Where("obligor_key = ?", obligorKey)
+	}
+	var adjustments []models.CreditAdjustment
+	err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&adjustments).Error
+	return adjustments, err
+}
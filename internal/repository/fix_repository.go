@@ -0,0 +1,234 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/edgeesg/edge-esg-backend/internal/fix"
+	"github.com/edgeesg/edge-esg-backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const fixStoreTimeout = 5 * time.Second
+
+type FIXRepository struct {
+	db *gorm.DB
+}
+
+func NewFIXRepository(db *gorm.DB) *FIXRepository {
+	return &FIXRepository{db: db}
+}
+
+// Lead blocks until this process holds the lock on a FIX session, trying
+// every retry, or ctx is done. The lock is a Postgres session advisory
+// lock, so one replica at a time runs the session and it passes to another
+// when the holder's connection goes away.
+func (r *FIXRepository) Lead(ctx context.Context, senderCompID, targetCompID string, retry time.Duration) (*Leadership, error) {
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return nil, err
+	}
+	key := advisoryLockKey("fix:" + senderCompID + "-" + targetCompID)
+	ticker := time.NewTicker(retry)
+	defer ticker.Stop()
+	for {
+		conn, err := sqlDB.Conn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var held bool
+		if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&held); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if held {
+			l := &Leadership{conn: conn, key: key, lost: make(chan struct{}), stop: make(chan struct{})}
+			l.wg.Add(1)
+			go l.watch(retry)
+			return l, nil
+		}
+		conn.Close()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Leadership is a held session lock. Lost is closed when the connection
+// holding it fails, since the lock goes with the connection.
+type Leadership struct {
+	conn *sql.Conn
+	key  int64
+	lost chan struct{}
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func (l *Leadership) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Release gives the lock up.
+func (l *Leadership) Release() {
+	close(l.stop)
+	l.wg.Wait()
+	ctx, cancel := context.WithTimeout(context.Background(), fixStoreTimeout)
+	defer cancel()
+	_, _ = l.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", l.key)
+	l.conn.Close()
+}
+
+func (l *Leadership) watch(interval time.Duration) {
+	defer l.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), fixStoreTimeout)
+			err := l.conn.PingContext(ctx)
+			cancel()
+			if err != nil {
+				close(l.lost)
+				<-l.stop
+				return
+			}
+		}
+	}
+}
+
+// advisoryLockKey maps a lock name onto Postgres's advisory lock keys.
+func advisoryLockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64()) // #nosec G115 -- a lock key, wrapping is fine
+}
+
+// OpenStore loads, or creates, a session's store. Run the session only
+// while holding its Leadership: the store caches sequence numbers.
+func (r *FIXRepository) OpenStore(ctx context.Context, senderCompID, targetCompID string) (*FIXStore, error) {
+	session := models.FIXSession{SenderCompID: senderCompID, TargetCompID: targetCompID, NextSenderSeq: 1, NextTargetSeq: 1}
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	err = r.db.WithContext(ctx).
+		First(&session, "sender_comp_id = ? AND target_comp_id = ?", senderCompID, targetCompID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &FIXStore{db: r.db, session: session}, nil
+}
+
+// FIXStore is a fix.Store in the database, so any replica can resume a
+// session.
+type FIXStore struct {
+	db *gorm.DB
+
+	mu      sync.Mutex
+	session models.FIXSession
+}
+
+var _ fix.Store = (*FIXStore)(nil)
+
+func (s *FIXStore) NextSenderSeq() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.session.NextSenderSeq
+}
+
+func (s *FIXStore) NextTargetSeq() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.session.NextTargetSeq
+}
+
+func (s *FIXStore) SetNextSenderSeq(seq int) error {
+	return s.update(map[string]interface{}{"next_sender_seq": seq}, func() { s.session.NextSenderSeq = seq })
+}
+
+func (s *FIXStore) SetNextTargetSeq(seq int) error {
+	return s.update(map[string]interface{}{"next_target_seq": seq}, func() { s.session.NextTargetSeq = seq })
+}
+
+func (s *FIXStore) SaveMessage(seq int, raw []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), fixStoreTimeout)
+	defer cancel()
+	message := models.FIXMessage{
+		SenderCompID: s.session.SenderCompID,
+		TargetCompID: s.session.TargetCompID,
+		Seq:          seq,
+		Raw:          raw,
+	}
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(&message).Error
+}
+
+func (s *FIXStore) Messages(begin, end int) ([]fix.StoredMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), fixStoreTimeout)
+	defer cancel()
+	var rows []models.FIXMessage
+	err := s.db.WithContext(ctx).
+		Where("sender_comp_id = ? AND target_comp_id = ? AND seq BETWEEN ? AND ?",
+			s.session.SenderCompID, s.session.TargetCompID, begin, end).
+		Order("seq").
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]fix.StoredMessage, len(rows))
+	for i, row := range rows {
+		messages[i] = fix.StoredMessage{Seq: row.Seq, Raw: row.Raw}
+	}
+	return messages, nil
+}
+
+func (s *FIXStore) Reset() error {
+	ctx, cancel := context.WithTimeout(context.Background(), fixStoreTimeout)
+	defer cancel()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Delete(&models.FIXMessage{}, "sender_comp_id = ? AND target_comp_id = ?",
+			s.session.SenderCompID, s.session.TargetCompID).Error
+		if err != nil {
+			return err
+		}
+		return s.where(tx).Updates(map[string]interface{}{
+			"next_sender_seq": 1,
+			"next_target_seq": 1,
+			"updated_at":      time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+	s.session.NextSenderSeq, s.session.NextTargetSeq = 1, 1
+	return nil
+}
+
+func (s *FIXStore) update(columns map[string]interface{}, apply func()) error {
+	ctx, cancel := context.WithTimeout(context.Background(), fixStoreTimeout)
+	defer cancel()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	columns["updated_at"] = time.Now()
+	if err := s.where(s.db.WithContext(ctx)).Updates(columns).Error; err != nil {
+		return fmt.Errorf("save FIX sequence numbers: %w", err)
+	}
+	apply()
+	return nil
+}
+
+func (s *FIXStore) where(tx *gorm.DB) *gorm.DB {
+	return tx.Model(&models.FIXSession{}).
+		Where("sender_comp_id = ? AND target_comp_id = ?", s.session.SenderCompID, s.session.TargetCompID)
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/edgeesg/edge-esg-backend/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type LocationRepository struct {
+	db *gorm.DB
+}
+
+func NewLocationRepository(db *gorm.DB) *LocationRepository {
+	return &LocationRepository{db: db}
+}
+
+// ReplaceCompanyLocations overwrites a company's locations, kept in the
+// order given.
+func (r *LocationRepository) ReplaceCompanyLocations(ctx context.Context, bankID uuid.UUID, companyKey string, locations []models.AssetLocation) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Delete(&models.AssetLocation{}, "bank_id = ? AND company_key = ?", bankID, companyKey).Error
+		if err != nil {
+			return err
+		}
+		if len(locations) == 0 {
+			return nil
+		}
+		for i := range locations {
+			locations[i].BankID = bankID
+			locations[i].CompanyKey = companyKey
+			locations[i].Position = i
+		}
+		return tx.Create(&locations).Error
+	})
+}
+
+// CompanyLocations returns the locations of the companies with the given
+// keys, by key, each in the order stored.
+func (r *LocationRepository) CompanyLocations(ctx context.Context, bankID uuid.UUID, companyKeys []string) (map[string][]models.AssetLocation, error) {
+	var locations []models.AssetLocation
+	err := r.db.WithContext(ctx).
+		Where("bank_id = ? AND company_key IN ?", bankID, companyKeys).
+		Order("company_key, position").
+		Find(&locations).Error
+	if err != nil {
+		return nil, err
+	}
+	byCompany := make(map[string][]models.AssetLocation)
+	for _, location := range locations {
+		byCompany[location.CompanyKey] = append(byCompany[location.CompanyKey], location)
+	}
+	return byCompany, nil
+}
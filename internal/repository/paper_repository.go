@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/edgeesg/edge-esg-backend/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrSignalOrderExists is returned by PlaceOrder for a second order of the
+// same signal in a portfolio.
+var ErrSignalOrderExists = errors.New("signal already traded in this portfolio")
+
+type PaperRepository struct {
+	db *gorm.DB
+}
+
+func NewPaperRepository(db *gorm.DB) *PaperRepository {
+	return &PaperRepository{db: db}
+}
+
+// CreatePortfolio stores a portfolio with its opening snapshot.
+func (r *PaperRepository) CreatePortfolio(ctx context.Context, portfolio *models.PaperPortfolio, opening *models.PaperSnapshot) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(portfolio).Error; err != nil {
+			return err
+		}
+		opening.PortfolioID = portfolio.ID
+		return tx.Create(opening).Error
+	})
+}
+
+// FindPortfolio returns a portfolio of bankID that viewer may see: bank-wide
+// portfolios and viewer's own.
+func (r *PaperRepository) FindPortfolio(ctx context.Context, bankID, id uuid.UUID, viewer string) (*models.PaperPortfolio, error) {
+	var portfolio models.PaperPortfolio
+	err := r.db.WithContext(ctx).
+		Where("id = ? AND bank_id = ?", id, bankID).
+		Where("owner = '' OR owner = ?", viewer).
+		First(&portfolio).Error
+	return &portfolio, err
+}
+
+func (r *PaperRepository) ListPortfolios(ctx context.Context, bankID uuid.UUID, viewer string) ([]models.PaperPortfolio, error) {
+	var portfolios []models.PaperPortfolio
+	err := r.db.WithContext(ctx).
+		Where("bank_id = ?", bankID).
+		Where("owner = '' OR owner = ?", viewer).
+		Order("created_at").
+		Find(&portfolios).Error
+	return portfolios, err
+}
+
+// Following returns the bank's portfolios that trade its approved signals.
+func (r *PaperRepository) Following(ctx context.Context, bankID uuid.UUID) ([]models.PaperPortfolio, error) {
+	var portfolios []models.PaperPortfolio
+	err := r.db.WithContext(ctx).
+		Where("bank_id = ? AND follow_signals", bankID).
+		Find(&portfolios).Error
+	return portfolios, err
+}
+
+// EachPortfolio calls fn for every portfolio of every bank, in batches.
+func (r *PaperRepository) EachPortfolio(ctx context.Context, fn func(portfolio *models.PaperPortfolio) error) error {
+	var batch []models.PaperPortfolio
+	return r.db.WithContext(ctx).FindInBatches(&batch, 100, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			if err := fn(&batch[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+func (r *PaperRepository) UpdatePortfolio(ctx context.Context, bankID, id uuid.UUID, updates map[string]interface{}) error {
+	updates["updated_at"] = time.Now()
+	return r.db.WithContext(ctx).Model(&models.PaperPortfolio{}).
+		Where("id = ? AND bank_id = ?", id, bankID).
+		Updates(updates).Error
+}
+
+func (r *PaperRepository) DeletePortfolio(ctx context.Context, bankID, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&models.PaperPortfolio{}, "id = ? AND bank_id = ?", id, bankID).Error
+}
+
+func (r *PaperRepository) Positions(ctx context.Context, portfolioID uuid.UUID) ([]models.PaperPosition, error) {
+	var positions []models.PaperPosition
+	err := r.db.WithContext(ctx).Where("portfolio_id = ?", portfolioID).Order("symbol").Find(&positions).Error
+	return positions, err
+}
+
+func (r *PaperRepository) Orders(ctx context.Context, portfolioID uuid.UUID, limit, offset int) ([]models.PaperOrder, error) {
+	var orders []models.PaperOrder
+	err := r.db.WithContext(ctx).
+		Where("portfolio_id = ?", portfolioID).
+		Order("created_at DESC").
+		Limit(limit).Offset(offset).
+		Find(&orders).Error
+	return orders, err
+}
+
+// PlaceOrder locks the portfolio and its position in symbol (nil when
+// flat) and passes them to plan, which fills or rejects the order by
+// editing them. The order, the portfolio's cash and the position plan
+// returns (nil to close it, the one it was given to leave it) are then
+// saved in the same transaction. An order for a signal the portfolio has
+// already traded is refused with ErrSignalOrderExists; the portfolio lock
+// makes the check safe against concurrent orders.
+func (r *PaperRepository) PlaceOrder(ctx context.Context, bankID, portfolioID uuid.UUID, symbol string,
+	plan func(portfolio *models.PaperPortfolio, position *models.PaperPosition) (*models.PaperOrder, *models.PaperPosition, error)) (*models.PaperOrder, error) {
+	var order *models.PaperOrder
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var portfolio models.PaperPortfolio
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&portfolio, "id = ? AND bank_id = ?", portfolioID, bankID).Error; err != nil {
+			return err
+		}
+
+		var current *models.PaperPosition
+		var held models.PaperPosition
+		err := tx.First(&held, "portfolio_id = ? AND symbol = ?", portfolioID, symbol).Error
+		switch {
+		case err == nil:
+			current = &held
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+
+		cash := portfolio.Cash
+		var next *models.PaperPosition
+		order, next, err = plan(&portfolio, current)
+		if err != nil {
+			return err
+		}
+		if order.SignalID != nil {
+			var count int64
+			if err := tx.Model(&models.PaperOrder{}).
+				Where("portfolio_id = ? AND signal_id = ?", portfolioID, *order.SignalID).
+				Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return ErrSignalOrderExists
+			}
+		}
+		order.PortfolioID = portfolioID
+		order.BankID = bankID
+		if err := tx.Create(order).Error; err != nil {
+			return err
+		}
+
+		if portfolio.Cash != cash {
+			if err := tx.Model(&models.PaperPortfolio{}).Where("id = ?", portfolioID).
+				Updates(map[string]interface{}{"cash": portfolio.Cash, "updated_at": time.Now()}).Error; err != nil {
+				return err
+			}
+		}
+		switch {
+		case next == current:
+			return nil
+		case next != nil:
+			next.PortfolioID = portfolioID
+			next.BankID = bankID
+			next.Symbol = symbol
+			return tx.Save(next).Error
+		case current != nil:
+			return tx.Delete(&models.PaperPosition{}, "portfolio_id = ? AND symbol = ?", portfolioID, symbol).Error
+		}
+		return nil
+	})
+	return order, err
+}
+
+// SaveSnapshot stores a day's snapshot, replacing an earlier one for the
+// same day.
+func (r *PaperRepository) SaveSnapshot(ctx context.Context, snapshot *models.PaperSnapshot) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "portfolio_id"}, {Name: "date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"cash", "market_value", "equity", "esg_score", "esg_leader_weight", "esg_laggard_weight", "created_at"}),
+	}).Create(snapshot).Error
+}
+
+// Snapshots returns a portfolio's snapshots since from, oldest first.
+func (r *PaperRepository) Snapshots(ctx context.Context, portfolioID uuid.UUID, from time.Time) ([]models.PaperSnapshot, error) {
+	var snapshots []models.PaperSnapshot
+	err := r.db.WithContext(ctx).
+		Where("portfolio_id = ? AND date >= ?", portfolioID, from).
+		Order("date").
+		Find(&snapshots).Error
+	return snapshots, err
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/edgeesg/edge-esg-backend/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type PortfolioRepository struct {
+	db *gorm.DB
+}
+
+func NewPortfolioRepository(db *gorm.DB) *PortfolioRepository {
+	return &PortfolioRepository{db: db}
+}
+
+// Create stores a portfolio with its holdings.
+func (r *PortfolioRepository) Create(ctx context.Context, portfolio *models.Portfolio) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Holdings").Create(portfolio).Error; err != nil {
+			return err
+		}
+		return createHoldings(tx, portfolio)
+	})
+}
+
+// Find returns a portfolio of bankID with its holdings.
+func (r *PortfolioRepository) Find(ctx context.Context, bankID, id uuid.UUID) (*models.Portfolio, error) {
+	var portfolio models.Portfolio
+	err := r.db.WithContext(ctx).
+		Preload("Holdings", holdingOrder).
+		Where("id = ? AND bank_id = ?", id, bankID).
+		First(&portfolio).Error
+	return &portfolio, err
+}
+
+func (r *PortfolioRepository) List(ctx context.Context, bankID uuid.UUID) ([]models.Portfolio, error) {
+	var portfolios []models.Portfolio
+	err := r.db.WithContext(ctx).
+		Preload("Holdings", holdingOrder).
+		Where("bank_id = ?", bankID).
+		Order("created_at").
+		Find(&portfolios).Error
+	return portfolios, err
+}
+
+// Replace overwrites a portfolio's settings and holdings. Drift state is
+// kept, so a breach that survives the edit is not alerted again. It
+// returns gorm.ErrRecordNotFound when the portfolio does not exist.
+func (r *PortfolioRepository) Replace(ctx context.Context, portfolio *models.Portfolio) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		portfolio.UpdatedAt = time.Now()
+		result := tx.Model(&models.Portfolio{}).
+			Where("id = ? AND bank_id = ?", portfolio.ID, portfolio.BankID).
+			Select("name", "benchmark", "mandate", "drift_threshold", "min_esg_score", "updated_at").
+			Updates(portfolio)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Delete(&models.PortfolioHolding{}, "portfolio_id = ?", portfolio.ID).Error; err != nil {
+			return err
+		}
+		return createHoldings(tx, portfolio)
+	})
+}
+
+func (r *PortfolioRepository) Delete(ctx context.Context, bankID, id uuid.UUID) (bool, error) {
+	result := r.db.WithContext(ctx).Delete(&models.Portfolio{}, "id = ? AND bank_id = ?", id, bankID)
+	return result.RowsAffected > 0, result.Error
+}
+
+// EachPortfolio calls fn for every portfolio of every bank, with its
+// holdings, in batches.
+func (r *PortfolioRepository) EachPortfolio(ctx context.Context, fn func(portfolio *models.Portfolio) error) error {
+	var batch []models.Portfolio
+	return r.db.WithContext(ctx).Preload("Holdings", holdingOrder).FindInBatches(&batch, 100, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			if err := fn(&batch[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+// RecordDrift stores the outcome of a drift check. The breaches only
+// change if they are still from, so when several replicas check the same
+// portfolio one of them reports the change; it returns whether this call
+// did.
+func (r *PortfolioRepository) RecordDrift(ctx context.Context, id uuid.UUID, from, to string, at time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.Portfolio{}).
+		Where("id = ? AND drift_breaches = ?", id, from).
+		Updates(map[string]interface{}{"drift_breaches": to, "drift_checked_at": at})
+	return result.RowsAffected > 0 && from != to, result.Error
+}
+
+// ReplaceExposures overwrites a portfolio's financed exposures, kept in
+// the order given. It returns gorm.ErrRecordNotFound when the portfolio
+// is not bankID's.
+func (r *PortfolioRepository) ReplaceExposures(ctx context.Context, bankID, portfolioID uuid.UUID, exposures []models.FinancedExposure) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		err := tx.Model(&models.Portfolio{}).Where("id = ? AND bank_id = ?", portfolioID, bankID).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Delete(&models.FinancedExposure{}, "portfolio_id = ?", portfolioID).Error; err != nil {
+			return err
+		}
+		if len(exposures) == 0 {
+			return nil
+		}
+		for i := range exposures {
+			exposures[i].PortfolioID = portfolioID
+			exposures[i].BankID = bankID
+			exposures[i].Position = i
+		}
+		return tx.Create(&exposures).Error
+	})
+}
+
+// Exposures returns a portfolio's financed exposures in the order stored.
+func (r *PortfolioRepository) Exposures(ctx context.Context, bankID, portfolioID uuid.UUID) ([]models.FinancedExposure, error) {
+	var exposures []models.FinancedExposure
+	err := r.db.WithContext(ctx).
+		Where("portfolio_id = ? AND bank_id = ?", portfolioID, bankID).
+		Order("position").
+		Find(&exposures).Error
+	return exposures, err
+}
+
+func createHoldings(tx *gorm.DB, portfolio *models.Portfolio) error {
+	if len(portfolio.Holdings) == 0 {
+		return nil
+	}
+	for i := range portfolio.Holdings {
+		portfolio.Holdings[i].PortfolioID = portfolio.ID
+		portfolio.Holdings[i].BankID = portfolio.BankID
+	}
+	return tx.Create(&portfolio.Holdings).Error
+}
+
+func holdingOrder(db *gorm.DB) *gorm.DB {
+	return db.Order("symbol")
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/edgeesg/edge-esg-backend/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SignalRepository struct {
+	db *gorm.DB
+}
+
+func NewSignalRepository(db *gorm.DB) *SignalRepository {
+	return &SignalRepository{db: db}
+}
+
+// SignalFilter narrows a bank's signal listing; empty fields match anything.
+type SignalFilter struct {
+	Status string
+	Symbol string
+	Limit  int
+	Offset int
+}
+
+// Create stores a new signal and its creation event in one transaction.
+func (r *SignalRepository) Create(ctx context.Context, signal *models.TradeSignal, event *models.TradeSignalEvent) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(signal).Error; err != nil {
+			return err
+		}
+		event.SignalID = signal.ID
+		return tx.Create(event).Error
+	})
+}
+
+func (r *SignalRepository) FindByID(ctx context.Context, bankID, id uuid.UUID) (*models.TradeSignal, error) {
+	var signal models.TradeSignal
+	err := r.db.WithContext(ctx).First(&signal, "id = ? AND bank_id = ?", id, bankID).Error
+	return &signal, err
+}
+
+func (r *SignalRepository) FindByAnalysisID(ctx context.Context, bankID uuid.UUID, analysisID string) (*models.TradeSignal, error) {
+	var signal models.TradeSignal
+	err := r.db.WithContext(ctx).First(&signal, "analysis_id = ? AND bank_id = ?", analysisID, bankID).Error
+	return &signal, err
+}
+
+func (r *SignalRepository) List(ctx context.Context, bankID uuid.UUID, filter SignalFilter) ([]models.TradeSignal, error) {
+	query := r.db.WithContext(ctx).Where("bank_id = ?", bankID)
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Symbol != "" {
+		query = query.Where("symbol = ?", filter.Symbol)
+	}
+	var signals []models.TradeSignal
+	err := query.Order("created_at DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&signals).Error
+	return signals, err
+}
+
+func (r *SignalRepository) Events(ctx context.Context, bankID, signalID uuid.UUID) ([]models.TradeSignalEvent, error) {
+	var events []models.TradeSignalEvent
+	err := r.db.WithContext(ctx).
+		Where("signal_id = ? AND bank_id = ?", signalID, bankID).
+		Order("created_at").
+		Find(&events).Error
+	return events, err
+}
+
+// Transition moves a signal to event.ToStatus if it is still in
+// event.FromStatus, applies updates and records the event, all in one
+// transaction. It reports false when the signal was not in FromStatus, for
+// example because a concurrent request moved it first.
+func (r *SignalRepository) Transition(ctx context.Context, bankID, id uuid.UUID, updates map[string]interface{}, event *models.TradeSignalEvent) (bool, error) {
+	moved := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		updates["status"] = event.ToStatus
+		updates["updated_at"] = time.Now()
+		result := tx.Model(&models.TradeSignal{}).
+			Where("id = ? AND bank_id = ? AND status = ?", id, bankID, event.FromStatus).
+			Updates(updates)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		moved = true
+		event.SignalID = id
+		event.BankID = bankID
+		return tx.Create(event).Error
+	})
+	return moved, err
+}
+
+// ListUnexported returns signals of every bank in status that have not
+// been exported, oldest first.
+func (r *SignalRepository) ListUnexported(ctx context.Context, status string, limit int) ([]models.TradeSignal, error) {
+	var signals []models.TradeSignal
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND exported_at IS NULL", status).
+		Order("updated_at").
+		Limit(limit).
+		Find(&signals).Error
+	return signals, err
+}
+
+// MarkExported records when a signal was exported, unless it already was.
+// It reports false when it already was.
+func (r *SignalRepository) MarkExported(ctx context.Context, bankID, id uuid.UUID, at time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.TradeSignal{}).
+		Where("id = ? AND bank_id = ? AND exported_at IS NULL", id, bankID).
+		Update("exported_at", at)
+	return result.RowsAffected > 0, result.Error
+}
+
+// DueForExpiry returns open signals whose expiry has passed, oldest first.
+func (r *SignalRepository) DueForExpiry(ctx context.Context, now time.Time, statuses []string, limit int) ([]models.TradeSignal, error) {
+	var signals []models.TradeSignal
+	err := r.db.WithContext(ctx).
+		Where("status IN ? AND expires_at <= ?", statuses, now).
+		Order("expires_at").
+		Limit(limit).
+		Find(&signals).Error
+	return signals, err
+}
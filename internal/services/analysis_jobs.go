@@ -0,0 +1,28 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/edgeesg/edge-esg-backend/internal/dtos"
+)
+
+// RunAnalyzeJob is the JobHandler for queued single-company analyses.
+func (o *Orchestrator) RunAnalyzeJob(ctx context.Context, job *Job, progress func(percent int)) (interface{}, error) {
+	var req dtos.AnalyzeRequest
+	if err := json.Unmarshal(job.Payload, &req); err != nil {
+		return nil, fmt.Errorf("invalid analyze payload: %w", err)
+	}
+	req.BankID = job.BankID
+
+	// The job ID doubles as the analysis ID in progress events
+	response, err := o.Execute8LayerPipeline(WithAnalysisID(ctx, job.ID), &req)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
@@ -0,0 +1,345 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edgeesg/edge-esg-backend/internal/models"
+	"github.com/edgeesg/edge-esg-backend/internal/repository"
+	"github.com/edgeesg/edge-esg-backend/internal/types"
+	"github.com/edgeesg/edge-esg-backend/internal/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	apiKeyPrefix = "edge_"
+
+	// SignatureMaxSkew bounds how old a signed request's timestamp may be.
+	SignatureMaxSkew = 5 * time.Minute
+
+	apiKeyCacheTTL   = 30 * time.Second
+	lastUsedInterval = time.Minute
+)
+
+var (
+	ErrAPIKeyInvalid    = errors.New("invalid or expired API key")
+	ErrAPIKeyNotFound   = errors.New("API key not found")
+	ErrSignatureInvalid = errors.New("invalid request signature")
+	ErrInvalidScope     = errors.New("invalid API key scope")
+)
+
+type IssueAPIKeyRequest struct {
+	BankID           uuid.UUID
+	Name             string
+	Scopes           []string
+	ExpiresAt        *time.Time
+	RequireSignature bool
+	CreatedBy        string
+}
+
+// IssuedAPIKey is returned once at issue/rotate time; the plaintext key and
+// signing secret are never stored and cannot be retrieved again.
+type IssuedAPIKey struct {
+	Key           *models.BankAPIKey
+	PlaintextKey  string
+	SigningSecret string
+}
+
+type cachedAPIKey struct {
+	key       models.BankAPIKey
+	expiresAt time.Time
+}
+
+// APIKeyService issues bank API keys and resolves incoming keys to banks.
+type APIKeyService struct {
+	repo          *repository.APIKeyRepository
+	encryptionKey string
+
+	mu          sync.Mutex
+	cache       map[string]cachedAPIKey
+	lastTouched map[uuid.UUID]time.Time
+}
+
+// NewAPIKeyService takes the gateway's hex ENCRYPTION_KEY, which protects the
+// stored signing secrets.
+func NewAPIKeyService(repo *repository.APIKeyRepository, encryptionKeyHex string) (*APIKeyService, error) {
+	keyBytes, err := hex.DecodeString(encryptionKeyHex)
+	if err != nil || len(keyBytes) != 32 {
+		return nil, fmt.Errorf("encryption key must be 64 hex characters")
+	}
+
+	return &APIKeyService{
+		repo:          repo,
+		encryptionKey: string(keyBytes),
+		cache:         make(map[string]cachedAPIKey),
+		lastTouched:   make(map[uuid.UUID]time.Time),
+	}, nil
+}
+
+// Issue creates a new key for a bank.
+func (s *APIKeyService) Issue(ctx context.Context, req *IssueAPIKeyRequest) (*IssuedAPIKey, error) {
+	if err := validateScopes(req.Scopes); err != nil {
+		return nil, err
+	}
+
+	issued, err := s.newKey(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Create(ctx, issued.Key); err != nil {
+		return nil, fmt.Errorf("failed to store API key: %w", err)
+	}
+	return issued, nil
+}
+
+func (s *APIKeyService) List(ctx context.Context, bankID uuid.UUID) ([]models.BankAPIKey, error) {
+	return s.repo.FindByBankID(ctx, bankID)
+}
+
+// Revoke disables a key immediately.
+func (s *APIKeyService) Revoke(ctx context.Context, id uuid.UUID) error {
+	key, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrAPIKeyNotFound
+		}
+		return err
+	}
+
+	if err := s.repo.Revoke(ctx, id, time.Now()); err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	s.evict(key.KeyHash)
+	return nil
+}
+
+// Rotate issues a replacement with the same bank, name, scopes and signing
+// requirement. The old key keeps working for the grace period so the bank can
+// roll the new one out without downtime.
+func (s *APIKeyService) Rotate(ctx context.Context, id uuid.UUID, grace time.Duration, createdBy string) (*IssuedAPIKey, error) {
+	old, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	if !old.IsActive(time.Now()) {
+		return nil, ErrAPIKeyInvalid
+	}
+
+	issued, err := s.newKey(&IssueAPIKeyRequest{
+		BankID:           old.BankID,
+		Name:             old.Name,
+		Scopes:           old.ScopeList(),
+		ExpiresAt:        old.ExpiresAt,
+		RequireSignature: old.RequireSignature,
+		CreatedBy:        createdBy,
+	})
+	if err != nil {
+		return nil, err
+	}
+	issued.Key.RotatedFrom = &old.ID
+
+	oldExpiresAt := time.Now().Add(grace)
+	if old.ExpiresAt != nil && old.ExpiresAt.Before(oldExpiresAt) {
+		oldExpiresAt = *old.ExpiresAt
+	}
+	if err := s.repo.Rotate(ctx, old.ID, oldExpiresAt, issued.Key); err != nil {
+		return nil, fmt.Errorf("failed to rotate API key: %w", err)
+	}
+	s.evict(old.KeyHash)
+	return issued, nil
+}
+
+// Authenticate resolves a plaintext key to its stored record.
+func (s *APIKeyService) Authenticate(ctx context.Context, plaintextKey string) (*models.BankAPIKey, error) {
+	if !strings.HasPrefix(plaintextKey, apiKeyPrefix) {
+		return nil, ErrAPIKeyInvalid
+	}
+
+	keyHash := hashAPIKey(plaintextKey)
+	now := time.Now()
+
+	key, err := s.lookup(ctx, keyHash, now)
+	if err != nil {
+		return nil, err
+	}
+	if !key.IsActive(now) {
+		return nil, ErrAPIKeyInvalid
+	}
+
+	s.touch(key.ID, now)
+	return key, nil
+}
+
+// VerifySignature checks an HMAC-SHA256 request signature. The signed string
+// is "<unix timestamp>\n<METHOD>\n<request URI>\n<hex sha256 of body>".
+func (s *APIKeyService) VerifySignature(key *models.BankAPIKey, method, requestURI string, body []byte, timestamp, signature string) error {
+	if key.SigningSecretEncrypted == "" || timestamp == "" || signature == "" {
+		return ErrSignatureInvalid
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+	skew := time.Since(time.Unix(ts, 0))
+	if skew > SignatureMaxSkew || skew < -SignatureMaxSkew {
+		return ErrSignatureInvalid
+	}
+
+	secret, err := utils.DecryptAES256GCM(key.SigningSecretEncrypted, s.encryptionKey)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt signing secret: %w", err)
+	}
+
+	expected := SignRequest(secret, timestamp, method, requestURI, body)
+	provided, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(provided, expected) {
+		return ErrSignatureInvalid
+	}
+	return nil
+}
+
+// SignRequest computes the signature a client must send in X-Signature
+// (hex encoded).
+func SignRequest(secret, timestamp, method, requestURI string, body []byte) []byte {
+	bodyHash := sha256.Sum256(body)
+	payload := timestamp + "\n" + strings.ToUpper(method) + "\n" + requestURI + "\n" + hex.EncodeToString(bodyHash[:])
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+func (s *APIKeyService) newKey(req *IssueAPIKeyRequest) (*IssuedAPIKey, error) {
+	prefix, err := randomHex(4)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	plaintext := apiKeyPrefix + prefix + "_" + secret
+
+	key := &models.BankAPIKey{
+		ID:               uuid.New(),
+		BankID:           req.BankID,
+		Name:             req.Name,
+		KeyPrefix:        apiKeyPrefix + prefix,
+		KeyHash:          hashAPIKey(plaintext),
+		Scopes:           strings.Join(req.Scopes, " "),
+		RequireSignature: req.RequireSignature,
+		ExpiresAt:        req.ExpiresAt,
+		CreatedBy:        req.CreatedBy,
+		CreatedAt:        time.Now(),
+	}
+	issued := &IssuedAPIKey{Key: key, PlaintextKey: plaintext}
+
+	// Every key gets a signing secret so signing can be adopted without a
+	// rotation; RequireSignature decides whether unsigned calls are refused.
+	signingSecret, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := utils.EncryptAES256GCM(signingSecret, s.encryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt signing secret: %w", err)
+	}
+	key.SigningSecretEncrypted = encrypted
+	issued.SigningSecret = signingSecret
+
+	return issued, nil
+}
+
+func (s *APIKeyService) lookup(ctx context.Context, keyHash string, now time.Time) (*models.BankAPIKey, error) {
+	s.mu.Lock()
+	cached, ok := s.cache[keyHash]
+	s.mu.Unlock()
+	if ok && now.Before(cached.expiresAt) {
+		key := cached.key
+		return &key, nil
+	}
+
+	key, err := s.repo.FindByHash(ctx, keyHash)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAPIKeyInvalid
+		}
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.cache[keyHash] = cachedAPIKey{key: *key, expiresAt: now.Add(apiKeyCacheTTL)}
+	s.mu.Unlock()
+	return key, nil
+}
+
+func (s *APIKeyService) evict(keyHash string) {
+	s.mu.Lock()
+	delete(s.cache, keyHash)
+	s.mu.Unlock()
+}
+
+// touch records last use at most once per lastUsedInterval per key so busy
+// integrations do not write to Postgres on every request.
+func (s *APIKeyService) touch(id uuid.UUID, now time.Time) {
+	s.mu.Lock()
+	if last, ok := s.lastTouched[id]; ok && now.Sub(last) < lastUsedInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastTouched[id] = now
+	s.mu.Unlock()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = s.repo.TouchLastUsed(ctx, id, now)
+	}()
+}
+
+func validateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("%w: at least one scope is required", ErrInvalidScope)
+	}
+	for _, scope := range scopes {
+		valid := false
+		for _, known := range types.ValidAPIScopes {
+			if scope == string(known) {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+		}
+	}
+	return nil
+}
+
+func hashAPIKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/edgeesg/edge-esg-backend/internal/dtos"
+	"github.com/edgeesg/edge-esg-backend/internal/types"
+)
+
+// BatchAnalyzeRequest is the payload of a batch_analyze job.
+type BatchAnalyzeRequest struct {
+	Companies []string `json:"companies"`
+	UserRole  string   `json:"user_role,omitempty"`
+}
+
+// BatchItem is the outcome for one company, stored as a job item as soon as
+// it completes.
+type BatchItem struct {
+	Index   int                   `json:"index"`
+	Company string                `json:"company"`
+	Status  types.JobStatus       `json:"status"`
+	Result  *dtos.AnalyzeResponse `json:"result,omitempty"`
+	Error   string                `json:"error,omitempty"`
+}
+
+// BatchSummary is the final result of a batch_analyze job.
+type BatchSummary struct {
+	Total     int `json:"total"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+}
+
+// BatchAnalyzer runs the analysis pipeline over a list of companies with a
+// bounded number in flight. Upstream calls are paced per provider, so a large
+// batch spreads over time instead of exhausting the shared daily quotas.
+type BatchAnalyzer struct {
+	orchestrator *Orchestrator
+	jobs         *JobService
+	concurrency  int
+}
+
+func NewBatchAnalyzer(orchestrator *Orchestrator, jobs *JobService, concurrency int) *BatchAnalyzer {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	return &BatchAnalyzer{orchestrator: orchestrator, jobs: jobs, concurrency: concurrency}
+}
+
+// Run is the JobHandler for batch_analyze jobs. A job requeued after a
+// restart skips the companies it already recorded.
+func (b *BatchAnalyzer) Run(ctx context.Context, job *Job, progress func(percent int)) (interface{}, error) {
+	var req BatchAnalyzeRequest
+	if err := json.Unmarshal(job.Payload, &req); err != nil {
+		return nil, fmt.Errorf("invalid batch payload: %w", err)
+	}
+
+	summary := &BatchSummary{Total: len(req.Companies)}
+	done, err := b.recorded(ctx, job.ID, summary)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = withoutSignals(WithUpstreamPacing(ctx))
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, b.concurrency)
+	)
+
+dispatch:
+	for i, company := range req.Companies {
+		if done[i] {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(index int, company string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			item := BatchItem{Index: index, Company: company, Status: types.JobSucceeded}
+			result, err := b.orchestrator.Execute8LayerPipeline(ctx, &dtos.AnalyzeRequest{
+				CompanyName: company,
+				BankID:      job.BankID,
+				UserRole:    req.UserRole,
+			})
+			if ctx.Err() != nil {
+				// Cancelled or shutting down; the company was not analysed
+				return
+			}
+			if err != nil {
+				item.Status = types.JobFailed
+				item.Error = err.Error()
+			} else {
+				item.Result = result
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err := b.jobs.AppendItem(context.Background(), job.ID, item); err != nil {
+				return
+			}
+			if item.Status == types.JobSucceeded {
+				summary.Succeeded++
+			} else {
+				summary.Failed++
+			}
+			progress(10 + 89*(summary.Succeeded+summary.Failed)/summary.Total)
+		}(i, company)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// recorded returns the indexes already stored for a job and counts them into
+// summary.
+func (b *BatchAnalyzer) recorded(ctx context.Context, jobID string, summary *BatchSummary) (map[int]bool, error) {
+	items, err := b.jobs.Items(ctx, jobID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(map[int]bool, len(items))
+	for _, raw := range items {
+		var item BatchItem
+		if err := json.Unmarshal(raw, &item); err != nil || done[item.Index] {
+			continue
+		}
+		done[item.Index] = true
+		if item.Status == types.JobSucceeded {
+			summary.Succeeded++
+		} else {
+			summary.Failed++
+		}
+	}
+	return done, nil
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/edgeesg/edge-esg-backend/internal/agents"
+	"github.com/edgeesg/edge-esg-backend/internal/dtos"
+	"github.com/edgeesg/edge-esg-backend/internal/marketdata"
+)
+
+// BenchmarkAnalytics is a portfolio measured against a benchmark, as of
+// AsOf. Positions cover the portfolio's holdings and the benchmark's
+// constituents, portfolio first.
+type BenchmarkAnalytics struct {
+	Benchmark string
+	AsOf      time.Time
+	Positions []agents.BenchmarkPosition
+	*agents.BenchmarkResponse
+}
+
+// benchmarkPositions lines a portfolio's holdings up with a benchmark's
+// constituents by symbol. Constituents are classified by the benchmark's
+// sectors, so both sides of the attribution use one scheme; a holding's
+// carbon intensity defaults to the benchmark's. Any position without an
+// ESG score takes the latest one in source as of at.
+func benchmarkPositions(holdings []agents.BenchmarkPosition, benchmark *marketdata.Benchmark, source marketdata.Source, at time.Time) []agents.BenchmarkPosition {
+	positions := append([]agents.BenchmarkPosition(nil), holdings...)
+	index := make(map[string]int, len(positions)+len(benchmark.Constituents))
+	for i, position := range positions {
+		index[strings.ToUpper(position.Symbol)] = i
+	}
+	for _, constituent := range benchmark.Constituents {
+		i, ok := index[constituent.Symbol]
+		if !ok {
+			i = len(positions)
+			index[constituent.Symbol] = i
+			positions = append(positions, agents.BenchmarkPosition{Symbol: constituent.Symbol})
+		}
+		position := &positions[i]
+		position.BenchmarkWeight += constituent.Weight
+		if constituent.Sector != "" {
+			position.Sector = constituent.Sector
+		}
+		if position.CarbonIntensity == nil {
+			position.CarbonIntensity = constituent.CarbonIntensity
+		}
+	}
+	if source == nil {
+		return positions
+	}
+	for i := range positions {
+		if positions[i].ESGScore != nil {
+			continue
+		}
+		if inputs, err := source.Inputs(positions[i].Symbol); err == nil {
+			if known, ok := marketdata.AsOf(inputs, at); ok {
+				score := known.ESGScore
+				positions[i].ESGScore = &score
+			}
+		}
+	}
+	return positions
+}
+
+// Response converts the analytics for the API. Active weights are listed
+// largest first.
+func (a *BenchmarkAnalytics) Response() dtos.BenchmarkAnalyticsResponse {
+	response := dtos.BenchmarkAnalyticsResponse{
+		Benchmark:                a.Benchmark,
+		AsOf:                     a.AsOf,
+		TrackingError:            a.TrackingError,
+		ESGScore:                 a.ESGScore,
+		BenchmarkESGScore:        a.BenchmarkESGScore,
+		ActiveESGScore:           a.ActiveESGScore,
+		ESGCoverage:              a.ESGCoverage,
+		BenchmarkESGCoverage:     a.BenchmarkESGCoverage,
+		CarbonIntensity:          a.CarbonIntensity,
+		BenchmarkCarbonIntensity: a.BenchmarkCarbonIntensity,
+		ActiveCarbonIntensity:    a.ActiveCarbonIntensity,
+		CarbonCoverage:           a.CarbonCoverage,
+		BenchmarkCarbonCoverage:  a.BenchmarkCarbonCoverage,
+		ActiveWeights:            make([]dtos.ActiveWeightResponse, len(a.Positions)),
+		SectorAttribution:        make([]dtos.SectorAttributionResponse, 0, len(a.SectorAttribution)),
+		CovarianceSource:         a.CovarianceSource,
+	}
+	for i, position := range a.Positions {
+		response.ActiveWeights[i] = dtos.ActiveWeightResponse{
+			Symbol:          position.Symbol,
+			Weight:          position.Weight,
+			BenchmarkWeight: position.BenchmarkWeight,
+			ActiveWeight:    a.ActiveWeights[i],
+		}
+	}
+	sort.SliceStable(response.ActiveWeights, func(i, j int) bool {
+		return math.Abs(response.ActiveWeights[i].ActiveWeight) > math.Abs(response.ActiveWeights[j].ActiveWeight)
+	})
+	for _, sector := range a.SectorAttribution {
+		line := dtos.SectorAttributionResponse{
+			Sector:          sector.Sector,
+			Weight:          sector.Weight,
+			BenchmarkWeight: sector.BenchmarkWeight,
+			Allocation:      sector.Allocation,
+			Selection:       sector.Selection,
+			Total:           sector.Allocation + sector.Selection,
+		}
+		if sector.Weight > 0 {
+			score := sector.ESGScore
+			line.ESGScore = &score
+		}
+		if sector.BenchmarkWeight > 0 {
+			score := sector.BenchmarkESGScore
+			line.BenchmarkESGScore = &score
+		}
+		response.SectorAttribution = append(response.SectorAttribution, line)
+	}
+	return response
+}
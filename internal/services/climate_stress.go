@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/edgeesg/edge-esg-backend/internal/climate"
+	"github.com/edgeesg/edge-esg-backend/internal/marketdata"
+)
+
+// ClimateStressService stress tests companies and portfolios against the
+// climate scenarios in the market data. Companies without reported
+// emissions are estimated from the sector emission factors there.
+type ClimateStressService struct {
+	scenarios marketdata.ScenarioSource
+	factors   marketdata.EmissionFactorSource
+}
+
+func NewClimateStressService(scenarios marketdata.ScenarioSource, factors marketdata.EmissionFactorSource) *ClimateStressService {
+	return &ClimateStressService{scenarios: scenarios, factors: factors}
+}
+
+// Scenarios lists the scenarios that can be stress tested against.
+func (s *ClimateStressService) Scenarios() ([]marketdata.Scenario, error) {
+	return s.scenarios.Scenarios()
+}
+
+// Scenario loads a scenario by name.
+func (s *ClimateStressService) Scenario(name string) (*marketdata.Scenario, error) {
+	return s.scenarios.Scenario(name)
+}
+
+// StressTest runs companies through each named scenario, or through every
+// scenario when none are named. A zero discount rate takes the default.
+func (s *ClimateStressService) StressTest(ctx context.Context, names []string, companies []climate.Company, discountRate float64) ([]*climate.Result, error) {
+	var scenarios []*marketdata.Scenario
+	if len(names) == 0 {
+		all, err := s.scenarios.Scenarios()
+		if err != nil {
+			return nil, err
+		}
+		if len(all) == 0 {
+			return nil, fmt.Errorf("%w: no scenarios", marketdata.ErrNoData)
+		}
+		for i := range all {
+			scenarios = append(scenarios, &all[i])
+		}
+	}
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		scenario, err := s.scenarios.Scenario(name)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[scenario.Name] {
+			seen[scenario.Name] = true
+			scenarios = append(scenarios, scenario)
+		}
+	}
+
+	factors, err := s.factors.EmissionFactors()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load emission factors: %w", err)
+	}
+	results := make([]*climate.Result, len(scenarios))
+	for i, scenario := range scenarios {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if results[i], err = climate.Stress(scenario, companies, factors, discountRate); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,58 @@
+package services
+
+import "context"
+
+type contextKey string
+
+const (
+	bankIDContextKey         contextKey = "bank_id"
+	upstreamPacingContextKey contextKey = "upstream_pacing"
+	skipSignalsContextKey    contextKey = "skip_signals"
+	actorContextKey          contextKey = "actor"
+)
+
+// WithBankID records the bank a pipeline runs for, so shared resources such
+// as upstream API quotas can be attributed to it.
+func WithBankID(ctx context.Context, bankID string) context.Context {
+	return context.WithValue(ctx, bankIDContextKey, bankID)
+}
+
+// BankIDFromContext returns the bank set by WithBankID, or "".
+func BankIDFromContext(ctx context.Context) string {
+	bankID, _ := ctx.Value(bankIDContextKey).(string)
+	return bankID
+}
+
+// WithUpstreamPacing makes upstream calls wait for the provider's per-minute
+// pace rather than spending the daily quota in a burst. Background work such
+// as batch analysis opts in; interactive requests do not.
+func WithUpstreamPacing(ctx context.Context) context.Context {
+	return context.WithValue(ctx, upstreamPacingContextKey, true)
+}
+
+func upstreamPacing(ctx context.Context) bool {
+	paced, _ := ctx.Value(upstreamPacingContextKey).(bool)
+	return paced
+}
+
+// withoutSignals keeps an analysis from storing its trading signal, for
+// screening runs where the signal is not a trade idea.
+func withoutSignals(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipSignalsContextKey, true)
+}
+
+func recordsSignals(ctx context.Context) bool {
+	skip, _ := ctx.Value(skipSignalsContextKey).(bool)
+	return !skip
+}
+
+// WithActor records who a pipeline runs for, as named in audit records.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorContextKey, actor)
+}
+
+// ActorFromContext returns the actor set by WithActor, or "".
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorContextKey).(string)
+	return actor
+}
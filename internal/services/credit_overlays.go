@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/edgeesg/edge-esg-backend/internal/climate"
+	"github.com/edgeesg/edge-esg-backend/internal/credit"
+	"github.com/edgeesg/edge-esg-backend/internal/marketdata"
+	"github.com/edgeesg/edge-esg-backend/internal/models"
+	"github.com/edgeesg/edge-esg-backend/internal/repository"
+	"github.com/google/uuid"
+)
+
+// Where a credit adjustment was made
+const (
+	CreditSourceAPI      = "api"
+	CreditSourceAnalysis = "analysis"
+)
+
+// MaxObligors bounds the obligors adjusted in one request.
+const MaxObligors = 100
+
+// CreditOverlayService adjusts obligors' ratings and PDs for ESG and
+// climate risk under each bank's overlay policy, and records every
+// adjustment with the policy version, factors and rules behind it for
+// model risk validation. Banks without a policy of their own get
+// credit.DefaultPolicy, as version 0.
+type CreditOverlayService struct {
+	repo         *repository.CreditRepository
+	physicalRisk *PhysicalRiskService
+
+	stress         *ClimateStressService
+	stressScenario string
+	stressYear     int
+}
+
+func NewCreditOverlayService(repo *repository.CreditRepository, physicalRisk *PhysicalRiskService) *CreditOverlayService {
+	return &CreditOverlayService{repo: repo, physicalRisk: physicalRisk}
+}
+
+// UseClimateStress gives obligors adjusted with their financials, and
+// without a climate_pd_change_bps factor, the rise in their PD by year
+// under scenario.
+func (s *CreditOverlayService) UseClimateStress(stress *ClimateStressService, scenario string, year int) error {
+	loaded, err := stress.Scenario(scenario)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(loaded.Years, year) {
+		return fmt.Errorf("%w: scenario %s has no year %d", marketdata.ErrNoData, loaded.Name, year)
+	}
+	s.stress, s.stressScenario, s.stressYear = stress, loaded.Name, year
+	return nil
+}
+
+// CreditObligor is an obligor to adjust and, when known, its financials
+// today for the climate stress test.
+type CreditObligor struct {
+	credit.Obligor
+	Financials *climate.Company
+}
+
+// Policy returns the bank's policy in force and its version.
+func (s *CreditOverlayService) Policy(ctx context.Context, bankID uuid.UUID) (credit.Policy, int, error) {
+	row, err := s.repo.CurrentPolicy(ctx, bankID)
+	if err != nil {
+		return credit.Policy{}, 0, err
+	}
+	if row == nil {
+		return credit.DefaultPolicy(), 0, nil
+	}
+	policy := credit.Policy{
+		Rules:        make([]credit.Rule, len(row.Rules)),
+		MaxDowngrade: row.MaxDowngrade,
+		MaxUpgrade:   row.MaxUpgrade,
+		PDFloor:      row.PDFloor,
+	}
+	for i, rule := range row.Rules {
+		policy.Rules[i] = credit.Rule(rule)
+	}
+	return policy, row.Version, nil
+}
+
+// ReplacePolicy validates and stores a policy as the bank's next version,
+// returning the version. Earlier versions are kept, so past adjustments
+// can be traced to the rules that made them.
+func (s *CreditOverlayService) ReplacePolicy(ctx context.Context, bankID uuid.UUID, createdBy string, policy credit.Policy) (int, error) {
+	for i := range policy.Rules {
+		policy.Rules[i].Name = strings.TrimSpace(policy.Rules[i].Name)
+	}
+	if err := policy.Validate(); err != nil {
+		return 0, err
+	}
+	row := &models.CreditOverlayPolicy{
+		BankID:       bankID,
+		Rules:        toCreditRuleRows(policy.Rules),
+		MaxDowngrade: policy.MaxDowngrade,
+		MaxUpgrade:   policy.MaxUpgrade,
+		PDFloor:      policy.PDFloor,
+		CreatedBy:    createdBy,
+	}
+	if err := s.repo.CreatePolicy(ctx, row); err != nil {
+		return 0, err
+	}
+	return row.Version, nil
+}
+
+// Adjust applies the bank's policy to obligors and records the
+// adjustments. An obligor without a physical_risk factor takes the
+// physical risk score of its stored asset locations, when it has any.
+// One without climate_pd_change_bps takes its PD rise under the climate
+// stress test, when it has financials and the stress test is in use.
+func (s *CreditOverlayService) Adjust(ctx context.Context, bankID uuid.UUID, createdBy, source string, obligors []CreditObligor) ([]models.CreditAdjustment, error) {
+	if len(obligors) > MaxObligors {
+		return nil, fmt.Errorf("%w: at most %d obligors at a time", credit.ErrInvalidObligor, MaxObligors)
+	}
+	policy, version, err := s.Policy(ctx, bankID)
+	if err != nil {
+		return nil, err
+	}
+	pdChanges, err := s.climatePDChanges(ctx, obligors)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]models.CreditAdjustment, len(obligors))
+	for i, input := range obligors {
+		obligor := input.Obligor
+		obligor.Name = strings.TrimSpace(obligor.Name)
+		factors := make(map[string]float64, len(obligor.Factors)+2)
+		for factor, value := range obligor.Factors {
+			factors[factor] = value
+		}
+		if change, ok := pdChanges[i]; ok {
+			factors[credit.FactorClimatePDChange] = change
+		}
+		if _, ok := factors[credit.FactorPhysicalRisk]; !ok && s.physicalRisk != nil && obligor.Name != "" {
+			risk, err := s.physicalRisk.CompanyRisk(ctx, bankID, obligor.Name)
+			if err != nil && !errors.Is(err, ErrNoLocations) {
+				return nil, err
+			}
+			if err == nil && risk.Score != nil {
+				factors[credit.FactorPhysicalRisk] = *risk.Score
+			}
+		}
+		obligor.Factors = factors
+
+		adjustment, err := credit.Adjust(obligor, policy)
+		if err != nil {
+			return nil, err
+		}
+		rows[i] = models.CreditAdjustment{
+			BankID:         bankID,
+			ObligorKey:     companyKey(obligor.Name),
+			Obligor:        obligor.Name,
+			PolicyVersion:  version,
+			Source:         source,
+			BaseRating:     adjustment.BaseRating,
+			BasePD:         adjustment.BasePD,
+			AdjustedRating: adjustment.AdjustedRating,
+			AdjustedPD:     adjustment.AdjustedPD,
+			RuleNotches:    adjustment.RuleNotches,
+			Notches:        adjustment.Notches,
+			Factors:        factors,
+			AppliedRules:   toCreditRuleRows(adjustment.Applied),
+			CreatedBy:      createdBy,
+		}
+	}
+	if err := s.repo.CreateAdjustments(ctx, rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// climatePDChanges stress tests the obligors with financials and without
+// a climate_pd_change_bps factor, returning each one's PD rise in the
+// stress year in basis points, by index. The obligor's base PD anchors
+// its stressed PDs.
+func (s *CreditOverlayService) climatePDChanges(ctx context.Context, obligors []CreditObligor) (map[int]float64, error) {
+	if s.stress == nil {
+		return nil, nil
+	}
+	var indexes []int
+	var companies []climate.Company
+	for i, obligor := range obligors {
+		if _, ok := obligor.Factors[credit.FactorClimatePDChange]; ok || obligor.Financials == nil {
+			continue
+		}
+		company := *obligor.Financials
+		company.Name = strings.TrimSpace(obligor.Name)
+		if pd, ok := obligor.BasePD(); ok {
+			company.PD = &pd
+		}
+		indexes = append(indexes, i)
+		companies = append(companies, company)
+	}
+	if len(companies) == 0 {
+		return nil, nil
+	}
+
+	results, err := s.stress.StressTest(ctx, []string{s.stressScenario}, companies, 0)
+	if errors.Is(err, climate.ErrInvalidCompany) {
+		return nil, fmt.Errorf("%w: %v", credit.ErrInvalidObligor, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	changes := make(map[int]float64, len(indexes))
+	for j, company := range results[0].Companies {
+		for _, year := range company.Years {
+			if year.Year == s.stressYear {
+				changes[indexes[j]] = year.PDChange * 10000
+			}
+		}
+	}
+	return changes, nil
+}
+
+// History lists the bank's recorded adjustments, newest first, for one
+// obligor when obligor is set.
+func (s *CreditOverlayService) History(ctx context.Context, bankID uuid.UUID, obligor string, limit, offset int) ([]models.CreditAdjustment, error) {
+	return s.repo.Adjustments(ctx, bankID, companyKey(obligor), limit, offset)
+}
+
+func toCreditRuleRows(rules []credit.Rule) []models.CreditOverlayRule {
+	rows := make([]models.CreditOverlayRule, len(rules))
+	for i, rule := range rules {
+		rows[i] = models.CreditOverlayRule(rule)
+	}
+	return rows
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/edgeesg/edge-esg-backend/internal/marketdata"
+	"github.com/edgeesg/edge-esg-backend/internal/models"
+	"github.com/edgeesg/edge-esg-backend/internal/pcaf"
+	"github.com/edgeesg/edge-esg-backend/internal/repository"
+	"github.com/edgeesg/edge-esg-backend/internal/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MaxExposures bounds a portfolio's financed exposures.
+const MaxExposures = 500
+
+// companyEmissions is what is encrypted in carbon_emissions_encrypted.
+type companyEmissions struct {
+	Scope12 *float64 `json:"scope_1_2,omitempty"`
+	Scope3  *float64 `json:"scope_3,omitempty"`
+}
+
+// FinancedEmissionsService keeps the loans and investments of stored
+// portfolios and reports their financed emissions under PCAF. Company
+// emissions are stored encrypted with the gateway's ENCRYPTION_KEY.
+// Companies that report none are estimated from the sector emission
+// factors in the market data.
+type FinancedEmissionsService struct {
+	repo          *repository.PortfolioRepository
+	portfolios    *PortfolioService
+	factors       marketdata.EmissionFactorSource
+	encryptionKey string
+}
+
+func NewFinancedEmissionsService(repo *repository.PortfolioRepository, portfolios *PortfolioService, factors marketdata.EmissionFactorSource, encryptionKeyHex string) (*FinancedEmissionsService, error) {
+	keyBytes, err := hex.DecodeString(encryptionKeyHex)
+	if err != nil || len(keyBytes) != 32 {
+		return nil, fmt.Errorf("encryption key must be 64 hex characters")
+	}
+	return &FinancedEmissionsService{
+		repo:          repo,
+		portfolios:    portfolios,
+		factors:       factors,
+		encryptionKey: string(keyBytes),
+	}, nil
+}
+
+// ReplaceExposures validates and stores a portfolio's exposures in place
+// of its current ones.
+func (s *FinancedEmissionsService) ReplaceExposures(ctx context.Context, bankID, portfolioID uuid.UUID, exposures []pcaf.Exposure) ([]pcaf.Exposure, error) {
+	if len(exposures) > MaxExposures {
+		return nil, fmt.Errorf("%w: a portfolio has at most %d exposures", pcaf.ErrInvalidExposure, MaxExposures)
+	}
+	rows := make([]models.FinancedExposure, len(exposures))
+	for i := range exposures {
+		exposure := &exposures[i]
+		exposure.Counterparty = strings.TrimSpace(exposure.Counterparty)
+		exposure.Sector = strings.TrimSpace(exposure.Sector)
+		if exposure.Symbol != "" {
+			symbol, err := marketdata.NormalizeSymbol(exposure.Symbol)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", pcaf.ErrInvalidExposure, exposure.Counterparty, err)
+			}
+			exposure.Symbol = symbol
+		}
+		if err := exposure.Validate(); err != nil {
+			return nil, err
+		}
+		row, err := s.toRow(*exposure)
+		if err != nil {
+			return nil, err
+		}
+		rows[i] = row
+	}
+	err := s.repo.ReplaceExposures(ctx, bankID, portfolioID, rows)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPortfolioNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return exposures, nil
+}
+
+// Exposures returns a portfolio's exposures, decrypted.
+func (s *FinancedEmissionsService) Exposures(ctx context.Context, bankID, portfolioID uuid.UUID) ([]pcaf.Exposure, error) {
+	if _, err := s.portfolios.Get(ctx, bankID, portfolioID); err != nil {
+		return nil, err
+	}
+	rows, err := s.repo.Exposures(ctx, bankID, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+	exposures := make([]pcaf.Exposure, len(rows))
+	for i, row := range rows {
+		if exposures[i], err = s.fromRow(row); err != nil {
+			return nil, err
+		}
+	}
+	return exposures, nil
+}
+
+// Report attributes the emissions of each of a portfolio's exposures and
+// totals them by sector and asset class.
+func (s *FinancedEmissionsService) Report(ctx context.Context, bankID, portfolioID uuid.UUID) (*pcaf.Report, error) {
+	exposures, err := s.Exposures(ctx, bankID, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+	factors, err := s.factors.EmissionFactors()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load emission factors: %w", err)
+	}
+	return pcaf.NewReport(exposures, factors), nil
+}
+
+func (s *FinancedEmissionsService) toRow(exposure pcaf.Exposure) (models.FinancedExposure, error) {
+	row := models.FinancedExposure{
+		Counterparty:      exposure.Counterparty,
+		Symbol:            exposure.Symbol,
+		AssetClass:        exposure.AssetClass,
+		Sector:            exposure.Sector,
+		OutstandingAmount: exposure.Outstanding,
+		EVIC:              exposure.EVIC,
+		EquityPlusDebt:    exposure.EquityPlusDebt,
+		Revenue:           exposure.Revenue,
+		EmissionsSource:   exposure.EmissionsSource,
+	}
+	if exposure.Emissions == nil && exposure.Scope3 == nil {
+		return row, nil
+	}
+	plain, err := json.Marshal(companyEmissions{Scope12: exposure.Emissions, Scope3: exposure.Scope3})
+	if err != nil {
+		return row, err
+	}
+	if row.CarbonEmissionsEncrypted, err = utils.EncryptAES256GCM(string(plain), s.encryptionKey); err != nil {
+		return row, fmt.Errorf("failed to encrypt emissions: %w", err)
+	}
+	return row, nil
+}
+
+func (s *FinancedEmissionsService) fromRow(row models.FinancedExposure) (pcaf.Exposure, error) {
+	exposure := pcaf.Exposure{
+		Counterparty:    row.Counterparty,
+		Symbol:          row.Symbol,
+		AssetClass:      row.AssetClass,
+		Sector:          row.Sector,
+		Outstanding:     row.OutstandingAmount,
+		EVIC:            row.EVIC,
+		EquityPlusDebt:  row.EquityPlusDebt,
+		Revenue:         row.Revenue,
+		EmissionsSource: row.EmissionsSource,
+	}
+	if row.CarbonEmissionsEncrypted == "" {
+		return exposure, nil
+	}
+	plain, err := utils.DecryptAES256GCM(row.CarbonEmissionsEncrypted, s.encryptionKey)
+	if err != nil {
+		return exposure, fmt.Errorf("failed to decrypt emissions of exposure %s: %w", row.ID, err)
+	}
+	var emissions companyEmissions
+	if err := json.Unmarshal([]byte(plain), &emissions); err != nil {
+		return exposure, err
+	}
+	exposure.Emissions = emissions.Scope12
+	exposure.Scope3 = emissions.Scope3
+	return exposure, nil
+}
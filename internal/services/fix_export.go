@@ -0,0 +1,416 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/edgeesg/edge-esg-backend/internal/fix"
+	"github.com/edgeesg/edge-esg-backend/internal/loggers"
+	"github.com/edgeesg/edge-esg-backend/internal/metrics"
+	"github.com/edgeesg/edge-esg-backend/internal/models"
+	"github.com/edgeesg/edge-esg-backend/internal/repository"
+	"github.com/edgeesg/edge-esg-backend/internal/types"
+	"github.com/google/uuid"
+)
+
+// FIX export modes
+const (
+	FIXModeOff     = ""
+	FIXModeSession = "session"
+	FIXModeDryRun  = "dry-run"
+)
+
+// SignalActorFIX is the actor for status changes made by FIX export.
+const SignalActorFIX = "fix_export"
+
+const (
+	fixExportBatch   = 100
+	fixReportTimeout = 10 * time.Second
+	fixDialTimeout   = 10 * time.Second
+)
+
+var (
+	fixOrdersTotal = metrics.NewCounter("edge_fix_orders_total",
+		"Approved signals exported as FIX NewOrderSingle messages, by result", "result")
+	fixExecutionReportsTotal = metrics.NewCounter("edge_fix_execution_reports_total",
+		"FIX execution reports received, by OrdStatus", "ord_status")
+	fixLeader = metrics.NewGauge("edge_fix_export_leader",
+		"1 while this replica holds the FIX session and exports signals")
+)
+
+// FIXExportConfig configures FIX export. In session mode orders go to the
+// OMS at Address; in dry-run mode they are appended to DryRunFile and
+// signals stay APPROVED.
+type FIXExportConfig struct {
+	Mode         string
+	Address      string
+	SenderCompID string
+	TargetCompID string
+	HeartBtInt   time.Duration
+	ResetOnLogon bool
+	DryRunFile   string
+	// OrderNotional sizes each order: quantity is OrderNotional divided by
+	// the signal's price, rounded down to whole shares
+	OrderNotional float64
+	PollInterval  time.Duration
+}
+
+// orderSender is a FIX session, or a dry run standing in for one.
+type orderSender interface {
+	LoggedOn() bool
+	Send(msg *fix.Message) error
+}
+
+// FIXExporter sends approved trade signals to an OMS as market day orders
+// (NewOrderSingle, ClOrdID = signal ID, Account = bank ID) and applies the
+// execution reports that come back: a fill marks the signal FILLED, and an
+// order the OMS rejects, cancels or expires unfilled closes it.
+//
+// Every replica starts an exporter, but only the one holding the session's
+// lock in the database exports; the others wait to take over. Sequence
+// numbers, sent messages and which signals were exported are kept in the
+// database, so the session carries on wherever it next runs.
+type FIXExporter struct {
+	signals *SignalService
+	state   *repository.FIXRepository
+	cfg     FIXExportConfig
+	sender  orderSender
+	dryRun  bool
+	wg      sync.WaitGroup
+}
+
+// StartFIXExport starts exporting approved signals until ctx is done. It
+// returns nil when cfg.Mode is off.
+func StartFIXExport(ctx context.Context, signals *SignalService, state *repository.FIXRepository, cfg FIXExportConfig) (*FIXExporter, error) {
+	switch cfg.Mode {
+	case FIXModeOff:
+		return nil, nil
+	case FIXModeSession, FIXModeDryRun:
+	default:
+		return nil, fmt.Errorf("unknown FIX mode %q", cfg.Mode)
+	}
+	e := &FIXExporter{signals: signals, state: state, cfg: cfg, dryRun: cfg.Mode == FIXModeDryRun}
+	if e.dryRun {
+		if err := os.MkdirAll(filepath.Dir(cfg.DryRunFile), 0o750); err != nil {
+			return nil, fmt.Errorf("create FIX dry-run directory: %w", err)
+		}
+	}
+
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		e.lead(ctx)
+	}()
+
+	loggers.Info("FIX export started", map[string]interface{}{
+		"mode":   cfg.Mode,
+		"sender": cfg.SenderCompID,
+		"target": cfg.TargetCompID,
+	})
+	return e, nil
+}
+
+// Wait blocks until export has stopped and the session has logged out.
+func (e *FIXExporter) Wait() {
+	if e != nil {
+		e.wg.Wait()
+	}
+}
+
+// lead exports while this replica holds the session's lock, and waits to
+// take it over otherwise.
+func (e *FIXExporter) lead(ctx context.Context) {
+	for ctx.Err() == nil {
+		leadership, err := e.state.Lead(ctx, e.cfg.SenderCompID, e.cfg.TargetCompID, e.cfg.PollInterval)
+		if err != nil {
+			if ctx.Err() == nil {
+				loggers.Error("FIX export leader election failed", err, nil)
+				sleepContext(ctx, e.cfg.PollInterval)
+			}
+			continue
+		}
+		fixLeader.Set(1)
+		loggers.Info("FIX export leadership acquired", nil)
+
+		termCtx, cancel := context.WithCancel(ctx)
+		go func() {
+			select {
+			case <-leadership.Lost():
+				loggers.Warn("FIX export leadership lost", nil)
+				cancel()
+			case <-termCtx.Done():
+			}
+		}()
+		if err := e.run(termCtx); err != nil {
+			loggers.Error("FIX export stopped", err, nil)
+			sleepContext(termCtx, e.cfg.PollInterval)
+		}
+		cancel()
+		leadership.Release()
+		fixLeader.Set(0)
+	}
+}
+
+// run exports until ctx is done, then logs the session out.
+func (e *FIXExporter) run(ctx context.Context) error {
+	store, err := e.state.OpenStore(ctx, e.cfg.SenderCompID, e.cfg.TargetCompID)
+	if err != nil {
+		return fmt.Errorf("open FIX store: %w", err)
+	}
+	sessionCfg := fix.SessionConfig{
+		SenderCompID: e.cfg.SenderCompID,
+		TargetCompID: e.cfg.TargetCompID,
+		HeartBtInt:   e.cfg.HeartBtInt,
+		ResetOnLogon: e.cfg.ResetOnLogon,
+	}
+
+	var session sync.WaitGroup
+	if e.dryRun {
+		file, err := os.OpenFile(e.cfg.DryRunFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600) // #nosec G304 -- path from configuration
+		if err != nil {
+			return fmt.Errorf("open FIX dry-run file: %w", err)
+		}
+		defer file.Close()
+		e.sender = fix.NewDryRun(sessionCfg, store, file)
+	} else {
+		s := fix.NewSession(sessionCfg, store, e)
+		e.sender = s
+		session.Add(1)
+		go func() {
+			defer session.Done()
+			s.Run(ctx, func(ctx context.Context) (net.Conn, error) {
+				dialer := net.Dialer{Timeout: fixDialTimeout}
+				return dialer.DialContext(ctx, "tcp", e.cfg.Address)
+			})
+		}()
+	}
+	e.poll(ctx)
+	session.Wait()
+	return nil
+}
+
+func (e *FIXExporter) poll(ctx context.Context) {
+	ticker := time.NewTicker(e.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !e.sender.LoggedOn() {
+				continue
+			}
+			if err := e.exportApproved(ctx); err != nil {
+				loggers.Error("FIX export failed", err, nil)
+			}
+		}
+	}
+}
+
+func (e *FIXExporter) exportApproved(ctx context.Context) error {
+	approved, err := e.signals.Unexported(ctx, fixExportBatch)
+	if err != nil {
+		return err
+	}
+	for i := range approved {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err := e.export(ctx, &approved[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// export sends one signal. Outside a dry run the signal is marked SENT
+// first, so a signal cancelled meanwhile is never sent; if the session drops
+// before the order is stored, the signal is cancelled rather than left SENT
+// with no order behind it. A dry run marks the signal exported first, so it
+// is written at most once.
+func (e *FIXExporter) export(ctx context.Context, signal *models.TradeSignal) error {
+	quantity := 0.0
+	if signal.CurrentPrice > 0 {
+		quantity = math.Floor(e.cfg.OrderNotional / signal.CurrentPrice)
+	}
+	if quantity < 1 {
+		fixOrdersTotal.Inc("unsized")
+		if e.dryRun {
+			_, err := e.signals.MarkExported(ctx, signal.BankID, signal.ID)
+			return err
+		}
+		return e.close(ctx, signal, types.SignalCancelled, "order quantity below one share")
+	}
+
+	order := fix.NewMessage(fix.MsgNewOrderSingle).
+		Set(fix.TagClOrdID, signal.ID.String()).
+		Set(fix.TagAccount, signal.BankID.String()).
+		Set(fix.TagSymbol, signal.Symbol).
+		Set(fix.TagSide, fixSide(signal.Action)).
+		SetTime(fix.TagTransactTime, time.Now()).
+		SetFloat(fix.TagOrderQty, quantity).
+		Set(fix.TagOrdType, "1").
+		Set(fix.TagTimeInForce, "0")
+
+	if e.dryRun {
+		marked, err := e.signals.MarkExported(ctx, signal.BankID, signal.ID)
+		if err != nil || !marked {
+			return err
+		}
+		if err := e.sender.Send(order); err != nil {
+			return err
+		}
+		fixOrdersTotal.Inc("dry_run")
+		return nil
+	}
+
+	if _, err := e.signals.MarkSent(ctx, signal.BankID, signal.ID, SignalActorFIX, "ClOrdID "+signal.ID.String()); err != nil {
+		if errors.Is(err, ErrSignalTransition) {
+			return nil
+		}
+		return err
+	}
+	err := e.sender.Send(order)
+	switch {
+	case errors.Is(err, fix.ErrNotLoggedOn):
+		fixOrdersTotal.Inc("not_sent")
+		return e.close(ctx, signal, types.SignalCancelled, "FIX session went down before the order was sent")
+	case err != nil:
+		// Stored before the write failed; it goes out again on the
+		// counterparty's resend request after reconnecting
+		fixOrdersTotal.Inc("write_failed")
+		loggers.Warn("FIX order write failed; will be resent", map[string]interface{}{
+			"signal_id": signal.ID.String(),
+			"error":     err.Error(),
+		})
+		return nil
+	}
+	fixOrdersTotal.Inc("sent")
+	loggers.Info("FIX order sent", map[string]interface{}{
+		"bank_id":   signal.BankID.String(),
+		"signal_id": signal.ID.String(),
+		"symbol":    signal.Symbol,
+		"side":      signal.Action,
+		"quantity":  quantity,
+	})
+	return nil
+}
+
+func (e *FIXExporter) close(ctx context.Context, signal *models.TradeSignal, to types.SignalStatus, reason string) error {
+	_, err := e.signals.MarkClosed(ctx, signal.BankID, signal.ID, to, SignalActorFIX, reason)
+	if errors.Is(err, ErrSignalTransition) {
+		return nil
+	}
+	return err
+}
+
+// OnLogon and OnLogout need nothing: polling waits while logged out.
+func (e *FIXExporter) OnLogon()  {}
+func (e *FIXExporter) OnLogout() {}
+
+// FromApp applies execution reports to their signals. Reports that change
+// nothing, such as acknowledgements, partial fills and duplicates, are only
+// logged.
+func (e *FIXExporter) FromApp(msg *fix.Message) {
+	switch msg.MsgType() {
+	case fix.MsgExecutionReport:
+		e.applyExecutionReport(msg)
+	case fix.MsgBusinessReject, fix.MsgOrderCancelReject:
+		loggers.Warn("FIX order rejected by counterparty", map[string]interface{}{
+			"msg_type": msg.MsgType(),
+			"ref":      msg.Value(fix.TagRefSeqNum),
+			"text":     msg.Value(fix.TagText),
+		})
+	}
+}
+
+func (e *FIXExporter) applyExecutionReport(msg *fix.Message) {
+	ordStatus := msg.Value(fix.TagOrdStatus)
+	fixExecutionReportsTotal.Inc(ordStatus)
+	fields := map[string]interface{}{
+		"cl_ord_id":  msg.Value(fix.TagClOrdID),
+		"order_id":   msg.Value(fix.TagOrderID),
+		"exec_type":  msg.Value(fix.TagExecType),
+		"ord_status": ordStatus,
+		"cum_qty":    msg.Value(fix.TagCumQty),
+		"avg_px":     msg.Value(fix.TagAvgPx),
+		"text":       msg.Value(fix.TagText),
+	}
+	signalID, err := uuid.Parse(msg.Value(fix.TagClOrdID))
+	if err != nil {
+		loggers.Warn("FIX execution report for unknown order", fields)
+		return
+	}
+	bankID, err := uuid.Parse(msg.Value(fix.TagAccount))
+	if err != nil {
+		loggers.Warn("FIX execution report without a bank account", fields)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), fixReportTimeout)
+	defer cancel()
+
+	cumQty, _ := msg.Float(fix.TagCumQty)
+	reason := "OMS order " + msg.Value(fix.TagOrderID)
+	if text := msg.Value(fix.TagText); text != "" {
+		reason += ": " + text
+	}
+	switch ordStatus {
+	case "2": // Filled
+		_, err = e.signals.MarkFilled(ctx, bankID, signalID, SignalActorFIX, executionTime(msg))
+	case "4", "8", "C": // Canceled, Rejected, Expired
+		switch {
+		case cumQty > 0:
+			// Partly filled before the rest was cancelled
+			_, err = e.signals.MarkFilled(ctx, bankID, signalID, SignalActorFIX, executionTime(msg))
+		case ordStatus == "C":
+			_, err = e.signals.MarkClosed(ctx, bankID, signalID, types.SignalExpired, SignalActorFIX, reason)
+		default:
+			_, err = e.signals.MarkClosed(ctx, bankID, signalID, types.SignalCancelled, SignalActorFIX, reason)
+		}
+	default:
+		loggers.Info("FIX execution report", fields)
+		return
+	}
+
+	switch {
+	case err == nil:
+		loggers.Info("FIX execution report applied", fields)
+	case errors.Is(err, ErrSignalTransition), errors.Is(err, ErrSignalNotFound):
+		// A duplicate, or a signal already closed here
+		fields["error"] = err.Error()
+		loggers.Warn("FIX execution report not applied", fields)
+	default:
+		loggers.Error("Failed to apply FIX execution report", err, fields)
+	}
+}
+
+func executionTime(msg *fix.Message) time.Time {
+	if t, err := msg.Time(fix.TagTransactTime); err == nil {
+		return t
+	}
+	return time.Now()
+}
+
+func fixSide(action string) string {
+	if action == "SELL" {
+		return "2"
+	}
+	return "1"
+}
+
+func sleepContext(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
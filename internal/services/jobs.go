@@ -0,0 +1,513 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/edgeesg/edge-esg-backend/internal/loggers"
+	"github.com/edgeesg/edge-esg-backend/internal/metrics"
+	"github.com/edgeesg/edge-esg-backend/internal/types"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	jobQueueKey      = "jobs:queue"
+	jobProcessingKey = "jobs:processing"
+
+	// A running job's lease is renewed by its worker; when a gateway dies the
+	// lease lapses and the reaper puts the job back on the queue.
+	jobLeaseTTL      = 30 * time.Second
+	jobLeaseRenewal  = 10 * time.Second
+	jobPollTimeout   = 5 * time.Second
+	jobCancelPolling = time.Second
+)
+
+var (
+	ErrJobNotFound    = errors.New("job not found")
+	ErrJobFinished    = errors.New("job already finished")
+	ErrUnknownJobType = errors.New("unknown job type")
+)
+
+var jobsCompleted = metrics.NewCounter("edge_jobs_total",
+	"Finished asynchronous jobs by type and final status", "type", "status")
+
+// transitionScript moves a job hash from one status to another and sets the
+// given fields in the same step. KEYS[1]: job hash; ARGV[1]: expected status;
+// ARGV[2..]: field/value pairs, which must include the new status.
+var transitionScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'status') ~= ARGV[1] then
+  return 0
+end
+for i = 2, #ARGV, 2 do
+  redis.call('HSET', KEYS[1], ARGV[i], ARGV[i + 1])
+end
+return 1
+`)
+
+// Job is an asynchronous unit of work. Payload and Result hold the handler's
+// JSON input and output.
+type Job struct {
+	ID         string          `json:"id"`
+	Type       types.JobType   `json:"type"`
+	BankID     string          `json:"bank_id,omitempty"`
+	Status     types.JobStatus `json:"status"`
+	Progress   int             `json:"progress"`
+	Payload    json.RawMessage `json:"payload,omitempty"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	StartedAt  *time.Time      `json:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
+
+// IsFinished reports whether the job reached a terminal status.
+func (j *Job) IsFinished() bool {
+	return j.Status == types.JobSucceeded || j.Status == types.JobFailed || j.Status == types.JobCancelled
+}
+
+// JobHandler runs one job. It should return promptly once ctx is cancelled
+// and may call progress with a percentage as it goes.
+type JobHandler func(ctx context.Context, job *Job, progress func(percent int)) (interface{}, error)
+
+type JobConfig struct {
+	Workers   int
+	Timeout   time.Duration
+	Retention time.Duration
+}
+
+type registeredJob struct {
+	handler JobHandler
+	timeout time.Duration
+}
+
+// JobService queues jobs in Redis so they survive gateway restarts, and runs
+// them on a bounded pool of workers shared by every replica.
+type JobService struct {
+	client   *redis.Client
+	config   JobConfig
+	handlers map[types.JobType]registeredJob
+
+	// observer, if set, receives pipeline events from every job this
+	// gateway runs.
+	observer func(job *Job, event PipelineEvent)
+
+	running sync.WaitGroup
+
+	// Reaper candidates seen without a lease on the previous pass.
+	mu           sync.Mutex
+	missingLease map[string]bool
+}
+
+func NewJobService(client *redis.Client, config JobConfig) *JobService {
+	if config.Workers <= 0 {
+		config.Workers = 1
+	}
+	return &JobService{
+		client:       client,
+		config:       config,
+		handlers:     make(map[types.JobType]registeredJob),
+		missingLease: make(map[string]bool),
+	}
+}
+
+// Register installs the handler for a job type. Call before Start. A zero
+// timeout uses the configured default.
+func (s *JobService) Register(jobType types.JobType, handler JobHandler, timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = s.config.Timeout
+	}
+	s.handlers[jobType] = registeredJob{handler: handler, timeout: timeout}
+}
+
+// OnPipelineEvent forwards pipeline progress of running jobs, tagged with
+// the job's ID. Call before Start.
+func (s *JobService) OnPipelineEvent(observer func(job *Job, event PipelineEvent)) {
+	s.observer = observer
+}
+
+// Submit stores a job and queues it.
+func (s *JobService) Submit(ctx context.Context, jobType types.JobType, bankID string, payload interface{}) (*Job, error) {
+	if _, ok := s.handlers[jobType]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownJobType, jobType)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job payload: %w", err)
+	}
+
+	job := &Job{
+		ID:        uuid.New().String(),
+		Type:      jobType,
+		BankID:    bankID,
+		Status:    types.JobQueued,
+		Payload:   body,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, jobKey(job.ID), map[string]interface{}{
+		"type":       string(job.Type),
+		"bank_id":    job.BankID,
+		"status":     string(job.Status),
+		"progress":   0,
+		"payload":    string(job.Payload),
+		"created_at": formatJobTime(job.CreatedAt),
+	})
+	pipe.LPush(ctx, jobQueueKey, job.ID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to queue job: %w", err)
+	}
+	return job, nil
+}
+
+// Get loads a job. Jobs belonging to another bank are reported as not found.
+func (s *JobService) Get(ctx context.Context, id, bankID string) (*Job, error) {
+	job, err := s.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.BankID != bankID {
+		return nil, ErrJobNotFound
+	}
+	return job, nil
+}
+
+// Cancel stops a job. Queued jobs are cancelled immediately; running jobs
+// have their context cancelled by the worker within about a second.
+func (s *JobService) Cancel(ctx context.Context, id, bankID string) (*Job, error) {
+	job, err := s.Get(ctx, id, bankID)
+	if err != nil {
+		return nil, err
+	}
+	if job.IsFinished() {
+		return job, ErrJobFinished
+	}
+
+	if err := s.client.HSet(ctx, jobKey(id), "cancel_requested", 1).Err(); err != nil {
+		return nil, fmt.Errorf("failed to cancel job: %w", err)
+	}
+	applied, err := s.transition(ctx, id, types.JobQueued, types.JobCancelled, "finished_at", formatJobTime(time.Now().UTC()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel job: %w", err)
+	}
+	if applied {
+		s.expire(ctx, id)
+		jobsCompleted.Inc(string(job.Type), string(types.JobCancelled))
+	}
+	return s.load(ctx, id)
+}
+
+// Start runs the worker pool and the lease reaper until ctx is cancelled.
+// Jobs interrupted by the cancellation go back on the queue.
+func (s *JobService) Start(ctx context.Context) {
+	for i := 0; i < s.config.Workers; i++ {
+		s.running.Add(1)
+		go func() {
+			defer s.running.Done()
+			s.worker(ctx)
+		}()
+	}
+	go s.reaper(ctx)
+}
+
+// Wait blocks until every worker started by Start has returned.
+func (s *JobService) Wait() {
+	s.running.Wait()
+}
+
+func (s *JobService) worker(ctx context.Context) {
+	for ctx.Err() == nil {
+		id, err := s.client.BLMove(ctx, jobQueueKey, jobProcessingKey, "RIGHT", "LEFT", jobPollTimeout).Result()
+		if err != nil {
+			if !errors.Is(err, redis.Nil) && ctx.Err() == nil {
+				loggers.Warn("Job queue unavailable", map[string]interface{}{"error": err.Error()})
+				time.Sleep(jobPollTimeout)
+			}
+			continue
+		}
+		s.run(ctx, id)
+	}
+}
+
+func (s *JobService) run(ctx context.Context, id string) {
+	defer s.client.LRem(context.Background(), jobProcessingKey, 1, id)
+
+	s.client.Set(ctx, jobLeaseKey(id), 1, jobLeaseTTL)
+	defer s.client.Del(context.Background(), jobLeaseKey(id))
+
+	started, err := s.transition(ctx, id, types.JobQueued, types.JobRunning,
+		"started_at", formatJobTime(time.Now().UTC()), "progress", 10)
+	if err != nil || !started {
+		// Cancelled while queued, expired, or already handled elsewhere
+		return
+	}
+
+	job, err := s.load(ctx, id)
+	if err != nil {
+		return
+	}
+
+	registered, ok := s.handlers[job.Type]
+	if !ok {
+		// Queued by a replica that knows a type this one does not, such as
+		// during a rolling deploy
+		s.finish(id, job.Type, types.JobFailed,
+			"finished_at", formatJobTime(time.Now().UTC()),
+			"error", fmt.Sprintf("%s: %s", ErrUnknownJobType, job.Type))
+		return
+	}
+	runCtx, cancel := context.WithTimeout(ctx, registered.timeout)
+	defer cancel()
+	runCtx = WithBankID(runCtx, job.BankID)
+	if s.observer != nil {
+		runCtx = WithPipelineObserver(runCtx, func(e PipelineEvent) {
+			e.JobID = job.ID
+			s.observer(job, e)
+		})
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go s.watch(runCtx, id, cancel, done)
+
+	progress := func(percent int) {
+		if percent < 10 || percent > 99 {
+			return
+		}
+		s.client.HSet(context.Background(), jobKey(id), "progress", percent)
+	}
+
+	result, runErr := registered.handler(runCtx, job, progress)
+	if ctx.Err() != nil {
+		// Shutting down; hand the job back so another worker reruns it
+		requeued, err := s.transition(context.Background(), id, types.JobRunning, types.JobQueued, "progress", 0)
+		if err == nil && requeued {
+			s.client.LPush(context.Background(), jobQueueKey, id)
+		}
+		return
+	}
+
+	status := types.JobSucceeded
+	fields := []interface{}{"finished_at", formatJobTime(time.Now().UTC())}
+	switch {
+	case s.cancelRequested(id):
+		status = types.JobCancelled
+	case runErr != nil:
+		status = types.JobFailed
+		message := runErr.Error()
+		if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+			message = fmt.Sprintf("job timed out after %s", registered.timeout)
+		}
+		fields = append(fields, "error", message)
+	default:
+		body, err := json.Marshal(result)
+		if err != nil {
+			status = types.JobFailed
+			fields = append(fields, "error", "failed to encode result")
+		} else {
+			fields = append(fields, "result", string(body), "progress", 100)
+		}
+	}
+
+	s.finish(id, job.Type, status, fields...)
+}
+
+// finish records a running job's outcome and starts its retention period.
+func (s *JobService) finish(id string, jobType types.JobType, status types.JobStatus, fields ...interface{}) {
+	finishCtx := context.Background()
+	if _, err := s.transition(finishCtx, id, types.JobRunning, status, fields...); err != nil {
+		loggers.Error("Failed to record job result", err, map[string]interface{}{
+			"job_id": id,
+		})
+		return
+	}
+	s.expire(finishCtx, id)
+	jobsCompleted.Inc(string(jobType), string(status))
+}
+
+// AppendItem records one partial result of a job, such as a single company
+// of a batch. Items are kept as long as the job.
+func (s *JobService) AppendItem(ctx context.Context, id string, item interface{}) error {
+	body, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("failed to encode job item: %w", err)
+	}
+	return s.client.RPush(ctx, jobItemsKey(id), body).Err()
+}
+
+// Items returns a job's partial results from offset onwards.
+func (s *JobService) Items(ctx context.Context, id string, offset int64) ([]json.RawMessage, error) {
+	values, err := s.client.LRange(ctx, jobItemsKey(id), offset, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load job items: %w", err)
+	}
+	items := make([]json.RawMessage, len(values))
+	for i, v := range values {
+		items[i] = json.RawMessage(v)
+	}
+	return items, nil
+}
+
+func (s *JobService) expire(ctx context.Context, id string) {
+	s.client.Expire(ctx, jobKey(id), s.config.Retention)
+	s.client.Expire(ctx, jobItemsKey(id), s.config.Retention)
+}
+
+// watch renews the job's lease and cancels it when a cancel is requested.
+func (s *JobService) watch(ctx context.Context, id string, cancel context.CancelFunc, done <-chan struct{}) {
+	ticker := time.NewTicker(jobCancelPolling)
+	defer ticker.Stop()
+	lastRenewal := time.Now()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if s.cancelRequested(id) {
+				cancel()
+			}
+			if time.Since(lastRenewal) >= jobLeaseRenewal {
+				s.client.Expire(context.Background(), jobLeaseKey(id), jobLeaseTTL)
+				lastRenewal = time.Now()
+			}
+		}
+	}
+}
+
+func (s *JobService) cancelRequested(id string) bool {
+	flag, err := s.client.HGet(context.Background(), jobKey(id), "cancel_requested").Result()
+	return err == nil && flag == "1"
+}
+
+// reaper requeues jobs left in the processing list by a gateway that stopped
+// without finishing them. A job must be seen without a lease on two passes
+// in a row so a worker that has just claimed it is not raced.
+func (s *JobService) reaper(ctx context.Context) {
+	ticker := time.NewTicker(jobLeaseTTL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.reap(ctx)
+		}
+	}
+}
+
+func (s *JobService) reap(ctx context.Context) {
+	ids, err := s.client.LRange(ctx, jobProcessingKey, 0, -1).Result()
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	previous := s.missingLease
+	s.missingLease = make(map[string]bool)
+	s.mu.Unlock()
+
+	for _, id := range ids {
+		exists, err := s.client.Exists(ctx, jobLeaseKey(id)).Result()
+		if err != nil || exists > 0 {
+			continue
+		}
+		if !previous[id] {
+			s.mu.Lock()
+			s.missingLease[id] = true
+			s.mu.Unlock()
+			continue
+		}
+
+		removed, err := s.client.LRem(ctx, jobProcessingKey, 1, id).Result()
+		if err != nil || removed == 0 {
+			continue
+		}
+		requeued, err := s.transition(ctx, id, types.JobRunning, types.JobQueued, "progress", 0)
+		if err != nil {
+			continue
+		}
+		status, _ := s.client.HGet(ctx, jobKey(id), "status").Result()
+		if requeued || status == string(types.JobQueued) {
+			s.client.LPush(ctx, jobQueueKey, id)
+			loggers.Warn("Requeued abandoned job", map[string]interface{}{"job_id": id})
+		}
+	}
+}
+
+func (s *JobService) transition(ctx context.Context, id string, from, to types.JobStatus, fields ...interface{}) (bool, error) {
+	args := append([]interface{}{string(from), "status", string(to)}, fields...)
+	applied, err := transitionScript.Run(ctx, s.client, []string{jobKey(id)}, args...).Int()
+	if err != nil {
+		return false, err
+	}
+	return applied == 1, nil
+}
+
+func (s *JobService) load(ctx context.Context, id string) (*Job, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrJobNotFound
+	}
+
+	fields, err := s.client.HGetAll(ctx, jobKey(id)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load job: %w", err)
+	}
+	if len(fields) == 0 {
+		return nil, ErrJobNotFound
+	}
+
+	job := &Job{
+		ID:        id,
+		Type:      types.JobType(fields["type"]),
+		BankID:    fields["bank_id"],
+		Status:    types.JobStatus(fields["status"]),
+		Error:     fields["error"],
+		CreatedAt: parseJobTime(fields["created_at"]),
+	}
+	job.Progress, _ = strconv.Atoi(fields["progress"])
+	if payload := fields["payload"]; payload != "" {
+		job.Payload = json.RawMessage(payload)
+	}
+	if result := fields["result"]; result != "" {
+		job.Result = json.RawMessage(result)
+	}
+	if started := fields["started_at"]; started != "" {
+		t := parseJobTime(started)
+		job.StartedAt = &t
+	}
+	if finished := fields["finished_at"]; finished != "" {
+		t := parseJobTime(finished)
+		job.FinishedAt = &t
+	}
+	return job, nil
+}
+
+func jobKey(id string) string {
+	return "job:" + id
+}
+
+func jobItemsKey(id string) string {
+	return "job:" + id + ":items"
+}
+
+func jobLeaseKey(id string) string {
+	return "job:" + id + ":lease"
+}
+
+func formatJobTime(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
+
+func parseJobTime(s string) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, s)
+	return t
+}
@@ -2,11 +2,21 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/edgeesg/edge-esg-backend/internal/agents"
+	"github.com/edgeesg/edge-esg-backend/internal/climate"
+	"github.com/edgeesg/edge-esg-backend/internal/credit"
 	"github.com/edgeesg/edge-esg-backend/internal/dtos"
+	"github.com/edgeesg/edge-esg-backend/internal/loggers"
+	"github.com/edgeesg/edge-esg-backend/internal/marketdata"
+	"github.com/edgeesg/edge-esg-backend/internal/models"
+	"github.com/edgeesg/edge-esg-backend/internal/physical"
+	"github.com/edgeesg/edge-esg-backend/internal/quant"
+	"github.com/google/uuid"
 )
 
 type Orchestrator struct {
@@ -21,11 +31,16 @@ type Orchestrator struct {
 	regulationAgent   *agents.RegulationAgent
 	optimizationAgent *agents.OptimizationAgent
 	digitalTwinAgent  *agents.DigitalTwinAgent
+	signals           *SignalService
+	marketData        marketdata.Source
+	benchmarks        marketdata.BenchmarkSource
+	physicalRisk      *PhysicalRiskService
+	creditOverlays    *CreditOverlayService
 }
 
-func NewOrchestrator() *Orchestrator {
+func NewOrchestrator(quota *QuotaManager) *Orchestrator {
 	return &Orchestrator{
-		realtimeAgents:    NewRealTimeAgents(),
+		realtimeAgents:    NewRealTimeAgents(quota),
 		riskAgent:         agents.NewRiskAgent(),
 		tradingAgent:      agents.NewTradingAgent(),
 		esgScoringAgent:   agents.NewESGScoringAgent(),
@@ -39,11 +54,54 @@ func NewOrchestrator() *Orchestrator {
 	}
 }
 
+// RecordSignals makes analyses run for a bank store their BUY and SELL
+// signals for approval.
+func (o *Orchestrator) RecordSignals(signals *SignalService) {
+	o.signals = signals
+}
+
+// UseMarketData gives portfolio optimisation price history to estimate
+// covariance from, and the risk agent history to measure value at risk.
+func (o *Orchestrator) UseMarketData(prices marketdata.Source) {
+	o.marketData = prices
+	o.optimizationAgent.UsePriceHistory(prices)
+	o.riskAgent.UsePriceHistory(prices)
+}
+
+// UseBenchmarks lets portfolio comparisons be measured against benchmark
+// definitions.
+func (o *Orchestrator) UseBenchmarks(benchmarks marketdata.BenchmarkSource) {
+	o.benchmarks = benchmarks
+}
+
+// UsePhysicalRisk adds the physical climate risk of a company's asset
+// locations to its risk assessment, for analyses run for a bank that has
+// stored them.
+func (o *Orchestrator) UsePhysicalRisk(physicalRisk *PhysicalRiskService) {
+	o.physicalRisk = physicalRisk
+}
+
+// UseCreditOverlays lets analyses given a base rating or PD adjust it for
+// ESG and climate risk under the bank's overlay policy.
+func (o *Orchestrator) UseCreditOverlays(creditOverlays *CreditOverlayService) {
+	o.creditOverlays = creditOverlays
+}
+
+// Steps reported in pipeline progress events
+const (
+	analyzePipelineSteps            = 8
+	comparePipelineStepsPerCompany  = 6 // plus one for the optimisation
+	frontierPipelineStepsPerCompany = 3 // plus one for the frontier
+)
+
 // Execute8LayerPipeline orchestrates all 10 agents with REAL-TIME data
 func (o *Orchestrator) Execute8LayerPipeline(ctx context.Context, req *dtos.AnalyzeRequest) (*dtos.AnalyzeResponse, error) {
 	startTime := time.Now()
+	tracker := newPipelineTracker(ctx, analyzePipelineSteps)
+	company := req.CompanyName
 
 	// Step 1: Validate company exists by checking if we can get real data
+	tracker.start(1, company, AgentMarketData)
 	stockSymbol := o.realtimeAgents.GuessStockSymbol(req.CompanyName)
 
 	// Try to get stock price
@@ -51,23 +109,30 @@ func (o *Orchestrator) Execute8LayerPipeline(ctx context.Context, req *dtos.Anal
 	var currentPrice float64
 	if stockErr != nil || stockData == nil {
 		// Try Alpha Vantage as fallback
-		currentPrice, stockErr = o.realtimeAgents.GetAlphaVantagePrice(stockSymbol)
+		currentPrice, stockErr = o.realtimeAgents.GetAlphaVantagePrice(ctx, stockSymbol)
 	} else {
 		currentPrice = stockData.Price
 	}
 
 	// Try to get news
-	sentiment, newsErr := o.realtimeAgents.GetNewsSentiment(req.CompanyName)
+	sentiment, newsErr := o.realtimeAgents.GetNewsSentiment(ctx, req.CompanyName)
 
 	// If BOTH stock price AND news fail, company likely doesn't exist
 	if (stockErr != nil || currentPrice == 0) && newsErr != nil {
-		return nil, fmt.Errorf("company '%s' not found - unable to retrieve market data or news. Please check the company name and try again", req.CompanyName)
+		err := fmt.Errorf("company '%s' not found - unable to retrieve market data or news. Please check the company name and try again", req.CompanyName)
+		tracker.fail(1, company, AgentMarketData, err)
+		return nil, err
 	}
 
 	// If only news failed but we have stock price, continue with neutral sentiment
 	if newsErr != nil {
 		sentiment = 0.5 // Neutral default
 	}
+	tracker.complete(1, company, AgentMarketData, map[string]interface{}{
+		"symbol":         stockSymbol,
+		"current_price":  currentPrice,
+		"news_sentiment": sentiment,
+	})
 
 	// Step 2: ESG Scoring Agent - Calculate comprehensive ESG score
 	esgReq := &agents.ESGCalculationRequest{
@@ -75,10 +140,18 @@ func (o *Orchestrator) Execute8LayerPipeline(ctx context.Context, req *dtos.Anal
 		NewsSentiment: sentiment,
 		Industry:      "technology", // TODO: detect industry
 	}
+	tracker.start(2, company, AgentESGScoring)
 	esgResult, err := o.esgScoringAgent.CalculateESG(ctx, esgReq)
 	if err != nil {
+		tracker.fail(2, company, AgentESGScoring, err)
 		return nil, fmt.Errorf("ESG scoring failed: %w", err)
 	}
+	tracker.complete(2, company, AgentESGScoring, map[string]interface{}{
+		"esg_score":     esgResult.OverallScore,
+		"environmental": esgResult.Environmental,
+		"social":        esgResult.Social,
+		"governance":    esgResult.Governance,
+	})
 
 	// Step 3: Get stock data (already validated above)
 	if currentPrice == 0 {
@@ -89,14 +162,41 @@ func (o *Orchestrator) Execute8LayerPipeline(ctx context.Context, req *dtos.Anal
 	// Step 4: Risk Agent - Assess financing risk
 	riskReq := &agents.RiskAssessmentRequest{
 		CompanyName:     req.CompanyName,
+		Symbol:          stockSymbol,
 		ESGScore:        esgResult.OverallScore,
 		NewsSentiment:   sentiment,
 		StockVolatility: 0.15, // Default volatility
+		MarketRisk:      marketRiskParams(req.MarketRisk),
 	}
+	physicalRisk := o.companyPhysicalRisk(ctx, req.CompanyName)
+	if physicalRisk != nil {
+		riskReq.PhysicalRisk = physicalRisk.Score
+	}
+	creditRisk, err := o.adjustCredit(ctx, req, esgResult, physicalRisk)
+	if err != nil {
+		return nil, fmt.Errorf("credit overlay failed: %w", err)
+	}
+	if creditRisk != nil {
+		riskReq.Credit = &credit.Adjustment{
+			BaseRating:     creditRisk.BaseRating,
+			BasePD:         creditRisk.BasePD,
+			AdjustedRating: creditRisk.AdjustedRating,
+			AdjustedPD:     creditRisk.AdjustedPD,
+			RuleNotches:    creditRisk.RuleNotches,
+			Notches:        creditRisk.Notches,
+		}
+	}
+	tracker.start(3, company, AgentRisk)
 	riskResult, err := o.riskAgent.AssessRisk(ctx, riskReq)
 	if err != nil {
+		tracker.fail(3, company, AgentRisk, err)
 		return nil, fmt.Errorf("risk assessment failed: %w", err)
 	}
+	tracker.complete(3, company, AgentRisk, map[string]interface{}{
+		"risk_level":  riskResult.RiskLevel,
+		"risk_score":  riskResult.RiskScore,
+		"risk_action": riskResult.Action,
+	})
 
 	// Step 5: Trading Agent - Generate trading signal
 	tradingReq := &agents.TradingSignalRequest{
@@ -106,10 +206,17 @@ func (o *Orchestrator) Execute8LayerPipeline(ctx context.Context, req *dtos.Anal
 		ESGScore:     esgResult.OverallScore,
 		Sentiment:    sentiment,
 	}
+	tracker.start(4, company, AgentTrading)
 	tradingResult, err := o.tradingAgent.GenerateSignal(ctx, tradingReq)
 	if err != nil {
+		tracker.fail(4, company, AgentTrading, err)
 		return nil, fmt.Errorf("trading signal failed: %w", err)
 	}
+	tracker.complete(4, company, AgentTrading, map[string]interface{}{
+		"action":       tradingResult.Action,
+		"target_price": fmt.Sprintf("$%.2f", tradingResult.TargetPrice),
+		"confidence":   tradingResult.Confidence,
+	})
 
 	// Step 6: Compliance Agent - Check regulatory compliance
 	complianceReq := &agents.ComplianceRequest{
@@ -117,10 +224,16 @@ func (o *Orchestrator) Execute8LayerPipeline(ctx context.Context, req *dtos.Anal
 		Industry:    "technology",
 		Region:      "global",
 	}
+	tracker.start(5, company, AgentCompliance)
 	complianceResult, err := o.complianceAgent.CheckCompliance(ctx, complianceReq)
 	if err != nil {
+		tracker.fail(5, company, AgentCompliance, err)
 		return nil, fmt.Errorf("compliance check failed: %w", err)
 	}
+	tracker.complete(5, company, AgentCompliance, map[string]interface{}{
+		"is_compliant":     complianceResult.IsCompliant,
+		"compliance_score": complianceResult.ComplianceScore,
+	})
 
 	// Step 7: Consensus Agent - Aggregate all agent decisions
 	consensusReq := &agents.ConsensusRequest{
@@ -146,10 +259,15 @@ func (o *Orchestrator) Execute8LayerPipeline(ctx context.Context, req *dtos.Anal
 			},
 		},
 	}
+	tracker.start(6, company, AgentConsensus)
 	consensusResult, err := o.consensusAgent.ReachConsensus(ctx, consensusReq)
 	if err != nil {
+		tracker.fail(6, company, AgentConsensus, err)
 		return nil, fmt.Errorf("consensus failed: %w", err)
 	}
+	tracker.complete(6, company, AgentConsensus, map[string]interface{}{
+		"final_decision": consensusResult.FinalDecision,
+	})
 
 	// Step 8: Blockchain Agent - Record audit trail
 	auditData := fmt.Sprintf("Company:%s,ESG:%.1f,Risk:%s,Trading:%s,Consensus:%s",
@@ -159,16 +277,26 @@ func (o *Orchestrator) Execute8LayerPipeline(ctx context.Context, req *dtos.Anal
 		AnalysisData: auditData,
 		Timestamp:    time.Now().Unix(),
 	}
+	tracker.start(7, company, AgentBlockchain)
 	auditResult, _ := o.blockchainAgent.RecordAudit(ctx, auditReq)
+	tracker.complete(7, company, AgentBlockchain, map[string]interface{}{
+		"audit_hash": auditResult.TransactionHash,
+	})
 
 	// Step 9: Get historical returns for investment analysis
-	historicalReturns := o.realtimeAgents.CalculateHistoricalReturns(stockSymbol, currentPrice)
+	tracker.start(8, company, AgentProjection)
+	historicalReturns := o.realtimeAgents.CalculateHistoricalReturns(ctx, stockSymbol, currentPrice)
 
 	// Step 10: Calculate investment projections based on historical returns
 	investmentProjections := o.realtimeAgents.CalculateInvestmentProjections(stockSymbol, currentPrice, historicalReturns)
+	tracker.complete(8, company, AgentProjection, map[string]interface{}{
+		"historical_periods": len(historicalReturns),
+		"projections":        len(investmentProjections),
+	})
 
 	// Build comprehensive response
 	response := &dtos.AnalyzeResponse{
+		AnalysisID: tracker.analysisID,
 		ESGScore:   fmt.Sprintf("%.1f/10", esgResult.OverallScore),
 		RiskAction: riskResult.Action,
 		TradingSignal: dtos.TradingSignal{
@@ -181,19 +309,144 @@ func (o *Orchestrator) Execute8LayerPipeline(ctx context.Context, req *dtos.Anal
 		},
 		HistoricalReturns:     historicalReturns,
 		InvestmentProjections: investmentProjections,
+		MarketRisk:            marketRiskResponse(riskResult.MarketRisk),
+		PhysicalRisk:          physicalRiskSummary(physicalRisk),
+		CreditRisk:            creditAdjustmentResponse(creditRisk),
 		RiskReasons:           riskResult.Reasons,
 		ProcessingTimeMs:      time.Since(startTime).Milliseconds(),
 		AuditHash:             auditResult.TransactionHash,
 		Timestamp:             time.Now(),
 	}
 
+	signal := o.recordSignal(ctx, SignalInput{
+		Company:      req.CompanyName,
+		AnalysisID:   tracker.analysisID,
+		Symbol:       tradingResult.Symbol,
+		Action:       tradingResult.Action,
+		CurrentPrice: tradingResult.CurrentPrice,
+		TargetPrice:  tradingResult.TargetPrice,
+		Confidence:   tradingResult.Confidence,
+		ESGScore:     esgResult.OverallScore,
+		RiskAction:   riskResult.Action,
+		Reasoning:    tradingResult.Reasoning,
+	})
+	if signal != nil {
+		response.TradingSignal.SignalID = signal.ID.String()
+		response.TradingSignal.SignalStatus = signal.Status
+	}
+
 	return response, nil
 }
 
+// recordSignal stores the analysis's signal for the bank it runs for. A
+// storage failure is logged rather than failing the analysis.
+func (o *Orchestrator) recordSignal(ctx context.Context, in SignalInput) *models.TradeSignal {
+	if o.signals == nil || !recordsSignals(ctx) {
+		return nil
+	}
+	bankID, err := uuid.Parse(BankIDFromContext(ctx))
+	if err != nil {
+		return nil
+	}
+	signal, err := o.signals.Record(ctx, bankID, in)
+	if err != nil {
+		loggers.Error("Failed to record trade signal", err, map[string]interface{}{
+			"bank_id": bankID.String(),
+			"company": in.Company,
+		})
+		return nil
+	}
+	return signal
+}
+
+// companyPhysicalRisk scores the physical risk of a company's assets for
+// the bank the analysis runs for. It is nil when the company has no scored
+// locations; a lookup failure is logged rather than failing the analysis.
+func (o *Orchestrator) companyPhysicalRisk(ctx context.Context, company string) *physical.Risk {
+	if o.physicalRisk == nil {
+		return nil
+	}
+	bankID, err := uuid.Parse(BankIDFromContext(ctx))
+	if err != nil {
+		return nil
+	}
+	risk, err := o.physicalRisk.CompanyRisk(ctx, bankID, company)
+	if errors.Is(err, ErrNoLocations) {
+		return nil
+	}
+	if err != nil {
+		loggers.Error("Failed to score physical risk", err, map[string]interface{}{
+			"bank_id": bankID.String(),
+			"company": company,
+		})
+		return nil
+	}
+	if risk.Score == nil {
+		return nil
+	}
+	return risk
+}
+
+// adjustCredit records the overlay on the base rating or PD the request
+// gives, with the analysis's ESG scores and physical risk as factors, and
+// the climate stress of the financials the request gives. It is nil
+// without a base, or outside a bank.
+func (o *Orchestrator) adjustCredit(ctx context.Context, req *dtos.AnalyzeRequest, esg *agents.ESGCalculationResponse, physicalRisk *physical.Risk) (*models.CreditAdjustment, error) {
+	if o.creditOverlays == nil || req.Credit == nil {
+		return nil, nil
+	}
+	bankID, err := uuid.Parse(BankIDFromContext(ctx))
+	if err != nil {
+		return nil, nil
+	}
+	obligor := CreditObligor{Obligor: credit.Obligor{
+		Name:   req.CompanyName,
+		Rating: req.Credit.Rating,
+		PD:     req.Credit.PD,
+		Factors: map[string]float64{
+			credit.FactorESGScore:      esg.OverallScore,
+			credit.FactorEnvironmental: esg.Environmental,
+			credit.FactorSocial:        esg.Social,
+			credit.FactorGovernance:    esg.Governance,
+		},
+	}}
+	if physicalRisk != nil {
+		obligor.Factors[credit.FactorPhysicalRisk] = *physicalRisk.Score
+	}
+	if f := req.Credit.Financials; f != nil {
+		obligor.Financials = &climate.Company{
+			Sector:          f.Sector,
+			Revenue:         f.Revenue,
+			EBITDA:          f.EBITDA,
+			Emissions:       f.Emissions,
+			EnterpriseValue: f.EnterpriseValue,
+			Debt:            f.TotalDebt,
+			AssetVolatility: f.AssetVolatility,
+		}
+	}
+	adjustments, err := o.creditOverlays.Adjust(ctx, bankID, ActorFromContext(ctx), CreditSourceAnalysis, []CreditObligor{obligor})
+	if err != nil {
+		return nil, err
+	}
+	return &adjustments[0], nil
+}
+
 // ComparePortfolio analyzes multiple companies and provides portfolio optimization
 func (o *Orchestrator) ComparePortfolio(ctx context.Context, req *dtos.PortfolioCompareRequest) (*dtos.PortfolioCompareResponse, error) {
 	startTime := time.Now()
 
+	// An unknown benchmark fails before any company is analysed
+	var benchmark *marketdata.Benchmark
+	if req.Benchmark != "" {
+		if o.benchmarks == nil {
+			return nil, fmt.Errorf("%w: benchmarks are not configured", marketdata.ErrNoData)
+		}
+		var err error
+		if benchmark, err = o.benchmarks.Benchmark(req.Benchmark); err != nil {
+			return nil, err
+		}
+	}
+
 	response := &dtos.PortfolioCompareResponse{
 		Companies:         make([]dtos.CompanyComparison, 0, len(req.Companies)),
 		OptimalAllocation: make([]float64, len(req.Companies)),
@@ -204,34 +457,29 @@ func (o *Orchestrator) ComparePortfolio(ctx context.Context, req *dtos.Portfolio
 	esgScores := make([]float64, 0, len(req.Companies))
 	expectedReturns := make([]float64, 0, len(req.Companies))
 	validCompanies := make([]string, 0, len(req.Companies))
+	symbols := make([]string, 0, len(req.Companies))
 	bestESGScore := 0.0
 	lowestRisk := 100.0
 	invalidCompanies := make([]string, 0)
 
-	for _, companyName := range req.Companies {
-		// Validate company by checking if we can get real data
-		stockSymbol := o.realtimeAgents.GuessStockSymbol(companyName)
-		stockData, stockErr := o.realtimeAgents.GetStockPrice(stockSymbol)
-		var currentPrice float64
-		if stockErr != nil || stockData == nil {
-			currentPrice, stockErr = o.realtimeAgents.GetAlphaVantagePrice(stockSymbol)
-		} else {
-			currentPrice = stockData.Price
-		}
+	tracker := newPipelineTracker(ctx, comparePipelineStepsPerCompany*len(req.Companies)+1)
 
-		sentiment, newsErr := o.realtimeAgents.GetNewsSentiment(companyName)
+	for i, companyName := range req.Companies {
+		step := i * comparePipelineStepsPerCompany
 
-		// Skip invalid companies (both stock and news failed)
-		if (stockErr != nil || currentPrice == 0) && newsErr != nil {
+		// Validate company by checking if we can get real data
+		tracker.start(step+1, companyName, AgentMarketData)
+		stockSymbol, currentPrice, sentiment, err := o.quoteCompany(ctx, companyName)
+		if err != nil {
+			tracker.fail(step+1, companyName, AgentMarketData, err)
 			invalidCompanies = append(invalidCompanies, companyName)
 			continue
 		}
-
-		if newsErr != nil {
-			sentiment = 0.5
-		}
-
-		validCompanies = append(validCompanies, companyName)
+		tracker.complete(step+1, companyName, AgentMarketData, map[string]interface{}{
+			"symbol":         stockSymbol,
+			"current_price":  currentPrice,
+			"news_sentiment": sentiment,
+		})
 
 		// ESG Scoring
 		esgReq := &agents.ESGCalculationRequest{
@@ -239,19 +487,39 @@ func (o *Orchestrator) ComparePortfolio(ctx context.Context, req *dtos.Portfolio
 			NewsSentiment: sentiment,
 			Industry:      "technology",
 		}
+		tracker.start(step+2, companyName, AgentESGScoring)
 		esgResult, err := o.esgScoringAgent.CalculateESG(ctx, esgReq)
 		if err != nil {
+			tracker.fail(step+2, companyName, AgentESGScoring, err)
 			continue
 		}
+		tracker.complete(step+2, companyName, AgentESGScoring, map[string]interface{}{
+			"esg_score":     esgResult.OverallScore,
+			"environmental": esgResult.Environmental,
+			"social":        esgResult.Social,
+			"governance":    esgResult.Governance,
+		})
 
 		// Risk Assessment
 		riskReq := &agents.RiskAssessmentRequest{
 			CompanyName:     companyName,
+			Symbol:          stockSymbol,
 			ESGScore:        esgResult.OverallScore,
 			NewsSentiment:   sentiment,
 			StockVolatility: 0.15,
+			MarketRisk:      marketRiskParams(req.MarketRisk),
+		}
+		physicalRisk := o.companyPhysicalRisk(ctx, companyName)
+		if physicalRisk != nil {
+			riskReq.PhysicalRisk = physicalRisk.Score
 		}
+		tracker.start(step+3, companyName, AgentRisk)
 		riskResult, _ := o.riskAgent.AssessRisk(ctx, riskReq)
+		tracker.complete(step+3, companyName, AgentRisk, map[string]interface{}{
+			"risk_level":  riskResult.RiskLevel,
+			"risk_score":  riskResult.RiskScore,
+			"risk_action": riskResult.Action,
+		})
 
 		// Trading Signal
 		tradingReq := &agents.TradingSignalRequest{
@@ -261,7 +529,13 @@ func (o *Orchestrator) ComparePortfolio(ctx context.Context, req *dtos.Portfolio
 			ESGScore:     esgResult.OverallScore,
 			Sentiment:    sentiment,
 		}
+		tracker.start(step+4, companyName, AgentTrading)
 		tradingResult, _ := o.tradingAgent.GenerateSignal(ctx, tradingReq)
+		tracker.complete(step+4, companyName, AgentTrading, map[string]interface{}{
+			"action":       tradingResult.Action,
+			"target_price": fmt.Sprintf("$%.2f", tradingResult.TargetPrice),
+			"confidence":   tradingResult.Confidence,
+		})
 
 		// Compliance Check
 		complianceReq := &agents.ComplianceRequest{
@@ -269,7 +543,12 @@ func (o *Orchestrator) ComparePortfolio(ctx context.Context, req *dtos.Portfolio
 			Industry:    "technology",
 			Region:      "global",
 		}
+		tracker.start(step+5, companyName, AgentCompliance)
 		complianceResult, _ := o.complianceAgent.CheckCompliance(ctx, complianceReq)
+		tracker.complete(step+5, companyName, AgentCompliance, map[string]interface{}{
+			"is_compliant":     complianceResult.IsCompliant,
+			"compliance_score": complianceResult.ComplianceScore,
+		})
 
 		// Regulation Analysis
 		regulationReq := &agents.RegulationRequest{
@@ -277,7 +556,11 @@ func (o *Orchestrator) ComparePortfolio(ctx context.Context, req *dtos.Portfolio
 			Region:      "global",
 			Industry:    "technology",
 		}
+		tracker.start(step+6, companyName, AgentRegulation)
 		regulationResult, _ := o.regulationAgent.AnalyzeRegulations(ctx, regulationReq)
+		tracker.complete(step+6, companyName, AgentRegulation, map[string]interface{}{
+			"regulatory_risk": regulationResult.RegulatoryRiskScore,
+		})
 
 		// Build comparison entry
 		comparison := dtos.CompanyComparison{
@@ -298,6 +581,8 @@ func (o *Orchestrator) ComparePortfolio(ctx context.Context, req *dtos.Portfolio
 			},
 			ComplianceScore: complianceResult.ComplianceScore,
 			RegulatoryRisk:  regulationResult.RegulatoryRiskScore,
+			MarketRisk:      marketRiskResponse(riskResult.MarketRisk),
+			PhysicalRisk:    physicalRiskSummary(physicalRisk),
 		}
 
 		response.Companies = append(response.Companies, comparison)
@@ -305,6 +590,8 @@ func (o *Orchestrator) ComparePortfolio(ctx context.Context, req *dtos.Portfolio
 		// Track for optimization
 		esgScores = append(esgScores, esgResult.OverallScore)
 		expectedReturns = append(expectedReturns, tradingResult.PriceChangePercent)
+		validCompanies = append(validCompanies, companyName)
+		symbols = append(symbols, stockSymbol)
 
 		// Track best performers
 		if esgResult.OverallScore > bestESGScore {
@@ -340,19 +627,348 @@ func (o *Orchestrator) ComparePortfolio(ctx context.Context, req *dtos.Portfolio
 
 		portfolioReq := &agents.PortfolioRequest{
 			Companies:       validCompanies,
+			Symbols:         symbols,
 			ESGScores:       esgScores,
 			ExpectedReturns: expectedReturns,
 			RiskTolerance:   riskTolerance,
+			RiskFreeRate:    req.RiskFreeRate,
+			Attributes:      assetAttributes(req.Attributes),
+			Constraints:     portfolioConstraints(req.Constraints),
 		}
+		optimizationStep := comparePipelineStepsPerCompany*len(req.Companies) + 1
+		tracker.start(optimizationStep, "", AgentOptimization)
 		portfolioResult, err := o.optimizationAgent.OptimizePortfolio(ctx, portfolioReq)
-		if err == nil {
+		if err != nil {
+			tracker.fail(optimizationStep, "", AgentOptimization, err)
+			// A mandate that cannot be met must not come back as an
+			// unconstrained allocation
+			if req.Constraints != nil {
+				return nil, err
+			}
+		} else {
+			tracker.complete(optimizationStep, "", AgentOptimization, map[string]interface{}{
+				"optimal_allocation":  portfolioResult.OptimalWeights,
+				"portfolio_esg_score": portfolioResult.ESGScore,
+				"portfolio_risk":      portfolioResult.PortfolioRisk,
+				"expected_return":     portfolioResult.ExpectedReturn,
+				"sharpe_ratio":        portfolioResult.Sharpe,
+				"covariance_source":   portfolioResult.CovarianceSource,
+				"binding_constraints": portfolioResult.BindingConstraints,
+			})
 			response.OptimalAllocation = portfolioResult.OptimalWeights
 			response.PortfolioESGScore = portfolioResult.ESGScore
 			response.PortfolioRisk = portfolioResult.PortfolioRisk
 			response.ExpectedReturn = portfolioResult.ExpectedReturn
+			response.SharpeRatio = portfolioResult.Sharpe
+			response.CovarianceSource = portfolioResult.CovarianceSource
+			response.BindingConstraints = portfolioResult.BindingConstraints
+			response.RiskContributions = make([]dtos.RiskContribution, len(portfolioReq.Companies))
+			for i, company := range portfolioReq.Companies {
+				response.RiskContributions[i] = dtos.RiskContribution{
+					CompanyName:  company,
+					Weight:       portfolioResult.OptimalWeights[i],
+					MarginalRisk: portfolioResult.MarginalRisk[i],
+					Contribution: portfolioResult.RiskContributions[i],
+				}
+			}
+			// The allocation has no value at risk without enough common
+			// price history
+			if marketRisk, err := o.riskAgent.MarketRisk(ctx, symbols, portfolioResult.OptimalWeights, marketRiskParams(req.MarketRisk)); err == nil {
+				response.MarketRisk = marketRiskResponse(marketRisk)
+			}
+			if benchmark != nil {
+				analytics, err := o.compareWithBenchmark(ctx, portfolioReq, portfolioResult.OptimalWeights, req.Attributes, benchmark)
+				if err != nil {
+					return nil, err
+				}
+				benchmarkResponse := analytics.Response()
+				response.BenchmarkAnalytics = &benchmarkResponse
+			}
+		}
+	}
+
+	response.AnalysisID = tracker.analysisID
+	response.ProcessingTimeMs = time.Since(startTime).Milliseconds()
+	return response, nil
+}
+
+// compareWithBenchmark measures an optimised allocation against a
+// benchmark. Companies keep the ESG scores the analysis gave them, and the
+// sector and carbon intensity the request did.
+func (o *Orchestrator) compareWithBenchmark(ctx context.Context, req *agents.PortfolioRequest, weights []float64, attributes map[string]dtos.CompanyAttributes, benchmark *marketdata.Benchmark) (*BenchmarkAnalytics, error) {
+	byName := make(map[string]dtos.CompanyAttributes, len(attributes))
+	for name, attr := range attributes {
+		byName[strings.ToLower(strings.TrimSpace(name))] = attr
+	}
+	holdings := make([]agents.BenchmarkPosition, len(req.Companies))
+	for i, company := range req.Companies {
+		attr := byName[strings.ToLower(strings.TrimSpace(company))]
+		score := req.ESGScores[i]
+		holdings[i] = agents.BenchmarkPosition{
+			Symbol:          strings.ToUpper(req.Symbols[i]),
+			Weight:          weights[i],
+			Sector:          attr.Sector,
+			ESGScore:        &score,
+			CarbonIntensity: attr.CarbonIntensity,
+		}
+	}
+	asOf := time.Now()
+	positions := benchmarkPositions(holdings, benchmark, o.marketData, asOf)
+	result, err := o.optimizationAgent.BenchmarkAnalytics(ctx, positions)
+	if err != nil {
+		return nil, err
+	}
+	return &BenchmarkAnalytics{Benchmark: benchmark.Name, AsOf: asOf, Positions: positions, BenchmarkResponse: result}, nil
+}
+
+// PortfolioFrontier scores each company and traces the efficient frontier
+// (and, on request, the ESG-return-risk surface) across them.
+func (o *Orchestrator) PortfolioFrontier(ctx context.Context, req *dtos.PortfolioFrontierRequest) (*dtos.PortfolioFrontierResponse, error) {
+	startTime := time.Now()
+	response := &dtos.PortfolioFrontierResponse{Timestamp: startTime}
+
+	portfolioReq := &agents.PortfolioRequest{
+		RiskFreeRate: req.RiskFreeRate,
+		Attributes:   assetAttributes(req.Attributes),
+		Constraints:  portfolioConstraints(req.Constraints),
+	}
+	tracker := newPipelineTracker(ctx, frontierPipelineStepsPerCompany*len(req.Companies)+1)
+	for i, companyName := range req.Companies {
+		step := i * frontierPipelineStepsPerCompany
+
+		tracker.start(step+1, companyName, AgentMarketData)
+		symbol, price, sentiment, err := o.quoteCompany(ctx, companyName)
+		if err != nil {
+			tracker.fail(step+1, companyName, AgentMarketData, err)
+			response.SkippedCompanies = append(response.SkippedCompanies, companyName)
+			continue
+		}
+		tracker.complete(step+1, companyName, AgentMarketData, map[string]interface{}{
+			"symbol":         symbol,
+			"current_price":  price,
+			"news_sentiment": sentiment,
+		})
+
+		tracker.start(step+2, companyName, AgentESGScoring)
+		esgResult, err := o.esgScoringAgent.CalculateESG(ctx, &agents.ESGCalculationRequest{
+			CompanyName:   companyName,
+			NewsSentiment: sentiment,
+			Industry:      "technology",
+		})
+		if err != nil {
+			tracker.fail(step+2, companyName, AgentESGScoring, err)
+			response.SkippedCompanies = append(response.SkippedCompanies, companyName)
+			continue
 		}
+		tracker.complete(step+2, companyName, AgentESGScoring, map[string]interface{}{
+			"esg_score": esgResult.OverallScore,
+		})
+
+		tracker.start(step+3, companyName, AgentTrading)
+		tradingResult, _ := o.tradingAgent.GenerateSignal(ctx, &agents.TradingSignalRequest{
+			CompanyName:  companyName,
+			Symbol:       symbol,
+			CurrentPrice: price,
+			ESGScore:     esgResult.OverallScore,
+			Sentiment:    sentiment,
+		})
+		tracker.complete(step+3, companyName, AgentTrading, map[string]interface{}{
+			"action":          tradingResult.Action,
+			"expected_return": tradingResult.PriceChangePercent,
+		})
+
+		portfolioReq.Companies = append(portfolioReq.Companies, companyName)
+		portfolioReq.Symbols = append(portfolioReq.Symbols, symbol)
+		portfolioReq.ESGScores = append(portfolioReq.ESGScores, esgResult.OverallScore)
+		portfolioReq.ExpectedReturns = append(portfolioReq.ExpectedReturns, tradingResult.PriceChangePercent)
+		response.Companies = append(response.Companies, dtos.FrontierCompany{
+			CompanyName:    companyName,
+			Symbol:         symbol,
+			ESGScore:       esgResult.OverallScore,
+			ExpectedReturn: tradingResult.PriceChangePercent,
+		})
+	}
+	if len(portfolioReq.Companies) < 2 {
+		return nil, fmt.Errorf("a frontier needs at least 2 companies with market data; skipped: %v", response.SkippedCompanies)
 	}
 
+	points := req.Points
+	if points == 0 {
+		points = defaultFrontierPoints
+	}
+	frontierStep := frontierPipelineStepsPerCompany*len(req.Companies) + 1
+	tracker.start(frontierStep, "", AgentOptimization)
+	frontier, err := o.optimizationAgent.EfficientFrontier(ctx, portfolioReq, points, req.ESGLevels)
+	if err != nil {
+		tracker.fail(frontierStep, "", AgentOptimization, err)
+		return nil, err
+	}
+	tracker.complete(frontierStep, "", AgentOptimization, map[string]interface{}{
+		"points":            len(frontier.Points),
+		"esg_levels":        len(frontier.Surface),
+		"covariance_source": frontier.CovarianceSource,
+	})
+
+	response.Points = frontierPointDTOs(frontier.Points)
+	response.MaxSharpeIndex = frontier.MaxSharpeIndex
+	for _, curve := range frontier.Surface {
+		response.Surface = append(response.Surface, dtos.FrontierCurve{
+			MinESGScore: curve.MinESGScore,
+			Points:      frontierPointDTOs(curve.Points),
+		})
+	}
+	response.CovarianceSource = frontier.CovarianceSource
+	response.AnalysisID = tracker.analysisID
 	response.ProcessingTimeMs = time.Since(startTime).Milliseconds()
 	return response, nil
 }
+
+const defaultFrontierPoints = 20
+
+// quoteCompany is the market data step: the company's likely symbol, its
+// price and its news sentiment. It fails when neither a price nor news is
+// available, which usually means the name is wrong; without news the
+// sentiment is a neutral 0.5.
+func (o *Orchestrator) quoteCompany(ctx context.Context, companyName string) (string, float64, float64, error) {
+	symbol := o.realtimeAgents.GuessStockSymbol(companyName)
+	stockData, stockErr := o.realtimeAgents.GetStockPrice(symbol)
+	var price float64
+	if stockErr != nil || stockData == nil {
+		price, stockErr = o.realtimeAgents.GetAlphaVantagePrice(ctx, symbol)
+	} else {
+		price = stockData.Price
+	}
+
+	sentiment, newsErr := o.realtimeAgents.GetNewsSentiment(ctx, companyName)
+	if (stockErr != nil || price == 0) && newsErr != nil {
+		return symbol, 0, 0, fmt.Errorf("unable to retrieve market data or news")
+	}
+	if newsErr != nil {
+		sentiment = 0.5
+	}
+	return symbol, price, sentiment, nil
+}
+
+func frontierPointDTOs(points []agents.FrontierPoint) []dtos.FrontierPoint {
+	out := make([]dtos.FrontierPoint, len(points))
+	for i, point := range points {
+		out[i] = dtos.FrontierPoint{
+			Weights:        point.Weights,
+			ExpectedReturn: point.ExpectedReturn,
+			PortfolioRisk:  point.PortfolioRisk,
+			SharpeRatio:    point.Sharpe,
+			ESGScore:       point.ESGScore,
+		}
+	}
+	return out
+}
+
+func assetAttributes(attributes map[string]dtos.CompanyAttributes) map[string]agents.AssetAttributes {
+	if len(attributes) == 0 {
+		return nil
+	}
+	out := make(map[string]agents.AssetAttributes, len(attributes))
+	for name, attr := range attributes {
+		out[name] = agents.AssetAttributes{
+			Sector:          attr.Sector,
+			Country:         attr.Country,
+			CarbonIntensity: attr.CarbonIntensity,
+		}
+	}
+	return out
+}
+
+func portfolioConstraints(constraints *dtos.PortfolioConstraints) *agents.PortfolioConstraints {
+	if constraints == nil {
+		return nil
+	}
+	out := &agents.PortfolioConstraints{
+		MinWeight:          constraints.MinWeight,
+		MaxWeight:          constraints.MaxWeight,
+		SectorCaps:         constraints.SectorCaps,
+		CountryCaps:        constraints.CountryCaps,
+		MinESGScore:        constraints.MinESGScore,
+		MaxCarbonIntensity: constraints.MaxCarbonIntensity,
+		Exclude:            constraints.Exclude,
+		CurrentWeights:     constraints.CurrentWeights,
+		MaxTurnover:        constraints.MaxTurnover,
+	}
+	if len(constraints.WeightBounds) > 0 {
+		out.WeightBounds = make(map[string]agents.WeightBounds, len(constraints.WeightBounds))
+		for name, bounds := range constraints.WeightBounds {
+			out.WeightBounds[name] = agents.WeightBounds{Min: bounds.Min, Max: bounds.Max}
+		}
+	}
+	return out
+}
+
+func marketRiskParams(req *dtos.MarketRiskRequest) agents.MarketRiskParams {
+	if req == nil {
+		return agents.MarketRiskParams{}
+	}
+	return agents.MarketRiskParams{
+		Confidence:  req.Confidence,
+		HorizonDays: req.HorizonDays,
+		Simulations: req.Simulations,
+	}
+}
+
+// marketRiskResponse converts market risk for the API, in percentages.
+func marketRiskResponse(risk *quant.MarketRisk) *dtos.MarketRiskResponse {
+	if risk == nil {
+		return nil
+	}
+	percent := func(t quant.TailRisk) dtos.TailRiskResponse {
+		return dtos.TailRiskResponse{VaR: t.VaR * 100, ExpectedShortfall: t.ExpectedShortfall * 100}
+	}
+	response := &dtos.MarketRiskResponse{
+		Confidence:   risk.Confidence,
+		HorizonDays:  risk.Horizon,
+		Observations: risk.Observations,
+		Volatility:   risk.Volatility * 100,
+		Parametric:   percent(risk.Parametric),
+		MonteCarlo:   percent(risk.MonteCarlo),
+	}
+	if risk.Historical != nil {
+		historical := percent(*risk.Historical)
+		response.Historical = &historical
+	}
+	return response
+}
+
+// physicalRiskSummary converts physical risk for the API.
+func physicalRiskSummary(risk *physical.Risk) *dtos.PhysicalRiskSummary {
+	if risk == nil {
+		return nil
+	}
+	return &dtos.PhysicalRiskSummary{Score: risk.Score, Hazards: risk.Hazards, Coverage: risk.Coverage}
+}
+
+// creditAdjustmentResponse converts a recorded credit adjustment for the
+// API.
+func creditAdjustmentResponse(row *models.CreditAdjustment) *dtos.CreditAdjustmentResponse {
+	if row == nil {
+		return nil
+	}
+	response := &dtos.CreditAdjustmentResponse{
+		ID:              row.ID.String(),
+		Obligor:         row.Obligor,
+		PolicyVersion:   row.PolicyVersion,
+		Source:          row.Source,
+		BaseRating:      row.BaseRating,
+		BasePD:          row.BasePD,
+		AdjustedRating:  row.AdjustedRating,
+		AdjustedPD:      row.AdjustedPD,
+		RuleNotches:     row.RuleNotches,
+		Notches:         row.Notches,
+		InvestmentGrade: credit.InvestmentGrade(row.AdjustedRating),
+		Factors:         row.Factors,
+		AppliedRules:    make([]dtos.CreditRuleRequest, len(row.AppliedRules)),
+		CreatedBy:       row.CreatedBy,
+		CreatedAt:       row.CreatedAt,
+	}
+	for i, rule := range row.AppliedRules {
+		response.AppliedRules[i] = dtos.CreditRuleRequest(rule)
+	}
+	return response
+}
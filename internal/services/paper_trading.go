@@ -0,0 +1,452 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/edgeesg/edge-esg-backend/internal/backtest"
+	"github.com/edgeesg/edge-esg-backend/internal/loggers"
+	"github.com/edgeesg/edge-esg-backend/internal/marketdata"
+	"github.com/edgeesg/edge-esg-backend/internal/metrics"
+	"github.com/edgeesg/edge-esg-backend/internal/models"
+	"github.com/edgeesg/edge-esg-backend/internal/repository"
+	"github.com/edgeesg/edge-esg-backend/internal/types"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ESG scores at or above esgLeaderScore, or at or below esgLaggardScore,
+// are the levels at which TradingAgent signals strong BUYs and SELLs.
+const (
+	esgLeaderScore  = 7.0
+	esgLaggardScore = 3.5
+
+	// Orders smaller than this are rejected rather than filled
+	minPaperNotional = 1.0
+)
+
+// Paper order statuses
+const (
+	PaperOrderFilled   = "FILLED"
+	PaperOrderRejected = "REJECTED"
+)
+
+var (
+	ErrPaperPortfolioNotFound = errors.New("paper portfolio not found")
+	ErrSignalNotApproved      = errors.New("signal has not been approved")
+)
+
+var paperOrdersTotal = metrics.NewCounter("edge_paper_orders_total",
+	"Simulated orders by outcome", "status")
+
+// PaperPortfolioInput configures a new paper portfolio.
+type PaperPortfolioInput struct {
+	Name          string
+	InitialCash   float64
+	PositionSize  float64
+	CostBps       float64
+	SlippageBps   float64
+	FollowSignals bool
+	// Shared portfolios are visible to everyone at the bank
+	Shared bool
+}
+
+// PaperHolding is a position marked at its latest quote.
+type PaperHolding struct {
+	models.PaperPosition
+	Quote       float64
+	QuoteDate   time.Time
+	MarketValue float64
+	// UnrealizedPnL is market value less cost basis
+	UnrealizedPnL float64
+	// CurrentESG is the latest known ESG score, or the signal's when there
+	// is none
+	CurrentESG float64
+}
+
+// PaperValuation is a portfolio marked to market.
+type PaperValuation struct {
+	Portfolio        models.PaperPortfolio
+	Holdings         []PaperHolding
+	MarketValue      float64
+	Equity           float64
+	ESGScore         *float64
+	ESGLeaderWeight  float64
+	ESGLaggardWeight float64
+}
+
+// PaperPerformance reports a portfolio's daily snapshots and a summary of
+// their equity curve.
+type PaperPerformance struct {
+	Snapshots   []models.PaperSnapshot
+	Performance backtest.Performance
+}
+
+// PaperTradingService runs simulated portfolios. Portfolios that follow
+// signals trade each signal the bank approves; orders fill at once against
+// the latest close in the market data, moved by slippage and charged fees.
+// Like backtests, portfolios are long-only: BUY opens a position of
+// PositionSize of equity when flat, SELL closes it.
+type PaperTradingService struct {
+	repo    *repository.PaperRepository
+	signals *SignalService
+	source  marketdata.Source
+}
+
+func NewPaperTradingService(repo *repository.PaperRepository, signals *SignalService, source marketdata.Source) *PaperTradingService {
+	return &PaperTradingService{repo: repo, signals: signals, source: source}
+}
+
+// CreatePortfolio opens a portfolio for owner, or for the whole bank when
+// in.Shared is set or there is no signed-in owner.
+func (s *PaperTradingService) CreatePortfolio(ctx context.Context, bankID uuid.UUID, owner string, in PaperPortfolioInput) (*models.PaperPortfolio, error) {
+	if in.Shared {
+		owner = ""
+	}
+	portfolio := &models.PaperPortfolio{
+		BankID:        bankID,
+		Owner:         owner,
+		Name:          in.Name,
+		InitialCash:   in.InitialCash,
+		Cash:          in.InitialCash,
+		PositionSize:  in.PositionSize,
+		CostBps:       in.CostBps,
+		SlippageBps:   in.SlippageBps,
+		FollowSignals: in.FollowSignals,
+	}
+	now := time.Now()
+	opening := &models.PaperSnapshot{
+		Date:        snapshotDate(now),
+		BankID:      bankID,
+		Cash:        in.InitialCash,
+		Equity:      in.InitialCash,
+		MarketValue: 0,
+		CreatedAt:   now,
+	}
+	if err := s.repo.CreatePortfolio(ctx, portfolio, opening); err != nil {
+		return nil, err
+	}
+	return portfolio, nil
+}
+
+func (s *PaperTradingService) Portfolios(ctx context.Context, bankID uuid.UUID, viewer string) ([]models.PaperPortfolio, error) {
+	return s.repo.ListPortfolios(ctx, bankID, viewer)
+}
+
+// Portfolio returns a portfolio marked at the latest quotes.
+func (s *PaperTradingService) Portfolio(ctx context.Context, bankID uuid.UUID, viewer string, id uuid.UUID) (*PaperValuation, error) {
+	portfolio, err := s.find(ctx, bankID, viewer, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.value(ctx, portfolio, time.Now())
+}
+
+// SetFollowSignals turns automatic trading of approved signals on or off.
+func (s *PaperTradingService) SetFollowSignals(ctx context.Context, bankID uuid.UUID, viewer string, id uuid.UUID, follow bool) (*models.PaperPortfolio, error) {
+	portfolio, err := s.find(ctx, bankID, viewer, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdatePortfolio(ctx, bankID, id, map[string]interface{}{"follow_signals": follow}); err != nil {
+		return nil, err
+	}
+	portfolio.FollowSignals = follow
+	return portfolio, nil
+}
+
+func (s *PaperTradingService) DeletePortfolio(ctx context.Context, bankID uuid.UUID, viewer string, id uuid.UUID) error {
+	if _, err := s.find(ctx, bankID, viewer, id); err != nil {
+		return err
+	}
+	return s.repo.DeletePortfolio(ctx, bankID, id)
+}
+
+func (s *PaperTradingService) Orders(ctx context.Context, bankID uuid.UUID, viewer string, id uuid.UUID, limit, offset int) ([]models.PaperOrder, error) {
+	if _, err := s.find(ctx, bankID, viewer, id); err != nil {
+		return nil, err
+	}
+	return s.repo.Orders(ctx, id, limit, offset)
+}
+
+// TradeSignal places an order in one portfolio for a signal the bank has
+// approved, such as one approved before the portfolio existed. A signal
+// trades at most once per portfolio; a repeat returns ErrSignalTransition.
+func (s *PaperTradingService) TradeSignal(ctx context.Context, bankID uuid.UUID, viewer string, id, signalID uuid.UUID) (*models.PaperOrder, error) {
+	portfolio, err := s.find(ctx, bankID, viewer, id)
+	if err != nil {
+		return nil, err
+	}
+	signal, err := s.signals.find(ctx, bankID, signalID)
+	if err != nil {
+		return nil, err
+	}
+	switch types.SignalStatus(signal.Status) {
+	case types.SignalApproved, types.SignalSent, types.SignalFilled:
+	default:
+		return nil, fmt.Errorf("%w: signal is %s", ErrSignalNotApproved, signal.Status)
+	}
+	return s.execute(ctx, portfolio, signal, viewer)
+}
+
+// FollowSignal trades an approved signal in every portfolio of its bank
+// that follows signals. Failures are logged; one portfolio does not stop
+// the others.
+func (s *PaperTradingService) FollowSignal(ctx context.Context, signal *models.TradeSignal) {
+	portfolios, err := s.repo.Following(ctx, signal.BankID)
+	if err != nil {
+		loggers.Error("Failed to load paper portfolios", err, map[string]interface{}{
+			"bank_id":   signal.BankID.String(),
+			"signal_id": signal.ID.String(),
+		})
+		return
+	}
+	for i := range portfolios {
+		if _, err := s.execute(ctx, &portfolios[i], signal, SignalActorSystem); err != nil {
+			loggers.Error("Paper order failed", err, map[string]interface{}{
+				"bank_id":      signal.BankID.String(),
+				"portfolio_id": portfolios[i].ID.String(),
+				"signal_id":    signal.ID.String(),
+			})
+		}
+	}
+}
+
+// Performance returns the daily snapshots of the last days days and the
+// return, volatility, Sharpe ratio and drawdown of their equity.
+func (s *PaperTradingService) Performance(ctx context.Context, bankID uuid.UUID, viewer string, id uuid.UUID, days int) (*PaperPerformance, error) {
+	if _, err := s.find(ctx, bankID, viewer, id); err != nil {
+		return nil, err
+	}
+	from := snapshotDate(time.Now()).AddDate(0, 0, -days)
+	snapshots, err := s.repo.Snapshots(ctx, id, from)
+	if err != nil {
+		return nil, err
+	}
+	equity := make([]float64, len(snapshots))
+	for i, snapshot := range snapshots {
+		equity[i] = snapshot.Equity
+	}
+	return &PaperPerformance{Snapshots: snapshots, Performance: backtest.Measure(equity, 0)}, nil
+}
+
+// SnapshotAll marks every portfolio to market and stores the result as
+// today's snapshot, replacing any earlier one, so the last run of a day
+// records its close.
+func (s *PaperTradingService) SnapshotAll(ctx context.Context) (int, error) {
+	now := time.Now()
+	count := 0
+	err := s.repo.EachPortfolio(ctx, func(portfolio *models.PaperPortfolio) error {
+		valuation, err := s.value(ctx, portfolio, now)
+		if err != nil {
+			return err
+		}
+		snapshot := &models.PaperSnapshot{
+			PortfolioID:      portfolio.ID,
+			Date:             snapshotDate(now),
+			BankID:           portfolio.BankID,
+			Cash:             portfolio.Cash,
+			MarketValue:      valuation.MarketValue,
+			Equity:           valuation.Equity,
+			ESGScore:         valuation.ESGScore,
+			ESGLeaderWeight:  valuation.ESGLeaderWeight,
+			ESGLaggardWeight: valuation.ESGLaggardWeight,
+			CreatedAt:        now,
+		}
+		if err := s.repo.SaveSnapshot(ctx, snapshot); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// StartSnapshots snapshots every portfolio each interval until ctx is done.
+func (s *PaperTradingService) StartSnapshots(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := s.SnapshotAll(ctx); err != nil {
+					loggers.Error("Paper portfolio snapshot failed", err, nil)
+				}
+			}
+		}
+	}()
+}
+
+// execute places the order signal calls for in portfolio. Orders that cannot
+// fill, for want of a quote, cash or a position, are stored as REJECTED.
+func (s *PaperTradingService) execute(ctx context.Context, portfolio *models.PaperPortfolio, signal *models.TradeSignal, actor string) (*models.PaperOrder, error) {
+	side := signal.Action
+	if side != "BUY" && side != "SELL" {
+		return nil, fmt.Errorf("cannot trade a %s signal", side)
+	}
+
+	// Equity for sizing is taken before the lock; a concurrent fill in the
+	// same portfolio can only make the BUY smaller, as cash is rechecked.
+	valuation, err := s.value(ctx, portfolio, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	bar, quoteErr := s.quote(signal.Symbol, time.Now())
+
+	signalID := signal.ID
+	order, err := s.repo.PlaceOrder(ctx, portfolio.BankID, portfolio.ID, signal.Symbol,
+		func(p *models.PaperPortfolio, held *models.PaperPosition) (*models.PaperOrder, *models.PaperPosition, error) {
+			order := &models.PaperOrder{
+				SignalID:  &signalID,
+				Symbol:    signal.Symbol,
+				Side:      side,
+				Status:    PaperOrderRejected,
+				CreatedBy: actor,
+			}
+			reject := func(reason string) (*models.PaperOrder, *models.PaperPosition, error) {
+				order.Reason = reason
+				return order, held, nil
+			}
+			if quoteErr != nil {
+				return reject("no quote: " + quoteErr.Error())
+			}
+			quoteDate := bar.Date
+			order.Quote = bar.Close
+			order.QuoteDate = &quoteDate
+
+			costRate := p.CostBps / 10000
+			slippage := p.SlippageBps / 10000
+			if side == "BUY" {
+				if held != nil {
+					return reject("position already open")
+				}
+				order.Price = bar.Close * (1 + slippage)
+				notional := math.Min(valuation.Equity*p.PositionSize, p.Cash/(1+costRate))
+				if notional < minPaperNotional {
+					return reject("insufficient cash")
+				}
+				order.Quantity = notional / order.Price
+				order.Fees = notional * costRate
+				order.Status = PaperOrderFilled
+				p.Cash -= notional + order.Fees
+				return order, &models.PaperPosition{
+					Quantity:  order.Quantity,
+					CostBasis: notional + order.Fees,
+					ESGScore:  signal.ESGScore,
+					OpenedAt:  time.Now(),
+				}, nil
+			}
+
+			if held == nil {
+				return reject("no position to sell")
+			}
+			order.Price = bar.Close * (1 - slippage)
+			order.Quantity = held.Quantity
+			proceeds := held.Quantity * order.Price
+			order.Fees = proceeds * costRate
+			pnl := proceeds - order.Fees - held.CostBasis
+			order.RealizedPnL = &pnl
+			order.Status = PaperOrderFilled
+			p.Cash += proceeds - order.Fees
+			return order, nil, nil
+		})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPaperPortfolioNotFound
+	}
+	if errors.Is(err, repository.ErrSignalOrderExists) {
+		return nil, fmt.Errorf("%w: %v", ErrSignalTransition, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	paperOrdersTotal.Inc(order.Status)
+	loggers.Info("Paper order placed", map[string]interface{}{
+		"bank_id":      portfolio.BankID.String(),
+		"portfolio_id": portfolio.ID.String(),
+		"signal_id":    signal.ID.String(),
+		"symbol":       order.Symbol,
+		"side":         order.Side,
+		"status":       order.Status,
+		"reason":       order.Reason,
+	})
+	return order, nil
+}
+
+// value marks portfolio's positions at the latest quotes as of at. A
+// position without a quote is carried at cost.
+func (s *PaperTradingService) value(ctx context.Context, portfolio *models.PaperPortfolio, at time.Time) (*PaperValuation, error) {
+	positions, err := s.repo.Positions(ctx, portfolio.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	valuation := &PaperValuation{Portfolio: *portfolio}
+	var esgValue, leaders, laggards float64
+	for _, position := range positions {
+		holding := PaperHolding{PaperPosition: position, MarketValue: position.CostBasis, CurrentESG: position.ESGScore}
+		if bar, err := s.quote(position.Symbol, at); err == nil {
+			holding.Quote = bar.Close
+			holding.QuoteDate = bar.Date
+			holding.MarketValue = position.Quantity * bar.Close
+		}
+		holding.UnrealizedPnL = holding.MarketValue - position.CostBasis
+		if inputs, err := s.source.Inputs(position.Symbol); err == nil {
+			if known, ok := marketdata.AsOf(inputs, at); ok {
+				holding.CurrentESG = known.ESGScore
+			}
+		}
+
+		valuation.MarketValue += holding.MarketValue
+		esgValue += holding.MarketValue * holding.CurrentESG
+		if holding.CurrentESG >= esgLeaderScore {
+			leaders += holding.MarketValue
+		} else if holding.CurrentESG <= esgLaggardScore {
+			laggards += holding.MarketValue
+		}
+		valuation.Holdings = append(valuation.Holdings, holding)
+	}
+
+	valuation.Equity = portfolio.Cash + valuation.MarketValue
+	if valuation.MarketValue > 0 {
+		score := esgValue / valuation.MarketValue
+		valuation.ESGScore = &score
+	}
+	if valuation.Equity > 0 {
+		valuation.ESGLeaderWeight = leaders / valuation.Equity
+		valuation.ESGLaggardWeight = laggards / valuation.Equity
+	}
+	return valuation, nil
+}
+
+// quote returns the latest bar for symbol on or before at.
+func (s *PaperTradingService) quote(symbol string, at time.Time) (marketdata.Bar, error) {
+	bars, err := s.source.Bars(symbol)
+	if err != nil {
+		return marketdata.Bar{}, err
+	}
+	bar, ok := marketdata.BarAsOf(bars, at)
+	if !ok || bar.Close <= 0 {
+		return marketdata.Bar{}, marketdata.ErrNoData
+	}
+	return bar, nil
+}
+
+func (s *PaperTradingService) find(ctx context.Context, bankID uuid.UUID, viewer string, id uuid.UUID) (*models.PaperPortfolio, error) {
+	portfolio, err := s.repo.FindPortfolio(ctx, bankID, id, viewer)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPaperPortfolioNotFound
+	}
+	return portfolio, err
+}
+
+// snapshotDate is the UTC day a snapshot taken at t belongs to.
+func snapshotDate(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
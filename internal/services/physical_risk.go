@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/edgeesg/edge-esg-backend/internal/marketdata"
+	"github.com/edgeesg/edge-esg-backend/internal/models"
+	"github.com/edgeesg/edge-esg-backend/internal/pcaf"
+	"github.com/edgeesg/edge-esg-backend/internal/physical"
+	"github.com/edgeesg/edge-esg-backend/internal/repository"
+	"github.com/google/uuid"
+)
+
+// MaxLocations bounds a company's locations.
+const MaxLocations = 1000
+
+var ErrNoLocations = errors.New("company has no locations")
+
+// PhysicalRiskService keeps the asset and collateral locations of
+// companies and scores their physical climate risk from the hazard data
+// bundled with the market data. Portfolios are scored through their
+// financed exposures.
+type PhysicalRiskService struct {
+	repo      *repository.LocationRepository
+	exposures *FinancedEmissionsService
+	hazards   marketdata.HazardSource
+}
+
+func NewPhysicalRiskService(repo *repository.LocationRepository, exposures *FinancedEmissionsService, hazards marketdata.HazardSource) *PhysicalRiskService {
+	return &PhysicalRiskService{repo: repo, exposures: exposures, hazards: hazards}
+}
+
+// ReplaceLocations validates and stores a company's locations in place of
+// its current ones.
+func (s *PhysicalRiskService) ReplaceLocations(ctx context.Context, bankID uuid.UUID, company string, locations []physical.Location) ([]physical.Location, error) {
+	company = strings.TrimSpace(company)
+	if company == "" {
+		return nil, fmt.Errorf("%w: a company is required", physical.ErrInvalidLocation)
+	}
+	if len(locations) > MaxLocations {
+		return nil, fmt.Errorf("%w: a company has at most %d locations", physical.ErrInvalidLocation, MaxLocations)
+	}
+	rows := make([]models.AssetLocation, len(locations))
+	for i := range locations {
+		location := &locations[i]
+		location.Name = strings.TrimSpace(location.Name)
+		location.Region = strings.ToUpper(strings.TrimSpace(location.Region))
+		location.Kind = location.LocationKind()
+		if err := location.Validate(); err != nil {
+			return nil, err
+		}
+		rows[i] = models.AssetLocation{
+			Company:    company,
+			Name:       location.Name,
+			Latitude:   location.Latitude,
+			Longitude:  location.Longitude,
+			Region:     location.Region,
+			AssetValue: location.AssetValue,
+			Kind:       location.Kind,
+		}
+	}
+	if err := s.repo.ReplaceCompanyLocations(ctx, bankID, companyKey(company), rows); err != nil {
+		return nil, err
+	}
+	return locations, nil
+}
+
+// Locations returns a company's locations.
+func (s *PhysicalRiskService) Locations(ctx context.Context, bankID uuid.UUID, company string) ([]physical.Location, error) {
+	byCompany, err := s.locations(ctx, bankID, []string{company})
+	if err != nil {
+		return nil, err
+	}
+	return byCompany[companyKey(company)], nil
+}
+
+// CompanyRisk scores the physical risk of a company's own assets. It
+// returns ErrNoLocations when the company has none.
+func (s *PhysicalRiskService) CompanyRisk(ctx context.Context, bankID uuid.UUID, company string) (*physical.Risk, error) {
+	locations, err := s.Locations(ctx, bankID, company)
+	if err != nil {
+		return nil, err
+	}
+	assets := ofKind(locations, physical.Asset)
+	if len(assets) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoLocations, company)
+	}
+	return physical.Assess(assets, s.hazards)
+}
+
+// PortfolioRisk scores a portfolio's financed exposures by the physical
+// risk of their counterparties, weighted by outstanding amount. Loans and
+// bonds are scored on the counterparty's collateral when it has any, and
+// on its assets otherwise; equity is scored on its assets.
+func (s *PhysicalRiskService) PortfolioRisk(ctx context.Context, bankID, portfolioID uuid.UUID) (*physical.PortfolioRisk, error) {
+	exposures, err := s.exposures.Exposures(ctx, bankID, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+	companies := make([]string, len(exposures))
+	for i, exposure := range exposures {
+		companies[i] = exposure.Counterparty
+	}
+	byCompany, err := s.locations(ctx, bankID, companies)
+	if err != nil {
+		return nil, err
+	}
+	holdings := make([]physical.Holding, len(exposures))
+	for i, exposure := range exposures {
+		holdings[i] = physical.Holding{Name: exposure.Counterparty, Amount: exposure.Outstanding}
+		locations := byCompany[companyKey(exposure.Counterparty)]
+		scored := ofKind(locations, physical.Asset)
+		if exposure.AssetClass != pcaf.ListedEquity {
+			if collateral := ofKind(locations, physical.Collateral); len(collateral) > 0 {
+				scored = collateral
+			}
+		}
+		if len(scored) == 0 {
+			continue
+		}
+		if holdings[i].Risk, err = physical.Assess(scored, s.hazards); err != nil {
+			return nil, err
+		}
+	}
+	return physical.Portfolio(holdings), nil
+}
+
+func (s *PhysicalRiskService) locations(ctx context.Context, bankID uuid.UUID, companies []string) (map[string][]physical.Location, error) {
+	keys := make([]string, len(companies))
+	for i, company := range companies {
+		keys[i] = companyKey(company)
+	}
+	rows, err := s.repo.CompanyLocations(ctx, bankID, keys)
+	if err != nil {
+		return nil, err
+	}
+	byCompany := make(map[string][]physical.Location, len(rows))
+	for key, locations := range rows {
+		for _, row := range locations {
+			byCompany[key] = append(byCompany[key], physical.Location{
+				Name:       row.Name,
+				Latitude:   row.Latitude,
+				Longitude:  row.Longitude,
+				Region:     row.Region,
+				AssetValue: row.AssetValue,
+				Kind:       row.Kind,
+			})
+		}
+	}
+	return byCompany, nil
+}
+
+func ofKind(locations []physical.Location, kind string) []physical.Location {
+	var out []physical.Location
+	for _, location := range locations {
+		if location.LocationKind() == kind {
+			out = append(out, location)
+		}
+	}
+	return out
+}
+
+func companyKey(company string) string {
+	return strings.ToLower(strings.TrimSpace(company))
+}
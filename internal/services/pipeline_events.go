@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Pipeline event statuses.
+const (
+	PipelineStarted   = "started"
+	PipelineCompleted = "completed"
+	PipelineFailed    = "failed"
+)
+
+// Agent IDs reported in pipeline events.
+const (
+	AgentMarketData   = "market_data"
+	AgentESGScoring   = "esg_scoring"
+	AgentRisk         = "risk"
+	AgentTrading      = "trading"
+	AgentCompliance   = "compliance"
+	AgentRegulation   = "regulation"
+	AgentConsensus    = "consensus"
+	AgentBlockchain   = "blockchain"
+	AgentProjection   = "investment_projection"
+	AgentOptimization = "optimization"
+)
+
+// PipelineEvent reports one agent of an analysis starting, completing with
+// its partial output, or failing.
+type PipelineEvent struct {
+	AnalysisID string                 `json:"analysis_id"`
+	JobID      string                 `json:"job_id,omitempty"`
+	Company    string                 `json:"company,omitempty" mask:"counterparty"`
+	Agent      string                 `json:"agent"`
+	Status     string                 `json:"status"`
+	Step       int                    `json:"step"`
+	Steps      int                    `json:"steps"`
+	Output     map[string]interface{} `json:"output,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	Timestamp  time.Time              `json:"timestamp"`
+}
+
+// PipelineObserver receives pipeline events. It is called synchronously from
+// the pipeline, so it must not block.
+type PipelineObserver func(PipelineEvent)
+
+const (
+	pipelineObserverContextKey contextKey = "pipeline_observer"
+	analysisIDContextKey       contextKey = "analysis_id"
+)
+
+// WithPipelineObserver adds an observer for pipelines run with ctx. Observers
+// already on ctx keep receiving events.
+func WithPipelineObserver(ctx context.Context, observer PipelineObserver) context.Context {
+	if previous, ok := ctx.Value(pipelineObserverContextKey).(PipelineObserver); ok {
+		next := observer
+		observer = func(e PipelineEvent) {
+			previous(e)
+			next(e)
+		}
+	}
+	return context.WithValue(ctx, pipelineObserverContextKey, observer)
+}
+
+// WithAnalysisID fixes the ID of the next analysis run with ctx, so callers
+// can correlate events before the response arrives.
+func WithAnalysisID(ctx context.Context, analysisID string) context.Context {
+	return context.WithValue(ctx, analysisIDContextKey, analysisID)
+}
+
+// pipelineTracker numbers an analysis' steps and forwards them to the
+// observer on its context, if any.
+type pipelineTracker struct {
+	observer   PipelineObserver
+	analysisID string
+	steps      int
+}
+
+func newPipelineTracker(ctx context.Context, steps int) *pipelineTracker {
+	analysisID, _ := ctx.Value(analysisIDContextKey).(string)
+	if analysisID == "" {
+		analysisID = uuid.New().String()
+	}
+	observer, _ := ctx.Value(pipelineObserverContextKey).(PipelineObserver)
+	return &pipelineTracker{observer: observer, analysisID: analysisID, steps: steps}
+}
+
+func (t *pipelineTracker) start(step int, company, agent string) {
+	t.emit(PipelineEvent{Step: step, Company: company, Agent: agent, Status: PipelineStarted})
+}
+
+func (t *pipelineTracker) complete(step int, company, agent string, output map[string]interface{}) {
+	t.emit(PipelineEvent{Step: step, Company: company, Agent: agent, Status: PipelineCompleted, Output: output})
+}
+
+func (t *pipelineTracker) fail(step int, company, agent string, err error) {
+	t.emit(PipelineEvent{Step: step, Company: company, Agent: agent, Status: PipelineFailed, Error: err.Error()})
+}
+
+func (t *pipelineTracker) emit(e PipelineEvent) {
+	if t.observer == nil {
+		return
+	}
+	e.AnalysisID = t.analysisID
+	e.Steps = t.steps
+	e.Timestamp = time.Now().UTC()
+	t.observer(e)
+}
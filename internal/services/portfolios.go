@@ -0,0 +1,644 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/edgeesg/edge-esg-backend/internal/agents"
+	"github.com/edgeesg/edge-esg-backend/internal/loggers"
+	"github.com/edgeesg/edge-esg-backend/internal/marketdata"
+	"github.com/edgeesg/edge-esg-backend/internal/metrics"
+	"github.com/edgeesg/edge-esg-backend/internal/models"
+	"github.com/edgeesg/edge-esg-backend/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultDriftThreshold is how far a holding's weight may stray from
+	// its target before drift is alerted, unless the portfolio sets one
+	DefaultDriftThreshold = 0.05
+
+	// Target weights must add up to 1 within this
+	targetWeightTolerance = 1e-4
+
+	// Proposed trades smaller than this are left out
+	minRebalanceNotional = 1.0
+)
+
+// Kinds of drift breach
+const (
+	DriftWeight = "weight"
+	DriftESG    = "esg"
+)
+
+var (
+	ErrPortfolioNotFound = errors.New("portfolio not found")
+	ErrInvalidPortfolio  = errors.New("invalid portfolio")
+	ErrPortfolioUnpriced = errors.New("portfolio holdings have no quote")
+)
+
+var portfolioDriftAlerts = metrics.NewCounter("edge_portfolio_drift_alerts_total",
+	"Portfolio drift alerts by whether drift was breached or cleared", "status")
+
+// PortfolioInput is a portfolio as a user defines it. Symbols in holdings
+// and in the mandate are normalised when it is saved.
+type PortfolioInput struct {
+	Name           string
+	Benchmark      string
+	Holdings       []models.PortfolioHolding
+	Mandate        *models.PortfolioMandate
+	DriftThreshold float64
+	MinESGScore    *float64
+}
+
+// HoldingValue is a holding marked at its latest quote. ESGScore is nil
+// when the market data has no score for the symbol.
+type HoldingValue struct {
+	models.PortfolioHolding
+	Quote       float64
+	QuoteDate   time.Time
+	MarketValue float64
+	Weight      float64
+	// Drift is Weight less TargetWeight
+	Drift    float64
+	ESGScore *float64
+}
+
+// DriftBreach is a threshold a portfolio is outside of: a holding whose
+// weight has drifted by more than Limit, or a portfolio ESG score below it.
+type DriftBreach struct {
+	Kind   string
+	Symbol string
+	Value  float64
+	Limit  float64
+}
+
+func (b DriftBreach) key() string {
+	if b.Symbol == "" {
+		return b.Kind
+	}
+	return b.Kind + ":" + b.Symbol
+}
+
+// PortfolioDrift is a portfolio marked to market against its targets.
+// ESGScore is value-weighted over the holdings that have one, and
+// ESGCoverage is their share of market value.
+type PortfolioDrift struct {
+	Portfolio   models.Portfolio
+	Holdings    []HoldingValue
+	MarketValue float64
+	ESGScore    *float64
+	ESGCoverage float64
+	MaxDrift    float64
+	Breaches    []DriftBreach
+	CheckedAt   time.Time
+}
+
+// breachKeys identifies the breaches, in a stable order, for comparing
+// one check with the last.
+func (d *PortfolioDrift) breachKeys() string {
+	keys := make([]string, len(d.Breaches))
+	for i, breach := range d.Breaches {
+		keys[i] = breach.key()
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+// RebalanceLine is one holding before and after a proposed rebalance.
+type RebalanceLine struct {
+	Symbol           string
+	Quote            float64
+	Quantity         float64
+	ProposedQuantity float64
+	CurrentWeight    float64
+	TargetWeight     float64
+	ProposedWeight   float64
+}
+
+// ProposedTrade is an order a rebalance calls for.
+type ProposedTrade struct {
+	Symbol       string
+	Side         string
+	Quantity     float64
+	Notional     float64
+	WeightChange float64
+}
+
+// RebalanceProposal is the set of trades that brings a portfolio as close
+// to its targets as its mandate allows. Nothing is traded. Tracking errors
+// are annual percentages against the targets; Turnover is one-way, as a
+// fraction of the portfolio's value.
+type RebalanceProposal struct {
+	Portfolio            models.Portfolio
+	MarketValue          float64
+	Cash                 float64
+	Lines                []RebalanceLine
+	Trades               []ProposedTrade
+	Turnover             float64
+	CurrentTrackingError float64
+	TrackingError        float64
+	CurrentESGScore      float64
+	ESGScore             float64
+	CovarianceSource     string
+	BindingConstraints   []string
+}
+
+// PortfolioService keeps each bank's portfolios, proposes rebalancing
+// trades with the OptimizationAgent and watches the portfolios for drift
+// from their targets. Holdings are valued at the latest close in the
+// market data.
+type PortfolioService struct {
+	repo       *repository.PortfolioRepository
+	source     marketdata.Source
+	benchmarks marketdata.BenchmarkSource
+	optimizer  *agents.OptimizationAgent
+	drifted    func(ctx context.Context, drift *PortfolioDrift)
+}
+
+func NewPortfolioService(repo *repository.PortfolioRepository, source marketdata.Source, benchmarks marketdata.BenchmarkSource) *PortfolioService {
+	optimizer := agents.NewOptimizationAgent()
+	optimizer.UsePriceHistory(source)
+	return &PortfolioService{repo: repo, source: source, benchmarks: benchmarks, optimizer: optimizer}
+}
+
+// OnDrift is called when a scheduled check finds a portfolio's breaches
+// have changed: with the new breaches, or with none once drift is back
+// within its thresholds. Call before starting drift checks.
+func (s *PortfolioService) OnDrift(observer func(ctx context.Context, drift *PortfolioDrift)) {
+	s.drifted = observer
+}
+
+func (s *PortfolioService) Create(ctx context.Context, bankID uuid.UUID, createdBy string, in PortfolioInput) (*models.Portfolio, error) {
+	portfolio, err := newPortfolio(bankID, in)
+	if err != nil {
+		return nil, err
+	}
+	portfolio.CreatedBy = createdBy
+	if err := s.repo.Create(ctx, portfolio); err != nil {
+		return nil, err
+	}
+	return portfolio, nil
+}
+
+func (s *PortfolioService) List(ctx context.Context, bankID uuid.UUID) ([]models.Portfolio, error) {
+	return s.repo.List(ctx, bankID)
+}
+
+func (s *PortfolioService) Get(ctx context.Context, bankID, id uuid.UUID) (*models.Portfolio, error) {
+	portfolio, err := s.repo.Find(ctx, bankID, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPortfolioNotFound
+	}
+	return portfolio, err
+}
+
+// Replace overwrites a portfolio's settings and holdings.
+func (s *PortfolioService) Replace(ctx context.Context, bankID, id uuid.UUID, in PortfolioInput) (*models.Portfolio, error) {
+	portfolio, err := newPortfolio(bankID, in)
+	if err != nil {
+		return nil, err
+	}
+	portfolio.ID = id
+	err = s.repo.Replace(ctx, portfolio)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPortfolioNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, bankID, id)
+}
+
+func (s *PortfolioService) Delete(ctx context.Context, bankID, id uuid.UUID) error {
+	deleted, err := s.repo.Delete(ctx, bankID, id)
+	if err == nil && !deleted {
+		return ErrPortfolioNotFound
+	}
+	return err
+}
+
+// Drift marks a portfolio to market and compares it with its targets and
+// thresholds, without alerting.
+func (s *PortfolioService) Drift(ctx context.Context, bankID, id uuid.UUID) (*PortfolioDrift, error) {
+	portfolio, err := s.Get(ctx, bankID, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.drift(portfolio, time.Now())
+}
+
+// Rebalance proposes the trades that bring a portfolio back to its target
+// weights, investing cash as well when given. Where the targets break the
+// mandate, or reaching them would exceed its turnover limit, the proposal
+// is the allowed portfolio with the least tracking error to them.
+func (s *PortfolioService) Rebalance(ctx context.Context, bankID, id uuid.UUID, cash float64) (*RebalanceProposal, error) {
+	portfolio, err := s.Get(ctx, bankID, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.rebalance(ctx, portfolio, cash)
+}
+
+func (s *PortfolioService) rebalance(ctx context.Context, portfolio *models.Portfolio, cash float64) (*RebalanceProposal, error) {
+	valued, err := s.drift(portfolio, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	total := valued.MarketValue + cash
+	if total <= 0 {
+		return nil, fmt.Errorf("%w: there is nothing to rebalance without holdings or cash", ErrInvalidPortfolio)
+	}
+
+	n := len(valued.Holdings)
+	req := &agents.RebalanceRequest{
+		PortfolioRequest: agents.PortfolioRequest{
+			Companies:   make([]string, n),
+			Symbols:     make([]string, n),
+			ESGScores:   make([]float64, n),
+			Attributes:  make(map[string]agents.AssetAttributes, n),
+			Constraints: mandateConstraints(portfolio.Mandate),
+		},
+		CurrentWeights: make([]float64, n),
+		TargetWeights:  make([]float64, n),
+	}
+	for i, holding := range valued.Holdings {
+		req.Companies[i] = holding.Symbol
+		req.Symbols[i] = holding.Symbol
+		// A holding without a score counts as 0 against an ESG floor
+		if holding.ESGScore != nil {
+			req.ESGScores[i] = *holding.ESGScore
+		}
+		req.Attributes[holding.Symbol] = agents.AssetAttributes{
+			Sector:          holding.Sector,
+			Country:         holding.Country,
+			CarbonIntensity: holding.CarbonIntensity,
+		}
+		req.CurrentWeights[i] = holding.MarketValue / total
+		req.TargetWeights[i] = holding.TargetWeight
+	}
+	result, err := s.optimizer.Rebalance(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	proposal := &RebalanceProposal{
+		Portfolio:            *portfolio,
+		MarketValue:          valued.MarketValue,
+		Cash:                 cash,
+		CurrentTrackingError: result.CurrentTrackingError,
+		TrackingError:        result.TrackingError,
+		ESGScore:             result.ESGScore,
+		CovarianceSource:     result.CovarianceSource,
+		BindingConstraints:   result.BindingConstraints,
+	}
+	moved := cash / total
+	invested := 0.0
+	for i, holding := range valued.Holdings {
+		weight := result.Weights[i]
+		line := RebalanceLine{
+			Symbol:           holding.Symbol,
+			Quote:            holding.Quote,
+			Quantity:         holding.Quantity,
+			ProposedQuantity: weight * total / holding.Quote,
+			CurrentWeight:    req.CurrentWeights[i],
+			TargetWeight:     holding.TargetWeight,
+			ProposedWeight:   weight,
+		}
+		proposal.Lines = append(proposal.Lines, line)
+		proposal.CurrentESGScore += req.CurrentWeights[i] * req.ESGScores[i]
+		invested += req.CurrentWeights[i]
+		moved += math.Abs(weight - line.CurrentWeight)
+
+		notional := (line.ProposedQuantity - line.Quantity) * holding.Quote
+		if math.Abs(notional) < minRebalanceNotional {
+			continue
+		}
+		trade := ProposedTrade{
+			Symbol:       holding.Symbol,
+			Side:         "BUY",
+			Quantity:     math.Abs(line.ProposedQuantity - line.Quantity),
+			Notional:     math.Abs(notional),
+			WeightChange: weight - line.CurrentWeight,
+		}
+		if notional < 0 {
+			trade.Side = "SELL"
+		}
+		proposal.Trades = append(proposal.Trades, trade)
+	}
+	if invested > 0 {
+		proposal.CurrentESGScore /= invested
+	}
+	proposal.Turnover = moved / 2
+	return proposal, nil
+}
+
+// PortfolioAnalytics is a stored portfolio, at its latest market value,
+// measured against a benchmark.
+type PortfolioAnalytics struct {
+	Portfolio   models.Portfolio
+	MarketValue float64
+	BenchmarkAnalytics
+}
+
+// Analytics measures a portfolio at its latest quotes against benchmark,
+// or against its own benchmark when that is empty. Holdings are weighted
+// by market value.
+func (s *PortfolioService) Analytics(ctx context.Context, bankID, id uuid.UUID, benchmark string) (*PortfolioAnalytics, error) {
+	portfolio, err := s.Get(ctx, bankID, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.analytics(ctx, portfolio, benchmark)
+}
+
+func (s *PortfolioService) analytics(ctx context.Context, portfolio *models.Portfolio, benchmark string) (*PortfolioAnalytics, error) {
+	if benchmark == "" {
+		benchmark = portfolio.Benchmark
+	}
+	if benchmark == "" {
+		return nil, fmt.Errorf("%w: the portfolio has no benchmark, so one must be named", ErrInvalidPortfolio)
+	}
+	definition, err := s.benchmarks.Benchmark(benchmark)
+	if err != nil {
+		return nil, err
+	}
+	valued, err := s.drift(portfolio, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if valued.MarketValue <= 0 {
+		return nil, fmt.Errorf("%w: the portfolio has no market value to compare", ErrInvalidPortfolio)
+	}
+
+	holdings := make([]agents.BenchmarkPosition, len(valued.Holdings))
+	for i, holding := range valued.Holdings {
+		holdings[i] = agents.BenchmarkPosition{
+			Symbol:          holding.Symbol,
+			Weight:          holding.Weight,
+			Sector:          holding.Sector,
+			ESGScore:        holding.ESGScore,
+			CarbonIntensity: holding.CarbonIntensity,
+		}
+	}
+	positions := benchmarkPositions(holdings, definition, s.source, valued.CheckedAt)
+	result, err := s.optimizer.BenchmarkAnalytics(ctx, positions)
+	if err != nil {
+		return nil, err
+	}
+	return &PortfolioAnalytics{
+		Portfolio:   *portfolio,
+		MarketValue: valued.MarketValue,
+		BenchmarkAnalytics: BenchmarkAnalytics{
+			Benchmark:         definition.Name,
+			AsOf:              valued.CheckedAt,
+			Positions:         positions,
+			BenchmarkResponse: result,
+		},
+	}, nil
+}
+
+// CheckAll checks every portfolio for drift and reports each whose
+// breaches changed since the last check to the OnDrift observer. A
+// portfolio that cannot be valued is logged and skipped. It returns the
+// number of portfolios alerted on.
+func (s *PortfolioService) CheckAll(ctx context.Context) (int, error) {
+	alerted := 0
+	err := s.repo.EachPortfolio(ctx, func(portfolio *models.Portfolio) error {
+		drift, err := s.drift(portfolio, time.Now())
+		if err != nil {
+			loggers.Warn("Portfolio drift check skipped", map[string]interface{}{
+				"bank_id":      portfolio.BankID.String(),
+				"portfolio_id": portfolio.ID.String(),
+				"error":        err.Error(),
+			})
+			return nil
+		}
+		changed, err := s.repo.RecordDrift(ctx, portfolio.ID, portfolio.DriftBreaches, drift.breachKeys(), drift.CheckedAt)
+		if err != nil || !changed {
+			return err
+		}
+
+		alerted++
+		status := "cleared"
+		if len(drift.Breaches) > 0 {
+			status = "breached"
+		}
+		portfolioDriftAlerts.Inc(status)
+		loggers.Info("Portfolio drift "+status, map[string]interface{}{
+			"bank_id":      portfolio.BankID.String(),
+			"portfolio_id": portfolio.ID.String(),
+			"breaches":     drift.breachKeys(),
+		})
+		if s.drifted != nil {
+			s.drifted(ctx, drift)
+		}
+		return nil
+	})
+	return alerted, err
+}
+
+// StartDriftChecks checks every portfolio each interval until ctx is done.
+func (s *PortfolioService) StartDriftChecks(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := s.CheckAll(ctx); err != nil {
+					loggers.Error("Portfolio drift check failed", err, nil)
+				}
+			}
+		}
+	}()
+}
+
+// drift values portfolio at the latest quotes as of at and finds its
+// breaches. Every holding needs a quote. A portfolio with no market value
+// has no weights to drift.
+func (s *PortfolioService) drift(portfolio *models.Portfolio, at time.Time) (*PortfolioDrift, error) {
+	drift := &PortfolioDrift{Portfolio: *portfolio, CheckedAt: at}
+	var unpriced []string
+	for _, holding := range portfolio.Holdings {
+		value := HoldingValue{PortfolioHolding: holding}
+		bars, err := s.source.Bars(holding.Symbol)
+		if err == nil {
+			if bar, ok := marketdata.BarAsOf(bars, at); ok && bar.Close > 0 {
+				value.Quote = bar.Close
+				value.QuoteDate = bar.Date
+			}
+		}
+		if value.Quote == 0 {
+			unpriced = append(unpriced, holding.Symbol)
+		}
+		value.MarketValue = holding.Quantity * value.Quote
+		if inputs, err := s.source.Inputs(holding.Symbol); err == nil {
+			if known, ok := marketdata.AsOf(inputs, at); ok {
+				score := known.ESGScore
+				value.ESGScore = &score
+			}
+		}
+		drift.MarketValue += value.MarketValue
+		drift.Holdings = append(drift.Holdings, value)
+	}
+	if len(unpriced) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrPortfolioUnpriced, strings.Join(unpriced, ", "))
+	}
+	if drift.MarketValue <= 0 {
+		return drift, nil
+	}
+
+	var esgValue, covered float64
+	for i := range drift.Holdings {
+		holding := &drift.Holdings[i]
+		holding.Weight = holding.MarketValue / drift.MarketValue
+		holding.Drift = holding.Weight - holding.TargetWeight
+		drift.MaxDrift = math.Max(drift.MaxDrift, math.Abs(holding.Drift))
+		if math.Abs(holding.Drift) > portfolio.DriftThreshold {
+			drift.Breaches = append(drift.Breaches, DriftBreach{
+				Kind:   DriftWeight,
+				Symbol: holding.Symbol,
+				Value:  holding.Drift,
+				Limit:  portfolio.DriftThreshold,
+			})
+		}
+		if holding.ESGScore != nil {
+			esgValue += holding.MarketValue * *holding.ESGScore
+			covered += holding.MarketValue
+		}
+	}
+	if covered > 0 {
+		score := esgValue / covered
+		drift.ESGScore = &score
+		drift.ESGCoverage = covered / drift.MarketValue
+		if floor := portfolio.MinESGScore; floor != nil && score < *floor {
+			drift.Breaches = append(drift.Breaches, DriftBreach{Kind: DriftESG, Value: score, Limit: *floor})
+		}
+	}
+	return drift, nil
+}
+
+// newPortfolio validates and normalises a portfolio definition.
+func newPortfolio(bankID uuid.UUID, in PortfolioInput) (*models.Portfolio, error) {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s", ErrInvalidPortfolio, fmt.Sprintf(format, args...))
+	}
+	portfolio := &models.Portfolio{
+		BankID:         bankID,
+		Name:           strings.TrimSpace(in.Name),
+		DriftThreshold: in.DriftThreshold,
+		MinESGScore:    in.MinESGScore,
+	}
+	if portfolio.DriftThreshold <= 0 {
+		portfolio.DriftThreshold = DefaultDriftThreshold
+	}
+	if in.Benchmark != "" {
+		benchmark, err := marketdata.NormalizeSymbol(in.Benchmark)
+		if err != nil {
+			return nil, invalid("benchmark: %v", err)
+		}
+		portfolio.Benchmark = benchmark
+	}
+
+	held := make(map[string]bool, len(in.Holdings))
+	total := 0.0
+	for _, holding := range in.Holdings {
+		symbol, err := marketdata.NormalizeSymbol(holding.Symbol)
+		if err != nil {
+			return nil, invalid("%v", err)
+		}
+		if held[symbol] {
+			return nil, invalid("%s is held twice", symbol)
+		}
+		if holding.Quantity < 0 || holding.TargetWeight < 0 || holding.TargetWeight > 1 {
+			return nil, invalid("%s needs a quantity of at least 0 and a target weight from 0 to 1", symbol)
+		}
+		held[symbol] = true
+		total += holding.TargetWeight
+		holding.Symbol = symbol
+		holding.Sector = strings.TrimSpace(holding.Sector)
+		holding.Country = strings.TrimSpace(holding.Country)
+		portfolio.Holdings = append(portfolio.Holdings, holding)
+	}
+	if len(portfolio.Holdings) == 0 {
+		return nil, invalid("a portfolio needs at least one holding")
+	}
+	if math.Abs(total-1) > targetWeightTolerance {
+		return nil, invalid("target weights add up to %.4f, not 1", total)
+	}
+
+	if mandate := in.Mandate; mandate != nil {
+		normalised := *mandate
+		symbol := func(name string) (string, error) {
+			s, err := marketdata.NormalizeSymbol(name)
+			if err != nil || !held[s] {
+				return "", invalid("the mandate names %q, which is not a holding", name)
+			}
+			return s, nil
+		}
+		normalised.Exclude = nil
+		for _, name := range mandate.Exclude {
+			s, err := symbol(name)
+			if err != nil {
+				return nil, err
+			}
+			normalised.Exclude = append(normalised.Exclude, s)
+		}
+		normalised.WeightBounds = nil
+		for name, bounds := range mandate.WeightBounds {
+			s, err := symbol(name)
+			if err != nil {
+				return nil, err
+			}
+			if normalised.WeightBounds == nil {
+				normalised.WeightBounds = make(map[string]models.MandateBounds, len(mandate.WeightBounds))
+			}
+			normalised.WeightBounds[s] = bounds
+		}
+		for _, holding := range portfolio.Holdings {
+			switch {
+			case len(mandate.SectorCaps) > 0 && holding.Sector == "":
+				return nil, invalid("sector caps need a sector for %s", holding.Symbol)
+			case len(mandate.CountryCaps) > 0 && holding.Country == "":
+				return nil, invalid("country caps need a country for %s", holding.Symbol)
+			case mandate.MaxCarbonIntensity != nil && holding.CarbonIntensity == nil:
+				return nil, invalid("max_carbon_intensity needs a carbon intensity for %s", holding.Symbol)
+			}
+		}
+		portfolio.Mandate = &normalised
+	}
+	return portfolio, nil
+}
+
+// mandateConstraints converts a stored mandate for the OptimizationAgent.
+func mandateConstraints(mandate *models.PortfolioMandate) *agents.PortfolioConstraints {
+	if mandate == nil {
+		return nil
+	}
+	out := &agents.PortfolioConstraints{
+		MinWeight:          mandate.MinWeight,
+		MaxWeight:          mandate.MaxWeight,
+		SectorCaps:         mandate.SectorCaps,
+		CountryCaps:        mandate.CountryCaps,
+		MinESGScore:        mandate.MinESGScore,
+		MaxCarbonIntensity: mandate.MaxCarbonIntensity,
+		Exclude:            mandate.Exclude,
+		MaxTurnover:        mandate.MaxTurnover,
+	}
+	if len(mandate.WeightBounds) > 0 {
+		out.WeightBounds = make(map[string]agents.WeightBounds, len(mandate.WeightBounds))
+		for symbol, bounds := range mandate.WeightBounds {
+			out.WeightBounds[symbol] = agents.WeightBounds{Min: bounds.Min, Max: bounds.Max}
+		}
+	}
+	return out
+}
@@ -0,0 +1,323 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/edgeesg/edge-esg-backend/internal/loggers"
+	"github.com/edgeesg/edge-esg-backend/internal/metrics"
+	"github.com/redis/go-redis/v9"
+)
+
+// Upstream data providers with hard daily quotas shared by every bank.
+const (
+	ProviderNewsAPI      = "newsapi"
+	ProviderAlphaVantage = "alphavantage"
+)
+
+var ErrQuotaExhausted = errors.New("upstream API daily quota exhausted")
+
+var (
+	upstreamQuotaUsed = metrics.NewGauge("edge_upstream_quota_used",
+		"Calls made today per upstream provider key", "provider", "key")
+	upstreamQuotaLimit = metrics.NewGauge("edge_upstream_quota_limit",
+		"Daily call limit per upstream provider key", "provider", "key")
+	upstreamCalls = metrics.NewCounter("edge_upstream_calls_total",
+		"Upstream data requests by outcome (called, cache_hit, exhausted)", "provider", "outcome")
+)
+
+// ProviderQuota describes one upstream provider. Every key in Keys has its
+// own DailyLimit; calls rotate across keys, least used first.
+type ProviderQuota struct {
+	Name       string
+	Keys       []string
+	DailyLimit int
+	// PriorityReserve is the fraction of each key's budget only priority
+	// banks may spend.
+	PriorityReserve float64
+	// CacheThreshold is the fraction of the total budget after which cached
+	// responses are served in preference to new calls.
+	CacheThreshold float64
+	// PerMinute is the provider's per-key rate limit; paced callers wait for
+	// it (see WithUpstreamPacing). Zero means unpaced.
+	PerMinute int
+}
+
+type QuotaKeyUsage struct {
+	Key   string `json:"key"`
+	Used  int    `json:"used"`
+	Limit int    `json:"limit"`
+}
+
+type ProviderUsage struct {
+	Provider            string          `json:"provider"`
+	Date                string          `json:"date"`
+	Used                int             `json:"used"`
+	Limit               int             `json:"limit"`
+	Remaining           int             `json:"remaining"`
+	ReservedForPriority int             `json:"reserved_for_priority"`
+	PreferCache         bool            `json:"prefer_cache"`
+	Keys                []QuotaKeyUsage `json:"keys"`
+}
+
+// acquireScript increments a key's daily counter only while it is below the
+// caller's allowance, setting the expiry in the same atomic step.
+// KEYS[1]: counter; ARGV[1]: allowance; ARGV[2]: ttl seconds.
+var acquireScript = redis.NewScript(`
+local used = tonumber(redis.call('GET', KEYS[1]) or '0')
+if used >= tonumber(ARGV[1]) then
+  return -1
+end
+used = redis.call('INCR', KEYS[1])
+if used == 1 then
+  redis.call('EXPIRE', KEYS[1], ARGV[2])
+end
+return used
+`)
+
+// QuotaManager tracks daily consumption of third-party API keys in Redis and
+// caches upstream responses so the gateway can degrade to cached data as the
+// budget runs out.
+type QuotaManager struct {
+	client        *redis.Client
+	providers     map[string]*ProviderQuota
+	priorityBanks map[string]bool
+	cacheTTL      time.Duration
+}
+
+func NewQuotaManager(client *redis.Client, providers []*ProviderQuota, priorityBanks []string, cacheTTL time.Duration) *QuotaManager {
+	q := &QuotaManager{
+		client:        client,
+		providers:     make(map[string]*ProviderQuota),
+		priorityBanks: make(map[string]bool),
+		cacheTTL:      cacheTTL,
+	}
+	for _, p := range providers {
+		q.providers[p.Name] = p
+		for _, key := range p.Keys {
+			upstreamQuotaLimit.Set(float64(p.DailyLimit), p.Name, keyFingerprint(key))
+		}
+	}
+	for _, bankID := range priorityBanks {
+		q.priorityBanks[bankID] = true
+	}
+	return q
+}
+
+// Acquire reserves one call against the least used key of provider that still
+// has budget for bankID, and returns that key.
+func (q *QuotaManager) Acquire(ctx context.Context, provider, bankID string) (string, error) {
+	p, ok := q.providers[provider]
+	if !ok || len(p.Keys) == 0 {
+		return "", fmt.Errorf("no API keys configured for %s", provider)
+	}
+
+	allowance := p.DailyLimit
+	if !q.priorityBanks[bankID] {
+		allowance = int(float64(p.DailyLimit) * (1 - p.PriorityReserve))
+	}
+
+	day, ttl := quotaDay(time.Now())
+	usage, err := q.keyUsage(ctx, p, day)
+	if err != nil {
+		// Without Redis we cannot count; keep serving rather than block
+		// every analysis, but make the blind spot visible.
+		loggers.Warn("Upstream quota tracking unavailable", map[string]interface{}{
+			"provider": provider,
+			"error":    err.Error(),
+		})
+		return p.Keys[0], nil
+	}
+
+	order := make([]int, len(p.Keys))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return usage[order[a]] < usage[order[b]] })
+
+	for _, i := range order {
+		key := p.Keys[i]
+		counter := quotaCounterKey(provider, key, day)
+		used, err := acquireScript.Run(ctx, q.client, []string{counter}, allowance, int(ttl.Seconds())).Int()
+		if err != nil {
+			return "", fmt.Errorf("failed to reserve %s quota: %w", provider, err)
+		}
+		if used >= 0 {
+			upstreamQuotaUsed.Set(float64(used), provider, keyFingerprint(key))
+			upstreamCalls.Inc(provider, "called")
+			return key, nil
+		}
+	}
+
+	upstreamCalls.Inc(provider, "exhausted")
+	return "", ErrQuotaExhausted
+}
+
+// Pace blocks until provider has room in the current minute across all of
+// its keys, or ctx is done. Every replica shares the same Redis window.
+func (q *QuotaManager) Pace(ctx context.Context, provider string) error {
+	p, ok := q.providers[provider]
+	if !ok || p.PerMinute <= 0 || len(p.Keys) == 0 {
+		return nil
+	}
+	limit := int64(p.PerMinute * len(p.Keys))
+
+	for {
+		now := time.Now()
+		window := now.Truncate(time.Minute)
+		key := fmt.Sprintf("upstream_pace:%s:%d", provider, window.Unix())
+
+		pipe := q.client.TxPipeline()
+		count := pipe.Incr(ctx, key)
+		pipe.Expire(ctx, key, 2*time.Minute)
+		if _, err := pipe.Exec(ctx); err != nil {
+			// Pacing is best effort; the daily quota still applies
+			return nil
+		}
+		if count.Val() <= limit {
+			return nil
+		}
+
+		wait := window.Add(time.Minute).Sub(now)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// PreferCache reports whether provider has crossed its cache threshold, in
+// which case cached responses should be used even if a call is possible.
+func (q *QuotaManager) PreferCache(ctx context.Context, provider string) bool {
+	p, ok := q.providers[provider]
+	if !ok || p.CacheThreshold <= 0 || len(p.Keys) == 0 {
+		return false
+	}
+
+	day, _ := quotaDay(time.Now())
+	usage, err := q.keyUsage(ctx, p, day)
+	if err != nil {
+		return false
+	}
+
+	used := 0
+	for _, u := range usage {
+		used += u
+	}
+	return float64(used) >= float64(p.DailyLimit*len(p.Keys))*p.CacheThreshold
+}
+
+// Cached returns a stored upstream response for provider and query.
+func (q *QuotaManager) Cached(ctx context.Context, provider, query string) ([]byte, bool) {
+	body, err := q.client.Get(ctx, upstreamCacheKey(provider, query)).Bytes()
+	if err != nil {
+		return nil, false
+	}
+	upstreamCalls.Inc(provider, "cache_hit")
+	return body, true
+}
+
+// Store caches a successful upstream response.
+func (q *QuotaManager) Store(ctx context.Context, provider, query string, body []byte) {
+	if err := q.client.Set(ctx, upstreamCacheKey(provider, query), body, q.cacheTTL).Err(); err != nil {
+		loggers.Warn("Failed to cache upstream response", map[string]interface{}{
+			"provider": provider,
+			"error":    err.Error(),
+		})
+	}
+}
+
+// Usage reports today's consumption for every provider.
+func (q *QuotaManager) Usage(ctx context.Context) ([]ProviderUsage, error) {
+	day, _ := quotaDay(time.Now())
+
+	names := make([]string, 0, len(q.providers))
+	for name := range q.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	report := make([]ProviderUsage, 0, len(names))
+	for _, name := range names {
+		p := q.providers[name]
+		usage, err := q.keyUsage(ctx, p, day)
+		if err != nil {
+			return nil, err
+		}
+
+		entry := ProviderUsage{
+			Provider: name,
+			Date:     day,
+			Keys:     make([]QuotaKeyUsage, 0, len(p.Keys)),
+		}
+		for i, key := range p.Keys {
+			fingerprint := keyFingerprint(key)
+			entry.Keys = append(entry.Keys, QuotaKeyUsage{Key: fingerprint, Used: usage[i], Limit: p.DailyLimit})
+			entry.Used += usage[i]
+			entry.Limit += p.DailyLimit
+			upstreamQuotaUsed.Set(float64(usage[i]), name, fingerprint)
+		}
+		entry.Remaining = entry.Limit - entry.Used
+		if entry.Remaining < 0 {
+			entry.Remaining = 0
+		}
+		entry.ReservedForPriority = int(float64(entry.Limit) * p.PriorityReserve)
+		entry.PreferCache = p.CacheThreshold > 0 && float64(entry.Used) >= float64(entry.Limit)*p.CacheThreshold
+		report = append(report, entry)
+	}
+	return report, nil
+}
+
+func (q *QuotaManager) keyUsage(ctx context.Context, p *ProviderQuota, day string) ([]int, error) {
+	// MGET with no keys is a Redis error
+	if len(p.Keys) == 0 {
+		return []int{}, nil
+	}
+	counters := make([]string, len(p.Keys))
+	for i, key := range p.Keys {
+		counters[i] = quotaCounterKey(p.Name, key, day)
+	}
+
+	values, err := q.client.MGet(ctx, counters...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	usage := make([]int, len(values))
+	for i, v := range values {
+		if s, ok := v.(string); ok {
+			_, _ = fmt.Sscanf(s, "%d", &usage[i])
+		}
+	}
+	return usage, nil
+}
+
+// quotaDay returns the UTC day providers reset on and how long the counter
+// must live (to the end of that day plus an hour of slack).
+func quotaDay(now time.Time) (string, time.Duration) {
+	now = now.UTC()
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	return now.Format("20060102"), tomorrow.Sub(now) + time.Hour
+}
+
+func quotaCounterKey(provider, key, day string) string {
+	return fmt.Sprintf("upstream_quota:%s:%s:%s", provider, keyFingerprint(key), day)
+}
+
+func upstreamCacheKey(provider, query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return fmt.Sprintf("upstream_cache:%s:%s", provider, hex.EncodeToString(sum[:]))
+}
+
+// keyFingerprint identifies an API key in Redis, logs and metrics without
+// revealing it.
+func keyFingerprint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:4])
+}
@@ -7,7 +7,7 @@ import (
 	"io"
 	"math"
 	"net/http"
-	"os"
+	"net/url"
 	"strings"
 	"time"
 )
@@ -15,13 +15,15 @@ import (
 // Real-time data aggregator using free APIs
 type RealTimeAgents struct {
 	httpClient *http.Client
+	quota      *QuotaManager
 }
 
-func NewRealTimeAgents() *RealTimeAgents {
+func NewRealTimeAgents(quota *QuotaManager) *RealTimeAgents {
 	return &RealTimeAgents{
 		httpClient: &http.Client{
 			Timeout: 15 * time.Second,
 		},
+		quota: quota,
 	}
 }
 
@@ -51,7 +53,7 @@ func (r *RealTimeAgents) AnalyzeCompany(ctx context.Context, company string) (*C
 	}
 
 	// 1. Get news sentiment (real-time)
-	sentiment, err := r.GetNewsSentiment(company)
+	sentiment, err := r.GetNewsSentiment(ctx, company)
 	if err == nil {
 		analysis.NewsSentiment = sentiment
 	} else {
@@ -73,7 +75,7 @@ func (r *RealTimeAgents) AnalyzeCompany(ctx context.Context, company string) (*C
 		analysis.CurrentPrice = stockData.Price
 	} else {
 		// Try Alpha Vantage as fallback
-		price, err2 := r.GetAlphaVantagePrice(analysis.StockSymbol)
+		price, err2 := r.GetAlphaVantagePrice(ctx, analysis.StockSymbol)
 		if err2 == nil {
 			analysis.CurrentPrice = price
 		} else {
@@ -100,23 +102,22 @@ func (r *RealTimeAgents) AnalyzeCompany(ctx context.Context, company string) (*C
 }
 
 // Get real news sentiment from NewsAPI
-func (r *RealTimeAgents) GetNewsSentiment(company string) (float64, error) {
-	// Get API key from environment
-	apiKey := os.Getenv("NEWS_API_KEY")
-	if apiKey == "" {
-		apiKey = "demo" // Fallback to demo (won't work)
-	}
-
-	url := fmt.Sprintf("https://newsapi.org/v2/everything?q=%s&sortBy=publishedAt&language=en&pageSize=20&apiKey=%s",
-		strings.ReplaceAll(company, " ", "+"), apiKey)
-
-	resp, err := r.httpClient.Get(url) // #nosec G107 G704
+func (r *RealTimeAgents) GetNewsSentiment(ctx context.Context, company string) (float64, error) {
+	body, err := r.fetchUpstream(ctx, ProviderNewsAPI, "everything:"+strings.ToLower(company),
+		func(apiKey string) string {
+			return fmt.Sprintf("https://newsapi.org/v2/everything?q=%s&sortBy=publishedAt&language=en&pageSize=20&apiKey=%s",
+				url.QueryEscape(company), apiKey)
+		},
+		func(body []byte) bool {
+			var status struct {
+				Status string `json:"status"`
+			}
+			return json.Unmarshal(body, &status) == nil && status.Status == "ok"
+		})
 	if err != nil {
 		return 0.5, err
 	}
-	defer resp.Body.Close()
 
-	body, _ := io.ReadAll(resp.Body)
 	var newsData map[string]interface{}
 	if err := json.Unmarshal(body, &newsData); err != nil {
 		return 0.5, nil // Return neutral on parse error
@@ -418,25 +419,20 @@ func (r *RealTimeAgents) GuessStockSymbol(company string) string {
 }
 
 // Get stock price from Alpha Vantage (fallback)
-func (r *RealTimeAgents) GetAlphaVantagePrice(symbol string) (float64, error) {
-	apiKey := os.Getenv("ALPHA_VANTAGE_KEY")
-	if apiKey == "" {
-		return 0, fmt.Errorf("ALPHA_VANTAGE_KEY not set")
-	}
-
+func (r *RealTimeAgents) GetAlphaVantagePrice(ctx context.Context, symbol string) (float64, error) {
 	// Alpha Vantage uses different symbols (no .NS suffix for Indian stocks)
 	cleanSymbol := strings.TrimSuffix(symbol, ".NS")
 
-	url := fmt.Sprintf("https://www.alphavantage.co/query?function=GLOBAL_QUOTE&symbol=%s&apikey=%s",
-		cleanSymbol, apiKey)
-
-	resp, err := r.httpClient.Get(url) // #nosec G107 G704
+	body, err := r.fetchUpstream(ctx, ProviderAlphaVantage, "GLOBAL_QUOTE:"+cleanSymbol,
+		func(apiKey string) string {
+			return fmt.Sprintf("https://www.alphavantage.co/query?function=GLOBAL_QUOTE&symbol=%s&apikey=%s",
+				url.QueryEscape(cleanSymbol), apiKey)
+		},
+		alphaVantageHasField("Global Quote"))
 	if err != nil {
 		return 0, err
 	}
-	defer resp.Body.Close()
 
-	body, _ := io.ReadAll(resp.Body)
 	var data map[string]interface{}
 	if err := json.Unmarshal(body, &data); err != nil {
 		return 0, err
@@ -466,26 +462,21 @@ func (r *RealTimeAgents) GetAlphaVantagePrice(symbol string) (float64, error) {
 }
 
 // GetHistoricalPrices gets historical stock prices for calculating returns
-func (r *RealTimeAgents) GetHistoricalPrices(symbol string) (map[string]float64, error) {
-	apiKey := os.Getenv("ALPHA_VANTAGE_KEY")
-	if apiKey == "" {
-		return nil, fmt.Errorf("ALPHA_VANTAGE_KEY not set")
-	}
-
+func (r *RealTimeAgents) GetHistoricalPrices(ctx context.Context, symbol string) (map[string]float64, error) {
 	// Remove .NS suffix for Alpha Vantage
 	cleanSymbol := strings.TrimSuffix(symbol, ".NS")
 
 	// Get daily time series (last 100 days)
-	url := fmt.Sprintf("https://www.alphavantage.co/query?function=TIME_SERIES_DAILY&symbol=%s&apikey=%s",
-		cleanSymbol, apiKey)
-
-	resp, err := r.httpClient.Get(url) // #nosec G107 G704
+	body, err := r.fetchUpstream(ctx, ProviderAlphaVantage, "TIME_SERIES_DAILY:"+cleanSymbol,
+		func(apiKey string) string {
+			return fmt.Sprintf("https://www.alphavantage.co/query?function=TIME_SERIES_DAILY&symbol=%s&apikey=%s",
+				url.QueryEscape(cleanSymbol), apiKey)
+		},
+		alphaVantageHasField("Time Series (Daily)"))
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	body, _ := io.ReadAll(resp.Body)
 	var data map[string]interface{}
 	if err := json.Unmarshal(body, &data); err != nil {
 		return nil, err
@@ -518,8 +509,8 @@ func (r *RealTimeAgents) GetHistoricalPrices(symbol string) (map[string]float64,
 }
 
 // CalculateHistoricalReturns calculates REAL returns for different time periods with actual dates
-func (r *RealTimeAgents) CalculateHistoricalReturns(symbol string, currentPrice float64) []map[string]interface{} {
-	prices, err := r.GetHistoricalPrices(symbol)
+func (r *RealTimeAgents) CalculateHistoricalReturns(ctx context.Context, symbol string, currentPrice float64) []map[string]interface{} {
+	prices, err := r.GetHistoricalPrices(ctx, symbol)
 	if err != nil || len(prices) == 0 {
 		return []map[string]interface{}{}
 	}
@@ -654,3 +645,68 @@ func (r *RealTimeAgents) CalculateInvestmentProjections(symbol string, currentPr
 
 	return projections
 }
+
+// fetchUpstream calls a keyed, quota-limited provider. Cached responses are
+// served once the provider nears its daily limit, when the quota is exhausted,
+// or when the call fails; responses passing valid are cached for later.
+func (r *RealTimeAgents) fetchUpstream(ctx context.Context, provider, query string, buildURL func(apiKey string) string, valid func([]byte) bool) ([]byte, error) {
+	if r.quota.PreferCache(ctx, provider) {
+		if body, ok := r.quota.Cached(ctx, provider, query); ok {
+			return body, nil
+		}
+	}
+
+	if upstreamPacing(ctx) {
+		if err := r.quota.Pace(ctx, provider); err != nil {
+			return nil, err
+		}
+	}
+
+	apiKey, err := r.quota.Acquire(ctx, provider, BankIDFromContext(ctx))
+	if err != nil {
+		if body, ok := r.quota.Cached(ctx, provider, query); ok {
+			return body, nil
+		}
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, buildURL(apiKey), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := r.httpClient.Do(req) // #nosec G107 G704
+	if err != nil {
+		if body, ok := r.quota.Cached(ctx, provider, query); ok {
+			return body, nil
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if valid(body) {
+		r.quota.Store(ctx, provider, query, body)
+	} else if cached, ok := r.quota.Cached(ctx, provider, query); ok {
+		// Provider answered with an error or throttling notice
+		return cached, nil
+	}
+	return body, nil
+}
+
+// alphaVantageHasField recognises real Alpha Vantage data; throttled calls
+// still return 200 with only a "Note" or "Information" message.
+func alphaVantageHasField(field string) func([]byte) bool {
+	return func(body []byte) bool {
+		var data map[string]json.RawMessage
+		if err := json.Unmarshal(body, &data); err != nil {
+			return false
+		}
+		raw, ok := data[field]
+		return ok && len(raw) > 2
+	}
+}
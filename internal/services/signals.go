@@ -0,0 +1,355 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/edgeesg/edge-esg-backend/internal/loggers"
+	"github.com/edgeesg/edge-esg-backend/internal/metrics"
+	"github.com/edgeesg/edge-esg-backend/internal/models"
+	"github.com/edgeesg/edge-esg-backend/internal/repository"
+	"github.com/edgeesg/edge-esg-backend/internal/types"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Actors for status changes no user made
+const (
+	SignalActorSystem    = "system"
+	SignalActorRiskAgent = "risk_agent"
+
+	signalExpiryBatch = 500
+)
+
+var (
+	ErrSignalNotFound   = errors.New("signal not found")
+	ErrSignalTransition = errors.New("signal cannot move to that status")
+	ErrSignalForbidden  = errors.New("not permitted to decide this signal")
+)
+
+var signalTransitionsTotal = metrics.NewCounter("edge_signal_transitions_total",
+	"Trade signal status changes by new status", "status")
+
+// signalTransitions lists where each open status may go. REJECTED, FILLED,
+// EXPIRED and CANCELLED are final.
+var signalTransitions = map[types.SignalStatus][]types.SignalStatus{
+	types.SignalPending:  {types.SignalApproved, types.SignalRejected, types.SignalExpired, types.SignalCancelled},
+	types.SignalApproved: {types.SignalSent, types.SignalExpired, types.SignalCancelled},
+	types.SignalSent:     {types.SignalFilled, types.SignalExpired, types.SignalCancelled},
+}
+
+// SignalActor is the signed-in user, or process, moving a signal.
+type SignalActor struct {
+	ID    string
+	Roles []string
+}
+
+func (a SignalActor) has(role types.UserRole) bool {
+	for _, r := range a.Roles {
+		if r == string(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// SignalInput is a trading signal as produced by the analysis pipeline.
+type SignalInput struct {
+	Company      string
+	AnalysisID   string
+	Symbol       string
+	Action       string
+	CurrentPrice float64
+	TargetPrice  float64
+	Confidence   float64
+	ESGScore     float64
+	RiskAction   string
+	Reasoning    string
+}
+
+// SignalService stores trade signals and moves them through their
+// lifecycle. Every change is conditional on the status it starts from, so
+// concurrent decisions and expiry sweeps on several replicas cannot both win.
+type SignalService struct {
+	repo     *repository.SignalRepository
+	horizon  time.Duration
+	approved func(ctx context.Context, signal *models.TradeSignal)
+}
+
+// NewSignalService takes the horizon after which an open signal expires.
+func NewSignalService(repo *repository.SignalRepository, horizon time.Duration) *SignalService {
+	if horizon <= 0 {
+		horizon = 24 * time.Hour
+	}
+	return &SignalService{repo: repo, horizon: horizon}
+}
+
+// OnApproved is called, in the background, with each signal a user
+// approves. Call before serving requests.
+func (s *SignalService) OnApproved(observer func(ctx context.Context, signal *models.TradeSignal)) {
+	s.approved = observer
+}
+
+// Record stores a new signal for bankID. HOLD signals call for no action and
+// are not stored; it returns nil for them. A signal the RiskAgent rejected
+// is stored as REJECTED. A second signal for the same analysis, as when a
+// job is retried, returns the first.
+func (s *SignalService) Record(ctx context.Context, bankID uuid.UUID, in SignalInput) (*models.TradeSignal, error) {
+	if in.Action != string(types.ActionBuy) && in.Action != string(types.ActionSell) {
+		return nil, nil
+	}
+	if in.AnalysisID != "" {
+		existing, err := s.repo.FindByAnalysisID(ctx, bankID, in.AnalysisID)
+		if err == nil {
+			return existing, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(s.horizon)
+	signal := &models.TradeSignal{
+		BankID:       bankID,
+		Company:      in.Company,
+		AnalysisID:   in.AnalysisID,
+		Symbol:       in.Symbol,
+		Action:       in.Action,
+		CurrentPrice: in.CurrentPrice,
+		TargetPrice:  in.TargetPrice,
+		Confidence:   in.Confidence,
+		ESGScore:     in.ESGScore,
+		RiskAction:   in.RiskAction,
+		Reasoning:    in.Reasoning,
+		Status:       string(types.SignalPending),
+		ExpiresAt:    &expiresAt,
+	}
+	event := &models.TradeSignalEvent{
+		BankID:   bankID,
+		ToStatus: string(types.SignalPending),
+		Actor:    SignalActorSystem,
+	}
+	if in.RiskAction == string(types.RiskReject) {
+		signal.Status = string(types.SignalRejected)
+		signal.StatusReason = "rejected by risk assessment"
+		signal.DecidedBy = SignalActorRiskAgent
+		signal.DecidedAt = &now
+		event.ToStatus = signal.Status
+		event.Actor = SignalActorRiskAgent
+		event.Reason = signal.StatusReason
+	}
+
+	if err := s.repo.Create(ctx, signal, event); err != nil {
+		return nil, err
+	}
+	signalTransitionsTotal.Inc(signal.Status)
+	return signal, nil
+}
+
+func (s *SignalService) Get(ctx context.Context, bankID, id uuid.UUID) (*models.TradeSignal, []models.TradeSignalEvent, error) {
+	signal, err := s.find(ctx, bankID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	events, err := s.repo.Events(ctx, bankID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return signal, events, nil
+}
+
+func (s *SignalService) List(ctx context.Context, bankID uuid.UUID, filter repository.SignalFilter) ([]models.TradeSignal, error) {
+	return s.repo.List(ctx, bankID, filter)
+}
+
+// Approve clears a pending signal for execution. Signals the RiskAgent sent
+// for review need a RISK officer; others may also be approved by a TRADER.
+func (s *SignalService) Approve(ctx context.Context, bankID, id uuid.UUID, actor SignalActor, reason string) (*models.TradeSignal, error) {
+	return s.decide(ctx, bankID, id, types.SignalApproved, actor, reason)
+}
+
+// Reject closes a pending signal, with the same role rules as Approve.
+func (s *SignalService) Reject(ctx context.Context, bankID, id uuid.UUID, actor SignalActor, reason string) (*models.TradeSignal, error) {
+	return s.decide(ctx, bankID, id, types.SignalRejected, actor, reason)
+}
+
+// Cancel withdraws an open signal. Traders and risk officers may cancel.
+func (s *SignalService) Cancel(ctx context.Context, bankID, id uuid.UUID, actor SignalActor, reason string) (*models.TradeSignal, error) {
+	signal, err := s.find(ctx, bankID, id)
+	if err != nil {
+		return nil, err
+	}
+	role := types.RoleTrader
+	if actor.has(types.RoleRisk) {
+		role = types.RoleRisk
+	} else if !actor.has(types.RoleTrader) {
+		return nil, ErrSignalForbidden
+	}
+	return s.transition(ctx, signal, types.SignalCancelled, actor.ID, string(role), reason, nil)
+}
+
+// MarkSent records that an approved signal was sent for execution.
+func (s *SignalService) MarkSent(ctx context.Context, bankID, id uuid.UUID, actor, reason string) (*models.TradeSignal, error) {
+	signal, err := s.find(ctx, bankID, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.transition(ctx, signal, types.SignalSent, actor, "", reason, map[string]interface{}{
+		"exported_at": time.Now(),
+	})
+}
+
+// Unexported returns up to limit approved signals of every bank that have
+// not been exported, longest waiting first, for sending to execution.
+func (s *SignalService) Unexported(ctx context.Context, limit int) ([]models.TradeSignal, error) {
+	return s.repo.ListUnexported(ctx, string(types.SignalApproved), limit)
+}
+
+// MarkExported records that a signal was exported without changing its
+// status, as a FIX dry run does. It reports false when it already was.
+func (s *SignalService) MarkExported(ctx context.Context, bankID, id uuid.UUID) (bool, error) {
+	return s.repo.MarkExported(ctx, bankID, id, time.Now())
+}
+
+// MarkClosed records that an approved or sent signal will not be executed,
+// for example because the venue rejected or expired its order. to is
+// CANCELLED or EXPIRED.
+func (s *SignalService) MarkClosed(ctx context.Context, bankID, id uuid.UUID, to types.SignalStatus, actor, reason string) (*models.TradeSignal, error) {
+	if to != types.SignalCancelled && to != types.SignalExpired {
+		return nil, fmt.Errorf("%w: cannot close a signal as %s", ErrSignalTransition, to)
+	}
+	signal, err := s.find(ctx, bankID, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.transition(ctx, signal, to, actor, "", reason, nil)
+}
+
+// MarkFilled records that a sent signal was executed.
+func (s *SignalService) MarkFilled(ctx context.Context, bankID, id uuid.UUID, actor string, executedAt time.Time) (*models.TradeSignal, error) {
+	signal, err := s.find(ctx, bankID, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.transition(ctx, signal, types.SignalFilled, actor, "", "", map[string]interface{}{
+		"executed_at": executedAt,
+	})
+}
+
+// ExpireDue expires open signals past their horizon and returns how many.
+func (s *SignalService) ExpireDue(ctx context.Context) (int, error) {
+	open := []string{string(types.SignalPending), string(types.SignalApproved), string(types.SignalSent)}
+	due, err := s.repo.DueForExpiry(ctx, time.Now(), open, signalExpiryBatch)
+	if err != nil {
+		return 0, err
+	}
+	expired := 0
+	for i := range due {
+		_, err := s.transition(ctx, &due[i], types.SignalExpired, SignalActorSystem, "", "signal horizon passed", nil)
+		switch {
+		case err == nil:
+			expired++
+		case errors.Is(err, ErrSignalTransition):
+			// Moved on, or expired by another replica, since it was listed
+		default:
+			return expired, err
+		}
+	}
+	return expired, nil
+}
+
+// StartExpiry sweeps for expired signals every interval until ctx is done.
+func (s *SignalService) StartExpiry(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if n, err := s.ExpireDue(ctx); err != nil {
+					loggers.Error("Signal expiry sweep failed", err, nil)
+				} else if n > 0 {
+					loggers.Info("Expired trade signals", map[string]interface{}{"count": n})
+				}
+			}
+		}
+	}()
+}
+
+func (s *SignalService) decide(ctx context.Context, bankID, id uuid.UUID, to types.SignalStatus, actor SignalActor, reason string) (*models.TradeSignal, error) {
+	signal, err := s.find(ctx, bankID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var role types.UserRole
+	switch {
+	case actor.has(types.RoleRisk):
+		role = types.RoleRisk
+	case actor.has(types.RoleTrader) && signal.RiskAction != string(types.RiskReview):
+		role = types.RoleTrader
+	default:
+		return nil, ErrSignalForbidden
+	}
+
+	now := time.Now()
+	signal, err = s.transition(ctx, signal, to, actor.ID, string(role), reason, map[string]interface{}{
+		"decided_by":   actor.ID,
+		"decided_role": string(role),
+		"decided_at":   now,
+	})
+	if err == nil && to == types.SignalApproved && s.approved != nil {
+		approved := *signal
+		go s.approved(context.WithoutCancel(ctx), &approved)
+	}
+	return signal, err
+}
+
+// transition moves signal to status to if the state machine allows it and
+// nobody moved it first, and returns the updated signal.
+func (s *SignalService) transition(ctx context.Context, signal *models.TradeSignal, to types.SignalStatus, actor, role, reason string, updates map[string]interface{}) (*models.TradeSignal, error) {
+	from := types.SignalStatus(signal.Status)
+	allowed := false
+	for _, next := range signalTransitions[from] {
+		if next == to {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, fmt.Errorf("%w: %s to %s", ErrSignalTransition, from, to)
+	}
+
+	if updates == nil {
+		updates = make(map[string]interface{})
+	}
+	updates["status_reason"] = reason
+	moved, err := s.repo.Transition(ctx, signal.BankID, signal.ID, updates, &models.TradeSignalEvent{
+		FromStatus: string(from),
+		ToStatus:   string(to),
+		Actor:      actor,
+		ActorRole:  role,
+		Reason:     reason,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !moved {
+		return nil, fmt.Errorf("%w: status changed concurrently", ErrSignalTransition)
+	}
+	signalTransitionsTotal.Inc(string(to))
+	return s.find(ctx, signal.BankID, signal.ID)
+}
+
+func (s *SignalService) find(ctx context.Context, bankID, id uuid.UUID) (*models.TradeSignal, error) {
+	signal, err := s.repo.FindByID(ctx, bankID, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSignalNotFound
+	}
+	return signal, err
+}
@@ -33,3 +33,48 @@ const (
 	RoleRisk       UserRole = "RISK"
 	RoleAdmin      UserRole = "ADMIN"
 )
+
+// APIScope names an area of the API a bank API key may call.
+type APIScope string
+
+const (
+	ScopeAnalyze   APIScope = "analyze"
+	ScopePortfolio APIScope = "portfolio"
+)
+
+// ValidAPIScopes lists every scope that can be granted to an API key.
+var ValidAPIScopes = []APIScope{ScopeAnalyze, ScopePortfolio}
+
+// JobStatus is the lifecycle state of an asynchronous job.
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "QUEUED"
+	JobRunning   JobStatus = "RUNNING"
+	JobSucceeded JobStatus = "SUCCEEDED"
+	JobFailed    JobStatus = "FAILED"
+	JobCancelled JobStatus = "CANCELLED"
+)
+
+// JobType selects the handler that runs a job.
+type JobType string
+
+const (
+	JobAnalyze      JobType = "analyze"
+	JobBatchAnalyze JobType = "batch_analyze"
+)
+
+// SignalStatus is the lifecycle state of a stored trade signal:
+// PENDING -> APPROVED | REJECTED, APPROVED -> SENT, SENT -> FILLED, and any
+// open signal may become EXPIRED or CANCELLED.
+type SignalStatus string
+
+const (
+	SignalPending   SignalStatus = "PENDING"
+	SignalApproved  SignalStatus = "APPROVED"
+	SignalRejected  SignalStatus = "REJECTED"
+	SignalSent      SignalStatus = "SENT"
+	SignalFilled    SignalStatus = "FILLED"
+	SignalExpired   SignalStatus = "EXPIRED"
+	SignalCancelled SignalStatus = "CANCELLED"
+)